@@ -19,20 +19,21 @@ type AddrList struct {
 
 	maxItems   int
 	listenPort int
-	clientIP   *net.IP
+	externalIP *externalip.Voter
 	blocklist  *blocklist.Blocklist
 
 	countBySource map[peersource.Source]int
 }
 
 // New returns a new AddrList.
-func New(maxItems int, blocklist *blocklist.Blocklist, listenPort int, clientIP *net.IP) *AddrList {
+// Addresses with the external IP of the client and listenPort are not added to the list.
+func New(maxItems int, blocklist *blocklist.Blocklist, listenPort int, externalIP *externalip.Voter) *AddrList {
 	return &AddrList{
 		peerByPriority: btree.New(2),
 
 		maxItems:      maxItems,
 		listenPort:    listenPort,
-		clientIP:      clientIP,
+		externalIP:    externalIP,
 		blocklist:     blocklist,
 		countBySource: make(map[peersource.Source]int),
 	}
@@ -77,12 +78,7 @@ func (d *AddrList) Push(addrs []*net.TCPAddr, source peersource.Source) {
 			continue
 		}
 		// Discard own client
-		if ad.IP.IsLoopback() && ad.Port == d.listenPort {
-			continue
-		} else if d.clientIP.Equal(ad.IP) {
-			continue
-		}
-		if externalip.IsExternal(ad.IP) {
+		if (ad.IP.IsLoopback() || d.externalIP.IsExternal(ad.IP)) && ad.Port == d.listenPort {
 			continue
 		}
 		if d.blocklist != nil && d.blocklist.Blocked(ad.IP) {
@@ -140,7 +136,7 @@ func (d *AddrList) removeExcessItems(delta int) {
 }
 
 func (d *AddrList) clientAddr() *net.TCPAddr {
-	ip := *d.clientIP
+	ip := d.externalIP.IP()
 	if ip == nil {
 		ip = net.IPv4(0, 0, 0, 0)
 	}
@@ -4,13 +4,16 @@ import (
 	"net"
 	"testing"
 
+	"github.com/cenkalti/rain/internal/externalip"
 	"github.com/cenkalti/rain/internal/peersource"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestAddrList(t *testing.T) {
-	clientIP := net.IPv4(1, 2, 3, 4)
-	al := New(2, nil, 5000, &clientIP)
+	clientIP := externalip.New()
+	clientIP.Vote(net.IPv4(1, 2, 3, 4), "tracker1")
+	clientIP.Vote(net.IPv4(1, 2, 3, 4), "tracker2")
+	al := New(2, nil, 5000, clientIP)
 
 	// Push 1st addr
 	al.Push([]*net.TCPAddr{newAddr("1.1.1.1")}, peersource.Tracker)
@@ -56,3 +59,19 @@ func TestAddrList(t *testing.T) {
 func newAddr(ip string) *net.TCPAddr {
 	return &net.TCPAddr{IP: net.ParseIP(ip), Port: 1}
 }
+
+func TestAddrListOwnAddress(t *testing.T) {
+	clientIP := externalip.New()
+	clientIP.Vote(net.IPv4(1, 2, 3, 4), "tracker1")
+	clientIP.Vote(net.IPv4(1, 2, 3, 4), "tracker2")
+	al := New(10, nil, 5000, clientIP)
+	al.Push([]*net.TCPAddr{
+		{IP: net.IPv4(1, 2, 3, 4), Port: 5000},
+		{IP: net.IPv4(127, 0, 0, 1), Port: 5000},
+		// Another client behind the same NAT.
+		{IP: net.IPv4(1, 2, 3, 4), Port: 6000},
+	}, peersource.Tracker)
+	assert.Equal(t, 1, al.Len())
+	addr, _ := al.Pop()
+	assert.Equal(t, 6000, addr.Port)
+}
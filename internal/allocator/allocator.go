@@ -10,8 +10,13 @@ type Allocator struct {
 	Files       []File
 	HasExisting bool
 	HasMissing  bool
-	Error       error
+	// True if all files are created by Linker.
+	Linked bool
+	Error  error
 
+	link   Linker
+	mode   Mode
+	skip   Skipper
 	closeC chan struct{}
 	doneC  chan struct{}
 }
@@ -28,9 +33,31 @@ type Progress struct {
 	AllocatedSize int64
 }
 
+// Mode of allocating disk space for files.
+type Mode int
+
+const (
+	// Sparse files are created without reserving disk space. Space is allocated as the data is written.
+	Sparse Mode = iota
+	// Full reserves disk space for the whole size of files when they are opened.
+	Full
+)
+
+// Linker creates a missing file from an existing copy of it.
+// Returns false if the file cannot be created from a copy.
+type Linker func(name string, size int64) (linked bool, err error)
+
+// Skipper returns true if the file at index in the torrent is not going to be downloaded.
+type Skipper func(index int) bool
+
 // New returns a new Allocator.
-func New() *Allocator {
+// If link is not nil, it is called for creating missing files before creating them empty.
+// If skip is not nil, skipped files are not created on the storage. They are opened only if they already exist.
+func New(link Linker, mode Mode, skip Skipper) *Allocator {
 	return &Allocator{
+		link:   link,
+		mode:   mode,
+		skip:   skip,
 		closeC: make(chan struct{}),
 		doneC:  make(chan struct{}),
 	}
@@ -61,17 +88,49 @@ func (a *Allocator) Run(info *metainfo.Info, sto storage.Storage, progressC chan
 	}()
 
 	var allocatedSize int64
+	a.Linked = a.link != nil
 	a.Files = make([]File, len(info.Files))
 	for i, f := range info.Files {
 		var sf storage.File
 		var exists bool
 		if f.Padding {
 			sf = storage.NewPaddingFile(f.Length)
+		} else if a.skip != nil && a.skip(i) {
+			a.Linked = false
+			if o, ok := sto.(storage.ExistingOpener); ok {
+				sf, exists, a.Error = o.OpenExisting(f.Path, f.Length)
+				if a.Error != nil {
+					return
+				}
+			}
+			if sf == nil {
+				sf = storage.NewMissingFile()
+			}
+			if exists {
+				a.HasExisting = true
+			}
 		} else {
+			var linked bool
+			if a.link != nil {
+				linked, a.Error = a.link(f.Path, f.Length)
+				if a.Error != nil {
+					return
+				}
+			}
+			if !linked {
+				a.Linked = false
+			}
 			sf, exists, a.Error = sto.Open(f.Path, f.Length)
 			if a.Error != nil {
 				return
 			}
+			if p, ok := sto.(storage.Preallocator); ok && a.mode == Full {
+				a.Error = preallocate(p, f.Path, f.Length, exists)
+				if a.Error != nil {
+					sf.Close()
+					return
+				}
+			}
 			if exists {
 				a.HasExisting = true
 			} else {
@@ -84,7 +143,25 @@ func (a *Allocator) Run(info *metainfo.Info, sto storage.Storage, progressC chan
 	}
 }
 
+// preallocate reserves disk space for the file unless it already exists with all of its space allocated.
+func preallocate(p storage.Preallocator, name string, size int64, exists bool) error {
+	if exists {
+		required, err := p.RequiredSpace(name, size)
+		if err != nil {
+			return err
+		}
+		if required == 0 {
+			return nil
+		}
+	}
+	return p.Preallocate(name, size)
+}
+
 func (a *Allocator) sendProgress(progressC chan Progress, size int64) {
+	// Progress is not reported when files are opened again.
+	if progressC == nil {
+		return
+	}
 	select {
 	case progressC <- Progress{AllocatedSize: size}:
 	case <-a.closeC:
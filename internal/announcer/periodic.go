@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/cenkalti/backoff/v3"
+	"github.com/cenkalti/rain/internal/externalip"
 	"github.com/cenkalti/rain/internal/logger"
 	"github.com/cenkalti/rain/internal/resolver"
 	"github.com/cenkalti/rain/internal/tracker"
@@ -47,6 +48,7 @@ type PeriodicalAnnouncer struct {
 	log           logger.Logger
 	completedC    chan struct{}
 	newPeers      chan []*net.TCPAddr
+	externalIP    *externalip.Voter
 	backoff       backoff.BackOff
 	getTorrent    func() tracker.Torrent
 	lastAnnounce  time.Time
@@ -63,7 +65,8 @@ type PeriodicalAnnouncer struct {
 }
 
 // NewPeriodicalAnnouncer returns a new PeriodicalAnnouncer.
-func NewPeriodicalAnnouncer(trk tracker.Tracker, numWant int, minInterval time.Duration, getTorrent func() tracker.Torrent, completedC chan struct{}, newPeers chan []*net.TCPAddr, l logger.Logger) *PeriodicalAnnouncer {
+// The external IP in announce responses are counted as votes in externalIP.
+func NewPeriodicalAnnouncer(trk tracker.Tracker, numWant int, minInterval time.Duration, getTorrent func() tracker.Torrent, completedC chan struct{}, newPeers chan []*net.TCPAddr, externalIP *externalip.Voter, l logger.Logger) *PeriodicalAnnouncer {
 	return &PeriodicalAnnouncer{
 		Tracker:        trk,
 		status:         NotContactedYet,
@@ -73,6 +76,7 @@ func NewPeriodicalAnnouncer(trk tracker.Tracker, numWant int, minInterval time.D
 		log:            l,
 		completedC:     completedC,
 		newPeers:       newPeers,
+		externalIP:     externalIP,
 		getTorrent:     getTorrent,
 		needMorePeersC: make(chan struct{}, 1),
 		responseC:      make(chan *tracker.AnnounceResponse),
@@ -175,6 +179,9 @@ func (a *PeriodicalAnnouncer) Run() {
 			}
 			a.HasAnnounced = true
 			a.lastError = nil
+			if resp.ExternalIP != nil && a.externalIP != nil && a.externalIP.Vote(resp.ExternalIP, a.Tracker.URL()) {
+				a.log.Infoln("external ip:", resp.ExternalIP)
+			}
 			a.backoff.Reset()
 			interval := a.getNextInterval()
 			resetTimer(interval)
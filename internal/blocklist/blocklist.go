@@ -1,8 +1,6 @@
 package blocklist
 
 import (
-	"bufio"
-	"bytes"
 	"encoding/binary"
 	"errors"
 	"io"
@@ -15,12 +13,15 @@ import (
 var errNotIPv4Address = errors.New("address is not ipv4")
 
 // Blocklist holds a list of IP ranges in a Segment Tree structure for faster lookups.
+// Additional rules can be added at runtime with Add. These rules are kept when the list is reloaded.
 type Blocklist struct {
 	logger Logger
 
 	tree  stree.Stree
+	allow stree.Stree
 	m     sync.RWMutex
 	count int
+	rules map[string]Rule
 }
 
 // Logger prints error messages during loading. Arguments are handled in the manner of fmt.Printf.
@@ -36,7 +37,7 @@ func NewLogger(logger Logger) *Blocklist {
 	return &Blocklist{logger: logger}
 }
 
-// Len returns the number of rules in the Blocklist.
+// Len returns the number of rules in the Blocklist, excluding the ones added with Add.
 func (b *Blocklist) Len() int {
 	b.m.RLock()
 	defer b.m.RUnlock()
@@ -48,64 +49,59 @@ func (b *Blocklist) Blocked(ip net.IP) bool {
 	b.m.RLock()
 	defer b.m.RUnlock()
 
+	if b.blockedByRules(ip) {
+		return true
+	}
+
 	ip = ip.To4()
 	if ip == nil {
 		return false
 	}
 
-	val := binary.BigEndian.Uint32(ip)
-	return b.tree.Contains(stree.ValueType(val))
+	val := stree.ValueType(binary.BigEndian.Uint32(ip))
+	return b.tree.Contains(val) && !b.allow.Contains(val)
 }
 
 // Reload the segment tree by reading new rules from a io.Reader.
+// See Parse for the supported formats.
 func (b *Blocklist) Reload(r io.Reader) (int, error) {
-	b.m.Lock()
-	defer b.m.Unlock()
-
-	tree, n, err := load(r, b.logger)
+	l, err := Parse(r, b.logger)
 	if err != nil {
-		return n, err
+		return 0, err
 	}
+	return b.Load(l), nil
+}
 
+// Load replaces the rules in the segment tree with the rules in lists and returns the number of loaded rules.
+func (b *Blocklist) Load(lists ...*List) int {
+	tree, n := buildTree(lists)
+	b.m.Lock()
 	b.tree = *tree
 	b.count = n
-	return n, nil
+	b.m.Unlock()
+	return n
 }
 
-func load(r io.Reader, logger Logger) (*stree.Stree, int, error) {
+// SetAllowlist sets the rules that override the rules loaded into the segment tree.
+// IPs in the allowlist are not blocked unless they are added with Add.
+func (b *Blocklist) SetAllowlist(l *List) {
+	tree, _ := buildTree([]*List{l})
+	b.m.Lock()
+	b.allow = *tree
+	b.m.Unlock()
+}
+
+func buildTree(lists []*List) (*stree.Stree, int) {
 	var tree stree.Stree
 	var n int
-	var hasError bool
-	scanner := bufio.NewScanner(r)
-	for scanner.Scan() {
-		l := bytes.TrimSpace(scanner.Bytes())
-		if len(l) == 0 {
-			continue
-		}
-		if l[0] == '#' {
-			continue
+	for _, l := range lists {
+		for _, r := range l.ranges {
+			tree.AddRange(stree.ValueType(r.first), stree.ValueType(r.last))
+			n++
 		}
-		r, err := parseCIDR(l)
-		if err != nil {
-			hasError = true
-			if logger != nil {
-				logger("cannot parse blocklist line (%q): %q", string(l), err.Error())
-			}
-			continue
-		}
-		tree.AddRange(stree.ValueType(r.first), stree.ValueType(r.last))
-		n++
-	}
-	if err := scanner.Err(); err != nil {
-		return nil, 0, err
-	}
-	if n == 0 && hasError {
-		// Probably we couln't decode the stream correctly.
-		// At least one line must be correct before we consider the load operation as successful.
-		return nil, 0, errors.New("no valid rules")
 	}
 	tree.Build()
-	return &tree, n, nil
+	return &tree, n
 }
 
 type ipRange struct {
@@ -5,7 +5,9 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -49,3 +51,64 @@ func TestEmptyList(t *testing.T) {
 	assert.False(t, b.Blocked(net.ParseIP("0.0.0.0")))
 	assert.False(t, b.Blocked(net.ParseIP("176.240.195.107")))
 }
+
+func TestRules(t *testing.T) {
+	b := New()
+	n, err := ParseNet("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Add(Rule{Net: n})
+	ip, err := ParseNet("1.2.3.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Add(Rule{Net: ip, ExpiresAt: time.Now().Add(-time.Second)})
+	assert.True(t, b.Blocked(net.ParseIP("10.1.2.3")))
+	assert.False(t, b.Blocked(net.ParseIP("1.2.3.4")))
+	assert.Equal(t, 0, b.Len())
+	rules := b.Rules()
+	if assert.Len(t, rules, 1) {
+		assert.Equal(t, "10.0.0.0/8", rules[0].Net.String())
+	}
+
+	_, err = b.Reload(bytes.NewReader(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, b.Blocked(net.ParseIP("10.1.2.3")))
+	assert.True(t, b.Remove(n))
+	assert.False(t, b.Blocked(net.ParseIP("10.1.2.3")))
+}
+
+func TestParseFormats(t *testing.T) {
+	const data = `# comment
+// another comment
+1.1.1.0/24
+Some Org, Inc:2.2.2.0-2.2.2.255
+003.003.003.000 - 003.003.003.255 , 000 , Bad Org
+004.004.004.000 - 004.004.004.255 , 200 , Allowed Org
+5.5.5.5
+`
+	l, err := Parse(strings.NewReader(data), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 4, l.Len())
+	b := New()
+	assert.Equal(t, 4, b.Load(l))
+	assert.True(t, b.Blocked(net.ParseIP("1.1.1.1")))
+	assert.True(t, b.Blocked(net.ParseIP("2.2.2.2")))
+	assert.True(t, b.Blocked(net.ParseIP("3.3.3.3")))
+	assert.False(t, b.Blocked(net.ParseIP("4.4.4.4")))
+	assert.True(t, b.Blocked(net.ParseIP("5.5.5.5")))
+	assert.False(t, b.Blocked(net.ParseIP("5.5.5.6")))
+
+	allow, err := Parse(strings.NewReader("2.2.2.2"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.SetAllowlist(allow)
+	assert.False(t, b.Blocked(net.ParseIP("2.2.2.2")))
+	assert.True(t, b.Blocked(net.ParseIP("2.2.2.3")))
+}
@@ -0,0 +1,143 @@
+package blocklist
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+)
+
+// datMaxBlockedLevel is the highest access level in eMule DAT format that is considered as blocked.
+// Ranges with a greater level are allowed.
+const datMaxBlockedLevel = 127
+
+var errInvalidRange = errors.New("invalid ip range")
+
+// List is a parsed list of IPv4 ranges that can be loaded into a Blocklist.
+type List struct {
+	ranges []ipRange
+}
+
+// Len returns the number of rules in the List.
+func (l *List) Len() int {
+	return len(l.ranges)
+}
+
+// Parse reads the rules from r. Each line may be in one of the following formats:
+//
+//	CIDR:              1.2.3.0/24
+//	P2P/PeerGuardian:  Some organization:1.2.3.0-1.2.3.255
+//	eMule DAT:         001.002.003.000 - 001.002.003.255 , 000 , Some organization
+//	Range or single IP 1.2.3.0-1.2.3.255
+//
+// Empty lines and lines starting with "#" or "//" are ignored.
+// Lines that cannot be parsed are reported to logger and skipped.
+func Parse(r io.Reader, logger Logger) (*List, error) {
+	var l List
+	var hasError bool
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 || line[0] == '#' || bytes.HasPrefix(line, []byte("//")) {
+			continue
+		}
+		r, ok, err := parseLine(line)
+		if err != nil {
+			hasError = true
+			if logger != nil {
+				logger("cannot parse blocklist line (%q): %q", string(line), err.Error())
+			}
+			continue
+		}
+		if ok {
+			l.ranges = append(l.ranges, r)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(l.ranges) == 0 && hasError {
+		// Probably we couln't decode the stream correctly.
+		// At least one line must be correct before we consider the load operation as successful.
+		return nil, errors.New("no valid rules")
+	}
+	return &l, nil
+}
+
+// parseLine returns false if the line is valid but does not contain a blocked range.
+func parseLine(line []byte) (r ipRange, ok bool, err error) {
+	// P2P format. Name may contain any character, including ':'.
+	if i := bytes.LastIndexByte(line, ':'); i >= 0 {
+		if r, err = parseRange(line[i+1:]); err == nil {
+			return r, true, nil
+		}
+	}
+	if bytes.IndexByte(line, ',') >= 0 {
+		return parseDAT(line)
+	}
+	if bytes.IndexByte(line, '/') >= 0 {
+		r, err = parseCIDR(line)
+		return r, err == nil, err
+	}
+	r, err = parseRange(line)
+	return r, err == nil, err
+}
+
+func parseDAT(line []byte) (r ipRange, ok bool, err error) {
+	fields := bytes.SplitN(line, []byte(","), 3)
+	if len(fields) < 2 {
+		return r, false, errInvalidRange
+	}
+	level, err := strconv.Atoi(string(bytes.TrimSpace(fields[1])))
+	if err != nil {
+		return r, false, err
+	}
+	r, err = parseRange(fields[0])
+	if err != nil {
+		return r, false, err
+	}
+	return r, level <= datMaxBlockedLevel, nil
+}
+
+// parseRange parses an IPv4 range in "first-last" format or a single IPv4 address.
+func parseRange(b []byte) (r ipRange, err error) {
+	first, last, found := bytes.Cut(b, []byte("-"))
+	r.first, err = parseIPv4(bytes.TrimSpace(first))
+	if err != nil {
+		return
+	}
+	if !found {
+		r.last = r.first
+		return
+	}
+	r.last, err = parseIPv4(bytes.TrimSpace(last))
+	if err != nil {
+		return
+	}
+	if r.last < r.first {
+		err = errInvalidRange
+	}
+	return
+}
+
+// parseIPv4 parses dotted decimal IPv4 address. Unlike net.ParseIP, leading zeros are allowed as in DAT files.
+func parseIPv4(b []byte) (uint32, error) {
+	parts := bytes.Split(b, []byte("."))
+	if len(parts) != 4 {
+		return 0, errNotIPv4Address
+	}
+	var ip [4]byte
+	for i, p := range parts {
+		if len(p) == 0 || len(p) > 3 {
+			return 0, errNotIPv4Address
+		}
+		n, err := strconv.ParseUint(string(p), 10, 8)
+		if err != nil {
+			return 0, errNotIPv4Address
+		}
+		ip[i] = byte(n)
+	}
+	return binary.BigEndian.Uint32(ip[:]), nil
+}
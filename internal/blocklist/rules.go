@@ -0,0 +1,83 @@
+package blocklist
+
+import (
+	"net"
+	"sort"
+	"time"
+)
+
+// Rule is an IP range that is added to the Blocklist at runtime, separately from the rules loaded with Reload.
+type Rule struct {
+	Net *net.IPNet
+	// Rule is removed after this time. Zero if the rule does not expire.
+	ExpiresAt time.Time
+}
+
+func (r Rule) expired(now time.Time) bool {
+	return !r.ExpiresAt.IsZero() && now.After(r.ExpiresAt)
+}
+
+// ParseNet parses s as a single IP address or an IP range in CIDR notation.
+func ParseNet(s string) (*net.IPNet, error) {
+	if ip := net.ParseIP(s); ip != nil {
+		bits := 128
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+			bits = 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, n, err := net.ParseCIDR(s)
+	return n, err
+}
+
+// Add a rule to the Blocklist. Existing rule for the same range is replaced.
+func (b *Blocklist) Add(r Rule) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	if b.rules == nil {
+		b.rules = make(map[string]Rule)
+	}
+	b.rules[r.Net.String()] = r
+}
+
+// Remove the rule for the range. Returns false if there is no such rule.
+func (b *Blocklist) Remove(n *net.IPNet) bool {
+	b.m.Lock()
+	defer b.m.Unlock()
+	key := n.String()
+	_, ok := b.rules[key]
+	delete(b.rules, key)
+	return ok
+}
+
+// Rules returns the rules that are added with Add and not expired yet.
+func (b *Blocklist) Rules() []Rule {
+	b.m.Lock()
+	defer b.m.Unlock()
+	now := time.Now()
+	ret := make([]Rule, 0, len(b.rules))
+	for key, r := range b.rules {
+		if r.expired(now) {
+			delete(b.rules, key)
+			continue
+		}
+		ret = append(ret, r)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Net.String() < ret[j].Net.String() })
+	return ret
+}
+
+// blockedByRules must be called while holding the read lock.
+func (b *Blocklist) blockedByRules(ip net.IP) bool {
+	if len(b.rules) == 0 {
+		return false
+	}
+	now := time.Now()
+	for _, r := range b.rules {
+		if r.Net.Contains(ip) && !r.expired(now) {
+			return true
+		}
+	}
+	return false
+}
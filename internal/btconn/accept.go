@@ -20,6 +20,22 @@ func Accept(
 	hasInfoHash func([20]byte) bool,
 	ourExtensions [8]byte, ourID [20]byte) (
 	encConn net.Conn, cipher mse.CryptoMethod, peerExtensions [8]byte, peerID [20]byte, infoHash [20]byte, err error) {
+	getPeerID := func(ih [20]byte) ([20]byte, bool) {
+		return ourID, hasInfoHash(ih)
+	}
+	return AcceptMulti(conn, handshakeTimeout, getSKey, forceEncryption, getPeerID, ourExtensions)
+}
+
+// AcceptMulti is like Accept but the connection may be for any of the multiple torrents.
+// getPeerID returns our peer ID for the info hash sent by the peer. It returns false if there is no torrent with the info hash.
+func AcceptMulti(
+	conn net.Conn,
+	handshakeTimeout time.Duration,
+	getSKey func(sKeyHash [20]byte) (sKey []byte),
+	forceEncryption bool,
+	getPeerID func(infoHash [20]byte) (ourID [20]byte, ok bool),
+	ourExtensions [8]byte) (
+	encConn net.Conn, cipher mse.CryptoMethod, peerExtensions [8]byte, peerID [20]byte, infoHash [20]byte, err error) {
 	log := logger.New("conn <- " + conn.RemoteAddr().String())
 
 	if forceEncryption && getSKey == nil {
@@ -72,7 +88,8 @@ func Accept(
 		return
 	}
 
-	if !hasInfoHash(infoHash) {
+	ourID, ok := getPeerID(infoHash)
+	if !ok {
 		err = errInvalidInfoHash
 		return
 	}
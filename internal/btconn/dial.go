@@ -10,11 +10,18 @@ import (
 	"github.com/cenkalti/rain/internal/mse"
 )
 
+// Dialer connects to peers. It is implemented by net.Dialer.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
 // Dial new connection to the address. Does the BitTorrent protocol handshake.
 // Handles encryption. May try to connect again if encryption does not match with given setting.
 // Returns a net.Conn that is ready for sending/receiving BitTorrent peer protocol messages.
+// If dialer is nil, connection is made directly.
 func Dial(
 	addr net.Addr,
+	dialer Dialer,
 	dialTimeout, handshakeTimeout time.Duration,
 	enableEncryption,
 	forceEncryption bool,
@@ -38,8 +45,10 @@ func Dial(
 
 	// First connection
 	log.Debug("Connecting to peer...")
-	dialer := net.Dialer{Timeout: dialTimeout}
-	conn, err = dialer.DialContext(ctx, addr.Network(), addr.String())
+	if dialer == nil {
+		dialer = &net.Dialer{}
+	}
+	conn, err = dialWithTimeout(ctx, dialer, addr, dialTimeout)
 	if err != nil {
 		return
 	}
@@ -97,7 +106,7 @@ func Dial(
 			// Close current connection and try again without encryption
 			conn.Close()
 			log.Debug("Connecting again without encryption...")
-			conn, err = dialer.DialContext(ctx, addr.Network(), addr.String())
+			conn, err = dialWithTimeout(ctx, dialer, addr, dialTimeout)
 			if err != nil {
 				return
 			}
@@ -155,3 +164,9 @@ func Dial(
 	}
 	return
 }
+
+func dialWithTimeout(ctx context.Context, dialer Dialer, addr net.Addr, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return dialer.DialContext(ctx, addr.Network(), addr.String())
+}
@@ -0,0 +1,175 @@
+// Package budget implements a limit on the number of slots that is shared fairly among many users.
+package budget
+
+import "sync"
+
+// Budget is a limited number of slots that is shared by many users.
+// Users can take free slots as long as no other user is waiting for a slot.
+// If there are waiting users, a user cannot take more than its fair share,
+// which is the limit divided by the number of users.
+// Zero limit means the number of slots is not limited, but usage is still tracked.
+type Budget[K comparable] struct {
+	m          sync.Mutex
+	limit      int
+	used       int
+	usage      map[K]int
+	waiting    map[K]chan struct{}
+	contention int64
+}
+
+// Stats about Budget.
+type Stats struct {
+	// Number of slots in use.
+	Used int
+	// Number of users that are waiting for a slot.
+	Waiting int
+	// Number of times a slot is requested but not given.
+	Contention int64
+}
+
+// New returns a new Budget with `limit` number of slots.
+func New[K comparable](limit int) *Budget[K] {
+	return &Budget[K]{
+		limit:   limit,
+		usage:   make(map[K]int),
+		waiting: make(map[K]chan struct{}),
+	}
+}
+
+// Acquire takes a slot for key and returns true if a slot is available.
+// Otherwise, the key is put into the waiting list and a value is sent to notifyC without blocking when slots are released.
+// notifyC should be a buffered channel.
+func (b *Budget[K]) Acquire(key K, notifyC chan struct{}) bool {
+	b.m.Lock()
+	defer b.m.Unlock()
+	n := b.usage[key] + 1
+	if b.allowed(key, n) < n {
+		b.contention++
+		b.waiting[key] = notifyC
+		return false
+	}
+	delete(b.waiting, key)
+	b.setUsage(key, n)
+	return true
+}
+
+// Reserve changes the number of slots held by key to at most n and returns the number of slots held after the call.
+// If less than n slots are given, the key is put into the waiting list until the next call to Reserve.
+func (b *Budget[K]) Reserve(key K, n int) int {
+	b.m.Lock()
+	defer b.m.Unlock()
+	allowed := b.allowed(key, n)
+	if allowed < n {
+		b.contention++
+		b.waiting[key] = nil
+	} else {
+		delete(b.waiting, key)
+	}
+	b.setUsage(key, allowed)
+	return allowed
+}
+
+// Set the number of slots used by key. Must be used to correct the usage after slots are released.
+func (b *Budget[K]) Set(key K, n int) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	b.setUsage(key, n)
+}
+
+// Remove the key from the budget and release all of its slots.
+func (b *Budget[K]) Remove(key K) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	delete(b.waiting, key)
+	b.setUsage(key, 0)
+}
+
+// Stats returns statistics about current status.
+func (b *Budget[K]) Stats() Stats {
+	b.m.Lock()
+	defer b.m.Unlock()
+	return Stats{
+		Used:       b.used,
+		Waiting:    len(b.waiting),
+		Contention: b.contention,
+	}
+}
+
+// allowed returns the number of slots, up to n, that key can hold.
+func (b *Budget[K]) allowed(key K, n int) int {
+	if b.limit <= 0 {
+		return n
+	}
+	current := b.usage[key]
+	free := b.limit - b.used + current
+	if n > free {
+		n = free
+	}
+	if b.othersWaiting(key) {
+		share := b.fairShare(key)
+		if n > share {
+			n = share
+		}
+	}
+	if n < 0 {
+		n = 0
+	}
+	return n
+}
+
+func (b *Budget[K]) othersWaiting(key K) bool {
+	for k := range b.waiting {
+		if k != key {
+			return true
+		}
+	}
+	return false
+}
+
+// fairShare returns the number of slots each user would get if the slots were shared equally.
+func (b *Budget[K]) fairShare(key K) int {
+	users := len(b.usage)
+	for k := range b.waiting {
+		if _, ok := b.usage[k]; !ok {
+			users++
+		}
+	}
+	if _, ok := b.usage[key]; !ok {
+		if _, ok = b.waiting[key]; !ok {
+			users++
+		}
+	}
+	share := b.limit / users
+	if share == 0 {
+		share = 1
+	}
+	return share
+}
+
+func (b *Budget[K]) setUsage(key K, n int) {
+	old := b.usage[key]
+	b.used += n - old
+	if n > 0 {
+		b.usage[key] = n
+	} else {
+		delete(b.usage, key)
+	}
+	if n < old {
+		b.notifyWaiting()
+	}
+}
+
+// notifyWaiting notifies the waiting users that slots are released.
+// Users without a notification channel stay in the waiting list until they reserve again.
+func (b *Budget[K]) notifyWaiting() {
+	for k, ch := range b.waiting {
+		if ch == nil {
+			continue
+		}
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+		delete(b.waiting, k)
+	}
+}
@@ -0,0 +1,58 @@
+package budget
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBudget(t *testing.T) {
+	b := New[string](4)
+	for i := 0; i < 4; i++ {
+		require.True(t, b.Acquire("foo", nil))
+	}
+	notifyC := make(chan struct{}, 1)
+	require.False(t, b.Acquire("bar", notifyC))
+	require.Equal(t, Stats{Used: 4, Waiting: 1, Contention: 1}, b.Stats())
+
+	// Releasing a slot notifies the waiting key.
+	b.Set("foo", 3)
+	select {
+	case <-notifyC:
+	default:
+		t.Fatal("waiting key is not notified")
+	}
+	require.True(t, b.Acquire("bar", notifyC))
+	require.Equal(t, 0, b.Stats().Waiting)
+
+	require.False(t, b.Acquire("bar", notifyC))
+	require.Equal(t, 1, b.Stats().Waiting)
+
+	b.Remove("foo")
+	b.Remove("bar")
+	require.Equal(t, 0, b.Stats().Used)
+}
+
+func TestBudgetReserve(t *testing.T) {
+	b := New[string](10)
+	require.Equal(t, 10, b.Reserve("foo", 20))
+	require.Equal(t, 0, b.Reserve("bar", 3))
+	// Fair share is 5 because "bar" is waiting.
+	require.Equal(t, 5, b.Reserve("foo", 20))
+	require.Equal(t, 3, b.Reserve("bar", 3))
+	require.Equal(t, 7, b.Reserve("foo", 20))
+	require.Equal(t, int64(4), b.Stats().Contention)
+
+	// "foo" is over its fair share while "bar" is waiting.
+	require.Equal(t, 3, b.Reserve("bar", 10))
+	require.Equal(t, 5, b.Reserve("foo", 20))
+	require.False(t, b.Acquire("foo", nil))
+	require.True(t, b.Acquire("bar", nil))
+}
+
+func TestBudgetUnlimited(t *testing.T) {
+	b := New[string](0)
+	require.Equal(t, 100, b.Reserve("foo", 100))
+	require.True(t, b.Acquire("foo", nil))
+	require.Equal(t, 101, b.Stats().Used)
+}
@@ -12,16 +12,17 @@ type CachedPiece struct {
 	pi       *piece.Piece
 	cache    *piececache.Cache
 	readSize int64
-	peerID   []byte
+	key      []byte
 }
 
 // New returns a new CachedPiece object. Reads are done with blocks of `readSize`.
-func New(pi *piece.Piece, cache *piececache.Cache, readSize int64, peerID [20]byte) *CachedPiece {
+// Blocks are cached with a key prefixed by `key` that identifies the data of the torrent.
+func New(pi *piece.Piece, cache *piececache.Cache, readSize int64, key [20]byte) *CachedPiece {
 	return &CachedPiece{
 		pi:       pi,
 		cache:    cache,
 		readSize: readSize,
-		peerID:   peerID[:],
+		key:      key[:],
 	}
 }
 
@@ -35,7 +36,7 @@ func (c *CachedPiece) ReadAt(p []byte, off int64) (n int, err error) {
 	}
 
 	key := make([]byte, 20+4+4)
-	copy(key, c.peerID)
+	copy(key, c.key)
 	binary.BigEndian.PutUint32(key[20:24], c.pi.Index)
 	binary.BigEndian.PutUint32(key[24:28], blk)
 
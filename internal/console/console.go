@@ -30,6 +30,7 @@ const (
 	trackers
 	peers
 	webseeds
+	bans
 )
 
 // Console is for drawing a text user interface for a remote Session.
@@ -64,6 +65,7 @@ type Console struct {
 	trackers     []rpctypes.Tracker
 	peers        []rpctypes.Peer
 	webseeds     []rpctypes.Webseed
+	bans         []rpctypes.Ban
 
 	// whether details tab is currently updating state
 	updatingDetails bool
@@ -156,6 +158,7 @@ func (c *Console) keybindings(g *gocui.Gui) {
 	_ = g.SetKeybinding("torrents", 't', gocui.ModAlt, c.switchTrackers)
 	_ = g.SetKeybinding("torrents", 'p', gocui.ModAlt, c.switchPeers)
 	_ = g.SetKeybinding("torrents", 'w', gocui.ModAlt, c.switchWebseeds)
+	_ = g.SetKeybinding("torrents", 'b', gocui.ModAlt, c.switchBans)
 
 	// Torrent control
 	_ = g.SetKeybinding("torrents", gocui.KeyCtrlS, gocui.ModNone, c.startTorrent)
@@ -298,6 +301,7 @@ func (c *Console) drawHelp(g *gocui.Gui) error {
 	fmt.Fprintln(v, "     alt+t  switch to Trackers tab")
 	fmt.Fprintln(v, "     alt+p  switch to Peers tab")
 	fmt.Fprintln(v, "     alt+w  switch to Webseeds tab")
+	fmt.Fprintln(v, "     alt+b  switch to Bans tab")
 
 	fmt.Fprintln(v, "")
 
@@ -539,6 +543,8 @@ func (c *Console) drawDetails(g *gocui.Gui) error {
 			v.Title = "Peers"
 		case webseeds:
 			v.Title = "WebSeeds"
+		case bans:
+			v.Title = "Bans"
 		}
 		if c.selectedID == "" {
 			return nil
@@ -616,6 +622,21 @@ func (c *Console) drawDetails(g *gocui.Gui) error {
 				}
 				fmt.Fprintf(v, format, num, p.URL, dl, errstr)
 			}
+		case bans:
+			format := "%2s %39s %7s %s\n"
+			fmt.Fprintf(v, format, "#", "IP", "Scope", "Expires")
+			for i, b := range c.bans {
+				num := fmt.Sprintf("%d", i+1)
+				scope := "torrent"
+				if b.Session {
+					scope = "session"
+				}
+				expires := "never"
+				if !b.ExpiresAt.IsZero() {
+					expires = b.ExpiresAt.Time.Format(time.RFC3339)
+				}
+				fmt.Fprintf(v, format, num, b.IP, scope, expires)
+			}
 		}
 	}
 	return nil
@@ -769,6 +790,12 @@ func (c *Console) updateDetails(g *gocui.Gui) {
 		c.webseeds = webseeds
 		c.errDetails = err
 		c.m.Unlock()
+	case bans:
+		bans, err := c.client.GetTorrentBans(selectedID)
+		c.m.Lock()
+		c.bans = bans
+		c.errDetails = err
+		c.m.Unlock()
 	}
 
 	c.m.Lock()
@@ -994,7 +1021,7 @@ func (c *Console) verify(g *gocui.Gui, v *gocui.View) error {
 	id := c.selectedID
 	c.m.Unlock()
 
-	err := c.client.VerifyTorrent(id)
+	err := c.client.VerifyTorrent(id, nil)
 	if err != nil {
 		return err
 	}
@@ -1060,6 +1087,14 @@ func (c *Console) switchWebseeds(g *gocui.Gui, v *gocui.View) error {
 	return nil
 }
 
+func (c *Console) switchBans(g *gocui.Gui, v *gocui.View) error {
+	c.m.Lock()
+	c.selectedTab = bans
+	c.m.Unlock()
+	c.triggerUpdateDetails(true)
+	return nil
+}
+
 func (c *Console) switchHelp(g *gocui.Gui, v *gocui.View) error {
 	c.selectedPage = help
 	return nil
@@ -1140,6 +1175,8 @@ func flags(p rpctypes.Peer) string {
 		sb.WriteString("I")
 	case "MANUAL":
 		sb.WriteString("M")
+	case "HOLEPUNCH":
+		sb.WriteString("P")
 	default:
 		sb.WriteString(" ")
 	}
@@ -1227,9 +1264,24 @@ func FormatStats(stats *rpctypes.Stats, v io.Writer) {
 // FormatSessionStats returns the human readable representation of session stats object.
 func FormatSessionStats(s *rpctypes.SessionStats, v io.Writer) {
 	fmt.Fprintf(v, "Torrents: %d, Peers: %d, Uptime: %s\n", s.Torrents, s.Peers, time.Duration(s.Uptime)*time.Second)
+	if s.ExternalIP != "" {
+		fmt.Fprintf(v, "ExternalIP: %s\n", s.ExternalIP)
+	}
+	fmt.Fprintf(v, "Connections: %d, Waiting: %d, Contention: %d\n", s.Connections, s.ConnectionsWaiting, s.ConnectionsContention)
+	fmt.Fprintf(v, "HalfOpen: %d, Waiting: %d, Contention: %d\n", s.HalfOpen, s.HalfOpenWaiting, s.HalfOpenContention)
+	fmt.Fprintf(v, "UploadSlots: %d, Waiting: %d, Contention: %d\n", s.UploadSlots, s.UploadSlotsWaiting, s.UploadSlotsContention)
+	fmt.Fprintf(v, "DHTNodes: %d, Buckets: %d, Queries: %d sent / %d received\n", s.DHTNodes, s.DHTBuckets, s.DHTQueriesSent, s.DHTQueriesReceived)
 	fmt.Fprintf(v, "BlocklistRules: %d, Updated: %s ago\n", s.BlockListRules, time.Duration(s.BlockListRecency)*time.Second)
+	for _, src := range s.BlocklistSources {
+		if src.Error != "" {
+			fmt.Fprintf(v, "  %s: %d rules, Error: %s\n", src.URL, src.Rules, src.Error)
+		} else {
+			fmt.Fprintf(v, "  %s: %d rules\n", src.URL, src.Rules)
+		}
+	}
 	fmt.Fprintf(v, "Reads: %d/s, %dKB/s, Active: %d, Pending: %d\n", s.ReadsPerSecond, s.SpeedRead/1024, s.ReadsActive, s.ReadsPending)
 	fmt.Fprintf(v, "Writes: %d/s, %dKB/s, Active: %d, Pending: %d\n", s.WritesPerSecond, s.SpeedWrite/1024, s.WritesActive, s.WritesPending)
+	fmt.Fprintf(v, "WriteLatency: %dms, SyncLatency: %dms\n", s.WriteLatency, s.SyncLatency)
 	fmt.Fprintf(v, "ReadCache Objects: %d, Size: %dMB, Utilization: %d%%\n", s.ReadCacheObjects, s.ReadCacheSize/(1<<20), s.ReadCacheUtilization)
 	fmt.Fprintf(v, "WriteCache Objects: %d, Size: %dMB, PendingKeys: %d\n", s.WriteCacheObjects, s.WriteCacheSize/(1<<20), s.WriteCachePendingKeys)
 	fmt.Fprintf(v, "DownloadSpeed: %dKB/s, UploadSpeed: %dKB/s\n", s.SpeedDownload/1024, s.SpeedUpload/1024)
@@ -0,0 +1,662 @@
+// Package dht implements a node of the BitTorrent Mainline DHT (BEP 5).
+// It also supports secure node IDs (BEP 42), storing arbitrary data (BEP 44) and sampling info hashes (BEP 51).
+package dht
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/cenkalti/rain/internal/logger"
+	"github.com/zeebo/bencode"
+)
+
+// InfoHash is the 20-byte info hash of a torrent.
+type InfoHash string
+
+const (
+	// Number of parallel queries in a lookup.
+	alpha = 3
+	// Maximum number of queries in a single lookup.
+	maxLookupQueries = 100
+	// Announced peers are removed after this duration.
+	peerExpiry = 30 * time.Minute
+	// Maximum number of peers returned in a get_peers response.
+	maxValues = 50
+	// Maximum number of stored peers for an info hash.
+	maxPeersPerInfoHash = 1000
+	// Maximum number of info hashes that peers can be announced for.
+	maxInfoHashes = 10000
+	// Secret used for generating tokens is changed with this interval.
+	tokenSecretInterval = 5 * time.Minute
+	// Interval for expiring stored data and refreshing buckets.
+	maintenanceInterval = time.Minute
+	// Number of nodes that must report the same external IP before a new node ID is generated for it.
+	externalIPVotes = 3
+)
+
+var (
+	errClosed          = errors.New("dht closed")
+	errTimeout         = errors.New("query timed out")
+	errInvalidResponse = errors.New("invalid response")
+)
+
+// Config for DHT node.
+type Config struct {
+	// Host to listen on. Listens on all interfaces if empty.
+	Host string
+	// UDP port to listen on. A random port is chosen if zero.
+	Port int
+	// Called on the UDP socket before it is bound if not nil, e.g. for binding the socket to an interface.
+	Control func(network, address string, c syscall.RawConn) error
+	// Known nodes in "host:port" format for joining the DHT network when the routing table is empty.
+	BootstrapNodes []string
+	// ID of the node from previous run. A random ID is generated if zero.
+	// A new ID is generated if it is not valid for the external IP reported by other nodes (BEP 42).
+	ID [20]byte
+	// Compact node infos of nodes saved from previous run. They are added to the routing table on start.
+	Nodes []byte
+	// Timeout of a single query.
+	QueryTimeout time.Duration
+	// Do not add nodes to the routing table if their IDs are not valid for their IPs (BEP 42).
+	EnforceSecureIDs bool
+}
+
+// Stats about the DHT node.
+type Stats struct {
+	// Number of nodes in routing table.
+	Nodes int
+	// Number of buckets that have at least one node.
+	Buckets int
+	// Number of queries sent to other nodes.
+	QueriesSent int64
+	// Number of queries received from other nodes.
+	QueriesReceived int64
+	// Number of queries that did not get a response in time.
+	Timeouts int64
+	// Number of peers that are announced to this node.
+	Peers int
+	// Number of BEP 44 items stored in this node.
+	Items int
+}
+
+// DHT is a node in the DHT network.
+type DHT struct {
+	// Results of get_peers lookups started with PeersRequestPort.
+	// Values are compact peer addresses.
+	PeersRequestResults chan map[InfoHash][]string
+
+	config Config
+	conn   *net.UDPConn
+	log    logger.Logger
+
+	m               sync.Mutex
+	id              [20]byte
+	table           *table
+	transactions    map[string]*transaction
+	nextTransaction uint16
+	peers           map[InfoHash]map[string]time.Time
+	items           map[[20]byte]*item
+	secret          [2][20]byte
+	externalIP      net.IP
+	ipVotes         map[string]map[string]struct{}
+
+	queriesSent     atomic.Int64
+	queriesReceived atomic.Int64
+	timeouts        atomic.Int64
+
+	closeC chan struct{}
+	wg     sync.WaitGroup
+}
+
+type transaction struct {
+	addr      *net.UDPAddr
+	responseC chan *msg
+}
+
+// New starts a DHT node listening on UDP port.
+func New(cfg Config) (*DHT, error) {
+	if cfg.QueryTimeout == 0 {
+		cfg.QueryTimeout = 5 * time.Second
+	}
+	laddr, err := net.ResolveUDPAddr("udp4", net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)))
+	if err != nil {
+		return nil, err
+	}
+	lc := net.ListenConfig{Control: cfg.Control}
+	pc, err := lc.ListenPacket(context.Background(), "udp4", laddr.String())
+	if err != nil {
+		return nil, err
+	}
+	conn := pc.(*net.UDPConn)
+	id := cfg.ID
+	if id == [20]byte{} {
+		id = randomID()
+	}
+	d := &DHT{
+		PeersRequestResults: make(chan map[InfoHash][]string),
+		config:              cfg,
+		conn:                conn,
+		log:                 logger.New("dht"),
+		id:                  id,
+		table:               newTable(id),
+		transactions:        make(map[string]*transaction),
+		peers:               make(map[InfoHash]map[string]time.Time),
+		items:               make(map[[20]byte]*item),
+		ipVotes:             make(map[string]map[string]struct{}),
+		closeC:              make(chan struct{}),
+	}
+	d.secret[0] = randomID()
+	d.secret[1] = d.secret[0]
+	nodes, err := decodeNodes(string(cfg.Nodes))
+	if err != nil {
+		d.log.Warningln("cannot load saved nodes:", err)
+	}
+	for _, n := range nodes {
+		// Saved nodes are questionable until they respond.
+		d.table.seen(n.id, n.addr, time.Time{})
+	}
+	d.wg.Add(2)
+	go d.readLoop()
+	go d.maintenanceLoop()
+	return d, nil
+}
+
+// Close the DHT node and wait for running lookups to finish.
+func (d *DHT) Close() {
+	close(d.closeC)
+	d.conn.Close()
+	d.wg.Wait()
+}
+
+// Addr returns the local address of the node.
+func (d *DHT) Addr() *net.UDPAddr {
+	return d.conn.LocalAddr().(*net.UDPAddr)
+}
+
+// ID returns the node ID.
+func (d *DHT) ID() [20]byte {
+	d.m.Lock()
+	defer d.m.Unlock()
+	return d.id
+}
+
+// Nodes returns compact node infos of the nodes in routing table, for loading them in the next run.
+func (d *DHT) Nodes() []byte {
+	d.m.Lock()
+	defer d.m.Unlock()
+	return []byte(encodeNodes(d.table.all()))
+}
+
+// Stats returns statistics about the node.
+func (d *DHT) Stats() Stats {
+	d.m.Lock()
+	defer d.m.Unlock()
+	var peers int
+	for _, m := range d.peers {
+		peers += len(m)
+	}
+	return Stats{
+		Nodes:           d.table.Len(),
+		Buckets:         d.table.NumBuckets(),
+		QueriesSent:     d.queriesSent.Load(),
+		QueriesReceived: d.queriesReceived.Load(),
+		Timeouts:        d.timeouts.Load(),
+		Peers:           peers,
+		Items:           len(d.items),
+	}
+}
+
+// AddNode pings the node at addr in "host:port" format and adds it to the routing table if it responds.
+func (d *DHT) AddNode(addr string) {
+	d.goWithClose(func() {
+		uaddr, err := net.ResolveUDPAddr("udp4", addr)
+		if err != nil {
+			return
+		}
+		_, _ = d.query(uaddr, methodPing, &queryArgs{})
+	})
+}
+
+// PeersRequestPort starts a lookup for peers of the info hash in the background.
+// Results are sent to PeersRequestResults channel.
+// If announce is true, the port is announced to the nodes that are closest to the info hash.
+func (d *DHT) PeersRequestPort(ih string, announce bool, port int) {
+	if len(ih) != 20 {
+		return
+	}
+	d.goWithClose(func() {
+		peers := d.getPeers(InfoHash(ih), announce, port)
+		if len(peers) == 0 {
+			return
+		}
+		select {
+		case d.PeersRequestResults <- map[InfoHash][]string{InfoHash(ih): peers}:
+		case <-d.closeC:
+		}
+	})
+}
+
+// goWithClose runs f in a goroutine unless the node is closed. Close waits for f to return.
+func (d *DHT) goWithClose(f func()) {
+	select {
+	case <-d.closeC:
+		return
+	default:
+	}
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		f()
+	}()
+}
+
+func (d *DHT) getPeers(ih InfoHash, announce bool, port int) []string {
+	var target [20]byte
+	copy(target[:], ih)
+	found := make(map[string]struct{})
+	var peers []string
+	results := d.lookup(target, methodGetPeers, func() *queryArgs {
+		return &queryArgs{InfoHash: string(ih)}
+	}, func(r *response) bool {
+		for _, v := range r.Values {
+			if _, ok := found[v]; !ok && len(v) == 6 {
+				found[v] = struct{}{}
+				peers = append(peers, v)
+			}
+		}
+		return false
+	})
+	if announce {
+		d.queryAll(results, methodAnnouncePeer, func(token string) *queryArgs {
+			return &queryArgs{InfoHash: string(ih), Port: port, Token: token}
+		})
+	}
+	return peers
+}
+
+// queryAll sends the query to all nodes that responded with a token in a lookup and waits for the responses.
+// It returns the number of successful responses.
+func (d *DHT) queryAll(results []lookupResult, method string, args func(token string) *queryArgs) int {
+	var wg sync.WaitGroup
+	var success atomic.Int32
+	for _, res := range results {
+		if res.token == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(res lookupResult) {
+			defer wg.Done()
+			if _, err := d.query(res.node.addr, method, args(res.token)); err == nil {
+				success.Add(1)
+			}
+		}(res)
+	}
+	wg.Wait()
+	return int(success.Load())
+}
+
+// query sends a query to addr and waits for the response.
+func (d *DHT) query(addr *net.UDPAddr, method string, args *queryArgs) (*response, error) {
+	t := &transaction{addr: addr, responseC: make(chan *msg, 1)}
+	d.m.Lock()
+	args.ID = string(d.id[:])
+	var key string
+	for {
+		d.nextTransaction++
+		key = string(binary.BigEndian.AppendUint16(nil, d.nextTransaction))
+		if _, ok := d.transactions[key]; !ok {
+			break
+		}
+	}
+	d.transactions[key] = t
+	d.m.Unlock()
+	defer func() {
+		d.m.Lock()
+		delete(d.transactions, key)
+		d.m.Unlock()
+	}()
+
+	d.queriesSent.Add(1)
+	err := d.send(addr, &msg{T: key, Y: "q", Q: method, A: args})
+	if err != nil {
+		return nil, err
+	}
+	timer := time.NewTimer(d.config.QueryTimeout)
+	defer timer.Stop()
+	select {
+	case m := <-t.responseC:
+		if m.Y == "e" {
+			return nil, parseError(m.E)
+		}
+		return m.R, nil
+	case <-timer.C:
+		d.timeouts.Add(1)
+		d.m.Lock()
+		d.table.failed(addr)
+		d.m.Unlock()
+		return nil, errTimeout
+	case <-d.closeC:
+		return nil, errClosed
+	}
+}
+
+func (d *DHT) send(addr *net.UDPAddr, m *msg) error {
+	b, err := bencode.EncodeBytes(m)
+	if err != nil {
+		return err
+	}
+	_, err = d.conn.WriteToUDP(b, addr)
+	return err
+}
+
+func (d *DHT) readLoop() {
+	defer d.wg.Done()
+	buf := make([]byte, 64*1024)
+	for {
+		n, addr, err := d.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-d.closeC:
+				return
+			default:
+			}
+			d.log.Debugln("read error:", err)
+			continue
+		}
+		if addr.Port == 0 {
+			continue
+		}
+		var m msg
+		if err = bencode.DecodeBytes(append([]byte(nil), buf[:n]...), &m); err != nil {
+			continue
+		}
+		switch m.Y {
+		case "q":
+			d.handleQuery(addr, &m)
+		case "r", "e":
+			d.handleResponse(addr, &m)
+		}
+	}
+}
+
+func (d *DHT) handleResponse(addr *net.UDPAddr, m *msg) {
+	d.m.Lock()
+	defer d.m.Unlock()
+	t, ok := d.transactions[m.T]
+	if !ok || !t.addr.IP.Equal(addr.IP) || t.addr.Port != addr.Port {
+		return
+	}
+	delete(d.transactions, m.T)
+	if m.Y == "r" {
+		if m.R == nil || len(m.R.ID) != 20 {
+			m = &msg{Y: "e", E: []any{int64(errorProtocol), errInvalidResponse.Error()}}
+		} else {
+			d.addNode(m.R.ID, addr)
+			if ip := decodeAddr(m.IP); ip != nil {
+				d.voteExternalIP(ip.IP, addr.IP)
+			}
+		}
+	}
+	t.responseC <- m
+}
+
+// addNode adds the node to routing table after a message is received from it. d.m must be held.
+func (d *DHT) addNode(rawID string, addr *net.UDPAddr) {
+	var id [20]byte
+	copy(id[:], rawID)
+	if d.config.EnforceSecureIDs && !isSecureID(id, addr.IP) {
+		return
+	}
+	d.table.seen(id, addr, time.Now())
+}
+
+// voteExternalIP counts the external IP reported by another node in a response.
+// If enough nodes agree on an IP that our node ID is not valid for, a new ID is generated for the IP (BEP 42).
+// d.m must be held.
+func (d *DHT) voteExternalIP(ip, voter net.IP) {
+	if ip.To4() == nil || isLocal(ip) || ip.Equal(d.externalIP) {
+		return
+	}
+	if len(d.ipVotes) > 100 {
+		clear(d.ipVotes)
+	}
+	votes, ok := d.ipVotes[ip.String()]
+	if !ok {
+		votes = make(map[string]struct{})
+		d.ipVotes[ip.String()] = votes
+	}
+	votes[voter.String()] = struct{}{}
+	if len(votes) < externalIPVotes {
+		return
+	}
+	clear(d.ipVotes)
+	d.externalIP = ip
+	d.log.Infoln("external ip:", ip)
+	if !isSecureID(d.id, ip) {
+		d.setID(secureID(ip))
+	}
+}
+
+// setID changes the node ID and rebuilds the routing table for the new ID. d.m must be held.
+func (d *DHT) setID(id [20]byte) {
+	d.log.Infof("changing node id to %x", id)
+	nodes := d.table.all()
+	d.id = id
+	d.table = newTable(id)
+	for _, n := range nodes {
+		d.table.seen(n.id, n.addr, n.lastSeen)
+	}
+}
+
+func (d *DHT) handleQuery(addr *net.UDPAddr, m *msg) {
+	d.queriesReceived.Add(1)
+	if m.A == nil || len(m.A.ID) != 20 {
+		d.sendError(addr, m.T, errorProtocol, "invalid id")
+		return
+	}
+	d.m.Lock()
+	r, e := d.answer(addr, m.Q, m.A)
+	if e == nil {
+		d.addNode(m.A.ID, addr)
+	}
+	d.m.Unlock()
+	if e != nil {
+		d.sendError(addr, m.T, e.Code, e.Message)
+		return
+	}
+	_ = d.send(addr, &msg{T: m.T, Y: "r", R: r, IP: encodeAddr(addr)})
+}
+
+// answer returns the response to the query. d.m must be held.
+func (d *DHT) answer(addr *net.UDPAddr, method string, a *queryArgs) (*response, *Error) {
+	r := &response{ID: string(d.id[:])}
+	switch method {
+	case methodPing:
+	case methodFindNode:
+		if len(a.Target) != 20 {
+			return nil, &Error{errorProtocol, "invalid target"}
+		}
+		r.Nodes = d.closestNodes(a.Target)
+	case methodGetPeers:
+		if len(a.InfoHash) != 20 {
+			return nil, &Error{errorProtocol, "invalid info_hash"}
+		}
+		r.Token = d.token(addr.IP, 0)
+		r.Values = d.values(InfoHash(a.InfoHash))
+		if len(r.Values) == 0 {
+			r.Nodes = d.closestNodes(a.InfoHash)
+		}
+	case methodAnnouncePeer:
+		if len(a.InfoHash) != 20 {
+			return nil, &Error{errorProtocol, "invalid info_hash"}
+		}
+		if !d.validToken(a.Token, addr.IP) {
+			return nil, &Error{errorProtocol, "invalid token"}
+		}
+		port := a.Port
+		if a.ImpliedPort != 0 {
+			port = addr.Port
+		}
+		if port <= 0 || port > 65535 {
+			return nil, &Error{errorProtocol, "invalid port"}
+		}
+		d.storePeer(InfoHash(a.InfoHash), encodeAddr(&net.UDPAddr{IP: addr.IP, Port: port}))
+	case methodGet:
+		if len(a.Target) != 20 {
+			return nil, &Error{errorProtocol, "invalid target"}
+		}
+		r.Token = d.token(addr.IP, 0)
+		r.Nodes = d.closestNodes(a.Target)
+		d.getItem(a, r)
+	case methodPut:
+		if !d.validToken(a.Token, addr.IP) {
+			return nil, &Error{errorProtocol, "invalid token"}
+		}
+		if e := d.putItem(a); e != nil {
+			return nil, e
+		}
+	case methodSampleInfohashes:
+		if len(a.Target) != 20 {
+			return nil, &Error{errorProtocol, "invalid target"}
+		}
+		r.Nodes = d.closestNodes(a.Target)
+		d.sampleInfoHashes(r)
+	default:
+		return nil, &Error{errorMethodUnknown, "method unknown"}
+	}
+	return r, nil
+}
+
+func (d *DHT) sendError(addr *net.UDPAddr, t string, code int, message string) {
+	_ = d.send(addr, &msg{T: t, Y: "e", E: []any{code, message}})
+}
+
+func (d *DHT) closestNodes(target string) string {
+	var id [20]byte
+	copy(id[:], target)
+	return encodeNodes(d.table.closest(id, bucketSize))
+}
+
+// token returns the token that the node at ip must send back for announcing or storing data.
+// Tokens generated with the previous secret are still valid.
+func (d *DHT) token(ip net.IP, secret int) string {
+	h := sha1.New()
+	_, _ = h.Write(d.secret[secret][:])
+	_, _ = h.Write(ip.To4())
+	return string(h.Sum(nil)[:8])
+}
+
+func (d *DHT) validToken(token string, ip net.IP) bool {
+	return token != "" && (token == d.token(ip, 0) || token == d.token(ip, 1))
+}
+
+func (d *DHT) storePeer(ih InfoHash, peer string) {
+	m, ok := d.peers[ih]
+	if !ok {
+		if len(d.peers) >= maxInfoHashes {
+			return
+		}
+		m = make(map[string]time.Time)
+		d.peers[ih] = m
+	}
+	if _, ok = m[peer]; !ok && len(m) >= maxPeersPerInfoHash {
+		return
+	}
+	m[peer] = time.Now()
+}
+
+func (d *DHT) values(ih InfoHash) []string {
+	m := d.peers[ih]
+	values := make([]string, 0, min(len(m), maxValues))
+	for peer := range m {
+		if len(values) == maxValues {
+			break
+		}
+		values = append(values, peer)
+	}
+	return values
+}
+
+func (d *DHT) maintenanceLoop() {
+	defer d.wg.Done()
+	d.bootstrap()
+	ticker := time.NewTicker(maintenanceInterval)
+	defer ticker.Stop()
+	lastSecret := time.Now()
+	for {
+		select {
+		case now := <-ticker.C:
+			d.m.Lock()
+			if now.Sub(lastSecret) >= tokenSecretInterval {
+				d.secret[1] = d.secret[0]
+				d.secret[0] = randomID()
+				lastSecret = now
+			}
+			d.expire(now)
+			empty := d.table.Len() == 0
+			stale := d.table.stale(now.Add(-questionableAfter))
+			d.m.Unlock()
+			if empty {
+				d.bootstrap()
+				continue
+			}
+			for _, id := range stale {
+				d.findNode(id)
+			}
+		case <-d.closeC:
+			return
+		}
+	}
+}
+
+func (d *DHT) expire(now time.Time) {
+	for ih, m := range d.peers {
+		for peer, t := range m {
+			if now.Sub(t) > peerExpiry {
+				delete(m, peer)
+			}
+		}
+		if len(m) == 0 {
+			delete(d.peers, ih)
+		}
+	}
+	for target, it := range d.items {
+		if now.Sub(it.storedAt) > itemExpiry {
+			delete(d.items, target)
+		}
+	}
+}
+
+// bootstrap fills the routing table by looking up our own ID.
+func (d *DHT) bootstrap() {
+	d.findNode(d.ID())
+}
+
+func (d *DHT) findNode(target [20]byte) {
+	d.lookup(target, methodFindNode, func() *queryArgs {
+		return &queryArgs{Target: string(target[:])}
+	}, nil)
+}
+
+// bootstrapNodes resolves the addresses of bootstrap nodes.
+func (d *DHT) bootstrapNodes(target [20]byte) []*node {
+	var nodes []*node
+	for _, s := range d.config.BootstrapNodes {
+		addr, err := net.ResolveUDPAddr("udp4", s)
+		if err != nil {
+			d.log.Debugln("cannot resolve bootstrap node:", err)
+			continue
+		}
+		// IDs of bootstrap nodes are not known. Target ID is used so they are queried first.
+		nodes = append(nodes, &node{id: target, addr: addr})
+	}
+	return nodes
+}
@@ -0,0 +1,189 @@
+package dht
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testNumNodes = 20
+
+// newTestNetwork starts DHT nodes on loopback that are bootstrapped from the first node.
+func newTestNetwork(t *testing.T, n int) []*DHT {
+	nodes := make([]*DHT, 0, n)
+	t.Cleanup(func() {
+		for _, d := range nodes {
+			d.Close()
+		}
+	})
+	var bootstrap []string
+	for i := 0; i < n; i++ {
+		d, err := New(Config{Host: "127.0.0.1", BootstrapNodes: bootstrap, QueryTimeout: time.Second})
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes = append(nodes, d)
+		if i == 0 {
+			bootstrap = []string{"127.0.0.1:" + strconv.Itoa(d.Addr().Port)}
+		}
+	}
+	// Refresh routing tables after all nodes have joined.
+	for _, d := range nodes {
+		d.bootstrap()
+	}
+	return nodes
+}
+
+func TestSecureID(t *testing.T) {
+	// Test vectors from BEP 42.
+	cases := []struct {
+		ip     string
+		r      byte
+		prefix string
+	}{
+		{"124.31.75.21", 1, "5fbfbf"},
+		{"21.75.31.124", 86, "5a3ce9"},
+		{"65.23.51.170", 22, "a5d432"},
+		{"84.124.73.14", 65, "1b0321"},
+		{"43.213.53.83", 90, "e56f6c"},
+	}
+	for _, c := range cases {
+		ip := net.ParseIP(c.ip)
+		crc := secureIDPrefix(ip, c.r)
+		prefix, _ := hex.DecodeString(c.prefix)
+		assert.Equal(t, prefix[:2], []byte{byte(crc >> 24), byte(crc >> 16)}, c.ip)
+		assert.Equal(t, prefix[2]&0xf8, byte(crc>>8)&0xf8, c.ip)
+
+		id := secureID(ip)
+		assert.True(t, isSecureID(id, ip), c.ip)
+		id[0]++
+		assert.False(t, isSecureID(id, ip), c.ip)
+	}
+	assert.True(t, isSecureID(randomID(), net.ParseIP("192.168.1.1")))
+}
+
+func TestRoutingTable(t *testing.T) {
+	nodes := newTestNetwork(t, testNumNodes)
+	var sent, received int64
+	for _, d := range nodes {
+		stats := d.Stats()
+		assert.GreaterOrEqual(t, stats.Nodes, bucketSize/2)
+		assert.Greater(t, stats.Buckets, 0)
+		sent += stats.QueriesSent
+		received += stats.QueriesReceived
+	}
+	assert.Greater(t, received, int64(0))
+	assert.GreaterOrEqual(t, sent, received)
+}
+
+func TestAnnounceAndGetPeers(t *testing.T) {
+	nodes := newTestNetwork(t, testNumNodes)
+	id := randomID()
+	ih := string(id[:])
+	ids := nodes[3].ID()
+	assert.Len(t, nodes[3].getPeers(InfoHash(ih), true, 6881), 0)
+	assert.Equal(t, ids, nodes[3].ID())
+
+	nodes[testNumNodes-1].PeersRequestPort(ih, false, 0)
+	select {
+	case res := <-nodes[testNumNodes-1].PeersRequestResults:
+		assert.Equal(t, []string{encodeAddr(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 6881})}, res[InfoHash(ih)])
+	case <-time.After(10 * time.Second):
+		t.Fatal("peers are not found")
+	}
+
+	samples, num, interval, err := nodes[0].SampleInfoHashes(nodes[testNumNodes-1].Addr().String())
+	assert.NoError(t, err)
+	assert.Equal(t, sampleInterval, interval)
+	assert.Equal(t, num, len(samples))
+}
+
+func TestImmutableItem(t *testing.T) {
+	nodes := newTestNetwork(t, testNumNodes)
+	v := []byte("12:Hello World!")
+	target, err := nodes[1].PutImmutable(v)
+	assert.NoError(t, err)
+	assert.Equal(t, "e5f96f6f38320f0f33959cb4d3d656452117aadb", hex.EncodeToString(target[:]))
+
+	v2, err := nodes[testNumNodes-1].GetImmutable(target)
+	assert.NoError(t, err)
+	assert.Equal(t, v, v2)
+
+	_, err = nodes[testNumNodes-1].GetImmutable(randomID())
+	assert.Equal(t, errItemNotFound, err)
+}
+
+func TestMutableItem(t *testing.T) {
+	nodes := newTestNetwork(t, testNumNodes)
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	salt := []byte("foobar")
+	assert.NoError(t, nodes[1].PutMutable(priv, salt, []byte("5:first"), 1))
+	assert.NoError(t, nodes[1].PutMutable(priv, salt, []byte("6:second"), 2))
+
+	v, seq, err := nodes[testNumNodes-1].GetMutable(pub, salt)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("6:second"), v)
+	assert.Equal(t, int64(2), seq)
+
+	_, _, err = nodes[testNumNodes-1].GetMutable(pub, nil)
+	assert.Equal(t, errItemNotFound, err)
+}
+
+func TestSignBuffer(t *testing.T) {
+	// Example from BEP 44.
+	assert.Equal(t, "4:salt6:foobar3:seqi1e1:v12:Hello World!", string(signBuffer([]byte("foobar"), 1, []byte("12:Hello World!"))))
+	assert.Equal(t, "3:seqi1e1:v12:Hello World!", string(signBuffer(nil, 1, []byte("12:Hello World!"))))
+}
+
+func TestSavedNodes(t *testing.T) {
+	nodes := newTestNetwork(t, 5)
+	saved := nodes[1].Nodes()
+	assert.Len(t, saved, nodes[1].Stats().Nodes*compactNodeSize)
+
+	d, err := New(Config{Host: "127.0.0.1", ID: nodes[1].ID(), Nodes: saved, QueryTimeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	assert.Equal(t, nodes[1].ID(), d.ID())
+	assert.Equal(t, nodes[1].Stats().Nodes, d.Stats().Nodes)
+}
+
+func TestRandomIDInBucket(t *testing.T) {
+	id := randomID()
+	for i := 0; i < 160; i++ {
+		assert.Equal(t, i, commonPrefixLen(id, randomIDInBucket(id, i)))
+	}
+}
+
+func TestPutItemValidation(t *testing.T) {
+	d := &DHT{items: make(map[[20]byte]*item)}
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	put := func(v string, seq int64, cas *int64) *Error {
+		sig := ed25519.Sign(priv, signBuffer(nil, seq, []byte(v)))
+		return d.putItem(&queryArgs{V: []byte(v), K: string(pub), Seq: &seq, Sig: string(sig), CAS: cas})
+	}
+	assert.Nil(t, put("1:a", 2, nil))
+	assert.Equal(t, errorSequenceTooLess, put("1:b", 1, nil).Code)
+	cas := int64(1)
+	assert.Equal(t, errorCASMismatch, put("1:b", 3, &cas).Code)
+	cas = 2
+	assert.Nil(t, put("1:b", 3, &cas))
+
+	seq := int64(4)
+	e := d.putItem(&queryArgs{V: []byte("1:c"), K: string(pub), Seq: &seq, Sig: string(make([]byte, 64))})
+	assert.Equal(t, errorInvalidSig, e.Code)
+	e = d.putItem(&queryArgs{V: make([]byte, maxItemSize+1)})
+	assert.Equal(t, errorValueTooBig, e.Code)
+}
@@ -0,0 +1,200 @@
+package dht
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha1"
+	"errors"
+	"strconv"
+	"time"
+)
+
+const (
+	// Maximum size of a bencoded value stored in DHT.
+	maxItemSize = 1000
+	// Maximum size of the salt of mutable items.
+	maxSaltSize = 64
+	// Stored items are removed after this duration unless they are put again.
+	itemExpiry = 2 * time.Hour
+)
+
+var (
+	errItemNotFound = errors.New("item not found")
+	errPutFailed    = errors.New("no node has stored the item")
+	errItemTooBig   = errors.New("item is too big")
+)
+
+// item is a value stored in the DHT (BEP 44).
+type item struct {
+	v        []byte
+	k        string // public key of mutable item
+	salt     string
+	seq      int64
+	sig      string
+	storedAt time.Time
+}
+
+// ImmutableTarget returns the target ID that the immutable item with bencoded value v is stored at.
+func ImmutableTarget(v []byte) [20]byte {
+	return sha1.Sum(v)
+}
+
+// MutableTarget returns the target ID that the mutable item with the public key and salt is stored at.
+func MutableTarget(key ed25519.PublicKey, salt []byte) [20]byte {
+	return sha1.Sum(append(append([]byte{}, key...), salt...))
+}
+
+// signBuffer returns the data that is signed for a mutable item.
+func signBuffer(salt []byte, seq int64, v []byte) []byte {
+	var b []byte
+	if len(salt) > 0 {
+		b = append(b, "4:salt"...)
+		b = strconv.AppendInt(b, int64(len(salt)), 10)
+		b = append(b, ':')
+		b = append(b, salt...)
+	}
+	b = append(b, "3:seqi"...)
+	b = strconv.AppendInt(b, seq, 10)
+	b = append(b, "e1:v"...)
+	return append(b, v...)
+}
+
+// getItem adds the stored item at target to the get response. d.m must be held.
+func (d *DHT) getItem(a *queryArgs, r *response) {
+	var target [20]byte
+	copy(target[:], a.Target)
+	it, ok := d.items[target]
+	if !ok {
+		return
+	}
+	if it.k == "" {
+		r.V = it.v
+		return
+	}
+	seq := it.seq
+	r.Seq = &seq
+	if a.Seq != nil && it.seq <= *a.Seq {
+		// Requester already has the latest value.
+		return
+	}
+	r.V = it.v
+	r.K = it.k
+	r.Sig = it.sig
+}
+
+// putItem validates and stores the item in put query. d.m must be held.
+func (d *DHT) putItem(a *queryArgs) *Error {
+	if len(a.V) == 0 {
+		return &Error{errorProtocol, "missing v"}
+	}
+	if len(a.V) > maxItemSize {
+		return &Error{errorValueTooBig, "message (v field) too big"}
+	}
+	now := time.Now()
+	if a.K == "" {
+		d.items[ImmutableTarget(a.V)] = &item{v: a.V, storedAt: now}
+		return nil
+	}
+	if len(a.K) != ed25519.PublicKeySize || len(a.Sig) != ed25519.SignatureSize || a.Seq == nil {
+		return &Error{errorProtocol, "invalid mutable item"}
+	}
+	if len(a.Salt) > maxSaltSize {
+		return &Error{errorSaltTooBig, "salt (salt field) too big"}
+	}
+	if !ed25519.Verify(ed25519.PublicKey(a.K), signBuffer([]byte(a.Salt), *a.Seq, a.V), []byte(a.Sig)) {
+		return &Error{errorInvalidSig, "invalid signature"}
+	}
+	target := MutableTarget(ed25519.PublicKey(a.K), []byte(a.Salt))
+	if old, ok := d.items[target]; ok {
+		if a.CAS != nil && *a.CAS != old.seq {
+			return &Error{errorCASMismatch, "CAS mismatch, re-read value and try again"}
+		}
+		if *a.Seq < old.seq {
+			return &Error{errorSequenceTooLess, "sequence number less than current"}
+		}
+	}
+	d.items[target] = &item{v: a.V, k: a.K, salt: a.Salt, seq: *a.Seq, sig: a.Sig, storedAt: now}
+	return nil
+}
+
+// GetImmutable looks up the bencoded value stored at target.
+func (d *DHT) GetImmutable(target [20]byte) ([]byte, error) {
+	var v []byte
+	d.lookup(target, methodGet, func() *queryArgs {
+		return &queryArgs{Target: string(target[:])}
+	}, func(r *response) bool {
+		if len(r.V) > 0 && ImmutableTarget(r.V) == target {
+			v = r.V
+			return true
+		}
+		return false
+	})
+	if v == nil {
+		return nil, errItemNotFound
+	}
+	return v, nil
+}
+
+// PutImmutable stores the bencoded value v in the nodes closest to its target and returns the target.
+func (d *DHT) PutImmutable(v []byte) ([20]byte, error) {
+	target := ImmutableTarget(v)
+	if len(v) > maxItemSize {
+		return target, errItemTooBig
+	}
+	results := d.lookup(target, methodGet, func() *queryArgs {
+		return &queryArgs{Target: string(target[:])}
+	}, nil)
+	n := d.queryAll(results, methodPut, func(token string) *queryArgs {
+		return &queryArgs{Token: token, V: v}
+	})
+	if n == 0 {
+		return target, errPutFailed
+	}
+	return target, nil
+}
+
+// GetMutable looks up the mutable item with the public key and salt.
+// It returns the bencoded value with the highest sequence number found.
+func (d *DHT) GetMutable(key ed25519.PublicKey, salt []byte) (v []byte, seq int64, err error) {
+	target := MutableTarget(key, salt)
+	found := false
+	d.lookup(target, methodGet, func() *queryArgs {
+		return &queryArgs{Target: string(target[:])}
+	}, func(r *response) bool {
+		if len(r.V) == 0 || r.Seq == nil || !bytes.Equal([]byte(r.K), key) {
+			return false
+		}
+		if found && *r.Seq <= seq {
+			return false
+		}
+		if !ed25519.Verify(key, signBuffer(salt, *r.Seq, r.V), []byte(r.Sig)) {
+			return false
+		}
+		v, seq, found = r.V, *r.Seq, true
+		return false
+	})
+	if !found {
+		return nil, 0, errItemNotFound
+	}
+	return v, seq, nil
+}
+
+// PutMutable signs the bencoded value v with the private key and stores it in the nodes closest to its target.
+func (d *DHT) PutMutable(key ed25519.PrivateKey, salt []byte, v []byte, seq int64) error {
+	if len(v) > maxItemSize {
+		return errItemTooBig
+	}
+	pub := key.Public().(ed25519.PublicKey)
+	target := MutableTarget(pub, salt)
+	sig := ed25519.Sign(key, signBuffer(salt, seq, v))
+	results := d.lookup(target, methodGet, func() *queryArgs {
+		return &queryArgs{Target: string(target[:])}
+	}, nil)
+	n := d.queryAll(results, methodPut, func(token string) *queryArgs {
+		return &queryArgs{Token: token, V: v, K: string(pub), Salt: string(salt), Seq: &seq, Sig: string(sig)}
+	})
+	if n == 0 {
+		return errPutFailed
+	}
+	return nil
+}
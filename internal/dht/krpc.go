@@ -0,0 +1,152 @@
+package dht
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/zeebo/bencode"
+)
+
+// Query methods of the DHT protocol.
+const (
+	methodPing             = "ping"
+	methodFindNode         = "find_node"
+	methodGetPeers         = "get_peers"
+	methodAnnouncePeer     = "announce_peer"
+	methodGet              = "get"
+	methodPut              = "put"
+	methodSampleInfohashes = "sample_infohashes"
+)
+
+// Error codes of KRPC protocol and BEP 44.
+const (
+	errorGeneric         = 201
+	errorProtocol        = 203
+	errorMethodUnknown   = 204
+	errorValueTooBig     = 205
+	errorInvalidSig      = 206
+	errorSaltTooBig      = 207
+	errorCASMismatch     = 301
+	errorSequenceTooLess = 302
+)
+
+// msg is a KRPC message. Only one of A, R and E is set depending on the type of message in Y.
+type msg struct {
+	T string     `bencode:"t"`
+	Y string     `bencode:"y"`
+	Q string     `bencode:"q,omitempty"`
+	A *queryArgs `bencode:"a,omitempty"`
+	R *response  `bencode:"r,omitempty"`
+	E []any      `bencode:"e,omitempty"`
+	// Compact address of the receiver of a response (BEP 42).
+	IP string `bencode:"ip,omitempty"`
+}
+
+type queryArgs struct {
+	ID          string `bencode:"id"`
+	Target      string `bencode:"target,omitempty"`
+	InfoHash    string `bencode:"info_hash,omitempty"`
+	Port        int    `bencode:"port,omitempty"`
+	ImpliedPort int    `bencode:"implied_port,omitempty"`
+	Token       string `bencode:"token,omitempty"`
+
+	// Fields of BEP 44 get and put queries.
+	V    bencode.RawMessage `bencode:"v,omitempty"`
+	K    string             `bencode:"k,omitempty"`
+	Salt string             `bencode:"salt,omitempty"`
+	Seq  *int64             `bencode:"seq,omitempty"`
+	Sig  string             `bencode:"sig,omitempty"`
+	CAS  *int64             `bencode:"cas,omitempty"`
+}
+
+type response struct {
+	ID     string   `bencode:"id"`
+	Nodes  string   `bencode:"nodes,omitempty"`
+	Values []string `bencode:"values,omitempty"`
+	Token  string   `bencode:"token,omitempty"`
+
+	// Fields of BEP 44 get response.
+	V   bencode.RawMessage `bencode:"v,omitempty"`
+	K   string             `bencode:"k,omitempty"`
+	Seq *int64             `bencode:"seq,omitempty"`
+	Sig string             `bencode:"sig,omitempty"`
+
+	// Fields of BEP 51 sample_infohashes response.
+	Samples  string `bencode:"samples,omitempty"`
+	Num      int    `bencode:"num,omitempty"`
+	Interval int    `bencode:"interval,omitempty"`
+}
+
+// Error is returned from queries when the remote node responds with an error message.
+type Error struct {
+	Code    int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("dht error %d: %s", e.Code, e.Message)
+}
+
+func parseError(e []any) *Error {
+	ret := &Error{Code: errorGeneric}
+	if len(e) > 0 {
+		if code, ok := e[0].(int64); ok {
+			ret.Code = int(code)
+		}
+	}
+	if len(e) > 1 {
+		if s, ok := e[1].(string); ok {
+			ret.Message = s
+		}
+	}
+	return ret
+}
+
+var errInvalidCompactNodes = errors.New("invalid compact node info")
+
+// compactNodeSize is the size of the compact node info of an IPv4 node: 20-byte node ID followed by compact address.
+const compactNodeSize = 26
+
+func encodeAddr(addr *net.UDPAddr) string {
+	b := make([]byte, 6)
+	copy(b, addr.IP.To4())
+	binary.BigEndian.PutUint16(b[4:], uint16(addr.Port))
+	return string(b)
+}
+
+func decodeAddr(s string) *net.UDPAddr {
+	if len(s) != 6 {
+		return nil
+	}
+	return &net.UDPAddr{
+		IP:   net.IP([]byte(s[:4])),
+		Port: int(binary.BigEndian.Uint16([]byte(s[4:]))),
+	}
+}
+
+func encodeNodes(nodes []*node) string {
+	b := make([]byte, 0, len(nodes)*compactNodeSize)
+	for _, n := range nodes {
+		b = append(b, n.id[:]...)
+		b = append(b, encodeAddr(n.addr)...)
+	}
+	return string(b)
+}
+
+func decodeNodes(s string) ([]*node, error) {
+	if len(s)%compactNodeSize != 0 {
+		return nil, errInvalidCompactNodes
+	}
+	nodes := make([]*node, 0, len(s)/compactNodeSize)
+	for ; len(s) > 0; s = s[compactNodeSize:] {
+		n := &node{addr: decodeAddr(s[20:compactNodeSize])}
+		copy(n.id[:], s[:20])
+		if n.addr.Port == 0 || n.addr.IP.IsUnspecified() {
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
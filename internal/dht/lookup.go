@@ -0,0 +1,113 @@
+package dht
+
+type lookupResult struct {
+	node  *node
+	token string
+}
+
+// lookup queries the nodes that are closest to target iteratively until the closest nodes found are all queried.
+// args returns the arguments of each query.
+// onResponse is called with every response, if it returns true the lookup is stopped.
+// Nodes that have responded are returned in the order of their distance to target.
+func (d *DHT) lookup(target [20]byte, method string, args func() *queryArgs, onResponse func(r *response) bool) []lookupResult {
+	d.m.Lock()
+	closest := d.table.closest(target, bucketSize)
+	self := d.id
+	d.m.Unlock()
+	candidates := make([]*node, 0, len(closest))
+	for _, n := range closest {
+		candidates = append(candidates, &node{id: n.id, addr: n.addr})
+	}
+	if len(candidates) == 0 {
+		candidates = d.bootstrapNodes(target)
+	}
+	seen := make(map[string]struct{})
+	for _, n := range candidates {
+		seen[n.addr.String()] = struct{}{}
+	}
+	queried := make(map[*node]struct{})
+	tokens := make(map[*node]string)
+	var responded []*node
+	type result struct {
+		node *node
+		resp *response
+		err  error
+	}
+	resultC := make(chan result, alpha)
+	for numQueries := 0; numQueries < maxLookupQueries; {
+		sortByDistance(candidates, target)
+		var batch []*node
+		for i, n := range candidates {
+			if i == bucketSize || len(batch) == alpha {
+				break
+			}
+			if _, ok := queried[n]; !ok {
+				batch = append(batch, n)
+			}
+		}
+		if len(batch) == 0 {
+			break
+		}
+		for _, n := range batch {
+			queried[n] = struct{}{}
+			numQueries++
+			go func(n *node) {
+				r, err := d.query(n.addr, method, args())
+				resultC <- result{node: n, resp: r, err: err}
+			}(n)
+		}
+		stop := false
+		for range batch {
+			res := <-resultC
+			if res.err != nil {
+				candidates = removeNode(candidates, res.node)
+				continue
+			}
+			// Bootstrap nodes are added with a fake ID, correct it from the response.
+			copy(res.node.id[:], res.resp.ID)
+			responded = append(responded, res.node)
+			tokens[res.node] = res.resp.Token
+			if onResponse != nil && onResponse(res.resp) {
+				stop = true
+			}
+			nodes, err := decodeNodes(res.resp.Nodes)
+			if err != nil {
+				continue
+			}
+			for _, n := range nodes {
+				key := n.addr.String()
+				if _, ok := seen[key]; ok || n.id == self {
+					continue
+				}
+				seen[key] = struct{}{}
+				candidates = append(candidates, n)
+			}
+		}
+		if stop {
+			break
+		}
+		select {
+		case <-d.closeC:
+			return nil
+		default:
+		}
+	}
+	sortByDistance(responded, target)
+	if len(responded) > bucketSize {
+		responded = responded[:bucketSize]
+	}
+	results := make([]lookupResult, len(responded))
+	for i, n := range responded {
+		results[i] = lookupResult{node: n, token: tokens[n]}
+	}
+	return results
+}
+
+func removeNode(nodes []*node, n *node) []*node {
+	for i := range nodes {
+		if nodes[i] == n {
+			return append(nodes[:i], nodes[i+1:]...)
+		}
+	}
+	return nodes
+}
@@ -0,0 +1,49 @@
+package dht
+
+import (
+	"net"
+	"time"
+)
+
+const (
+	// Maximum number of info hashes in a sample_infohashes response.
+	maxSamples = 20
+	// Interval that requesters should wait before sampling the same node again.
+	sampleInterval = 5 * time.Minute
+)
+
+// sampleInfoHashes adds a sample of info hashes that peers are announced for to the response. d.m must be held.
+func (d *DHT) sampleInfoHashes(r *response) {
+	samples := make([]byte, 0, min(len(d.peers), maxSamples)*20)
+	for ih := range d.peers {
+		if len(samples) == cap(samples) {
+			break
+		}
+		samples = append(samples, ih...)
+	}
+	r.Samples = string(samples)
+	r.Num = len(d.peers)
+	r.Interval = int(sampleInterval / time.Second)
+}
+
+// SampleInfoHashes queries the node at addr in "host:port" format for a sample of the info hashes that it stores (BEP 51).
+// It returns the sample, the total number of info hashes in the node and the interval that should be waited before querying the node again.
+func (d *DHT) SampleInfoHashes(addr string) (samples []InfoHash, num int, interval time.Duration, err error) {
+	uaddr, err := net.ResolveUDPAddr("udp4", addr)
+	if err != nil {
+		return
+	}
+	target := randomID()
+	r, err := d.query(uaddr, methodSampleInfohashes, &queryArgs{Target: string(target[:])})
+	if err != nil {
+		return
+	}
+	if len(r.Samples)%20 != 0 {
+		err = errInvalidResponse
+		return
+	}
+	for s := r.Samples; len(s) > 0; s = s[20:] {
+		samples = append(samples, InfoHash(s[:20]))
+	}
+	return samples, r.Num, time.Duration(r.Interval) * time.Second, nil
+}
@@ -0,0 +1,54 @@
+package dht
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"hash/crc32"
+	"net"
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+func randomID() (id [20]byte) {
+	_, _ = rand.Read(id[:])
+	return
+}
+
+// secureIDPrefix returns the CRC32-C hash that the first 21 bits of a node ID must match for the IP (BEP 42).
+func secureIDPrefix(ip net.IP, r byte) uint32 {
+	ip4 := ip.To4()
+	v := binary.BigEndian.Uint32(ip4) & 0x030f3fff
+	v |= uint32(r&7) << 29
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	return crc32.Checksum(b[:], castagnoli)
+}
+
+// secureID returns a random node ID that is valid for ip according to BEP 42.
+// It returns a fully random ID if ip is not an IPv4 address.
+func secureID(ip net.IP) [20]byte {
+	id := randomID()
+	if ip.To4() == nil {
+		return id
+	}
+	r := id[19]
+	crc := secureIDPrefix(ip, r)
+	id[0] = byte(crc >> 24)
+	id[1] = byte(crc >> 16)
+	id[2] = byte(crc>>8)&0xf8 | id[2]&0x07
+	return id
+}
+
+// isSecureID returns true if the node ID is valid for ip according to BEP 42.
+// IDs of nodes with local addresses are always valid.
+func isSecureID(id [20]byte, ip net.IP) bool {
+	if ip.To4() == nil || isLocal(ip) {
+		return true
+	}
+	crc := secureIDPrefix(ip, id[19])
+	return id[0] == byte(crc>>24) && id[1] == byte(crc>>16) && id[2]&0xf8 == byte(crc>>8)&0xf8
+}
+
+func isLocal(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified()
+}
@@ -0,0 +1,227 @@
+package dht
+
+import (
+	"bytes"
+	"math/bits"
+	"net"
+	"sort"
+	"time"
+)
+
+// bucketSize is the maximum number of nodes in a bucket (K in Kademlia).
+const bucketSize = 8
+
+// maxFailures is the number of consecutive failed queries after a node is removed from the routing table.
+const maxFailures = 3
+
+// questionableAfter is the duration after a node is considered questionable if it has not responded.
+const questionableAfter = 15 * time.Minute
+
+type node struct {
+	id       [20]byte
+	addr     *net.UDPAddr
+	lastSeen time.Time
+	failures int
+}
+
+func (n *node) good(now time.Time) bool {
+	return n.failures == 0 && now.Sub(n.lastSeen) < questionableAfter
+}
+
+// table is the routing table. Nodes are put into buckets by the length of the common prefix of their IDs with ours.
+type table struct {
+	id      [20]byte
+	buckets [160][]*node
+	// Nodes by address for finding the node that a response is received from.
+	byAddr map[string]*node
+	// Last time a node is added or refreshed in each bucket.
+	changed [160]time.Time
+}
+
+func newTable(id [20]byte) *table {
+	return &table{
+		id:     id,
+		byAddr: make(map[string]*node),
+	}
+}
+
+func commonPrefixLen(a, b [20]byte) int {
+	for i := range a {
+		if x := a[i] ^ b[i]; x != 0 {
+			return i*8 + bits.LeadingZeros8(x)
+		}
+	}
+	return 160
+}
+
+func (t *table) bucketIndex(id [20]byte) int {
+	return min(commonPrefixLen(t.id, id), 159)
+}
+
+// Len returns the number of nodes in the table.
+func (t *table) Len() int {
+	return len(t.byAddr)
+}
+
+// NumBuckets returns the number of buckets that have at least one node.
+func (t *table) NumBuckets() int {
+	var n int
+	for _, b := range t.buckets {
+		if len(b) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func (t *table) get(addr *net.UDPAddr) *node {
+	return t.byAddr[addr.String()]
+}
+
+// seen adds the node or marks it as good after a message is received from it.
+// If the bucket of node is full, a bad node in the bucket is replaced with it. Otherwise, the new node is dropped.
+func (t *table) seen(id [20]byte, addr *net.UDPAddr, now time.Time) {
+	if id == t.id {
+		return
+	}
+	if n := t.get(addr); n != nil {
+		if n.id == id {
+			n.lastSeen = now
+			n.failures = 0
+			t.changed[t.bucketIndex(id)] = now
+			return
+		}
+		// Node at the address has changed its ID.
+		t.remove(n)
+	}
+	i := t.bucketIndex(id)
+	b := t.buckets[i]
+	for _, n := range b {
+		if n.id == id {
+			// Same node at a different address. Keep the old one until it fails.
+			return
+		}
+	}
+	n := &node{id: id, addr: addr, lastSeen: now}
+	if len(b) >= bucketSize {
+		j := t.worst(b, now)
+		if j < 0 {
+			return
+		}
+		delete(t.byAddr, b[j].addr.String())
+		b[j] = n
+	} else {
+		t.buckets[i] = append(b, n)
+	}
+	t.byAddr[addr.String()] = n
+	t.changed[i] = now
+}
+
+// worst returns the index of the node that is not good and not seen for the longest time. Returns -1 if all nodes are good.
+func (t *table) worst(b []*node, now time.Time) int {
+	j := -1
+	for k, n := range b {
+		if n.good(now) {
+			continue
+		}
+		if j < 0 || n.failures > b[j].failures || (n.failures == b[j].failures && n.lastSeen.Before(b[j].lastSeen)) {
+			j = k
+		}
+	}
+	return j
+}
+
+// failed is called when a query to the node at addr times out.
+func (t *table) failed(addr *net.UDPAddr) {
+	n := t.get(addr)
+	if n == nil {
+		return
+	}
+	n.failures++
+	if n.failures >= maxFailures {
+		t.remove(n)
+	}
+}
+
+func (t *table) remove(n *node) {
+	i := t.bucketIndex(n.id)
+	b := t.buckets[i]
+	for k := range b {
+		if b[k] == n {
+			t.buckets[i] = append(b[:k], b[k+1:]...)
+			break
+		}
+	}
+	delete(t.byAddr, n.addr.String())
+}
+
+// closest returns at most count nodes that are closest to target.
+func (t *table) closest(target [20]byte, count int) []*node {
+	nodes := make([]*node, 0, len(t.byAddr))
+	for _, n := range t.byAddr {
+		nodes = append(nodes, n)
+	}
+	sortByDistance(nodes, target)
+	if len(nodes) > count {
+		nodes = nodes[:count]
+	}
+	return nodes
+}
+
+// all returns all nodes in the table.
+func (t *table) all() []*node {
+	nodes := make([]*node, 0, len(t.byAddr))
+	for _, b := range t.buckets {
+		nodes = append(nodes, b...)
+	}
+	return nodes
+}
+
+// stale returns a random ID in each bucket that is not changed since given time.
+// Lookups for these IDs refresh the buckets.
+func (t *table) stale(since time.Time) [][20]byte {
+	var ids [][20]byte
+	last := t.lastBucket()
+	for i := 0; i <= last; i++ {
+		if t.changed[i].Before(since) {
+			ids = append(ids, randomIDInBucket(t.id, i))
+		}
+	}
+	return ids
+}
+
+// lastBucket returns the index of the deepest bucket that has nodes.
+func (t *table) lastBucket() int {
+	for i := len(t.buckets) - 1; i >= 0; i-- {
+		if len(t.buckets[i]) > 0 {
+			return i
+		}
+	}
+	return 0
+}
+
+func randomIDInBucket(id [20]byte, i int) [20]byte {
+	r := randomID()
+	// Copy first i bits of id, flip the next bit, keep the rest random.
+	for k := 0; k < i; k++ {
+		mask := byte(0x80) >> (k % 8)
+		r[k/8] = r[k/8]&^mask | id[k/8]&mask
+	}
+	mask := byte(0x80) >> (i % 8)
+	r[i/8] = r[i/8]&^mask | ^id[i/8]&mask
+	return r
+}
+
+func distance(a, b [20]byte) (d [20]byte) {
+	for i := range a {
+		d[i] = a[i] ^ b[i]
+	}
+	return
+}
+
+func sortByDistance(nodes []*node, target [20]byte) {
+	sort.Slice(nodes, func(i, j int) bool {
+		di, dj := distance(nodes[i].id, target), distance(nodes[j].id, target)
+		return bytes.Compare(di[:], dj[:]) < 0
+	})
+}
@@ -1,65 +1,126 @@
+// Package externalip provides a way to find out the external IP address of the client.
+// The address is decided by votes from trackers and peers that report the address they see.
 package externalip
 
 import (
 	"net"
+	"sync"
+)
+
+const (
+	// An IP is accepted as the external IP after the total weight of the sources reporting it reaches this value.
+	minScore = 4
+	// Trackers are trusted more than peers because any peer can send an arbitrary IP.
+	trackerWeight = 2
+	peerWeight    = 1
+	// Votes are cleared after this many sources vote, so a changed external IP can be accepted.
+	maxVotes = 1000
+)
 
-	"github.com/cenkalti/log"
+// Peers in the same subnet are counted as a single source, so a few hosts cannot decide the external IP.
+var (
+	peerSubnetIPv4 = net.CIDRMask(16, 32)
+	peerSubnetIPv6 = net.CIDRMask(32, 128)
 )
 
-var ips []net.IP
+// Voter decides the external IP of the client by counting the IPs reported by different sources.
+// It is safe for concurrent use.
+type Voter struct {
+	m  sync.RWMutex
+	ip net.IP
+	// IP -> sources that reported the IP -> weight of the source.
+	votes    map[string]map[string]int
+	numVotes int
+}
+
+// New returns a new Voter.
+func New() *Voter {
+	return &Voter{
+		votes: make(map[string]map[string]int),
+	}
+}
 
-func init() {
-	addrs, err := net.InterfaceAddrs()
-	if err != nil {
-		log.Warningln("cannot get interface addresses:", err)
-		return
+// Vote counts the IP reported by a tracker. Each tracker is counted once for an IP.
+// Source identifies the tracker, such as its URL.
+// It returns true if the external IP is changed with this vote.
+func (v *Voter) Vote(ip net.IP, source string) bool {
+	return v.vote(ip, "tracker:"+source, trackerWeight)
+}
+
+// VotePeer counts the IP reported by the peer at peerIP.
+// Peers in the same /16 IPv4 or /32 IPv6 subnet are counted once for an IP.
+// It returns true if the external IP is changed with this vote.
+func (v *Voter) VotePeer(ip, peerIP net.IP) bool {
+	if i4 := peerIP.To4(); i4 != nil {
+		peerIP = i4.Mask(peerSubnetIPv4)
+	} else {
+		peerIP = peerIP.Mask(peerSubnetIPv6)
 	}
-	for _, addr := range addrs {
-		in, ok := addr.(*net.IPNet)
-		if !ok {
-			continue
-		}
-		i4 := in.IP.To4()
-		if i4 == nil {
-			continue
-		}
-		if !isPublicIP(i4) {
-			continue
-		}
-		ips = append(ips, i4)
+	if peerIP == nil {
+		return false
 	}
+	return v.vote(ip, "peer:"+peerIP.String(), peerWeight)
 }
 
-func isPublicIP(ip4 net.IP) bool {
-	if ip4.IsLoopback() || ip4.IsLinkLocalMulticast() || ip4.IsLinkLocalUnicast() {
+func (v *Voter) vote(ip net.IP, source string, weight int) bool {
+	if i4 := ip.To4(); i4 != nil {
+		ip = i4
+	}
+	if len(ip) != net.IPv4len && len(ip) != net.IPv6len {
 		return false
 	}
-	switch {
-	case ip4[0] == 10:
+	if ip.IsUnspecified() || ip.IsLoopback() || ip.IsMulticast() {
 		return false
-	case ip4[0] == 172 && ip4[1] >= 16 && ip4[1] <= 31:
+	}
+	v.m.Lock()
+	defer v.m.Unlock()
+	if v.numVotes >= maxVotes {
+		clear(v.votes)
+		v.numVotes = 0
+	}
+	key := ip.String()
+	sources, ok := v.votes[key]
+	if !ok {
+		sources = make(map[string]int)
+		v.votes[key] = sources
+	}
+	if _, ok = sources[source]; ok {
 		return false
-	case ip4[0] == 192 && ip4[1] == 168:
+	}
+	sources[source] = weight
+	v.numVotes++
+	score := v.score(key)
+	if score < minScore || ip.Equal(v.ip) {
 		return false
-	default:
-		return true
 	}
+	if v.ip != nil && score <= v.score(v.ip.String()) {
+		return false
+	}
+	v.ip = ip
+	return true
 }
 
-// IsExternal returns true if the given IP matches one of the IP address of the external network interfaces on the server.
-func IsExternal(ip net.IP) bool {
-	for i := range ips {
-		if ip.Equal(ips[i]) {
-			return true
-		}
+// score returns the total weight of the sources that reported ip.
+func (v *Voter) score(ip string) int {
+	var n int
+	for _, w := range v.votes[ip] {
+		n += w
 	}
-	return false
+	return n
 }
 
-// FirstExternalIP returns the first external IP of the network interfaces on the server.
-func FirstExternalIP() net.IP {
-	if len(ips) == 0 {
+// IP returns the external IP of the client. It returns nil if the IP is not known yet or v is nil.
+func (v *Voter) IP() net.IP {
+	if v == nil {
 		return nil
 	}
-	return ips[0]
+	v.m.RLock()
+	defer v.m.RUnlock()
+	return v.ip
+}
+
+// IsExternal returns true if ip is the external IP of the client.
+func (v *Voter) IsExternal(ip net.IP) bool {
+	ext := v.IP()
+	return ext != nil && ext.Equal(ip)
 }
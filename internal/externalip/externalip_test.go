@@ -0,0 +1,58 @@
+package externalip
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVoter(t *testing.T) {
+	v := New()
+	ip1 := net.IPv4(1, 1, 1, 1)
+	ip2 := net.IPv4(2, 2, 2, 2)
+	assert.Nil(t, v.IP())
+
+	assert.False(t, v.Vote(ip1, "a"))
+	assert.False(t, v.Vote(ip1, "a"))
+	assert.False(t, v.Vote(net.IPv4(127, 0, 0, 1), "b"))
+	assert.Nil(t, v.IP())
+	assert.True(t, v.Vote(ip1, "b"))
+	assert.True(t, v.IsExternal(ip1))
+
+	// Another IP must get more votes to replace the current one.
+	assert.False(t, v.Vote(ip2, "c"))
+	assert.False(t, v.Vote(ip2, "d"))
+	assert.True(t, v.IsExternal(ip1))
+	assert.True(t, v.Vote(ip2, "e"))
+	assert.True(t, v.IsExternal(ip2))
+
+	var nilVoter *Voter
+	assert.False(t, nilVoter.IsExternal(ip1))
+}
+
+func TestVoterPeers(t *testing.T) {
+	v := New()
+	ip := net.IPv4(1, 1, 1, 1)
+
+	// Peers in the same subnet are counted once.
+	assert.False(t, v.VotePeer(ip, net.IPv4(5, 5, 1, 1)))
+	assert.False(t, v.VotePeer(ip, net.IPv4(5, 5, 2, 2)))
+	assert.False(t, v.VotePeer(ip, net.IPv4(5, 5, 3, 3)))
+	assert.False(t, v.VotePeer(ip, net.IPv4(5, 5, 4, 4)))
+	assert.Nil(t, v.IP())
+
+	// Peers from different subnets are required to decide the IP.
+	assert.False(t, v.VotePeer(ip, net.IPv4(6, 6, 1, 1)))
+	assert.False(t, v.VotePeer(ip, net.IPv4(7, 7, 1, 1)))
+	assert.Nil(t, v.IP())
+	assert.True(t, v.VotePeer(ip, net.IPv4(8, 8, 1, 1)))
+	assert.True(t, v.IsExternal(ip))
+
+	// A tracker counts more than a peer.
+	v = New()
+	assert.False(t, v.Vote(ip, "tracker"))
+	assert.False(t, v.VotePeer(ip, net.IPv4(5, 5, 1, 1)))
+	assert.True(t, v.VotePeer(ip, net.IPv4(6, 6, 1, 1)))
+	assert.True(t, v.IsExternal(ip))
+}
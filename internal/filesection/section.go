@@ -75,3 +75,20 @@ func (p Piece) Write(b []byte) (n int, err error) {
 	}
 	return
 }
+
+// Sync commits the written data of files in p to disk.
+// Files that do not implement Sync method are skipped.
+func (p Piece) Sync() error {
+	for _, sec := range p {
+		if sec.Padding {
+			continue
+		}
+		if f, ok := sec.File.(interface{ Sync() error }); ok {
+			err := f.Sync()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
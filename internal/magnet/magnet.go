@@ -2,6 +2,7 @@
 package magnet
 
 import (
+	"bytes"
 	"encoding/base32"
 	"encoding/hex"
 	"errors"
@@ -15,10 +16,23 @@ import (
 
 // Magnet link contains the information to download torrent metadata from network.
 type Magnet struct {
+	// Info hash that is used in peer protocol, trackers and DHT.
+	// It is the truncated v2 info hash if the magnet link has only the v2 info hash.
 	InfoHash [20]byte
-	Name     string
-	Trackers [][]string
-	Peers    []string
+	// SHA-256 info hash of v2 and hybrid torrents (BEP 52). Nil if the magnet link has only a v1 info hash.
+	InfoHashV2 []byte
+	Name       string
+	Trackers   [][]string
+	Peers      []string
+	// Indexes of the files that are downloaded (BEP 53). Nil means all files.
+	SelectOnly []int
+	// Webseed sources (BEP 19).
+	Webseeds []string
+	// URLs of the .torrent file. Metadata is downloaded from these before trying peers.
+	ExactSources      []string
+	AcceptableSources []string
+	// Exact length of the torrent in bytes. Zero if unknown.
+	Length int64
 }
 
 // New parses the string and returns new Magnet.
@@ -41,12 +55,29 @@ func New(s string) (*Magnet, error) {
 	if len(xts) == 0 {
 		return nil, errors.New("empty xt param")
 	}
-	xt := xts[0]
 
 	var magnet Magnet
-	magnet.InfoHash, err = infoHashString(xt)
-	if err != nil {
-		return nil, err
+	var hasV1 bool
+	var firstErr error
+	for _, xt := range xts {
+		ih, v2, err := infoHashString(xt)
+		switch {
+		case err != nil:
+			if firstErr == nil {
+				firstErr = err
+			}
+		case v2 != nil:
+			magnet.InfoHashV2 = v2
+		default:
+			magnet.InfoHash = ih
+			hasV1 = true
+		}
+	}
+	if !hasV1 && magnet.InfoHashV2 == nil {
+		return nil, firstErr
+	}
+	if !hasV1 {
+		copy(magnet.InfoHash[:], magnet.InfoHashV2)
 	}
 
 	names := params["dn"]
@@ -76,15 +107,92 @@ func New(s string) (*Magnet, error) {
 	}
 
 	magnet.Peers = params["x.pe"]
+	magnet.Webseeds = params["ws"]
+	magnet.ExactSources = params["xs"]
+	magnet.AcceptableSources = params["as"]
+
+	if so := params.Get("so"); so != "" {
+		magnet.SelectOnly, err = parseSelectOnly(so)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if xl := params.Get("xl"); xl != "" {
+		magnet.Length, err = strconv.ParseInt(xl, 10, 64)
+		if err != nil || magnet.Length < 0 {
+			return nil, errors.New("invalid xl param")
+		}
+	}
 
 	return &magnet, nil
 }
 
+// parseSelectOnly parses the file indexes in "so" param.
+// Indexes are separated by commas, ranges are given as "first-last" inclusive.
+func parseSelectOnly(s string) ([]int, error) {
+	var ret []int
+	for _, part := range strings.Split(s, ",") {
+		first, last, isRange := strings.Cut(part, "-")
+		begin, err := strconv.Atoi(first)
+		if err != nil || begin < 0 {
+			return nil, errors.New("invalid so param")
+		}
+		end := begin
+		if isRange {
+			end, err = strconv.Atoi(last)
+			if err != nil || end < begin {
+				return nil, errors.New("invalid so param")
+			}
+		}
+		if end-begin > maxSelectOnlyRange {
+			return nil, errors.New("so param range is too large")
+		}
+		for i := begin; i <= end; i++ {
+			ret = append(ret, i)
+		}
+	}
+	return ret, nil
+}
+
+// maxSelectOnlyRange limits the number of indexes in a single range of "so" param.
+const maxSelectOnlyRange = 1 << 16
+
+// formatSelectOnly returns the value of "so" param, merging consecutive indexes into ranges.
+func formatSelectOnly(indexes []int) string {
+	sorted := append([]int(nil), indexes...)
+	sort.Ints(sorted)
+	var parts []string
+	for i := 0; i < len(sorted); {
+		j := i
+		for j+1 < len(sorted) && sorted[j+1] <= sorted[j]+1 {
+			j++
+		}
+		if sorted[i] == sorted[j] {
+			parts = append(parts, strconv.Itoa(sorted[i]))
+		} else {
+			parts = append(parts, strconv.Itoa(sorted[i])+"-"+strconv.Itoa(sorted[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
 func (m *Magnet) String() string {
 	var b strings.Builder
 	b.Grow(2048)
-	b.WriteString("magnet:?xt=urn:btih:")
-	b.WriteString(hex.EncodeToString(m.InfoHash[:]))
+	b.WriteString("magnet:?")
+	// Truncated v2 info hash is not written as v1 info hash.
+	if m.InfoHashV2 == nil || !bytes.Equal(m.InfoHash[:], m.InfoHashV2[:20]) {
+		b.WriteString("xt=urn:btih:")
+		b.WriteString(hex.EncodeToString(m.InfoHash[:]))
+		if m.InfoHashV2 != nil {
+			b.WriteString("&")
+		}
+	}
+	if m.InfoHashV2 != nil {
+		b.WriteString("xt=urn:btmh:1220")
+		b.WriteString(hex.EncodeToString(m.InfoHashV2))
+	}
 	if m.Name != "" {
 		b.WriteString("&dn=")
 		b.WriteString(url.QueryEscape(m.Name))
@@ -106,6 +214,26 @@ func (m *Magnet) String() string {
 		b.WriteString("&x.pe=")
 		b.WriteString(p)
 	}
+	for _, ws := range m.Webseeds {
+		b.WriteString("&ws=")
+		b.WriteString(url.QueryEscape(ws))
+	}
+	for _, xs := range m.ExactSources {
+		b.WriteString("&xs=")
+		b.WriteString(url.QueryEscape(xs))
+	}
+	for _, as := range m.AcceptableSources {
+		b.WriteString("&as=")
+		b.WriteString(url.QueryEscape(as))
+	}
+	if m.Length > 0 {
+		b.WriteString("&xl=")
+		b.WriteString(strconv.FormatInt(m.Length, 10))
+	}
+	if len(m.SelectOnly) > 0 {
+		b.WriteString("&so=")
+		b.WriteString(formatSelectOnly(m.SelectOnly))
+	}
 	return b.String()
 }
 
@@ -115,11 +243,10 @@ type trackerTier struct {
 }
 
 // infoHashString returns a new info hash value from a string.
-// s must be 40 (hex encoded) or 32 (base32 encoded) characters, otherwise it returns error.
-func infoHashString(xt string) ([20]byte, error) {
-	var ih [20]byte
+// v1 info hash must be 40 (hex encoded) or 32 (base32 encoded) characters, otherwise it returns error.
+// If the string is a SHA-256 multihash, v2 info hash is returned in v2.
+func infoHashString(xt string) (ih [20]byte, v2 []byte, err error) {
 	var b []byte
-	var err error
 	switch {
 	case strings.HasPrefix(xt, "urn:btih:"):
 		xt = xt[9:]
@@ -129,23 +256,34 @@ func infoHashString(xt string) ([20]byte, error) {
 		case 32:
 			b, err = base32.StdEncoding.DecodeString(xt)
 		default:
-			return ih, errors.New("info hash must be 32 or 40 characters")
+			return ih, nil, errors.New("info hash must be 32 or 40 characters")
 		}
 		if err != nil {
-			return ih, err
+			return ih, nil, err
 		}
 	case strings.HasPrefix(xt, "urn:btmh:"):
 		xt = xt[9:]
-		b, err = multihash.FromHexString(xt)
+		var mh multihash.Multihash
+		mh, err = multihash.FromHexString(xt)
+		if err != nil {
+			return ih, nil, err
+		}
+		var dm *multihash.DecodedMultihash
+		dm, err = multihash.Decode(mh)
 		if err != nil {
-			return ih, err
+			return ih, nil, err
 		}
-		if len(b) != 20 {
-			return ih, errors.New("invalid multihash (len != 20)")
+		switch {
+		case dm.Code == multihash.SHA2_256 && len(dm.Digest) == 32:
+			return ih, dm.Digest, nil
+		case dm.Code == multihash.SHA1 && len(dm.Digest) == 20:
+			b = dm.Digest
+		default:
+			return ih, nil, errors.New("unsupported multihash: must be sha2-256 or sha1")
 		}
 	default:
-		return ih, errors.New("invalid xt param: must start with \"urn:btih:\" or \"urn:btmh\"")
+		return ih, nil, errors.New("invalid xt param: must start with \"urn:btih:\" or \"urn:btmh\"")
 	}
 	copy(ih[:], b)
-	return ih, nil
+	return ih, nil, nil
 }
@@ -2,6 +2,7 @@ package magnet
 
 import (
 	"encoding/hex"
+	"fmt"
 	"strings"
 	"testing"
 )
@@ -31,3 +32,78 @@ func TestParse(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestParseV2(t *testing.T) {
+	const v2 = "d8dd32ac93357c368556af3ac1d95c9d76bd0dff6fa9833ecdac3d53134efabb"
+	u := "magnet:?xt=urn:btmh:1220" + v2 + "&dn=v2_torrent"
+	m, err := New(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(m.InfoHashV2) != v2 {
+		t.Fatal("invalid v2 info hash")
+	}
+	if hex.EncodeToString(m.InfoHash[:]) != v2[:40] {
+		t.Fatal("info hash must be truncated v2 info hash")
+	}
+	if s := m.String(); s != u {
+		t.Log(u)
+		t.Log(s)
+		t.FailNow()
+	}
+
+	// Hybrid torrent
+	u = "magnet:?xt=urn:btih:631a31dd0a46257d5078c0dee4e66e26f73e42ac&xt=urn:btmh:1220" + v2
+	m, err = New(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(m.InfoHash[:]) != "631a31dd0a46257d5078c0dee4e66e26f73e42ac" {
+		t.Fatal("invalid info hash")
+	}
+	if hex.EncodeToString(m.InfoHashV2) != v2 {
+		t.Fatal("invalid v2 info hash")
+	}
+	if s := m.String(); s != u {
+		t.Log(u)
+		t.Log(s)
+		t.FailNow()
+	}
+}
+
+func TestParseExtensions(t *testing.T) {
+	u := "magnet:?xt=urn:btih:f60cc95e3566af84c1ab223fd4ce80fa88e6438a" +
+		"&ws=http%3A%2F%2Fseed.rain%2Ffiles%2F" +
+		"&xs=http%3A%2F%2Fsource.rain%2Fa.torrent" +
+		"&as=http%3A%2F%2Fmirror.rain%2Fa.torrent" +
+		"&xl=1024&so=0,2,4-6"
+	m, err := New(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Webseeds) != 1 || m.Webseeds[0] != "http://seed.rain/files/" {
+		t.Fatal("invalid webseeds")
+	}
+	if len(m.ExactSources) != 1 || m.ExactSources[0] != "http://source.rain/a.torrent" {
+		t.Fatal("invalid exact sources")
+	}
+	if len(m.AcceptableSources) != 1 || m.AcceptableSources[0] != "http://mirror.rain/a.torrent" {
+		t.Fatal("invalid acceptable sources")
+	}
+	if m.Length != 1024 {
+		t.Fatal("invalid length")
+	}
+	if fmt.Sprint(m.SelectOnly) != "[0 2 4 5 6]" {
+		t.Fatalf("invalid select only: %v", m.SelectOnly)
+	}
+	if s := m.String(); s != u {
+		t.Log(u)
+		t.Log(s)
+		t.FailNow()
+	}
+	for _, so := range []string{"a", "-1", "3-1", "1,", "0-100000000"} {
+		if _, err = New("magnet:?xt=urn:btih:f60cc95e3566af84c1ab223fd4ce80fa88e6438a&so=" + so); err == nil {
+			t.Errorf("so=%s must be invalid", so)
+		}
+	}
+}
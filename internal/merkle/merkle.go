@@ -0,0 +1,109 @@
+// Package merkle implements the SHA-256 merkle trees that are used for verifying the data of BitTorrent v2 torrents (BEP 52).
+package merkle
+
+import (
+	"crypto/sha256"
+	"errors"
+	"math/bits"
+)
+
+// BlockSize is the size of data that is hashed for each leaf of the tree.
+const BlockSize = 16 * 1024
+
+// HashSize is the size of a node in the tree.
+const HashSize = sha256.Size
+
+var errInvalidProof = errors.New("invalid number of proof hashes")
+
+// NextPowerOfTwo returns the smallest power of two that is greater or equal to n.
+func NextPowerOfTwo(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	return 1 << bits.Len(uint(n-1))
+}
+
+// Log2 returns the base 2 logarithm of n. n must be a power of two.
+func Log2(n int) int {
+	return bits.TrailingZeros(uint(n))
+}
+
+// Root returns the root of the tree whose leaves are the hashes of 16 KiB blocks in data.
+// The tree is padded with zero hashes up to numLeaves leaves.
+func Root(data []byte, numLeaves int) []byte {
+	leaves := make([]byte, 0, numLeaves*HashSize)
+	for len(data) > 0 {
+		n := min(len(data), BlockSize)
+		sum := sha256.Sum256(data[:n])
+		leaves = append(leaves, sum[:]...)
+		data = data[n:]
+	}
+	return RootOfLayer(leaves, numLeaves, make([]byte, HashSize))
+}
+
+// RootOfLayer returns the root of the tree built from concatenated hashes in layer.
+// The layer is padded with pad hashes up to width hashes.
+func RootOfLayer(layer []byte, width int, pad []byte) []byte {
+	return Layers(layer, width, pad)[0]
+}
+
+// Layers returns all layers of the tree built from concatenated hashes in layer, starting from the root.
+// The layer is padded with pad hashes up to width hashes. Width must be a power of two.
+func Layers(layer []byte, width int, pad []byte) [][]byte {
+	base := make([]byte, width*HashSize)
+	n := copy(base, layer)
+	for ; n < len(base); n += HashSize {
+		copy(base[n:], pad)
+	}
+	layers := make([][]byte, Log2(width)+1)
+	layers[len(layers)-1] = base
+	for i := len(layers) - 2; i >= 0; i-- {
+		layers[i] = parentLayer(layers[i+1])
+	}
+	return layers
+}
+
+func parentLayer(layer []byte) []byte {
+	parent := make([]byte, 0, len(layer)/2)
+	for i := 0; i < len(layer); i += 2 * HashSize {
+		parent = append(parent, hashPair(layer[i:i+HashSize], layer[i+HashSize:i+2*HashSize])...)
+	}
+	return parent
+}
+
+func hashPair(left, right []byte) []byte {
+	h := sha256.New()
+	_, _ = h.Write(left)
+	_, _ = h.Write(right)
+	return h.Sum(nil)
+}
+
+// PadHash returns the root of a tree with numLeaves zero leaves.
+// It is used for padding the piece layer of a file.
+func PadHash(numLeaves int) []byte {
+	pad := make([]byte, HashSize)
+	for ; numLeaves > 1; numLeaves /= 2 {
+		pad = hashPair(pad, pad)
+	}
+	return pad
+}
+
+// ProofRoot returns the root of the tree from the hashes of a subtree at index and the uncle hashes of the subtree root.
+// Uncle hashes are ordered from the lowest layer up.
+func ProofRoot(hashes []byte, index int, uncles []byte) ([]byte, error) {
+	if len(uncles)%HashSize != 0 {
+		return nil, errInvalidProof
+	}
+	count := len(hashes) / HashSize
+	node := RootOfLayer(hashes, count, nil)
+	pos := index / count
+	for ; len(uncles) > 0; uncles = uncles[HashSize:] {
+		if pos%2 == 0 {
+			node = hashPair(node, uncles[:HashSize])
+		} else {
+			node = hashPair(uncles[:HashSize], node)
+		}
+		pos /= 2
+	}
+	return node, nil
+}
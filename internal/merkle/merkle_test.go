@@ -0,0 +1,57 @@
+package merkle
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoot(t *testing.T) {
+	data := bytes.Repeat([]byte{1}, 3*BlockSize)
+	block := sha256.Sum256(data[:BlockSize])
+	zero := make([]byte, HashSize)
+	left := hashPair(block[:], block[:])
+	right := hashPair(block[:], zero)
+	assert.Equal(t, hashPair(left, right), Root(data, 4))
+	assert.Equal(t, block[:], Root(data[:BlockSize], 1))
+}
+
+func TestPadHash(t *testing.T) {
+	zero := make([]byte, HashSize)
+	assert.Equal(t, zero, PadHash(1))
+	assert.Equal(t, hashPair(hashPair(zero, zero), hashPair(zero, zero)), PadHash(4))
+}
+
+func TestProofRoot(t *testing.T) {
+	layer := make([]byte, 8*HashSize)
+	for i := range layer {
+		layer[i] = byte(i)
+	}
+	layers := Layers(layer, 8, nil)
+	root := layers[0]
+	// Request 2 hashes at index 4. Uncles are the sibling of the subtree root at each layer going up.
+	hashes := layer[4*HashSize : 6*HashSize]
+	uncles := append(append([]byte{}, layers[2][3*HashSize:4*HashSize]...), layers[1][0:HashSize]...)
+	proof, err := ProofRoot(hashes, 4, uncles)
+	assert.NoError(t, err)
+	assert.Equal(t, root, proof)
+
+	proof, err = ProofRoot(hashes, 6, uncles)
+	assert.NoError(t, err)
+	assert.NotEqual(t, root, proof)
+
+	proof, err = ProofRoot(layer, 0, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, root, proof)
+}
+
+func TestNextPowerOfTwo(t *testing.T) {
+	assert.Equal(t, 1, NextPowerOfTwo(0))
+	assert.Equal(t, 1, NextPowerOfTwo(1))
+	assert.Equal(t, 2, NextPowerOfTwo(2))
+	assert.Equal(t, 4, NextPowerOfTwo(3))
+	assert.Equal(t, 512, NextPowerOfTwo(300))
+	assert.Equal(t, 9, Log2(512))
+}
@@ -13,6 +13,7 @@ import (
 	"unicode"
 
 	"github.com/cenkalti/rain/internal/logger"
+	"github.com/cenkalti/rain/internal/merkle"
 	"github.com/zeebo/bencode"
 )
 
@@ -27,13 +28,26 @@ var (
 type Info struct {
 	PieceLength uint32
 	Name        string
-	Hash        [20]byte
-	Length      int64
-	NumPieces   uint32
-	Bytes       []byte
-	Private     bool
-	Files       []File
-	pieces      []byte
+	// Identifies the torrent in peer protocol, trackers and DHT.
+	// SHA-1 hash of the info dictionary for v1 and hybrid torrents, truncated SHA-256 hash for v2 torrents.
+	Hash [20]byte
+	// SHA-256 hash of the info dictionary. Set for v2 and hybrid torrents.
+	HashV2    [32]byte
+	Length    int64
+	NumPieces uint32
+	Bytes     []byte
+	Private   bool
+	Files     []File
+	// V1 is true if the torrent has SHA-1 piece hashes. V2 is true if the torrent has a file tree (BEP 52).
+	// Both are true for hybrid torrents.
+	V1, V2 bool
+	// Piece layers of the files in v2 torrents that are larger than a piece.
+	PieceLayers []PieceLayer
+	// Root of the SHA-1 hash tree of pieces in merkle torrents (BEP 30). Nil for other torrents.
+	RootHash   []byte
+	pieces     []byte
+	filesV2    []fileV2
+	merkleTree []byte
 }
 
 // File represents a file inside a Torrent.
@@ -66,11 +80,14 @@ func (f *file) isPadding() bool {
 type infoType struct {
 	PieceLength uint32             `bencode:"piece length"`
 	Pieces      []byte             `bencode:"pieces"`
+	RootHash    []byte             `bencode:"root hash"` // Merkle torrents
 	Name        string             `bencode:"name"`
 	NameUTF8    string             `bencode:"name.utf-8,omitempty"`
 	Private     bencode.RawMessage `bencode:"private"`
-	Length      int64              `bencode:"length"` // Single File Mode
-	Files       []file             `bencode:"files"`  // Multiple File mode
+	Length      int64              `bencode:"length"`       // Single File Mode
+	Files       []file             `bencode:"files"`        // Multiple File mode
+	MetaVersion int                `bencode:"meta version"` // 2 for v2 and hybrid torrents
+	FileTree    bencode.RawMessage `bencode:"file tree"`    // v2 and hybrid torrents
 }
 
 func (ib *infoType) overrideUTF8Keys() {
@@ -93,11 +110,22 @@ func NewInfo(b []byte, utf8 bool, pad bool) (*Info, error) {
 	if ib.PieceLength == 0 {
 		return nil, errZeroPieceLength
 	}
+	if ib.MetaVersion == 2 {
+		return newInfoV2(b, &ib, utf8, pad)
+	}
+	return newInfoV1(b, &ib, utf8, pad)
+}
+
+func newInfoV1(b []byte, ib *infoType, utf8 bool, pad bool) (*Info, error) {
+	isMerkle := len(ib.Pieces) == 0 && len(ib.RootHash) > 0
+	if isMerkle && len(ib.RootHash) != sha1.Size {
+		return nil, errInvalidRootHash
+	}
 	if len(ib.Pieces)%sha1.Size != 0 {
 		return nil, errInvalidPieceData
 	}
 	numPieces := len(ib.Pieces) / sha1.Size
-	if numPieces == 0 {
+	if numPieces == 0 && !isMerkle {
 		return nil, errZeroPieces
 	}
 	if utf8 {
@@ -117,6 +145,7 @@ func NewInfo(b []byte, utf8 bool, pad bool) (*Info, error) {
 		pieces:      ib.Pieces,
 		Name:        ib.Name,
 		Private:     parsePrivateField(ib.Private),
+		V1:          true,
 	}
 	multiFile := len(ib.Files) > 0
 	if multiFile {
@@ -126,6 +155,15 @@ func NewInfo(b []byte, utf8 bool, pad bool) (*Info, error) {
 	} else {
 		i.Length = ib.Length
 	}
+	if isMerkle {
+		// Number of pieces is not known until the length of files are summed.
+		if i.Length <= 0 {
+			return nil, errZeroPieces
+		}
+		i.NumPieces = uint32((i.Length + int64(i.PieceLength) - 1) / int64(i.PieceLength))
+		i.RootHash = ib.RootHash
+		i.merkleTree = newMerkleTree(ib.RootHash, i.NumPieces)
+	}
 	totalPieceDataLength := int64(i.PieceLength) * int64(i.NumPieces)
 	delta := totalPieceDataLength - i.Length
 	if delta >= int64(i.PieceLength) || delta < 0 {
@@ -226,26 +264,13 @@ func parsePrivateField(s bencode.RawMessage) bool {
 
 // NewInfoBytes creates a new Info dictionary by reading and hashing the files on the disk.
 func NewInfoBytes(root string, paths []string, private bool, pieceLength uint32, name string, log logger.Logger) ([]byte, error) {
-	var singleFileTorrent bool
-	switch len(paths) {
-	case 0:
-		return nil, errors.New("no path specified")
-	case 1:
-		if name == "" {
-			name = filepath.Base(paths[0])
-		}
-		fi, err := os.Stat(paths[0])
-		if err != nil {
-			return nil, err
-		}
-		singleFileTorrent = !fi.IsDir()
-	default:
-		if root == "" {
-			return nil, errors.New("no root specified")
-		}
-		if name == "" {
-			return nil, errors.New("no name specified")
-		}
+	return newInfoBytes(root, paths, private, pieceLength, name, false, log)
+}
+
+func newInfoBytes(root string, paths []string, private bool, pieceLength uint32, name string, isMerkle bool, log logger.Logger) ([]byte, error) {
+	name, singleFileTorrent, err := checkPaths(root, paths, name)
+	if err != nil {
+		return nil, err
 	}
 	totalLength, err := findTotalLength(paths)
 	if err != nil {
@@ -319,15 +344,21 @@ func NewInfoBytes(root string, paths []string, private bool, pieceLength uint32,
 		Name        string `bencode:"name"`
 		Private     bool   `bencode:"private"`
 		PieceLength uint32 `bencode:"piece length"`
-		Pieces      []byte `bencode:"pieces"`
-		Length      int64  `bencode:"length,omitempty"` // Single File Mode
-		Files       []file `bencode:"files,omitempty"`  // Multiple File mode
+		Pieces      []byte `bencode:"pieces,omitempty"`
+		RootHash    []byte `bencode:"root hash,omitempty"` // Merkle torrents
+		Length      int64  `bencode:"length,omitempty"`    // Single File Mode
+		Files       []file `bencode:"files,omitempty"`     // Multiple File mode
 	}{
 		Name:        name,
 		Private:     private,
 		PieceLength: pieceLength,
 		Pieces:      pieces,
 	}
+	if isMerkle {
+		width := merkle.NextPowerOfTwo(len(pieces) / sha1.Size)
+		b.RootHash = buildMerkleTree(pieces, width)[:sha1.Size]
+		b.Pieces = nil
+	}
 	if singleFileTorrent {
 		b.Length = totalLength
 	} else {
@@ -337,12 +368,59 @@ func NewInfoBytes(root string, paths []string, private bool, pieceLength uint32,
 }
 
 // PieceHash returns the hash of a piece at index.
+// Pieces of v2 torrents are verified with the root of the merkle tree of the piece.
+// It returns nil if the piece layer of the file or the hash of the piece in merkle torrent is not known yet.
 func (i *Info) PieceHash(index uint32) []byte {
+	if !i.V1 {
+		return i.pieceHashV2(index)
+	}
+	if i.RootHash != nil {
+		n := i.merkleLeaf(index)
+		if !i.merkleNodeKnown(n) {
+			return nil
+		}
+		return i.merkleNode(n)
+	}
 	begin := index * sha1.Size
 	end := begin + sha1.Size
 	return i.pieces[begin:end]
 }
 
+// PieceTreeLeaves returns the number of leaves in the merkle tree of the piece at index.
+// It returns zero if the piece is verified with the SHA-1 hash.
+func (i *Info) PieceTreeLeaves(index uint32) int {
+	if i.V1 {
+		return 0
+	}
+	return i.pieceTreeLeaves(index)
+}
+
+// checkPaths validates the paths for creating a new torrent and returns the name of the torrent.
+func checkPaths(root string, paths []string, name string) (string, bool, error) {
+	var singleFileTorrent bool
+	switch len(paths) {
+	case 0:
+		return "", false, errors.New("no path specified")
+	case 1:
+		if name == "" {
+			name = filepath.Base(paths[0])
+		}
+		fi, err := os.Stat(paths[0])
+		if err != nil {
+			return "", false, err
+		}
+		singleFileTorrent = !fi.IsDir()
+	default:
+		if root == "" {
+			return "", false, errors.New("no root specified")
+		}
+		if name == "" {
+			return "", false, errors.New("no name specified")
+		}
+	}
+	return name, singleFileTorrent, nil
+}
+
 func findTotalLength(paths []string) (n int64, err error) {
 	for _, path := range paths {
 		err = filepath.Walk(path, func(path string, fi os.FileInfo, err error) error {
@@ -0,0 +1,209 @@
+package metainfo
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"errors"
+
+	"github.com/cenkalti/rain/internal/logger"
+	"github.com/cenkalti/rain/internal/merkle"
+)
+
+var (
+	errInvalidRootHash   = errors.New("invalid root hash")
+	errInvalidHashChain  = errors.New("hash chain does not match root hash")
+	errInvalidMerkleTree = errors.New("invalid merkle tree")
+	errMissingPieceHash  = errors.New("missing piece hash")
+)
+
+// Pieces of merkle torrents (BEP 30) are verified with a tree of SHA-1 hashes.
+// Only the root of the tree is in the info dictionary, other nodes are received from peers with the pieces.
+// Nodes are numbered starting from the root. Children of node n are 2n+1 and 2n+2.
+// Leaves are the hashes of pieces. Tree is padded with zero hashes up to a power of two leaves.
+// Unknown nodes are kept as zero hashes, non-padding nodes are never zero.
+
+func newMerkleTree(root []byte, numPieces uint32) []byte {
+	width := merkle.NextPowerOfTwo(int(numPieces))
+	tree := make([]byte, (2*width-1)*sha1.Size)
+	copy(tree, root)
+	return tree
+}
+
+func (i *Info) merkleNode(n uint32) []byte {
+	return i.merkleTree[n*sha1.Size : (n+1)*sha1.Size]
+}
+
+func (i *Info) merkleNumNodes() uint32 {
+	return uint32(len(i.merkleTree) / sha1.Size)
+}
+
+// merkleLeaf returns the node number of the piece at index.
+func (i *Info) merkleLeaf(index uint32) uint32 {
+	return i.merkleNumNodes()/2 + index
+}
+
+// merkleNodeKnown returns true if the value of node n is known.
+// Padding leaves are always known.
+func (i *Info) merkleNodeKnown(n uint32) bool {
+	if n >= i.merkleLeaf(i.NumPieces) {
+		return true
+	}
+	return !isZeroHash(i.merkleNode(n))
+}
+
+func isZeroHash(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func merkleSibling(n uint32) uint32 {
+	if n%2 == 1 {
+		return n + 1
+	}
+	return n - 1
+}
+
+func merkleParent(n uint32) uint32 {
+	return (n - 1) / 2
+}
+
+// hashMerklePair returns the hash of parent of node n and its sibling.
+func hashMerklePair(n uint32, node, sibling []byte) []byte {
+	h := sha1.New()
+	if n%2 == 1 {
+		_, _ = h.Write(node)
+		_, _ = h.Write(sibling)
+	} else {
+		_, _ = h.Write(sibling)
+		_, _ = h.Write(node)
+	}
+	return h.Sum(nil)
+}
+
+// HashChain returns the nodes that are needed for verifying the piece at index against the root hash, keyed by node number.
+// The chain contains the piece hash, the siblings of the nodes on the path to the root and the root itself.
+// It returns nil if the torrent is not a merkle torrent or any of the nodes is not known.
+func (i *Info) HashChain(index uint32) map[uint32][]byte {
+	if i.RootHash == nil || index >= i.NumPieces {
+		return nil
+	}
+	n := i.merkleLeaf(index)
+	if !i.merkleNodeKnown(n) {
+		return nil
+	}
+	chain := map[uint32][]byte{0: i.RootHash, n: i.merkleNode(n)}
+	for ; n > 0; n = merkleParent(n) {
+		s := merkleSibling(n)
+		if !i.merkleNodeKnown(s) {
+			return nil
+		}
+		chain[s] = i.merkleNode(s)
+	}
+	return chain
+}
+
+// SetHashChain verifies the nodes received with the piece at index against the root hash and saves them.
+// Nodes that are already known are not required to be in the chain.
+func (i *Info) SetHashChain(index uint32, chain map[uint32][]byte) error {
+	if i.RootHash == nil || index >= i.NumPieces {
+		return errInvalidHashChain
+	}
+	node := func(n uint32) []byte {
+		if h, ok := chain[n]; ok && len(h) == sha1.Size {
+			return h
+		}
+		if i.merkleNodeKnown(n) {
+			return i.merkleNode(n)
+		}
+		return nil
+	}
+	leaf := i.merkleLeaf(index)
+	h := node(leaf)
+	if h == nil {
+		return errInvalidHashChain
+	}
+	path := make(map[uint32][]byte)
+	for n := leaf; n > 0; n = merkleParent(n) {
+		path[n] = h
+		s := merkleSibling(n)
+		sh := node(s)
+		if sh == nil {
+			return errInvalidHashChain
+		}
+		path[s] = sh
+		h = hashMerklePair(n, h, sh)
+	}
+	if !bytes.Equal(h, i.RootHash) {
+		return errInvalidHashChain
+	}
+	for n, h := range path {
+		if !i.merkleNodeKnown(n) {
+			copy(i.merkleNode(n), h)
+		}
+	}
+	return nil
+}
+
+// SetPieceHashes builds the merkle tree from the hashes of all pieces and saves it if the root matches.
+// Known piece hashes are used for the pieces that are missing in hashes.
+// It is used for verifying the pieces on disk when the hashes are not received from peers yet.
+func (i *Info) SetPieceHashes(hashes map[uint32][]byte) error {
+	if i.RootHash == nil {
+		return errInvalidMerkleTree
+	}
+	width := int(i.merkleNumNodes()/2 + 1)
+	leaves := make([]byte, 0, width*sha1.Size)
+	for index := uint32(0); index < i.NumPieces; index++ {
+		h, ok := hashes[index]
+		if !ok {
+			h = i.PieceHash(index)
+		}
+		if len(h) != sha1.Size {
+			return errMissingPieceHash
+		}
+		leaves = append(leaves, h...)
+	}
+	tree := buildMerkleTree(leaves, width)
+	if !bytes.Equal(tree[:sha1.Size], i.RootHash) {
+		return errInvalidRootHash
+	}
+	i.merkleTree = tree
+	return nil
+}
+
+// buildMerkleTree returns all nodes of the tree built from concatenated piece hashes in leaves.
+func buildMerkleTree(leaves []byte, width int) []byte {
+	tree := make([]byte, (2*width-1)*sha1.Size)
+	copy(tree[(width-1)*sha1.Size:], leaves)
+	for n := width - 2; n >= 0; n-- {
+		left := tree[(2*n+1)*sha1.Size : (2*n+2)*sha1.Size]
+		right := tree[(2*n+2)*sha1.Size : (2*n+3)*sha1.Size]
+		copy(tree[n*sha1.Size:], hashMerklePair(1, left, right))
+	}
+	return tree
+}
+
+// MerkleTree returns the nodes of the merkle tree for saving in resume database.
+// Unknown nodes are zero. It returns nil if the torrent is not a merkle torrent.
+func (i *Info) MerkleTree() []byte {
+	return i.merkleTree
+}
+
+// SetMerkleTree sets the nodes of the merkle tree that is previously returned from MerkleTree.
+func (i *Info) SetMerkleTree(b []byte) error {
+	if i.RootHash == nil || len(b) != len(i.merkleTree) || !bytes.Equal(b[:sha1.Size], i.RootHash) {
+		return errInvalidMerkleTree
+	}
+	i.merkleTree = b
+	return nil
+}
+
+// NewInfoBytesMerkle creates a new merkle torrent (BEP 30) info dictionary by reading and hashing the files on the disk.
+// The info dictionary contains only the root of the piece hashes, so the size of the torrent file does not grow with the number of pieces.
+func NewInfoBytesMerkle(root string, paths []string, private bool, pieceLength uint32, name string, log logger.Logger) ([]byte, error) {
+	return newInfoBytes(root, paths, private, pieceLength, name, true, log)
+}
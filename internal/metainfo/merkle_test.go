@@ -0,0 +1,62 @@
+package metainfo
+
+import (
+	"crypto/sha1"
+	"testing"
+
+	"github.com/cenkalti/rain/internal/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerkleTorrent(t *testing.T) {
+	dir, _ := writeTestFiles(t)
+	b, err := NewInfoBytesMerkle("", []string{dir}, false, testPieceLength, "", logger.New("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := NewInfo(b, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1, err := NewInfoBytes("", []string{dir}, false, testPieceLength, "", logger.New("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	infoV1, err := NewInfo(v1, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, info.RootHash, sha1.Size)
+	assert.Equal(t, infoV1.NumPieces, info.NumPieces)
+	assert.Equal(t, infoV1.Length, info.Length)
+	assert.Nil(t, info.PieceHash(0))
+	assert.Nil(t, info.HashChain(0))
+
+	// Seeder builds the tree from the pieces on disk.
+	hashes := make(map[uint32][]byte)
+	for i := uint32(0); i < infoV1.NumPieces; i++ {
+		hashes[i] = infoV1.PieceHash(i)
+	}
+	seed, _ := NewInfo(b, true, true)
+	assert.Equal(t, errMissingPieceHash, seed.SetPieceHashes(map[uint32][]byte{0: hashes[0]}))
+	assert.NoError(t, seed.SetPieceHashes(hashes))
+
+	// Downloader verifies the hash chains received from the seeder.
+	for i := uint32(0); i < info.NumPieces; i++ {
+		chain := seed.HashChain(i)
+		if !assert.NotNil(t, chain) {
+			return
+		}
+		assert.NoError(t, info.SetHashChain(i, chain))
+		assert.Equal(t, hashes[i], info.PieceHash(i))
+	}
+	assert.Equal(t, seed.MerkleTree(), info.MerkleTree())
+
+	// Chain with a wrong piece hash is rejected.
+	info, _ = NewInfo(b, true, true)
+	chain := seed.HashChain(1)
+	chain[info.merkleLeaf(1)] = make([]byte, sha1.Size)
+	chain[info.merkleLeaf(1)][0] = 1
+	assert.Equal(t, errInvalidHashChain, info.SetHashChain(1, chain))
+	assert.Nil(t, info.PieceHash(1))
+}
@@ -18,6 +18,9 @@ type MetaInfo struct {
 	Info         Info
 	AnnounceList [][]string
 	URLList      []string
+	HTTPSeeds    []string
+	// Signatures of the info dictionary (BEP 35).
+	Signatures []Signature
 }
 
 // New returns a torrent from bencoded stream.
@@ -28,6 +31,9 @@ func New(r io.Reader) (*MetaInfo, error) {
 		Announce     bencode.RawMessage `bencode:"announce"`
 		AnnounceList bencode.RawMessage `bencode:"announce-list"`
 		URLList      bencode.RawMessage `bencode:"url-list"`
+		HTTPSeeds    bencode.RawMessage `bencode:"httpseeds"`
+		PieceLayers  bencode.RawMessage `bencode:"piece layers"`
+		Signatures   bencode.RawMessage `bencode:"signatures"`
 	}
 	err := bencode.NewDecoder(r).Decode(&t)
 	if err != nil {
@@ -40,6 +46,15 @@ func New(r io.Reader) (*MetaInfo, error) {
 	if err != nil {
 		return nil, err
 	}
+	if len(t.PieceLayers) > 0 {
+		err = info.SetPieceLayers(t.PieceLayers)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !info.HasPieceLayers() {
+		return nil, errMissingLayers
+	}
 	ret.Info = *info
 	if len(t.AnnounceList) > 0 {
 		var ll [][]string
@@ -83,6 +98,20 @@ func New(r io.Reader) (*MetaInfo, error) {
 			}
 		}
 	}
+	if len(t.HTTPSeeds) > 0 {
+		var l []string
+		err = bencode.DecodeBytes(t.HTTPSeeds, &l)
+		if err == nil {
+			for _, s := range l {
+				if isWebseedSupported(s) {
+					ret.HTTPSeeds = append(ret.HTTPSeeds, s)
+				}
+			}
+		}
+	}
+	if len(t.Signatures) > 0 {
+		ret.Signatures, _ = UnmarshalSignatures(t.Signatures)
+	}
 	return &ret, nil
 }
 
@@ -95,17 +124,22 @@ func isWebseedSupported(s string) bool {
 }
 
 // NewBytes creates a new torrent metadata file from given information.
-func NewBytes(info []byte, trackers [][]string, webseeds []string, comment string) ([]byte, error) {
+// pieceLayers is required for v2 and hybrid torrents.
+// If signers are given, the info dictionary is signed by each of them.
+func NewBytes(info, pieceLayers []byte, trackers [][]string, webseeds []string, comment string, signers ...Signer) ([]byte, error) {
 	mi := struct {
 		Info         bencode.RawMessage `bencode:"info"`
+		PieceLayers  bencode.RawMessage `bencode:"piece layers,omitempty"`
 		Announce     string             `bencode:"announce,omitempty"`
 		AnnounceList [][]string         `bencode:"announce-list,omitempty"`
 		URLList      bencode.RawMessage `bencode:"url-list,omitempty"`
 		Comment      string             `bencode:"comment,omitempty"`
 		CreationDate int64              `bencode:"creation date"`
 		CreatedBy    string             `bencode:"created by,omitempty"`
+		Signatures   bencode.RawMessage `bencode:"signatures,omitempty"`
 	}{
 		Info:         info,
+		PieceLayers:  pieceLayers,
 		Comment:      comment,
 		CreationDate: time.Now().UTC().Unix(),
 		CreatedBy:    Creator,
@@ -120,5 +154,20 @@ func NewBytes(info []byte, trackers [][]string, webseeds []string, comment strin
 	} else if len(webseeds) > 1 {
 		mi.URLList, _ = bencode.EncodeBytes(webseeds)
 	}
+	if len(signers) > 0 {
+		sigs := make([]Signature, len(signers))
+		for i, s := range signers {
+			sig, err := s.Sign(info)
+			if err != nil {
+				return nil, err
+			}
+			sigs[i] = sig
+		}
+		b, err := MarshalSignatures(sigs)
+		if err != nil {
+			return nil, err
+		}
+		mi.Signatures = b
+	}
 	return bencode.EncodeBytes(mi)
 }
@@ -0,0 +1,184 @@
+package metainfo
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"sort"
+
+	"github.com/zeebo/bencode"
+)
+
+var (
+	errNoPrivateKey   = errors.New("no private key in PEM data")
+	errKeyType        = errors.New("only ed25519 keys are supported")
+	errCertificateKey = errors.New("certificate does not match the private key")
+	errNoCertificate  = errors.New("signature has no certificate")
+	errNoSigner       = errors.New("signer name is required if there is no certificate")
+)
+
+// Signature of the info dictionary in signed torrents (BEP 35).
+// Only the info dictionary is signed, so the info hash of the torrent does not change.
+type Signature struct {
+	// Identity of the signer. It is the key in the "signatures" dictionary.
+	Signer string
+	// DER encoded X.509 certificate of the signer. Optional if the public key of the signer is known by the receiver.
+	Certificate []byte
+	// Optional bencoded dictionary that is signed together with the info dictionary.
+	Info []byte
+	// Ed25519 signature of the info dictionary followed by Info.
+	Signature []byte
+}
+
+type signature struct {
+	Certificate []byte             `bencode:"certificate,omitempty"`
+	Info        bencode.RawMessage `bencode:"info,omitempty"`
+	Signature   []byte             `bencode:"signature"`
+}
+
+// Signer creates a signature of the info dictionary while creating a torrent with NewBytes.
+type Signer interface {
+	Sign(info []byte) (Signature, error)
+}
+
+// Sign returns the signature itself, so the existing signatures can be put into a new torrent file.
+func (s Signature) Sign(info []byte) (Signature, error) {
+	return s, nil
+}
+
+func (s *Signature) message(info []byte) []byte {
+	m := make([]byte, 0, len(info)+len(s.Info))
+	m = append(m, info...)
+	return append(m, s.Info...)
+}
+
+// Verify returns true if the info dictionary is signed with key.
+func (s *Signature) Verify(info []byte, key ed25519.PublicKey) bool {
+	return len(key) == ed25519.PublicKeySize && ed25519.Verify(key, s.message(info), s.Signature)
+}
+
+// PublicKey returns the public key in the certificate of the signer.
+func (s *Signature) PublicKey() (ed25519.PublicKey, error) {
+	if len(s.Certificate) == 0 {
+		return nil, errNoCertificate
+	}
+	cert, err := x509.ParseCertificate(s.Certificate)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := cert.PublicKey.(ed25519.PublicKey)
+	if !ok {
+		return nil, errKeyType
+	}
+	return key, nil
+}
+
+// KeySigner signs the info dictionary with an ed25519 private key.
+type KeySigner struct {
+	Name        string
+	Key         ed25519.PrivateKey
+	Certificate []byte
+}
+
+// NewKeySigner returns a KeySigner from PEM encoded private key and an optional certificate.
+// The private key must be in PKCS #8 form. Name of the signer is taken from the certificate if name is empty.
+func NewKeySigner(name string, pemData []byte) (*KeySigner, error) {
+	s := &KeySigner{Name: name}
+	for {
+		var block *pem.Block
+		block, pemData = pem.Decode(pemData)
+		if block == nil {
+			break
+		}
+		switch block.Type {
+		case "PRIVATE KEY":
+			key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			edKey, ok := key.(ed25519.PrivateKey)
+			if !ok {
+				return nil, errKeyType
+			}
+			s.Key = edKey
+		case "CERTIFICATE":
+			s.Certificate = block.Bytes
+		}
+	}
+	if s.Key == nil {
+		return nil, errNoPrivateKey
+	}
+	if s.Certificate != nil {
+		cert, err := x509.ParseCertificate(s.Certificate)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := cert.PublicKey.(ed25519.PublicKey)
+		if !ok || !key.Equal(s.Key.Public()) {
+			return nil, errCertificateKey
+		}
+		if s.Name == "" {
+			s.Name = cert.Subject.CommonName
+		}
+	}
+	if s.Name == "" {
+		return nil, errNoSigner
+	}
+	return s, nil
+}
+
+// Sign the info dictionary.
+func (s *KeySigner) Sign(info []byte) (Signature, error) {
+	return Signature{
+		Signer:      s.Name,
+		Certificate: s.Certificate,
+		Signature:   ed25519.Sign(s.Key, info),
+	}, nil
+}
+
+// MarshalSignatures returns the "signatures" dictionary in torrent file.
+func MarshalSignatures(sigs []Signature) ([]byte, error) {
+	m := make(map[string]signature, len(sigs))
+	for _, s := range sigs {
+		m[s.Signer] = signature{
+			Certificate: s.Certificate,
+			Info:        s.Info,
+			Signature:   s.Signature,
+		}
+	}
+	return bencode.EncodeBytes(m)
+}
+
+// UnmarshalSignatures parses the "signatures" dictionary in torrent file.
+// Signatures are sorted by the name of the signer.
+func UnmarshalSignatures(b []byte) ([]Signature, error) {
+	var m map[string]signature
+	if err := bencode.DecodeBytes(b, &m); err != nil {
+		return nil, err
+	}
+	sigs := make([]Signature, 0, len(m))
+	for name, s := range m {
+		sigs = append(sigs, Signature{
+			Signer:      name,
+			Certificate: s.Certificate,
+			Info:        s.Info,
+			Signature:   s.Signature,
+		})
+	}
+	sort.Slice(sigs, func(i, j int) bool { return sigs[i].Signer < sigs[j].Signer })
+	return sigs, nil
+}
+
+// TrustedSigner returns the name of the first signer whose signature is made with one of the keys.
+// It returns an empty string if the info is not signed with any of the keys.
+func TrustedSigner(info []byte, sigs []Signature, keys []ed25519.PublicKey) string {
+	for i := range sigs {
+		for _, key := range keys {
+			if sigs[i].Verify(info, key) {
+				return sigs[i].Signer
+			}
+		}
+	}
+	return ""
+}
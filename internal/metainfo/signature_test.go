@@ -0,0 +1,112 @@
+package metainfo
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSigningKey(t *testing.T, name string) (ed25519.PublicKey, []byte) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, pub, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	b = append(b, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})...)
+	return pub, b
+}
+
+func TestSignedTorrent(t *testing.T) {
+	pub, pemData := newTestSigningKey(t, "publisher")
+	signer, err := NewKeySigner("", pemData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "publisher", signer.Name)
+	dir, _ := writeTestFiles(t)
+	info, err := NewInfoBytes("", []string{dir}, false, testPieceLength, "", logger.New("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := NewBytes(info, nil, nil, nil, "", signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mi, err := New(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned, err := New(bytes.NewReader(mustNewBytes(t, info)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, unsigned.Info.Hash, mi.Info.Hash)
+	assert.Len(t, mi.Signatures, 1)
+	sig := mi.Signatures[0]
+	assert.Equal(t, "publisher", sig.Signer)
+	key, err := sig.PublicKey()
+	assert.NoError(t, err)
+	assert.Equal(t, pub, key)
+
+	other, _ := newTestSigningKey(t, "other")
+	assert.Equal(t, "publisher", TrustedSigner(mi.Info.Bytes, mi.Signatures, []ed25519.PublicKey{other, pub}))
+	assert.Equal(t, "", TrustedSigner(mi.Info.Bytes, mi.Signatures, []ed25519.PublicKey{other}))
+	assert.Equal(t, "", TrustedSigner(unsigned.Info.Bytes, unsigned.Signatures, []ed25519.PublicKey{pub}))
+
+	// Modified info is not accepted.
+	info2 := append([]byte{}, mi.Info.Bytes...)
+	info2[len(info2)-2] ^= 1
+	assert.Equal(t, "", TrustedSigner(info2, mi.Signatures, []ed25519.PublicKey{pub}))
+
+	// Existing signatures are kept when the torrent is created again.
+	b2, err := NewBytes(mi.Info.Bytes, nil, nil, nil, "", sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mi2, err := New(bytes.NewReader(b2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, mi.Signatures, mi2.Signatures)
+}
+
+func TestKeySignerWithoutCertificate(t *testing.T) {
+	_, pemData := newTestSigningKey(t, "publisher")
+	block, _ := pem.Decode(pemData)
+	keyOnly := pem.EncodeToMemory(block)
+	_, err := NewKeySigner("", keyOnly)
+	assert.Equal(t, errNoSigner, err)
+	signer, err := NewKeySigner("me", keyOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := signer.Sign([]byte("info"))
+	assert.NoError(t, err)
+	_, err = sig.PublicKey()
+	assert.Equal(t, errNoCertificate, err)
+	assert.True(t, sig.Verify([]byte("info"), signer.Key.Public().(ed25519.PublicKey)))
+}
@@ -0,0 +1,498 @@
+package metainfo
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cenkalti/rain/internal/logger"
+	"github.com/cenkalti/rain/internal/merkle"
+	"github.com/zeebo/bencode"
+)
+
+var (
+	errPieceLengthV2   = errors.New("piece length of v2 torrent must be a power of two and at least 16K")
+	errEmptyFileTree   = errors.New("torrent has empty file tree")
+	errInvalidRoot     = errors.New("invalid pieces root")
+	errHybridMismatch  = errors.New("v1 and v2 parts of hybrid torrent do not match")
+	errInvalidLayer    = errors.New("piece layer does not match pieces root")
+	errUnknownLayer    = errors.New("unknown pieces root")
+	errMissingLayers   = errors.New("torrent has missing piece layers")
+	errFileTreeTooDeep = errors.New("file tree is too deep")
+)
+
+// PieceLayer is the layer of the merkle tree of a file whose nodes are the hashes of pieces (BEP 52).
+type PieceLayer struct {
+	PiecesRoot []byte
+	NumPieces  uint32
+	// Concatenated SHA-256 hashes of pieces. Nil until the layer is known.
+	Hashes []byte
+}
+
+// fileV2 is a file in the file tree of a v2 torrent.
+type fileV2 struct {
+	path       []string
+	length     int64
+	root       []byte
+	firstPiece uint32
+	numPieces  uint32
+	layer      int // index in Info.PieceLayers, -1 if the file fits in a single piece
+}
+
+type fileTreeLeaf struct {
+	Length     int64  `bencode:"length"`
+	PiecesRoot []byte `bencode:"pieces root,omitempty"`
+}
+
+// parseFileTree appends the files in the tree to files in the order of keys.
+func parseFileTree(raw bencode.RawMessage, path []string, files *[]fileV2) error {
+	if len(path) > 64 {
+		return errFileTreeTooDeep
+	}
+	var m map[string]bencode.RawMessage
+	if err := bencode.DecodeBytes(raw, &m); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if k == "" {
+			var leaf fileTreeLeaf
+			if err := bencode.DecodeBytes(m[k], &leaf); err != nil {
+				return err
+			}
+			if len(path) == 0 || leaf.Length < 0 {
+				return errInvalidPieceData
+			}
+			if leaf.Length > 0 && len(leaf.PiecesRoot) != merkle.HashSize {
+				return errInvalidRoot
+			}
+			*files = append(*files, fileV2{path: path, length: leaf.Length, root: leaf.PiecesRoot})
+			continue
+		}
+		if strings.TrimSpace(k) == ".." {
+			return fmt.Errorf("invalid file name: %q", filepath.Join(append(path, k)...))
+		}
+		p := make([]string, len(path), len(path)+1)
+		copy(p, path)
+		if err := parseFileTree(m[k], append(p, k), files); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newInfoV2(b []byte, ib *infoType, utf8 bool, pad bool) (*Info, error) {
+	if ib.PieceLength < merkle.BlockSize || ib.PieceLength&(ib.PieceLength-1) != 0 {
+		return nil, errPieceLengthV2
+	}
+	var files []fileV2
+	if err := parseFileTree(ib.FileTree, nil, &files); err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, errEmptyFileTree
+	}
+	var layers []PieceLayer
+	var numPieces uint32
+	for j := range files {
+		f := &files[j]
+		f.firstPiece = numPieces
+		f.numPieces = uint32((f.length + int64(ib.PieceLength) - 1) / int64(ib.PieceLength))
+		numPieces += f.numPieces
+		f.layer = -1
+		if f.numPieces > 1 {
+			f.layer = len(layers)
+			layers = append(layers, PieceLayer{PiecesRoot: f.root, NumPieces: f.numPieces})
+		}
+	}
+	if numPieces == 0 {
+		return nil, errZeroPieces
+	}
+	hashV2 := sha256.Sum256(b)
+
+	var i *Info
+	if len(ib.Pieces) > 0 {
+		// Hybrid torrent. Files are laid out as in the v1 part, which has padding files for aligning files to pieces.
+		var err error
+		i, err = newInfoV1(b, ib, utf8, pad)
+		if err != nil {
+			return nil, err
+		}
+		if i.NumPieces != numPieces {
+			return nil, errHybridMismatch
+		}
+	} else {
+		if utf8 && len(ib.NameUTF8) > 0 {
+			ib.Name = ib.NameUTF8
+		}
+		i = &Info{
+			PieceLength: ib.PieceLength,
+			NumPieces:   numPieces,
+			Name:        ib.Name,
+			Private:     parsePrivateField(ib.Private),
+			Bytes:       b,
+		}
+		copy(i.Hash[:], hashV2[:])
+		if i.Name == "" {
+			i.Name = hex.EncodeToString(i.Hash[:])
+		}
+		var err error
+		i.Files, err = alignFiles(files, cleanName(i.Name), ib.PieceLength)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range i.Files {
+			i.Length += f.Length
+		}
+	}
+	i.V2 = true
+	i.HashV2 = hashV2
+	i.filesV2 = files
+	i.PieceLayers = layers
+	return i, nil
+}
+
+// alignFiles returns the files of a v2 torrent with padding files between them, so every file starts at a piece boundary.
+func alignFiles(files []fileV2, name string, pieceLength uint32) ([]File, error) {
+	if len(files) == 1 && len(files[0].path) == 1 {
+		return []File{{Path: name, Length: files[0].length}}, nil
+	}
+	ret := make([]File, 0, 2*len(files))
+	uniquePaths := make(map[string]struct{}, len(files))
+	for j, f := range files {
+		parts := make([]string, 0, len(f.path)+1)
+		parts = append(parts, name)
+		for _, p := range f.path {
+			parts = append(parts, cleanName(p))
+		}
+		joinedPath := filepath.Join(parts...)
+		if _, ok := uniquePaths[joinedPath]; ok {
+			return nil, fmt.Errorf("duplicate file name: %q", joinedPath)
+		}
+		uniquePaths[joinedPath] = struct{}{}
+		ret = append(ret, File{Path: joinedPath, Length: f.length})
+		if rem := f.length % int64(pieceLength); rem != 0 && j < len(files)-1 {
+			padding := int64(pieceLength) - rem
+			ret = append(ret, File{
+				Path:    filepath.Join(name, ".pad", strconv.FormatInt(padding, 10)),
+				Length:  padding,
+				Padding: true,
+			})
+		}
+	}
+	return ret, nil
+}
+
+// fileV2Of returns the file that contains the piece at index.
+func (i *Info) fileV2Of(index uint32) *fileV2 {
+	j := sort.Search(len(i.filesV2), func(j int) bool {
+		f := &i.filesV2[j]
+		return f.firstPiece+f.numPieces > index
+	})
+	return &i.filesV2[j]
+}
+
+func (i *Info) pieceHashV2(index uint32) []byte {
+	f := i.fileV2Of(index)
+	if f.layer == -1 {
+		return f.root
+	}
+	hashes := i.PieceLayers[f.layer].Hashes
+	if hashes == nil {
+		return nil
+	}
+	begin := (index - f.firstPiece) * merkle.HashSize
+	return hashes[begin : begin+merkle.HashSize]
+}
+
+func (i *Info) pieceTreeLeaves(index uint32) int {
+	f := i.fileV2Of(index)
+	if f.layer == -1 {
+		// Tree of a file that is not larger than a piece is not padded to the piece length.
+		numBlocks := (f.length + merkle.BlockSize - 1) / merkle.BlockSize
+		return merkle.NextPowerOfTwo(int(numBlocks))
+	}
+	return int(i.PieceLength / merkle.BlockSize)
+}
+
+// PieceLayer returns the piece layer of the file with pieces root.
+func (i *Info) PieceLayer(root []byte) *PieceLayer {
+	for j := range i.PieceLayers {
+		if bytes.Equal(i.PieceLayers[j].PiecesRoot, root) {
+			return &i.PieceLayers[j]
+		}
+	}
+	return nil
+}
+
+// SetPieceLayer verifies the hashes against the pieces root and sets the piece layer of the file.
+func (i *Info) SetPieceLayer(root, hashes []byte) error {
+	l := i.PieceLayer(root)
+	if l == nil {
+		return errUnknownLayer
+	}
+	if len(hashes) != int(l.NumPieces)*merkle.HashSize {
+		return errInvalidLayer
+	}
+	width := merkle.NextPowerOfTwo(int(l.NumPieces))
+	pad := merkle.PadHash(int(i.PieceLength / merkle.BlockSize))
+	if !bytes.Equal(merkle.RootOfLayer(hashes, width, pad), root) {
+		return errInvalidLayer
+	}
+	l.Hashes = hashes
+	return nil
+}
+
+// SetPieceLayers sets the piece layers from the bencoded "piece layers" dictionary in torrent file.
+func (i *Info) SetPieceLayers(b []byte) error {
+	var m map[string][]byte
+	if err := bencode.DecodeBytes(b, &m); err != nil {
+		return err
+	}
+	for j := range i.PieceLayers {
+		root := i.PieceLayers[j].PiecesRoot
+		hashes, ok := m[string(root)]
+		if !ok {
+			continue
+		}
+		if err := i.SetPieceLayer(root, hashes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HasPieceLayers returns true if all pieces of the torrent can be verified.
+// Pieces of v2 torrents cannot be verified until piece layers are known.
+func (i *Info) HasPieceLayers() bool {
+	if i.V1 {
+		return true
+	}
+	for _, l := range i.PieceLayers {
+		if l.Hashes == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// PieceLayersBytes returns the known piece layers as bencoded dictionary.
+// It returns nil if no piece layer is known.
+func (i *Info) PieceLayersBytes() []byte {
+	m := make(map[string][]byte)
+	for _, l := range i.PieceLayers {
+		if l.Hashes != nil {
+			m[string(l.PiecesRoot)] = l.Hashes
+		}
+	}
+	if len(m) == 0 {
+		return nil
+	}
+	b, _ := bencode.EncodeBytes(m)
+	return b
+}
+
+// diskFile is a file on the disk that is going to be put into a new torrent.
+type diskFile struct {
+	path string
+	rel  []string
+}
+
+// NewInfoBytesV2 creates a new v2 info dictionary by reading and hashing the files on the disk.
+// If hybrid is true, the dictionary also contains the v1 fields, so the torrent can be downloaded by v1 clients too.
+// The returned piece layers must be put into the torrent file together with the info dictionary.
+func NewInfoBytesV2(root string, paths []string, private bool, pieceLength uint32, name string, hybrid bool, log logger.Logger) (info, pieceLayers []byte, err error) {
+	name, singleFileTorrent, err := checkPaths(root, paths, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	totalLength, err := findTotalLength(paths)
+	if err != nil {
+		return nil, nil, err
+	}
+	if totalLength == 0 {
+		return nil, nil, errors.New("no files")
+	}
+	if pieceLength == 0 {
+		pieceLength = calculatePieceLength(totalLength)
+		log.Infof("Calculated piece length: %d K", pieceLength>>10)
+	} else if pieceLength < merkle.BlockSize || pieceLength&(pieceLength-1) != 0 {
+		return nil, nil, errPieceLengthV2
+	}
+	files, err := findFiles(root, paths, name, singleFileTorrent)
+	if err != nil {
+		return nil, nil, err
+	}
+	buf := make([]byte, pieceLength)
+	tree := make(map[string]any)
+	layers := make(map[string][]byte)
+	var v1Files []file
+	var v1Pieces *[]byte
+	if hybrid {
+		v1Pieces = new([]byte)
+	}
+	for j, f := range files {
+		log.Infof("Adding %q", filepath.Join(f.rel...))
+		last := j == len(files)-1
+		length, root, layer, err := hashFileV2(f.path, buf, v1Pieces, !last)
+		if err != nil {
+			return nil, nil, err
+		}
+		addToFileTree(tree, f.rel, fileTreeLeaf{Length: length, PiecesRoot: root})
+		if layer != nil {
+			layers[string(root)] = layer
+		}
+		v1Files = append(v1Files, file{Path: f.rel, Length: length})
+		if rem := length % int64(pieceLength); rem != 0 && !last {
+			padding := int64(pieceLength) - rem
+			v1Files = append(v1Files, file{Path: []string{".pad", strconv.FormatInt(padding, 10)}, Length: padding, Attr: "p"})
+		}
+	}
+	b := struct {
+		Name        string         `bencode:"name"`
+		Private     bool           `bencode:"private"`
+		PieceLength uint32         `bencode:"piece length"`
+		MetaVersion int            `bencode:"meta version"`
+		FileTree    map[string]any `bencode:"file tree"`
+		Pieces      []byte         `bencode:"pieces,omitempty"`
+		Length      int64          `bencode:"length,omitempty"` // Single File Mode
+		Files       []file         `bencode:"files,omitempty"`  // Multiple File mode
+	}{
+		Name:        name,
+		Private:     private,
+		PieceLength: pieceLength,
+		MetaVersion: 2,
+		FileTree:    tree,
+	}
+	if hybrid {
+		b.Pieces = *v1Pieces
+		if singleFileTorrent {
+			b.Length = totalLength
+		} else {
+			b.Files = v1Files
+		}
+	}
+	info, err = bencode.EncodeBytes(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(layers) > 0 {
+		pieceLayers, err = bencode.EncodeBytes(layers)
+	}
+	return info, pieceLayers, err
+}
+
+// findFiles returns the files under paths in the order of v2 file tree.
+func findFiles(root string, paths []string, name string, singleFileTorrent bool) ([]diskFile, error) {
+	if singleFileTorrent {
+		return []diskFile{{path: paths[0], rel: []string{name}}}, nil
+	}
+	var files []diskFile
+	for _, path := range paths {
+		relroot := path
+		if root != "" {
+			relroot = root
+		}
+		err := filepath.Walk(path, func(vpath string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if fi.IsDir() {
+				return nil
+			}
+			relpath, err := filepath.Rel(relroot, vpath)
+			if err != nil {
+				return err
+			}
+			files = append(files, diskFile{path: vpath, rel: strings.Split(relpath, string(os.PathSeparator))})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		a, b := files[i].rel, files[j].rel
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	return files, nil
+}
+
+// hashFileV2 returns the length, the pieces root and the piece layer of the file at path.
+// The piece layer is nil if the file is not larger than a piece.
+// If v1Pieces is not nil, SHA-1 hashes of pieces are appended to it.
+// The last piece is padded with zeros before hashing if pad is true.
+func hashFileV2(path string, buf []byte, v1Pieces *[]byte, pad bool) (length int64, root, layer []byte, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	numLeaves := len(buf) / merkle.BlockSize
+	var n int
+	for {
+		n, err = io.ReadFull(f, buf)
+		if err == io.EOF {
+			err = nil
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return
+		}
+		err = nil
+		length += int64(n)
+		layer = append(layer, merkle.Root(buf[:n], numLeaves)...)
+		if v1Pieces != nil {
+			data := buf[:n]
+			if pad {
+				clear(buf[n:])
+				data = buf
+			}
+			sum := sha1.Sum(data)
+			*v1Pieces = append(*v1Pieces, sum[:]...)
+		}
+		if n < len(buf) {
+			break
+		}
+	}
+	switch numPieces := len(layer) / merkle.HashSize; numPieces {
+	case 0:
+		return 0, nil, nil, nil
+	case 1:
+		numBlocks := (length + merkle.BlockSize - 1) / merkle.BlockSize
+		return length, merkle.Root(buf[:length], merkle.NextPowerOfTwo(int(numBlocks))), nil, nil
+	default:
+		root = merkle.RootOfLayer(layer, merkle.NextPowerOfTwo(numPieces), merkle.PadHash(numLeaves))
+		return length, root, layer, nil
+	}
+}
+
+func addToFileTree(tree map[string]any, path []string, leaf fileTreeLeaf) {
+	for _, p := range path {
+		sub, ok := tree[p].(map[string]any)
+		if !ok {
+			sub = make(map[string]any)
+			tree[p] = sub
+		}
+		tree = sub
+	}
+	tree[""] = leaf
+}
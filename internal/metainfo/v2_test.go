@@ -0,0 +1,123 @@
+package metainfo
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cenkalti/rain/internal/logger"
+	"github.com/cenkalti/rain/internal/merkle"
+	"github.com/stretchr/testify/assert"
+)
+
+const testPieceLength = 32 << 10
+
+func writeTestFiles(t *testing.T) (dir string, data map[string][]byte) {
+	dir = t.TempDir()
+	data = map[string][]byte{
+		"a":                       bytes.Repeat([]byte{'a'}, 100<<10),
+		"b":                       bytes.Repeat([]byte{'b'}, 10<<10),
+		filepath.Join("dir", "c"): bytes.Repeat([]byte{'c'}, 40<<10),
+	}
+	for name, b := range data {
+		path := filepath.Join(dir, "files", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, b, 0640); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filepath.Join(dir, "files"), data
+}
+
+func newTestTorrentV2(t *testing.T, hybrid bool) (*MetaInfo, map[string][]byte) {
+	dir, data := writeTestFiles(t)
+	info, layers, err := NewInfoBytesV2("", []string{dir}, false, testPieceLength, "", hybrid, logger.New("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewBytes(info, layers, nil, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mi, err := New(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = New(bytes.NewReader(mustNewBytes(t, info)))
+	if hybrid {
+		assert.NoError(t, err)
+	} else {
+		assert.Equal(t, errMissingLayers, err)
+	}
+	return mi, data
+}
+
+func mustNewBytes(t *testing.T, info []byte) []byte {
+	b, err := NewBytes(info, nil, nil, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestNewInfoBytesV2(t *testing.T) {
+	mi, data := newTestTorrentV2(t, false)
+	info := mi.Info
+	assert.False(t, info.V1)
+	assert.True(t, info.V2)
+	assert.Equal(t, info.HashV2[:20], info.Hash[:])
+	// Files are ordered by path and aligned to pieces: a (4 pieces), b (1 piece), dir/c (2 pieces).
+	assert.Equal(t, uint32(7), info.NumPieces)
+	assert.Len(t, info.PieceLayers, 2)
+	assert.True(t, info.HasPieceLayers())
+	var paths []string
+	for _, f := range info.Files {
+		if !f.Padding {
+			paths = append(paths, f.Path)
+		}
+	}
+	assert.Equal(t, []string{filepath.Join("files", "a"), filepath.Join("files", "b"), filepath.Join("files", "dir", "c")}, paths)
+	assert.Equal(t, int64(7*testPieceLength-(testPieceLength-8<<10)), info.Length)
+
+	a := data["a"]
+	assert.Equal(t, merkle.Root(a[:testPieceLength], 2), info.PieceHash(0))
+	assert.Equal(t, merkle.Root(a[3*testPieceLength:], 2), info.PieceHash(3))
+	assert.Equal(t, 2, info.PieceTreeLeaves(3))
+	// Tree of a file that fits in a piece is not padded to the piece length.
+	assert.Equal(t, merkle.Root(data["b"], 1), info.PieceHash(4))
+	assert.Equal(t, 1, info.PieceTreeLeaves(4))
+
+	// Piece layers can be set again from their serialized form.
+	info2, err := NewInfo(info.Bytes, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, info2.HasPieceLayers())
+	assert.NoError(t, info2.SetPieceLayers(info.PieceLayersBytes()))
+	assert.True(t, info2.HasPieceLayers())
+	assert.Equal(t, info.PieceHash(2), info2.PieceHash(2))
+
+	hashes := append([]byte{}, info.PieceLayers[0].Hashes...)
+	hashes[0]++
+	assert.Equal(t, errInvalidLayer, info2.SetPieceLayer(info.PieceLayers[0].PiecesRoot, hashes))
+}
+
+func TestNewInfoBytesHybrid(t *testing.T) {
+	mi, data := newTestTorrentV2(t, true)
+	info := mi.Info
+	assert.True(t, info.V1)
+	assert.True(t, info.V2)
+	assert.Equal(t, sha1.Sum(info.Bytes), info.Hash)
+	assert.Equal(t, uint32(7), info.NumPieces)
+	assert.Equal(t, 0, info.PieceTreeLeaves(0))
+	// Last piece of a file is padded with zeros in v1 part.
+	last := make([]byte, testPieceLength)
+	copy(last, data["a"][3*testPieceLength:])
+	sum := sha1.Sum(last)
+	assert.Equal(t, sum[:], info.PieceHash(3))
+	assert.Equal(t, merkle.Root(data["a"][3*testPieceLength:], 2), info.PieceLayers[0].Hashes[3*merkle.HashSize:4*merkle.HashSize])
+}
@@ -0,0 +1,59 @@
+package mover
+
+import (
+	"os"
+
+	"github.com/cenkalti/rain/internal/storage/filestorage"
+)
+
+// Mover moves the files of a completed torrent to their final location.
+type Mover struct {
+	// Storage at the new location. Nil if the files are not moved.
+	Storage *filestorage.FileStorage
+	Error   error
+
+	closeC chan struct{}
+	doneC  chan struct{}
+}
+
+// New returns a new Mover.
+func New() *Mover {
+	return &Mover{
+		closeC: make(chan struct{}),
+		doneC:  make(chan struct{}),
+	}
+}
+
+// Close the Mover.
+func (m *Mover) Close() {
+	close(m.closeC)
+	<-m.doneC
+}
+
+// Run the Mover.
+// Part suffixes of the files are removed first, then the entry at name is moved into dest.
+// If dest is empty, files are not moved.
+// If removeRoot is true, root directory of sto is removed after moving if it is empty.
+func (m *Mover) Run(sto *filestorage.FileStorage, files []string, name, dest string, removeRoot bool, resultC chan *Mover) {
+	defer close(m.doneC)
+
+	defer func() {
+		select {
+		case resultC <- m:
+		case <-m.closeC:
+		}
+	}()
+
+	m.Error = sto.RemovePartSuffix(files)
+	if m.Error != nil || dest == "" {
+		return
+	}
+	m.Storage, m.Error = sto.Move(name, dest, m.closeC)
+	if m.Error != nil {
+		return
+	}
+	if removeRoot && m.Storage.RootDir() != sto.RootDir() {
+		// Fails if the directory is not empty.
+		_ = os.Remove(sto.RootDir())
+	}
+}
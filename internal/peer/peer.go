@@ -38,6 +38,7 @@ type Peer struct {
 	ExtensionsEnabled bool
 	FastEnabled       bool
 	DHTEnabled        bool
+	V2Enabled         bool
 	EncryptionCipher  mse.CryptoMethod
 
 	ClientInterested bool
@@ -52,6 +53,9 @@ type Peer struct {
 
 	Downloading bool
 
+	// UploadOnly means peer is not interested in downloading more pieces (BEP 21).
+	UploadOnly bool
+
 	downloadSpeed metrics.Meter
 	uploadSpeed   metrics.Meter
 
@@ -62,6 +66,11 @@ type Peer struct {
 
 	PEX *pex
 
+	// Tracker URLs that are sent to the peer with tracker exchange extension.
+	TEXSent map[string]struct{}
+	// Time of the last tracker exchange message received from the peer.
+	TEXReceivedAt time.Time
+
 	snubTimeout time.Duration
 	snubTimer   *time.Timer
 
@@ -89,6 +98,7 @@ func New(conn net.Conn, source peersource.Source, id [20]byte, extensions [8]byt
 	fastEnabled := bf.Test(61)
 	extensionsEnabled := bf.Test(43)
 	dhtEnabled := bf.Test(63)
+	v2Enabled := bf.Test(59)
 
 	t := time.NewTimer(math.MaxInt64)
 	t.Stop()
@@ -102,6 +112,7 @@ func New(conn net.Conn, source peersource.Source, id [20]byte, extensions [8]byt
 		ExtensionsEnabled: extensionsEnabled,
 		FastEnabled:       fastEnabled,
 		DHTEnabled:        dhtEnabled,
+		V2Enabled:         v2Enabled,
 		EncryptionCipher:  cipher,
 		snubTimeout:       snubTimeout,
 		snubTimer:         t,
@@ -209,6 +220,15 @@ func (p *Peer) UploadSpeed() int {
 	return int(p.uploadSpeed.Rate1())
 }
 
+// HavePieces returns the number of pieces that the Peer has and the total number of pieces.
+// Both are zero if the torrent info is not known yet.
+func (p *Peer) HavePieces() (have, total uint32) {
+	if p.Bitfield == nil {
+		return 0, 0
+	}
+	return p.Bitfield.Count(), p.Bitfield.Len()
+}
+
 // Choke the connected Peer by sending a "choke" protocol message.
 func (p *Peer) Choke() {
 	p.ClientChoking = true
@@ -227,8 +247,9 @@ func (p *Peer) Choking() bool {
 }
 
 // Interested returns true if remote Peer is interested for pieces we have.
+// Upload-only peers are not counted as interested because they are not going to download more pieces.
 func (p *Peer) Interested() bool {
-	return p.PeerInterested
+	return p.PeerInterested && !p.UploadOnly
 }
 
 // Optimistic returns true if we are unchoking the Peer optimistically.
@@ -76,8 +76,8 @@ func (p *Conn) SendMessage(msg peerprotocol.Message) {
 // SendPiece queues a piece message for sending. Does not block.
 // Piece data is read just before the message is sent.
 // If queued messages greater than `maxRequestsIn` specified in constructor, the last message is dropped.
-func (p *Conn) SendPiece(msg peerprotocol.RequestMessage, pi io.ReaderAt) {
-	p.writer.SendPiece(msg, pi)
+func (p *Conn) SendPiece(msg peerprotocol.RequestMessage, pi io.ReaderAt, hashes []byte) {
+	p.writer.SendPiece(msg, pi, hashes)
 }
 
 // CancelRequest removes previously queued piece message matching msg.
@@ -10,4 +10,6 @@ import (
 type Piece struct {
 	peerprotocol.PieceMessage
 	Buffer bufferpool.Buffer
+	// Merkle tree nodes that are received with the first block of a piece in merkle torrents (BEP 30).
+	Hashes map[uint32][]byte
 }
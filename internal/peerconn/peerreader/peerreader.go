@@ -23,6 +23,10 @@ const (
 	readTimeout = 2 * time.Minute
 	// length + msgid + requestmsg
 	readBufferSize = 4 + 1 + 12
+	// 512 hashes at most can be requested in a message, followed by the uncle hashes.
+	maxHashesLength = (512 + 32) * 32
+	// Hash list in merkle piece messages contains 2 nodes for each level of the tree.
+	maxHashListLength = 64 * 64
 )
 
 var blockPool = bufferpool.New(piece.BlockSize)
@@ -186,13 +190,20 @@ func (p *PeerReader) Run() {
 				return
 			}
 			msg = cm
-		case peerprotocol.Piece:
+		case peerprotocol.Piece, peerprotocol.MerklePiece:
 			var pm peerprotocol.PieceMessage
 			err = binary.Read(p.r, binary.BigEndian, &pm)
 			if err != nil {
 				return
 			}
 			length -= 8
+			var hashes map[uint32][]byte
+			if id == peerprotocol.MerklePiece {
+				hashes, length, err = p.readHashList(length)
+				if err != nil {
+					return
+				}
+			}
 			if length > piece.BlockSize {
 				err = &blockSizeError{
 					messageID:  id,
@@ -206,7 +217,7 @@ func (p *PeerReader) Run() {
 			if err != nil {
 				return
 			}
-			msg = Piece{PieceMessage: pm, Buffer: buf}
+			msg = Piece{PieceMessage: pm, Buffer: buf, Hashes: hashes}
 		case peerprotocol.HaveAll:
 			msg = peerprotocol.HaveAllMessage{}
 		case peerprotocol.HaveNone:
@@ -225,6 +236,37 @@ func (p *PeerReader) Run() {
 				return
 			}
 			msg = pm
+		case peerprotocol.HashRequest:
+			var hm peerprotocol.HashRequestMessage
+			err = binary.Read(p.r, binary.BigEndian, &hm)
+			if err != nil {
+				return
+			}
+			msg = hm
+		case peerprotocol.HashReject:
+			var hm peerprotocol.HashRejectMessage
+			err = binary.Read(p.r, binary.BigEndian, &hm)
+			if err != nil {
+				return
+			}
+			msg = hm
+		case peerprotocol.Hashes:
+			if length < 48 || length-48 > maxHashesLength || (length-48)%32 != 0 {
+				err = fmt.Errorf("invalid hashes message length: %d", length)
+				return
+			}
+			var hm peerprotocol.HashesMessage
+			err = binary.Read(p.r, binary.BigEndian, &hm.HashRequestMessage)
+			if err != nil {
+				return
+			}
+			length -= 48
+			hm.Hashes = make([]byte, length)
+			_, err = io.ReadFull(p.r, hm.Hashes)
+			if err != nil {
+				return
+			}
+			msg = hm
 		case peerprotocol.Extension:
 			buf := make([]byte, length)
 			_, err = io.ReadFull(p.r, buf)
@@ -301,6 +343,29 @@ func (p *PeerReader) readPiece(length uint32) (buf bufferpool.Buffer, err error)
 	}
 }
 
+// readHashList reads the hash list that precedes the block in merkle piece messages.
+// It returns the remaining length of the message.
+func (p *PeerReader) readHashList(length uint32) (map[uint32][]byte, uint32, error) {
+	var n uint32
+	err := binary.Read(p.r, binary.BigEndian, &n)
+	if err != nil {
+		return nil, 0, err
+	}
+	if length < 4 || n > length-4 || n > maxHashListLength {
+		return nil, 0, fmt.Errorf("invalid hash list length: %d", n)
+	}
+	b := make([]byte, n)
+	_, err = io.ReadFull(p.r, b)
+	if err != nil {
+		return nil, 0, err
+	}
+	hashes, err := peerprotocol.UnmarshalHashList(b)
+	if err != nil {
+		return nil, 0, err
+	}
+	return hashes, length - 4 - n, nil
+}
+
 var errStoppedWhileWaitingBucket = errors.New("peer reader stopped while waiting for bucket")
 
 type blockSizeError struct {
@@ -69,8 +69,9 @@ func (p *PeerWriter) SendMessage(msg peerprotocol.Message) {
 // SendPiece is used to send a "piece" message to the Peer.
 // Data is not read when the method is called.
 // Data is read by the run loop when writing the piece message.
-func (p *PeerWriter) SendPiece(msg peerprotocol.RequestMessage, pi io.ReaderAt) {
-	m := Piece{Data: pi, RequestMessage: msg}
+// If hashes is not nil, it is sent before the data in a merkle piece message.
+func (p *PeerWriter) SendPiece(msg peerprotocol.RequestMessage, pi io.ReaderAt, hashes []byte) {
+	m := Piece{Data: pi, RequestMessage: msg, Hashes: hashes}
 	select {
 	case p.queueC <- m:
 	case <-p.doneC:
@@ -212,6 +213,11 @@ func (p *PeerWriter) messageWriter() {
 			// Reserve space for length and message ID
 			buf.Write([]byte{0, 0, 0, 0, 0})
 
+			// Hash list does not fit into the space that is reserved for a piece message.
+			if pi, ok := msg.(Piece); ok && pi.Hashes != nil {
+				buf.Grow(8 + pi.hashListLength() + int(pi.Length))
+			}
+
 			var m int64
 			if wt, ok := msg.(io.WriterTo); ok {
 				m, err = wt.WriteTo(buf)
@@ -243,8 +249,8 @@ func (p *PeerWriter) messageWriter() {
 			}
 
 			n, err := p.conn.Write(buf.Bytes())
-			if _, ok := msg.(Piece); ok {
-				p.countUploadBytes(n)
+			if pi, ok := msg.(Piece); ok {
+				p.countUploadBytes(n - pi.hashListLength())
 			}
 			if _, ok := err.(*net.OpError); ok {
 				p.log.Debugf("cannot write message [%v]: %s", msg.ID(), err.Error())
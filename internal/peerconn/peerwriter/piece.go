@@ -11,19 +11,39 @@ import (
 type Piece struct {
 	Data io.ReaderAt
 	peerprotocol.RequestMessage
+	// Bencoded hash list that is sent before the block in merkle torrents (BEP 30).
+	Hashes []byte
 }
 
 // ID returns the BitTorrent protocol message ID.
-func (p Piece) ID() peerprotocol.MessageID { return peerprotocol.Piece }
+func (p Piece) ID() peerprotocol.MessageID {
+	if p.Hashes != nil {
+		return peerprotocol.MerklePiece
+	}
+	return peerprotocol.Piece
+}
 
 // Read piece data.
 func (p Piece) Read(b []byte) (int, error) {
 	binary.BigEndian.PutUint32(b[0:4], p.Index)
 	binary.BigEndian.PutUint32(b[4:8], p.Begin)
-	n, err := p.Data.ReadAt(b[8:8+p.Length], int64(p.Begin))
-	m := n + 8
+	m := 8
+	if p.Hashes != nil {
+		binary.BigEndian.PutUint32(b[8:12], uint32(len(p.Hashes)))
+		m += 4 + copy(b[12:], p.Hashes)
+	}
+	n, err := p.Data.ReadAt(b[m:m+int(p.Length)], int64(p.Begin))
+	m += n
 	if err != nil {
 		return m, err
 	}
 	return m, io.EOF
 }
+
+// hashListLength returns the number of bytes in message that is used for sending the hash list.
+func (p Piece) hashListLength() int {
+	if p.Hashes == nil {
+		return 0
+	}
+	return 4 + len(p.Hashes)
+}
@@ -2,6 +2,7 @@ package peerprotocol
 
 import (
 	"bytes"
+	"encoding"
 	"encoding/binary"
 	"fmt"
 	"io"
@@ -17,6 +18,14 @@ const (
 	ExtensionIDMetadata
 	// ExtensionIDPEX is ID for PEX extension messages.
 	ExtensionIDPEX
+	// ExtensionIDHolepunch is ID for holepunch extension messages.
+	ExtensionIDHolepunch
+	// ExtensionIDUploadOnly is ID for upload_only extension messages.
+	ExtensionIDUploadOnly
+	// ExtensionIDDontHave is ID for lt_donthave extension messages.
+	ExtensionIDDontHave
+	// ExtensionIDTEX is ID for tracker exchange extension messages.
+	ExtensionIDTEX
 )
 
 const (
@@ -24,6 +33,14 @@ const (
 	ExtensionKeyMetadata = "ut_metadata"
 	// ExtensionKeyPEX is the key for the PEX extension.
 	ExtensionKeyPEX = "ut_pex"
+	// ExtensionKeyHolepunch is the key for the holepunch extension.
+	ExtensionKeyHolepunch = "ut_holepunch"
+	// ExtensionKeyUploadOnly is the key for the upload_only extension.
+	ExtensionKeyUploadOnly = "upload_only"
+	// ExtensionKeyDontHave is the key for the lt_donthave extension.
+	ExtensionKeyDontHave = "lt_donthave"
+	// ExtensionKeyTEX is the key for the tracker exchange extension.
+	ExtensionKeyTEX = "lt_tex"
 )
 
 const (
@@ -56,6 +73,16 @@ func (m ExtensionMessage) WriteTo(w io.Writer) (n int64, err error) {
 	if err != nil {
 		return
 	}
+	if bm, ok := m.Payload.(encoding.BinaryMarshaler); ok {
+		var b []byte
+		b, err = bm.MarshalBinary()
+		if err != nil {
+			return
+		}
+		nn, err = w.Write(b)
+		n += int64(nn)
+		return
+	}
 	wc := newWriterCounter(w)
 	err = bencode.NewEncoder(wc).Encode(m.Payload)
 	n += wc.Count()
@@ -100,6 +127,22 @@ func (m *ExtensionMessage) UnmarshalBinary(data []byte) error {
 		var extMsg ExtensionPEXMessage
 		err = dec.Decode(&extMsg)
 		m.Payload = extMsg
+	case ExtensionIDHolepunch:
+		var extMsg ExtensionHolepunchMessage
+		err = extMsg.UnmarshalBinary(payload)
+		m.Payload = extMsg
+	case ExtensionIDTEX:
+		var extMsg ExtensionTEXMessage
+		err = dec.Decode(&extMsg)
+		m.Payload = extMsg
+	case ExtensionIDUploadOnly:
+		var extMsg ExtensionUploadOnlyMessage
+		err = extMsg.UnmarshalBinary(payload)
+		m.Payload = extMsg
+	case ExtensionIDDontHave:
+		var extMsg ExtensionDontHaveMessage
+		err = extMsg.UnmarshalBinary(payload)
+		m.Payload = extMsg
 	default:
 		return fmt.Errorf("peer sent invalid extension message id: %d", m.ExtendedMessageID)
 	}
@@ -111,16 +154,23 @@ type ExtensionHandshakeMessage struct {
 	M            map[string]uint8 `bencode:"m"`
 	V            string           `bencode:"v"`
 	YourIP       string           `bencode:"yourip,omitempty"`
+	Port         int              `bencode:"p,omitempty"`
 	MetadataSize int              `bencode:"metadata_size,omitempty"`
 	RequestQueue int              `bencode:"reqq"`
+	UploadOnly   int              `bencode:"upload_only,omitempty"`
+	TrackersHash string           `bencode:"tr,omitempty"`
 }
 
 // NewExtensionHandshake returns a new ExtensionHandshakeMessage by filling the struct with given values.
 func NewExtensionHandshake(metadataSize uint32, version string, yourip net.IP, requestQueueLength int) ExtensionHandshakeMessage {
 	return ExtensionHandshakeMessage{
 		M: map[string]uint8{
-			ExtensionKeyMetadata: ExtensionIDMetadata,
-			ExtensionKeyPEX:      ExtensionIDPEX,
+			ExtensionKeyMetadata:   ExtensionIDMetadata,
+			ExtensionKeyPEX:        ExtensionIDPEX,
+			ExtensionKeyHolepunch:  ExtensionIDHolepunch,
+			ExtensionKeyUploadOnly: ExtensionIDUploadOnly,
+			ExtensionKeyDontHave:   ExtensionIDDontHave,
+			ExtensionKeyTEX:        ExtensionIDTEX,
 		},
 		V:            version,
 		YourIP:       string(truncateIP(yourip)),
@@ -143,6 +193,11 @@ type ExtensionPEXMessage struct {
 	Dropped string `bencode:"dropped"`
 }
 
+// ExtensionTEXMessage is the message for the tracker exchange extension (BEP 28).
+type ExtensionTEXMessage struct {
+	Added []string `bencode:"added"`
+}
+
 func truncateIP(ip net.IP) net.IP {
 	ip4 := ip.To4()
 	if ip4 != nil {
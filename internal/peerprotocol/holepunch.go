@@ -0,0 +1,84 @@
+package peerprotocol
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+)
+
+const (
+	// HolepunchRendezvous is sent to a relay peer to ask it to introduce us to the target peer.
+	HolepunchRendezvous = iota
+	// HolepunchConnect is sent by the relay peer to both peers to make them connect each other at the same time.
+	HolepunchConnect
+	// HolepunchError is sent by the relay peer when it cannot relay the rendezvous message.
+	HolepunchError
+)
+
+const (
+	// HolepunchNoSuchPeer means the target peer is not connected to the relay.
+	HolepunchNoSuchPeer = iota + 1
+	// HolepunchNotConnected means the relay is no longer connected to the target peer.
+	HolepunchNotConnected
+	// HolepunchNoSupport means the target peer does not support the holepunch extension.
+	HolepunchNoSupport
+	// HolepunchNoSelf means the target is the sender of the rendezvous message.
+	HolepunchNoSelf
+)
+
+var errInvalidHolepunchMessage = errors.New("invalid holepunch message")
+
+// ExtensionHolepunchMessage is the message for the holepunch extension (BEP 55).
+// It is not bencoded.
+type ExtensionHolepunchMessage struct {
+	Type    uint8
+	Addr    *net.TCPAddr
+	ErrCode uint32
+}
+
+// MarshalBinary encodes the message.
+func (m ExtensionHolepunchMessage) MarshalBinary() ([]byte, error) {
+	ip := m.Addr.IP.To4()
+	addrType := byte(0)
+	if ip == nil {
+		ip = m.Addr.IP.To16()
+		addrType = 1
+	}
+	if ip == nil {
+		return nil, fmt.Errorf("invalid holepunch address: %s", m.Addr)
+	}
+	b := make([]byte, 0, 2+len(ip)+2+4)
+	b = append(b, m.Type, addrType)
+	b = append(b, ip...)
+	b = binary.BigEndian.AppendUint16(b, uint16(m.Addr.Port))
+	b = binary.BigEndian.AppendUint32(b, m.ErrCode)
+	return b, nil
+}
+
+// UnmarshalBinary decodes the message.
+func (m *ExtensionHolepunchMessage) UnmarshalBinary(data []byte) error {
+	if len(data) < 2 {
+		return errInvalidHolepunchMessage
+	}
+	m.Type = data[0]
+	var ipLen int
+	switch data[1] {
+	case 0:
+		ipLen = net.IPv4len
+	case 1:
+		ipLen = net.IPv6len
+	default:
+		return errInvalidHolepunchMessage
+	}
+	data = data[2:]
+	if len(data) < ipLen+2+4 {
+		return errInvalidHolepunchMessage
+	}
+	m.Addr = &net.TCPAddr{
+		IP:   net.IP(append([]byte(nil), data[:ipLen]...)),
+		Port: int(binary.BigEndian.Uint16(data[ipLen : ipLen+2])),
+	}
+	m.ErrCode = binary.BigEndian.Uint32(data[ipLen+2 : ipLen+6])
+	return nil
+}
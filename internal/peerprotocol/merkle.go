@@ -0,0 +1,49 @@
+package peerprotocol
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/zeebo/bencode"
+)
+
+var errInvalidHashList = errors.New("invalid hash list")
+
+// MarshalHashList encodes the merkle tree nodes that are sent with the first block of a piece in merkle torrents (BEP 30).
+// Nodes are encoded as a bencoded list of [node number, hash] pairs.
+func MarshalHashList(nodes map[uint32][]byte) ([]byte, error) {
+	keys := make([]uint32, 0, len(nodes))
+	for n := range nodes {
+		keys = append(keys, n)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	l := make([][]any, len(keys))
+	for i, n := range keys {
+		l[i] = []any{n, nodes[n]}
+	}
+	return bencode.EncodeBytes(l)
+}
+
+// UnmarshalHashList decodes the hash list in a merkle piece message.
+func UnmarshalHashList(b []byte) (map[uint32][]byte, error) {
+	var l [][]any
+	if err := bencode.DecodeBytes(b, &l); err != nil {
+		return nil, err
+	}
+	nodes := make(map[uint32][]byte, len(l))
+	for _, pair := range l {
+		if len(pair) != 2 {
+			return nil, errInvalidHashList
+		}
+		n, ok := pair[0].(int64)
+		if !ok || n < 0 || n > int64(^uint32(0)) {
+			return nil, errInvalidHashList
+		}
+		h, ok := pair[1].(string)
+		if !ok {
+			return nil, errInvalidHashList
+		}
+		nodes[uint32(n)] = []byte(h)
+	}
+	return nodes, nil
+}
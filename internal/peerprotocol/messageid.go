@@ -23,25 +23,33 @@ const (
 	Reject      = 16
 	AllowedFast = 17
 	Extension   = 20
+	HashRequest = 21
+	Hashes      = 22
+	HashReject  = 23
+	MerklePiece = 250 // Piece message with hash list in merkle torrents (BEP 30)
 )
 
 var messageIDStrings = map[MessageID]string{
-	0:  "choke",
-	1:  "unchoke",
-	2:  "interested",
-	3:  "not interested",
-	4:  "have",
-	5:  "bitfield",
-	6:  "request",
-	7:  "piece",
-	8:  "cancel",
-	9:  "port",
-	13: "suggest",
-	14: "have all",
-	15: "have none",
-	16: "reject",
-	17: "allowed fast",
-	20: "extension",
+	0:   "choke",
+	1:   "unchoke",
+	2:   "interested",
+	3:   "not interested",
+	4:   "have",
+	5:   "bitfield",
+	6:   "request",
+	7:   "piece",
+	8:   "cancel",
+	9:   "port",
+	13:  "suggest",
+	14:  "have all",
+	15:  "have none",
+	16:  "reject",
+	17:  "allowed fast",
+	20:  "extension",
+	21:  "hash request",
+	22:  "hashes",
+	23:  "hash reject",
+	250: "merkle piece",
 }
 
 func (m MessageID) String() string {
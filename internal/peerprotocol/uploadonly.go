@@ -0,0 +1,54 @@
+package peerprotocol
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	errInvalidUploadOnlyMessage = errors.New("invalid upload_only message")
+	errInvalidDontHaveMessage   = errors.New("invalid lt_donthave message")
+)
+
+// ExtensionUploadOnlyMessage is sent when the peer stops or starts downloading (BEP 21).
+// Payload is a single byte that is non-zero if the peer is not interested in more data.
+type ExtensionUploadOnlyMessage struct {
+	UploadOnly bool
+}
+
+// MarshalBinary encodes the message.
+func (m ExtensionUploadOnlyMessage) MarshalBinary() ([]byte, error) {
+	if m.UploadOnly {
+		return []byte{1}, nil
+	}
+	return []byte{0}, nil
+}
+
+// UnmarshalBinary decodes the message.
+func (m *ExtensionUploadOnlyMessage) UnmarshalBinary(data []byte) error {
+	if len(data) < 1 {
+		return errInvalidUploadOnlyMessage
+	}
+	m.UploadOnly = data[0] != 0
+	return nil
+}
+
+// ExtensionDontHaveMessage withdraws a piece that is advertised with a previous have or bitfield message (BEP 54).
+// Payload is the 4-byte big-endian piece index.
+type ExtensionDontHaveMessage struct {
+	Index uint32
+}
+
+// MarshalBinary encodes the message.
+func (m ExtensionDontHaveMessage) MarshalBinary() ([]byte, error) {
+	return binary.BigEndian.AppendUint32(nil, m.Index), nil
+}
+
+// UnmarshalBinary decodes the message.
+func (m *ExtensionDontHaveMessage) UnmarshalBinary(data []byte) error {
+	if len(data) != 4 {
+		return errInvalidDontHaveMessage
+	}
+	m.Index = binary.BigEndian.Uint32(data)
+	return nil
+}
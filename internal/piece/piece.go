@@ -6,6 +6,7 @@ import (
 
 	"github.com/cenkalti/rain/internal/allocator"
 	"github.com/cenkalti/rain/internal/filesection"
+	"github.com/cenkalti/rain/internal/merkle"
 	"github.com/cenkalti/rain/internal/metainfo"
 	"golang.org/x/exp/constraints"
 )
@@ -21,6 +22,8 @@ type Piece struct {
 	Hash    []byte
 	Writing bool
 	Done    bool
+	// Number of leaves in the merkle tree of the piece in v2 torrents. Zero if the piece is verified with SHA-1 hash.
+	TreeLeaves int
 }
 
 // Block is part of a Piece that is specified in peerprotocol.Request messages.
@@ -56,8 +59,9 @@ func NewPieces(info *metainfo.Info, files []allocator.File) []Piece {
 	pieces := make([]Piece, info.NumPieces)
 	for i := uint32(0); i < info.NumPieces; i++ {
 		p := Piece{
-			Index: i,
-			Hash:  info.PieceHash(i),
+			Index:      i,
+			Hash:       info.PieceHash(i),
+			TreeLeaves: info.PieceTreeLeaves(i),
 		}
 
 		var sections filesection.Piece
@@ -167,10 +171,21 @@ func (p *Piece) calculateBlocks(blockSize uint32) []Block {
 }
 
 // VerifyHash returns true if hash of piece data in buffer `buf` matches the hash of Piece.
+// Pieces of v2 torrents are verified with the root of the merkle tree of the data, h is not used for them.
 func (p *Piece) VerifyHash(buf []byte, h hash.Hash) bool {
 	if uint32(len(buf)) != p.Length {
 		return false
 	}
+	if p.TreeLeaves > 0 {
+		// Padding at the end of the piece is not a part of the file.
+		var n int64
+		for _, sec := range p.Data {
+			if !sec.Padding {
+				n += sec.Length
+			}
+		}
+		return bytes.Equal(merkle.Root(buf[:n], p.TreeLeaves), p.Hash)
+	}
 	_, _ = h.Write(buf)
 	sum := h.Sum(nil)
 	return bytes.Equal(sum, p.Hash)
@@ -28,7 +28,15 @@ type PieceDownloader struct {
 	blocks    map[uint32]uint32   // begin -> length
 	remaining []uint32            // blocks to be downloaded from peers in consecutive order.
 	pending   map[uint32]struct{} // in-flight requests
-	done      map[uint32]struct{} // downloaded requests
+	done      map[uint32]Peer     // downloaded requests -> peer that sent the block
+}
+
+// Block is a downloaded part of the piece.
+type Block struct {
+	Begin  uint32
+	Length uint32
+	// Peer that the block is received from.
+	Peer Peer
 }
 
 // Peer of a Torrent.
@@ -49,7 +57,7 @@ func New(pi *piece.Piece, pe Peer, allowedFast bool, buf bufferpool.Buffer) *Pie
 		blocks:      makeBlocks(blocks),
 		remaining:   makeRemaining(blocks),
 		pending:     make(map[uint32]struct{}, len(blocks)),
-		done:        make(map[uint32]struct{}, len(blocks)),
+		done:        make(map[uint32]Peer, len(blocks)),
 	}
 }
 
@@ -98,7 +106,7 @@ func (d *PieceDownloader) GotBlock(begin uint32, data []byte) error {
 		return ErrBlockDuplicate
 	}
 	copy(d.Buffer.Data[begin:begin+uint32(len(data))], data)
-	d.done[begin] = struct{}{}
+	d.done[begin] = d.Peer
 	if _, ok := d.pending[begin]; !ok {
 		// We got the block data although we didn't request it.
 		// Data is still saved but error returned here to notify the caller about the issue.
@@ -153,3 +161,12 @@ func (d *PieceDownloader) RequestBlocks(queueLength int) {
 func (d *PieceDownloader) Done() bool {
 	return len(d.done) == len(d.blocks)
 }
+
+// Blocks returns the downloaded blocks of the piece with the peers that they are received from.
+func (d *PieceDownloader) Blocks() []Block {
+	ret := make([]Block, 0, len(d.done))
+	for begin, pe := range d.done {
+		ret = append(ret, Block{Begin: begin, Length: d.blocks[begin], Peer: pe})
+	}
+	return ret
+}
@@ -3,6 +3,7 @@ package piecepicker
 import (
 	"fmt"
 	"sort"
+	"time"
 
 	"github.com/cenkalti/rain/internal/peer"
 	"github.com/cenkalti/rain/internal/piece"
@@ -22,7 +23,9 @@ These are the things to consider when selecting a piece for downloading:
   * Piece is marked as allowed-fast
   * Piece is requested from another peers
   * Piece is reserved for downloading by a webseed source
+  * Piece has a deadline or a priority
   * Is endgame mode activated (all pieces are requested)
+  * Is peer upload-only (not used for duplicate requests in endgame mode)
   * Are there stalled peers (snubbed or choked in the middle of download)
 
 Do not forget to re-check these when making changes.
@@ -36,11 +39,26 @@ type PiecePicker struct {
 	pieces               []myPiece
 	piecesByAvailability []*myPiece
 	piecesByStalled      []*myPiece
+	piecesByDeadline     []*myPiece
 	maxDuplicateDownload int
 	available            uint32
 	endgame              bool
 }
 
+// Priorities of pieces. Pieces with higher priority are downloaded before the rarest pieces.
+// Pieces with PriorityNone are not downloaded.
+const (
+	PriorityNone   = -1
+	PriorityNormal = 0
+	PriorityHigh   = 1
+)
+
+// A piece with a deadline is requested from more than one peer if the deadline is closer than this duration.
+const deadlineDuplicateWindow = 5 * time.Second
+
+// downloadSpeed returns the download speed of the peer. It is replaced in tests.
+var downloadSpeed = (*peer.Peer).DownloadSpeed
+
 type myPiece struct {
 	*piece.Piece
 	Having    sliceset.SliceSet[peer.Peer]
@@ -50,6 +68,10 @@ type myPiece struct {
 
 	// Downloading from webseed source or marked to be downloaded later.
 	RequestedWebseed *webseedsource.WebseedSource
+
+	Priority int
+	// Zero value means the piece has no deadline.
+	Deadline time.Time
 }
 
 // RunningDownloads returns the number of pieces that are being downloaded actively.
@@ -63,10 +85,15 @@ func (p *myPiece) StalledDownloads() int {
 	return p.Snubbed.Len() + p.Choked.Len()
 }
 
+// Skip returns true if the piece must not be requested.
+func (p *myPiece) Skip() bool {
+	return p.Done || p.Writing || p.Priority == PriorityNone
+}
+
 // AvailableForWebseed returns true if the piece can be downloaded from a webseed source.
 // If the piece is already requested from a peer, it does not become eligible for downloading from webseed until entering the endgame mode.
 func (p *myPiece) AvailableForWebseed(duplicate bool) bool {
-	if p.Done || p.Writing || p.RequestedWebseed != nil {
+	if p.Skip() || p.RequestedWebseed != nil {
 		return false
 	}
 	if !duplicate {
@@ -146,6 +173,37 @@ func (p *PiecePicker) RequestedWebseedSource(i uint32) *webseedsource.WebseedSou
 	return p.pieces[i].RequestedWebseed
 }
 
+// SetPriority sets the download priority of the piece at index `i`.
+func (p *PiecePicker) SetPriority(i uint32, priority int) {
+	p.pieces[i].Priority = priority
+}
+
+// SetDeadline sets the time that the piece at index `i` is needed.
+// Pieces with deadlines are downloaded before other pieces, earliest deadline first.
+// Zero value clears the deadline.
+func (p *PiecePicker) SetDeadline(i uint32, deadline time.Time) {
+	mp := &p.pieces[i]
+	if !mp.Deadline.IsZero() {
+		for j, mp2 := range p.piecesByDeadline {
+			if mp2 == mp {
+				p.piecesByDeadline = append(p.piecesByDeadline[:j], p.piecesByDeadline[j+1:]...)
+				break
+			}
+		}
+	}
+	mp.Deadline = deadline
+	if deadline.IsZero() {
+		return
+	}
+	// Keep the list sorted by deadline so it does not need to be sorted on every pick.
+	j := sort.Search(len(p.piecesByDeadline), func(j int) bool {
+		return deadline.Before(p.piecesByDeadline[j].Deadline)
+	})
+	p.piecesByDeadline = append(p.piecesByDeadline, nil)
+	copy(p.piecesByDeadline[j+1:], p.piecesByDeadline[j:])
+	p.piecesByDeadline[j] = mp
+}
+
 // HandleHave must be called to set the availability of the piece at the peer.
 func (p *PiecePicker) HandleHave(pe *peer.Peer, i uint32) {
 	pe.Bitfield.Set(i)
@@ -182,6 +240,18 @@ func (p *PiecePicker) HandleCancelDownload(pe *peer.Peer, i uint32) {
 	p.pieces[i].Snubbed.Remove(pe)
 }
 
+// HandleCorrupt must be called when the piece received from the peer fails hash check.
+// The piece is not requested from the peer again unless it announces the piece again.
+func (p *PiecePicker) HandleCorrupt(pe *peer.Peer, i uint32) {
+	p.removeHavingPeer(int(i), pe)
+}
+
+// HandleDontHave must be called when the peer withdraws a piece that it has announced before.
+func (p *PiecePicker) HandleDontHave(pe *peer.Peer, i uint32) {
+	pe.Bitfield.Clear(i)
+	p.removeHavingPeer(int(i), pe)
+}
+
 // HandleDisconnect must be called to remove the peer from internal indexes.
 func (p *PiecePicker) HandleDisconnect(pe *peer.Peer) {
 	for i := range p.pieces {
@@ -220,6 +290,11 @@ func (p *PiecePicker) findPiece(pe *peer.Peer) (mp *myPiece, allowedFast bool) {
 	if pe.Downloading {
 		return nil, false
 	}
+	// Pieces with deadlines come before everything else
+	mp, allowedFast = p.pickDeadline(pe)
+	if mp != nil {
+		return mp, allowedFast
+	}
 	if p.downloadingWebseed() {
 		if pe.PeerChoking {
 			return nil, false
@@ -260,10 +335,62 @@ func (p *PiecePicker) findPiece(pe *peer.Peer) (mp *myPiece, allowedFast bool) {
 	return p.pickStalled(pe), false
 }
 
+// pickDeadline returns the piece with the earliest deadline that can be requested from the peer.
+// The piece is left to a faster peer if there is one that is idle, unless the deadline is near.
+// Near the deadline, the piece may be requested from more than one peer.
+func (p *PiecePicker) pickDeadline(pe *peer.Peer) (mp *myPiece, allowedFast bool) {
+	if len(p.piecesByDeadline) == 0 {
+		return nil, false
+	}
+	now := time.Now()
+	for _, mp := range p.piecesByDeadline {
+		if mp.Skip() {
+			continue
+		}
+		if !mp.Having.Has(pe) || mp.Requested.Has(pe) {
+			continue
+		}
+		allowedFast = pe.ReceivedAllowedFast.Has(mp.Piece)
+		if pe.PeerChoking && !allowedFast {
+			continue
+		}
+		near := mp.Deadline.Sub(now) < deadlineDuplicateWindow
+		if mp.Requested.Len() > 0 || mp.RequestedWebseed != nil {
+			if !near || mp.Requested.Len() >= p.maxDuplicateDownload {
+				continue
+			}
+		}
+		if !near && p.hasFasterIdlePeer(mp, pe) {
+			continue
+		}
+		return mp, allowedFast
+	}
+	return nil, false
+}
+
+// hasFasterIdlePeer returns true if another peer that has the piece can download it faster than pe.
+func (p *PiecePicker) hasFasterIdlePeer(mp *myPiece, pe *peer.Peer) bool {
+	var speed int
+	var speedKnown bool
+	for _, other := range mp.Having.Items {
+		if other == pe || other.Downloading || other.PeerChoking || other.Snubbed {
+			continue
+		}
+		if !speedKnown {
+			speed = downloadSpeed(pe)
+			speedKnown = true
+		}
+		if downloadSpeed(other) > speed {
+			return true
+		}
+	}
+	return false
+}
+
 func (p *PiecePicker) pickAllowedFast(pe *peer.Peer) *myPiece {
 	for _, pi := range pe.ReceivedAllowedFast.Items {
 		mp := &p.pieces[pi.Index]
-		if mp.Done || mp.Writing {
+		if mp.Skip() {
 			continue
 		}
 		if mp.Requested.Len() == 0 && mp.Having.Has(pe) {
@@ -274,15 +401,19 @@ func (p *PiecePicker) pickAllowedFast(pe *peer.Peer) *myPiece {
 }
 
 func (p *PiecePicker) pickRarest(pe *peer.Peer) *myPiece {
-	// Sort by rarity
+	// Sort by priority, then rarity
 	sort.Slice(p.piecesByAvailability, func(i, j int) bool {
-		return len(p.piecesByAvailability[i].Having.Items) < len(p.piecesByAvailability[j].Having.Items)
+		pi, pj := p.piecesByAvailability[i], p.piecesByAvailability[j]
+		if pi.Priority != pj.Priority {
+			return pi.Priority > pj.Priority
+		}
+		return len(pi.Having.Items) < len(pj.Having.Items)
 	})
 	var picked *myPiece
 	var hasUnrequested bool
 	// Select unrequested piece
 	for _, mp := range p.piecesByAvailability {
-		if mp.Done || mp.Writing {
+		if mp.Skip() {
 			continue
 		}
 		if mp.Requested.Len() == 0 && mp.Having.Has(pe) {
@@ -299,14 +430,20 @@ func (p *PiecePicker) pickRarest(pe *peer.Peer) *myPiece {
 	return picked
 }
 
+// pickEndgame returns a piece that is already requested from other peers.
+// Upload-only peers are not used as download sources in endgame mode.
+// Their upload capacity is shared by all downloaders in the swarm, so duplicate requests are not sent to them.
 func (p *PiecePicker) pickEndgame(pe *peer.Peer) *myPiece {
+	if pe.UploadOnly {
+		return nil
+	}
 	// Sort by request count
 	sort.Slice(p.piecesByAvailability, func(i, j int) bool {
 		return p.piecesByAvailability[i].RunningDownloads() < p.piecesByAvailability[j].RunningDownloads()
 	})
 	// Select unrequested piece
 	for _, mp := range p.piecesByAvailability {
-		if mp.Done || mp.Writing {
+		if mp.Skip() {
 			continue
 		}
 		if mp.Requested.Len() < p.maxDuplicateDownload && mp.Having.Has(pe) {
@@ -323,7 +460,7 @@ func (p *PiecePicker) pickStalled(pe *peer.Peer) *myPiece {
 	})
 	// Select unrequested piece
 	for _, mp := range p.piecesByStalled {
-		if mp.Done || mp.Writing {
+		if mp.Skip() {
 			continue
 		}
 		if mp.RunningDownloads() > 0 {
@@ -2,6 +2,7 @@ package piecepicker
 
 import (
 	"testing"
+	"time"
 
 	"github.com/cenkalti/rain/internal/bitfield"
 	"github.com/cenkalti/rain/internal/peer"
@@ -69,6 +70,94 @@ func TestPiecePicker(t *testing.T) {
 	assert.True(t, pp.endgame)
 }
 
+func TestPiecePickerPriority(t *testing.T) {
+	pieces := make([]piece.Piece, numPieces)
+	for i := range pieces {
+		pieces[i] = newPiece(i)
+	}
+	pe := newPeer(0)
+	pe2 := newPeer(1)
+	pp := New(pieces, 2, nil)
+	for i := range pieces {
+		pp.HandleHave(pe, uint32(i))
+	}
+	pp.HandleHave(pe2, 1)
+	pp.SetPriority(6, PriorityHigh)
+	assert.Equal(t, &pieces[6], pp.pickFor(pe))
+}
+
+func TestPiecePickerDeadline(t *testing.T) {
+	speeds := make(map[*peer.Peer]int)
+	downloadSpeed = func(pe *peer.Peer) int { return speeds[pe] }
+	defer func() { downloadSpeed = (*peer.Peer).DownloadSpeed }()
+
+	pieces := make([]piece.Piece, numPieces)
+	for i := range pieces {
+		pieces[i] = newPiece(i)
+	}
+	slow := newPeer(0)
+	fast := newPeer(1)
+	speeds[fast] = 1000
+	pp := New(pieces, 2, nil)
+	for i := range pieces {
+		pp.HandleHave(slow, uint32(i))
+		pp.HandleHave(fast, uint32(i))
+	}
+	pp.SetDeadline(5, time.Now().Add(time.Minute))
+	pp.SetDeadline(3, time.Now().Add(2*time.Minute))
+
+	// Deadline piece is left to the faster peer.
+	mp, _ := pp.pickDeadline(slow)
+	assert.Nil(t, mp)
+	assert.Equal(t, &pieces[5], pp.pickFor(fast))
+	fast.Downloading = true
+	assert.Equal(t, &pieces[3], pp.pickFor(slow))
+	slow.Downloading = true
+
+	// Piece is requested again when the deadline is near.
+	another := newPeer(2)
+	pp.HandleHave(another, 5)
+	mp, _ = pp.pickDeadline(another)
+	assert.Nil(t, mp)
+	pp.SetDeadline(5, time.Now().Add(time.Second))
+	assert.Equal(t, &pieces[5], pp.pickFor(another))
+
+	// Pieces are kept in deadline order.
+	assert.Equal(t, []*myPiece{&pp.pieces[5], &pp.pieces[3]}, pp.piecesByDeadline)
+	pp.SetDeadline(3, time.Now())
+	assert.Equal(t, []*myPiece{&pp.pieces[3], &pp.pieces[5]}, pp.piecesByDeadline)
+
+	pp.SetDeadline(5, time.Time{})
+	pp.SetDeadline(3, time.Time{})
+	assert.Empty(t, pp.piecesByDeadline)
+}
+
+func TestPiecePickerUploadOnly(t *testing.T) {
+	pieces := make([]piece.Piece, numPieces)
+	for i := range pieces {
+		pieces[i] = newPiece(i)
+		pieces[i].Done = i > 0
+	}
+	pe := newPeer(0)
+	partialSeed := newPeer(1)
+	partialSeed.UploadOnly = true
+	pp := New(pieces, 2, nil)
+	pp.HandleHave(pe, 0)
+	pp.HandleHave(partialSeed, 0)
+	assert.Equal(t, uint32(1), pp.Available())
+
+	assert.Equal(t, &pieces[0], pp.pickFor(pe))
+	assert.Nil(t, pp.pickFor(partialSeed))
+	assert.True(t, pp.endgame)
+
+	// Withdrawn piece is not available at the peer anymore.
+	pp.HandleDontHave(pe, 0)
+	assert.False(t, pe.Bitfield.Test(0))
+	assert.Equal(t, uint32(1), pp.Available())
+	pp.HandleDontHave(partialSeed, 0)
+	assert.Equal(t, uint32(0), pp.Available())
+}
+
 func newPiece(i int) piece.Piece {
 	return piece.Piece{Index: uint32(i)}
 }
@@ -49,10 +49,47 @@ func (p *PiecePicker) findPieceRangeForWebseed() (begin, end uint32) {
 		gap := p.webseedStealsFromAnotherWebseed()
 		return gap.Begin, gap.End
 	}
+	if r, ok := p.urgentRange(gaps); ok {
+		return r.Begin, r.End
+	}
 	sort.Slice(gaps, func(i, j int) bool { return gaps[i].Len() > gaps[j].Len() })
 	return gaps[0].Begin, gaps[0].End
 }
 
+// urgentRange returns the part of a gap that starts from the piece with the earliest deadline.
+// If there are no pieces with deadline in gaps, it returns the part that starts from the first high priority piece.
+func (p *PiecePicker) urgentRange(gaps []Range) (r Range, ok bool) {
+	var urgent *myPiece
+	for _, mp := range p.piecesByDeadline {
+		if _, in := findGap(gaps, mp.Index); in && (urgent == nil || mp.Deadline.Before(urgent.Deadline)) {
+			urgent = mp
+		}
+	}
+	for i := range p.pieces {
+		if urgent != nil {
+			break
+		}
+		if _, in := findGap(gaps, uint32(i)); in && p.pieces[i].Priority > PriorityNormal {
+			urgent = &p.pieces[i]
+		}
+	}
+	if urgent == nil {
+		return
+	}
+	gap, _ := findGap(gaps, urgent.Index)
+	return Range{Begin: urgent.Index, End: gap.End}, true
+}
+
+// findGap returns the gap that contains the piece index.
+func findGap(gaps []Range, i uint32) (Range, bool) {
+	for _, gap := range gaps {
+		if i >= gap.Begin && i < gap.End {
+			return gap, true
+		}
+	}
+	return Range{}, false
+}
+
 func (p *PiecePicker) getDownloadingSources() []*webseedsource.WebseedSource {
 	ret := make([]*webseedsource.WebseedSource, 0, len(p.webseedSources))
 	for _, src := range p.webseedSources {
@@ -84,7 +121,7 @@ func (p *PiecePicker) peerStealsFromWebseed(pe *peer.Peer) *myPiece {
 		}
 		for i := src.Downloader.End - 1; i > src.Downloader.ReadCurrent(); i-- {
 			pi := &p.pieces[i]
-			if pi.Done || pi.Writing {
+			if pi.Skip() {
 				continue
 			}
 			if !pi.Having.Has(pe) {
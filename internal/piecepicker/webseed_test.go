@@ -2,6 +2,7 @@ package piecepicker
 
 import (
 	"testing"
+	"time"
 
 	"github.com/cenkalti/rain/internal/piece"
 	"github.com/stretchr/testify/assert"
@@ -17,3 +18,25 @@ func TestPickLastPieceOfSmallestGap(t *testing.T) {
 	pp := New(pieces, 2, nil)
 	assert.Nil(t, pp.pickLastPieceOfSmallestGap(peer))
 }
+
+func TestFindPieceRangeForWebseedDeadline(t *testing.T) {
+	pieces := make([]piece.Piece, numPieces)
+	for i := range pieces {
+		pieces[i] = newPiece(i)
+	}
+	pieces[1].Done = true
+	pp := New(pieces, 2, nil)
+	begin, end := pp.findPieceRangeForWebseed()
+	assert.Equal(t, uint32(2), begin)
+	assert.Equal(t, uint32(numPieces), end)
+
+	pp.SetPriority(4, PriorityHigh)
+	begin, end = pp.findPieceRangeForWebseed()
+	assert.Equal(t, uint32(4), begin)
+	assert.Equal(t, uint32(numPieces), end)
+
+	pp.SetDeadline(0, time.Now().Add(time.Minute))
+	begin, end = pp.findPieceRangeForWebseed()
+	assert.Equal(t, uint32(0), begin)
+	assert.Equal(t, uint32(1), end)
+}
@@ -2,9 +2,11 @@ package piecewriter
 
 import (
 	"crypto/sha1"
+	"time"
 
 	"github.com/cenkalti/rain/internal/bufferpool"
 	"github.com/cenkalti/rain/internal/piece"
+	"github.com/cenkalti/rain/internal/piecedownloader"
 	"github.com/cenkalti/rain/internal/semaphore"
 	"github.com/rcrowley/go-metrics"
 )
@@ -14,28 +16,38 @@ type PieceWriter struct {
 	Piece  *piece.Piece
 	Source any
 	Buffer bufferpool.Buffer
+	// Blocks of the piece with the peers that they are received from.
+	// Nil if the piece is not downloaded from peers.
+	Blocks []piecedownloader.Block
 
 	HashOK bool
 	Error  error
 }
 
 // New returns new PieceWriter for a given piece.
-func New(p *piece.Piece, source any, buf bufferpool.Buffer) *PieceWriter {
+func New(p *piece.Piece, source any, buf bufferpool.Buffer, blocks []piecedownloader.Block) *PieceWriter {
 	return &PieceWriter{
 		Piece:  p,
 		Source: source,
 		Buffer: buf,
+		Blocks: blocks,
 	}
 }
 
 // Run checks the hash, then writes the data in the buffer to the disk.
-func (w *PieceWriter) Run(resultC chan *PieceWriter, closeC chan struct{}, writesPerSecond, writeBytesPerSecond metrics.Meter, sem *semaphore.Semaphore) {
+// If sync is true, written data is flushed to disk before returning the result.
+func (w *PieceWriter) Run(resultC chan *PieceWriter, closeC chan struct{}, writesPerSecond, writeBytesPerSecond metrics.Meter, writeLatency metrics.Timer, sem *semaphore.Semaphore, sync bool) {
 	w.HashOK = w.Piece.VerifyHash(w.Buffer.Data, sha1.New())
 	if w.HashOK {
 		writesPerSecond.Mark(1)
 		writeBytesPerSecond.Mark(int64(len(w.Buffer.Data)))
 		sem.Wait()
+		start := time.Now()
 		_, w.Error = w.Piece.Data.Write(w.Buffer.Data)
+		if w.Error == nil && sync {
+			w.Error = w.Piece.Data.Sync()
+		}
+		writeLatency.UpdateSince(start)
 		sem.Signal()
 	}
 	select {
@@ -0,0 +1,258 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// socksServer is a minimal SOCKS5 server for testing. Requires username and password if user is not empty.
+func socksServer(t *testing.T, user, pass string) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveSocks(conn, user, pass)
+		}
+	}()
+	return l
+}
+
+func serveSocks(conn net.Conn, user, pass string) {
+	defer conn.Close()
+	buf := make([]byte, 2)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return
+	}
+	methods := make([]byte, buf[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return
+	}
+	if user != "" {
+		_, _ = conn.Write([]byte{socksVersion, socksAuthPassword})
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return
+		}
+		u := make([]byte, buf[1])
+		_, _ = io.ReadFull(conn, u)
+		_, _ = io.ReadFull(conn, buf[:1])
+		p := make([]byte, buf[0])
+		_, _ = io.ReadFull(conn, p)
+		if string(u) != user || string(p) != pass {
+			_, _ = conn.Write([]byte{socksAuthPasswordVer, 1})
+			return
+		}
+		_, _ = conn.Write([]byte{socksAuthPasswordVer, socksAuthPasswordOK})
+	} else {
+		_, _ = conn.Write([]byte{socksVersion, socksAuthNone})
+	}
+	header := make([]byte, 3)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return
+	}
+	dest, err := readSocksAddr(conn)
+	if err != nil {
+		return
+	}
+	switch header[1] {
+	case socksCmdConnect:
+		target, err := net.Dial("tcp", dest.String())
+		if err != nil {
+			_, _ = conn.Write([]byte{socksVersion, 0x05, 0, socksAddrTypeIPv4, 0, 0, 0, 0, 0, 0})
+			return
+		}
+		defer target.Close()
+		_, _ = conn.Write([]byte{socksVersion, socksReplySucceeded, 0, socksAddrTypeIPv4, 0, 0, 0, 0, 0, 0})
+		go func() { _, _ = io.Copy(target, conn) }()
+		_, _ = io.Copy(conn, target)
+	case socksCmdUDPAssociate:
+		relay, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			return
+		}
+		defer relay.Close()
+		reply := []byte{socksVersion, socksReplySucceeded, 0, socksAddrTypeIPv4, 127, 0, 0, 1}
+		reply = binary.BigEndian.AppendUint16(reply, uint16(relay.LocalAddr().(*net.UDPAddr).Port))
+		_, _ = conn.Write(reply)
+		go relayUDP(relay)
+		_, _ = conn.Read(make([]byte, 1))
+	}
+}
+
+func relayUDP(relay *net.UDPConn) {
+	buf := make([]byte, maxUDPPacketSize)
+	var client *net.UDPAddr
+	for {
+		n, from, err := relay.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		if client == nil || from.String() == client.String() {
+			client = from
+			addr, payload, err := parseUDPHeader(buf[:n])
+			if err != nil {
+				continue
+			}
+			_, _ = relay.WriteToUDP(payload, addr)
+			continue
+		}
+		packet, _ := appendSocksAddr([]byte{0, 0, 0}, from.String())
+		_, _ = relay.WriteToUDP(append(packet, buf[:n]...), client)
+	}
+}
+
+func echoServer(t *testing.T) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return l
+}
+
+func testEcho(t *testing.T, conn net.Conn) {
+	t.Helper()
+	defer conn.Close()
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "hello" {
+		t.Fatalf("unexpected response: %q", buf)
+	}
+}
+
+func TestSOCKS5Connect(t *testing.T) {
+	echo := echoServer(t)
+	srv := socksServer(t, "user", "pass")
+
+	p, err := New("socks5://user:pass@"+srv.Addr().String(), &net.Dialer{Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := p.DialContext(context.Background(), "tcp", echo.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testEcho(t, conn)
+
+	p, _ = New("socks5://user:wrong@"+srv.Addr().String(), &net.Dialer{Timeout: time.Second})
+	_, err = p.DialContext(context.Background(), "tcp", echo.Addr().String())
+	if err == nil {
+		t.Fatal("expected authentication error")
+	}
+}
+
+func TestSOCKS5UDP(t *testing.T) {
+	echo, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echo.Close()
+	go func() {
+		buf := make([]byte, 100)
+		for {
+			n, from, err := echo.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			_, _ = echo.WriteToUDP(buf[:n], from)
+		}
+	}()
+	srv := socksServer(t, "", "")
+
+	p, _ := New("socks5://"+srv.Addr().String(), &net.Dialer{Timeout: time.Second})
+	conn, err := p.ListenUDP(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err = conn.WriteTo([]byte("hello"), echo.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 100)
+	n, from, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "hello" {
+		t.Fatalf("unexpected response: %q", buf[:n])
+	}
+	if from.String() != echo.LocalAddr().String() {
+		t.Fatalf("unexpected address: %s", from)
+	}
+}
+
+func TestHTTPConnect(t *testing.T) {
+	echo := echoServer(t)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil || req.Method != http.MethodConnect || req.Header.Get("Proxy-Authorization") == "" {
+			return
+		}
+		target, err := net.Dial("tcp", req.Host)
+		if err != nil {
+			return
+		}
+		defer target.Close()
+		_, _ = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		go func() { _, _ = io.Copy(target, conn) }()
+		_, _ = io.Copy(conn, target)
+	}()
+
+	p, err := New("http://user:pass@"+l.Addr().String(), &net.Dialer{Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := p.DialContext(context.Background(), "tcp", echo.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testEcho(t, conn)
+}
+
+func TestNew(t *testing.T) {
+	for _, s := range []string{"ftp://127.0.0.1:21", "socks5://127.0.0.1", "://"} {
+		if _, err := New(s, &net.Dialer{Timeout: time.Second}); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package proxy
+
+// https://www.rfc-editor.org/rfc/rfc1928
+// https://www.rfc-editor.org/rfc/rfc1929
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+const (
+	socksVersion = 5
+
+	socksAuthNone          = 0x00
+	socksAuthPassword      = 0x02
+	socksAuthNoAcceptable  = 0xff
+	socksAuthPasswordVer   = 0x01
+	socksAuthPasswordOK    = 0x00
+	socksCmdConnect        = 0x01
+	socksCmdUDPAssociate   = 0x03
+	socksAddrTypeIPv4      = 0x01
+	socksAddrTypeDomain    = 0x03
+	socksAddrTypeIPv6      = 0x04
+	socksReplySucceeded    = 0x00
+	socksMaxDomainNameSize = 255
+)
+
+var socksReplies = map[byte]string{
+	0x01: "general SOCKS server failure",
+	0x02: "connection not allowed by ruleset",
+	0x03: "network unreachable",
+	0x04: "host unreachable",
+	0x05: "connection refused",
+	0x06: "TTL expired",
+	0x07: "command not supported",
+	0x08: "address type not supported",
+}
+
+// socksRequest does the SOCKS5 handshake and sends the command. Returns the bound address in server reply.
+func (p *Proxy) socksRequest(conn net.Conn, cmd byte, addr string) (*net.UDPAddr, error) {
+	if err := p.socksAuthenticate(conn); err != nil {
+		return nil, err
+	}
+	req := []byte{socksVersion, cmd, 0}
+	req, err := appendSocksAddr(req, addr)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = conn.Write(req); err != nil {
+		return nil, err
+	}
+	var header [3]byte
+	if _, err = io.ReadFull(conn, header[:]); err != nil {
+		return nil, err
+	}
+	if header[0] != socksVersion {
+		return nil, fmt.Errorf("unexpected SOCKS version: %d", header[0])
+	}
+	if header[1] != socksReplySucceeded {
+		msg, ok := socksReplies[header[1]]
+		if !ok {
+			msg = "unknown error " + strconv.Itoa(int(header[1]))
+		}
+		return nil, errors.New("SOCKS5 proxy: " + msg)
+	}
+	return readSocksAddr(conn)
+}
+
+func (p *Proxy) socksAuthenticate(conn net.Conn) error {
+	username := p.url.User.Username()
+	password, _ := p.url.User.Password()
+	methods := []byte{socksVersion, 1, socksAuthNone}
+	if username != "" {
+		methods = []byte{socksVersion, 2, socksAuthNone, socksAuthPassword}
+	}
+	if _, err := conn.Write(methods); err != nil {
+		return err
+	}
+	var resp [2]byte
+	if _, err := io.ReadFull(conn, resp[:]); err != nil {
+		return err
+	}
+	if resp[0] != socksVersion {
+		return fmt.Errorf("unexpected SOCKS version: %d", resp[0])
+	}
+	switch resp[1] {
+	case socksAuthNone:
+		return nil
+	case socksAuthPassword:
+		if username == "" {
+			return errors.New("SOCKS5 proxy requires authentication")
+		}
+		if len(username) > 255 || len(password) > 255 {
+			return errors.New("SOCKS5 username or password is too long")
+		}
+		req := []byte{socksAuthPasswordVer, byte(len(username))}
+		req = append(req, username...)
+		req = append(req, byte(len(password)))
+		req = append(req, password...)
+		if _, err := conn.Write(req); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, resp[:]); err != nil {
+			return err
+		}
+		if resp[1] != socksAuthPasswordOK {
+			return errors.New("SOCKS5 proxy authentication failed")
+		}
+		return nil
+	case socksAuthNoAcceptable:
+		return errors.New("no acceptable SOCKS5 authentication method")
+	default:
+		return fmt.Errorf("unexpected SOCKS5 authentication method: %d", resp[1])
+	}
+}
+
+// appendSocksAddr appends the address in "host:port" format to b in SOCKS5 address format.
+func appendSocksAddr(b []byte, addr string) ([]byte, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port: %q", portStr)
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			b = append(b, socksAddrTypeIPv4)
+			b = append(b, ip4...)
+		} else {
+			b = append(b, socksAddrTypeIPv6)
+			b = append(b, ip.To16()...)
+		}
+	} else {
+		if len(host) > socksMaxDomainNameSize {
+			return nil, errors.New("domain name is too long")
+		}
+		b = append(b, socksAddrTypeDomain, byte(len(host)))
+		b = append(b, host...)
+	}
+	return binary.BigEndian.AppendUint16(b, uint16(port)), nil
+}
+
+// readSocksAddr reads an address in SOCKS5 format. Domain names are not resolved and returned IP is nil for them.
+func readSocksAddr(r io.Reader) (*net.UDPAddr, error) {
+	var typ [1]byte
+	if _, err := io.ReadFull(r, typ[:]); err != nil {
+		return nil, err
+	}
+	var addr net.UDPAddr
+	switch typ[0] {
+	case socksAddrTypeIPv4:
+		addr.IP = make(net.IP, net.IPv4len)
+	case socksAddrTypeIPv6:
+		addr.IP = make(net.IP, net.IPv6len)
+	case socksAddrTypeDomain:
+		var l [1]byte
+		if _, err := io.ReadFull(r, l[:]); err != nil {
+			return nil, err
+		}
+		if _, err := io.CopyN(io.Discard, r, int64(l[0])); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown SOCKS5 address type: %d", typ[0])
+	}
+	if _, err := io.ReadFull(r, addr.IP); err != nil {
+		return nil, err
+	}
+	var port [2]byte
+	if _, err := io.ReadFull(r, port[:]); err != nil {
+		return nil, err
+	}
+	addr.Port = int(binary.BigEndian.Uint16(port[:]))
+	return &addr, nil
+}
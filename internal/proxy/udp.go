@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// maxUDPPacketSize is the size of the read buffer including the SOCKS5 header.
+const maxUDPPacketSize = 64 * 1024
+
+var errFragmented = errors.New("fragmented SOCKS5 UDP packets are not supported")
+
+// udpConn sends and receives packets through a SOCKS5 UDP relay.
+type udpConn struct {
+	conn  *net.UDPConn
+	ctrl  net.Conn
+	relay *net.UDPAddr
+
+	m   sync.Mutex
+	buf []byte
+
+	closeOnce sync.Once
+}
+
+var _ net.PacketConn = (*udpConn)(nil)
+
+func newUDPConn(conn *net.UDPConn, ctrl net.Conn, relay *net.UDPAddr) *udpConn {
+	c := &udpConn{
+		conn:  conn,
+		ctrl:  ctrl,
+		relay: relay,
+		buf:   make([]byte, maxUDPPacketSize),
+	}
+	// The association terminates when the control connection is closed by the proxy.
+	go func() {
+		_, _ = ctrl.Read(make([]byte, 1))
+		c.Close()
+	}()
+	return c
+}
+
+// ReadFrom reads a packet that is relayed by the proxy. Returned address is the original sender of the packet.
+func (c *udpConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	for {
+		n, from, err := c.conn.ReadFromUDP(c.buf)
+		if err != nil {
+			return 0, nil, err
+		}
+		if !from.IP.Equal(c.relay.IP) {
+			// Packets from other hosts are not allowed.
+			continue
+		}
+		addr, payload, err := parseUDPHeader(c.buf[:n])
+		if err != nil {
+			continue
+		}
+		return copy(b, payload), addr, nil
+	}
+}
+
+// WriteTo sends the packet to the address via proxy.
+func (c *udpConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	packet := []byte{0, 0, 0}
+	packet, err := appendSocksAddr(packet, addr.String())
+	if err != nil {
+		return 0, err
+	}
+	packet = append(packet, b...)
+	if _, err = c.conn.WriteToUDP(packet, c.relay); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *udpConn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		c.ctrl.Close()
+		err = c.conn.Close()
+	})
+	return err
+}
+
+func (c *udpConn) LocalAddr() net.Addr                { return c.conn.LocalAddr() }
+func (c *udpConn) SetDeadline(t time.Time) error      { return c.conn.SetDeadline(t) }
+func (c *udpConn) SetReadDeadline(t time.Time) error  { return c.conn.SetReadDeadline(t) }
+func (c *udpConn) SetWriteDeadline(t time.Time) error { return c.conn.SetWriteDeadline(t) }
+
+// parseUDPHeader parses the SOCKS5 UDP request header and returns the address and the data.
+func parseUDPHeader(b []byte) (*net.UDPAddr, []byte, error) {
+	if len(b) < 3 {
+		return nil, nil, errors.New("short SOCKS5 UDP packet")
+	}
+	if b[2] != 0 {
+		return nil, nil, errFragmented
+	}
+	r := bytes.NewReader(b[3:])
+	addr, err := readSocksAddr(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	return addr, b[len(b)-r.Len():], nil
+}
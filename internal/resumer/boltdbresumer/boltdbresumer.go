@@ -18,13 +18,26 @@ const LatestVersion = 3
 var Keys = struct {
 	InfoHash          []byte
 	Port              []byte
+	OwnPort           []byte
 	Name              []byte
 	Trackers          []byte
 	URLList           []byte
+	HTTPSeeds         []byte
 	FixedPeers        []byte
 	Dest              []byte
+	Label             []byte
+	ChokingAlgorithm  []byte
+	FirstLastPieces   []byte
+	SelectedFiles     []byte
+	ExactLength       []byte
+	MetadataSources   []byte
 	Info              []byte
+	PieceLayers       []byte
+	MerkleTree        []byte
+	Signatures        []byte
 	Bitfield          []byte
+	FileStats         []byte
+	Bans              []byte
 	AddedAt           []byte
 	BytesDownloaded   []byte
 	BytesUploaded     []byte
@@ -38,13 +51,26 @@ var Keys = struct {
 }{
 	InfoHash:          []byte("info_hash"),
 	Port:              []byte("port"),
+	OwnPort:           []byte("own_port"),
 	Name:              []byte("name"),
 	Trackers:          []byte("trackers"),
 	URLList:           []byte("url_list"),
+	HTTPSeeds:         []byte("http_seeds"),
 	FixedPeers:        []byte("fixed_peers"),
 	Dest:              []byte("dest"),
+	Label:             []byte("label"),
+	ChokingAlgorithm:  []byte("choking_algorithm"),
+	FirstLastPieces:   []byte("first_last_pieces"),
+	SelectedFiles:     []byte("selected_files"),
+	ExactLength:       []byte("exact_length"),
+	MetadataSources:   []byte("metadata_sources"),
 	Info:              []byte("info"),
+	PieceLayers:       []byte("piece_layers"),
+	MerkleTree:        []byte("merkle_tree"),
+	Signatures:        []byte("signatures"),
 	Bitfield:          []byte("bitfield"),
+	FileStats:         []byte("file_stats"),
+	Bans:              []byte("bans"),
 	AddedAt:           []byte("added_at"),
 	BytesDownloaded:   []byte("bytes_downloaded"),
 	BytesUploaded:     []byte("bytes_uploaded"),
@@ -89,10 +115,30 @@ func (r *Resumer) Write(torrentID string, spec *Spec) error {
 	if err != nil {
 		return err
 	}
+	httpSeeds, err := json.Marshal(spec.HTTPSeeds)
+	if err != nil {
+		return err
+	}
 	fixedPeers, err := json.Marshal(spec.FixedPeers)
 	if err != nil {
 		return err
 	}
+	selectedFiles, err := json.Marshal(spec.SelectedFiles)
+	if err != nil {
+		return err
+	}
+	metadataSources, err := json.Marshal(spec.MetadataSources)
+	if err != nil {
+		return err
+	}
+	fileStats, err := json.Marshal(spec.FileStats)
+	if err != nil {
+		return err
+	}
+	bans, err := json.Marshal(spec.Bans)
+	if err != nil {
+		return err
+	}
 	version := LatestVersion
 	if spec.Version != 0 {
 		version = spec.Version
@@ -104,12 +150,26 @@ func (r *Resumer) Write(torrentID string, spec *Spec) error {
 		}
 		_ = b.Put(Keys.InfoHash, spec.InfoHash)
 		_ = b.Put(Keys.Port, []byte(port))
+		_ = b.Put(Keys.OwnPort, []byte(strconv.FormatBool(spec.OwnPort)))
 		_ = b.Put(Keys.Name, []byte(spec.Name))
 		_ = b.Put(Keys.Trackers, trackers)
 		_ = b.Put(Keys.URLList, urlList)
+		_ = b.Put(Keys.HTTPSeeds, httpSeeds)
 		_ = b.Put(Keys.FixedPeers, fixedPeers)
+		_ = b.Put(Keys.Dest, []byte(spec.Dest))
+		_ = b.Put(Keys.Label, []byte(spec.Label))
+		_ = b.Put(Keys.ChokingAlgorithm, []byte(spec.ChokingAlgorithm))
+		_ = b.Put(Keys.FirstLastPieces, []byte(strconv.FormatBool(spec.FirstLastPieces)))
+		_ = b.Put(Keys.SelectedFiles, selectedFiles)
+		_ = b.Put(Keys.ExactLength, []byte(strconv.FormatInt(spec.ExactLength, 10)))
+		_ = b.Put(Keys.MetadataSources, metadataSources)
 		_ = b.Put(Keys.Info, spec.Info)
+		_ = b.Put(Keys.PieceLayers, spec.PieceLayers)
+		_ = b.Put(Keys.MerkleTree, spec.MerkleTree)
+		_ = b.Put(Keys.Signatures, spec.Signatures)
 		_ = b.Put(Keys.Bitfield, spec.Bitfield)
+		_ = b.Put(Keys.FileStats, fileStats)
+		_ = b.Put(Keys.Bans, bans)
 		_ = b.Put(Keys.AddedAt, []byte(spec.AddedAt.Format(time.RFC3339)))
 		_ = b.Put(Keys.BytesDownloaded, []byte(strconv.FormatInt(spec.BytesDownloaded, 10)))
 		_ = b.Put(Keys.BytesUploaded, []byte(strconv.FormatInt(spec.BytesUploaded, 10)))
@@ -135,6 +195,39 @@ func (r *Resumer) WriteInfo(torrentID string, value []byte) error {
 	})
 }
 
+// WritePieceLayers writes only the piece layers of a v2 torrent.
+func (r *Resumer) WritePieceLayers(torrentID string, value []byte) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(r.bucket).Bucket([]byte(torrentID))
+		if b == nil {
+			return nil
+		}
+		return b.Put(Keys.PieceLayers, value)
+	})
+}
+
+// WriteMerkleTree writes only the merkle tree of a merkle torrent.
+func (r *Resumer) WriteMerkleTree(torrentID string, value []byte) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(r.bucket).Bucket([]byte(torrentID))
+		if b == nil {
+			return nil
+		}
+		return b.Put(Keys.MerkleTree, value)
+	})
+}
+
+// WriteSignatures writes only the signatures of the info dict.
+func (r *Resumer) WriteSignatures(torrentID string, value []byte) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(r.bucket).Bucket([]byte(torrentID))
+		if b == nil {
+			return nil
+		}
+		return b.Put(Keys.Signatures, value)
+	})
+}
+
 // WriteBitfield writes only bitfield of a torrent.
 func (r *Resumer) WriteBitfield(torrentID string, value []byte) error {
 	return r.db.Update(func(tx *bbolt.Tx) error {
@@ -146,6 +239,47 @@ func (r *Resumer) WriteBitfield(torrentID string, value []byte) error {
 	})
 }
 
+// WriteFileStats writes only the sizes and modification times of torrent files.
+func (r *Resumer) WriteFileStats(torrentID string, value []FileStat) error {
+	stats, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(r.bucket).Bucket([]byte(torrentID))
+		if b == nil {
+			return nil
+		}
+		return b.Put(Keys.FileStats, stats)
+	})
+}
+
+// WriteBans writes only the banned IP ranges of a torrent.
+func (r *Resumer) WriteBans(torrentID string, value []Ban) error {
+	bans, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(r.bucket).Bucket([]byte(torrentID))
+		if b == nil {
+			return nil
+		}
+		return b.Put(Keys.Bans, bans)
+	})
+}
+
+// WriteDest writes only the data directory of a torrent.
+func (r *Resumer) WriteDest(torrentID string, value string) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(r.bucket).Bucket([]byte(torrentID))
+		if b == nil {
+			return nil
+		}
+		return b.Put(Keys.Dest, []byte(value))
+	})
+}
+
 // WriteStarted writes the start status of a torrent.
 func (r *Resumer) WriteStarted(torrentID string, value bool) error {
 	return r.db.Update(func(tx *bbolt.Tx) error {
@@ -267,6 +401,14 @@ func (r *Resumer) Read(torrentID string) (spec *Spec, err error) {
 			}
 		}
 
+		value = b.Get(Keys.HTTPSeeds)
+		if value != nil {
+			err = json.Unmarshal(value, &spec.HTTPSeeds)
+			if err != nil {
+				return err
+			}
+		}
+
 		value = b.Get(Keys.FixedPeers)
 		if value != nil {
 			err = json.Unmarshal(value, &spec.FixedPeers)
@@ -275,18 +417,91 @@ func (r *Resumer) Read(torrentID string) (spec *Spec, err error) {
 			}
 		}
 
+		value = b.Get(Keys.Dest)
+		if value != nil {
+			spec.Dest = string(value)
+		}
+
+		value = b.Get(Keys.Label)
+		if value != nil {
+			spec.Label = string(value)
+		}
+
+		value = b.Get(Keys.ChokingAlgorithm)
+		if value != nil {
+			spec.ChokingAlgorithm = string(value)
+		}
+
+		value = b.Get(Keys.SelectedFiles)
+		if value != nil {
+			err = json.Unmarshal(value, &spec.SelectedFiles)
+			if err != nil {
+				return err
+			}
+		}
+
+		value = b.Get(Keys.ExactLength)
+		if value != nil {
+			spec.ExactLength, err = strconv.ParseInt(string(value), 10, 64)
+			if err != nil {
+				return err
+			}
+		}
+
+		value = b.Get(Keys.MetadataSources)
+		if value != nil {
+			err = json.Unmarshal(value, &spec.MetadataSources)
+			if err != nil {
+				return err
+			}
+		}
+
 		value = b.Get(Keys.Info)
 		if value != nil {
 			spec.Info = make([]byte, len(value))
 			copy(spec.Info, value)
 		}
 
+		value = b.Get(Keys.PieceLayers)
+		if value != nil {
+			spec.PieceLayers = make([]byte, len(value))
+			copy(spec.PieceLayers, value)
+		}
+
+		value = b.Get(Keys.MerkleTree)
+		if value != nil {
+			spec.MerkleTree = make([]byte, len(value))
+			copy(spec.MerkleTree, value)
+		}
+
+		value = b.Get(Keys.Signatures)
+		if value != nil {
+			spec.Signatures = make([]byte, len(value))
+			copy(spec.Signatures, value)
+		}
+
 		value = b.Get(Keys.Bitfield)
 		if value != nil {
 			spec.Bitfield = make([]byte, len(value))
 			copy(spec.Bitfield, value)
 		}
 
+		value = b.Get(Keys.FileStats)
+		if value != nil {
+			err = json.Unmarshal(value, &spec.FileStats)
+			if err != nil {
+				return err
+			}
+		}
+
+		value = b.Get(Keys.Bans)
+		if value != nil {
+			err = json.Unmarshal(value, &spec.Bans)
+			if err != nil {
+				return err
+			}
+		}
+
 		value = b.Get(Keys.AddedAt)
 		if value != nil {
 			spec.AddedAt, err = time.Parse(time.RFC3339, string(value))
@@ -351,6 +566,22 @@ func (r *Resumer) Read(torrentID string) (spec *Spec, err error) {
 			}
 		}
 
+		value = b.Get(Keys.OwnPort)
+		if value != nil {
+			spec.OwnPort, err = strconv.ParseBool(string(value))
+			if err != nil {
+				return err
+			}
+		}
+
+		value = b.Get(Keys.FirstLastPieces)
+		if value != nil {
+			spec.FirstLastPieces, err = strconv.ParseBool(string(value))
+			if err != nil {
+				return err
+			}
+		}
+
 		value = b.Get(Keys.CompleteCmdRun)
 		if value != nil {
 			spec.CompleteCmdRun, err = strconv.ParseBool(string(value))
@@ -10,12 +10,26 @@ import (
 type Spec struct {
 	InfoHash          []byte
 	Port              int
+	OwnPort           bool
 	Name              string
 	Trackers          [][]string
 	URLList           []string
+	HTTPSeeds         []string
 	FixedPeers        []string
 	Info              []byte
+	PieceLayers       []byte
+	MerkleTree        []byte
+	Signatures        []byte
 	Bitfield          []byte
+	FileStats         []FileStat
+	Bans              []Ban
+	Dest              string
+	Label             string
+	ChokingAlgorithm  string
+	FirstLastPieces   bool
+	SelectedFiles     []int
+	ExactLength       int64
+	MetadataSources   []string
 	AddedAt           time.Time
 	BytesDownloaded   int64
 	BytesUploaded     int64
@@ -28,12 +42,36 @@ type Spec struct {
 	Version           int
 }
 
+// FileStat contains the size and modification time of a torrent file at the time its pieces are saved.
+// It is used for detecting the files that are changed while the torrent is not running.
+type FileStat struct {
+	Size    int64
+	ModTime int64 // Unix time in nanoseconds
+}
+
+// Ban is an IP range that is not allowed to connect to the torrent.
+type Ban struct {
+	Net       string // CIDR notation
+	ExpiresAt time.Time
+}
+
 type jsonSpec struct {
 	Port              int
+	OwnPort           bool
 	Name              string
 	Trackers          [][]string
 	URLList           []string
+	HTTPSeeds         []string
 	FixedPeers        []string
+	FileStats         []FileStat
+	Bans              []Ban
+	Dest              string
+	Label             string
+	ChokingAlgorithm  string
+	FirstLastPieces   bool
+	SelectedFiles     []int
+	ExactLength       int64
+	MetadataSources   []string
 	AddedAt           time.Time
 	BytesDownloaded   int64
 	BytesUploaded     int64
@@ -45,20 +83,34 @@ type jsonSpec struct {
 	Version           int
 
 	// JSON unsafe types
-	InfoHash  string
-	Info      string
-	Bitfield  string
-	SeededFor int64
+	InfoHash    string
+	Info        string
+	PieceLayers string
+	MerkleTree  string
+	Signatures  string
+	Bitfield    string
+	SeededFor   int64
 }
 
 // MarshalJSON converts the Spec to a JSON string.
 func (s Spec) MarshalJSON() ([]byte, error) {
 	j := jsonSpec{
 		Port:              s.Port,
+		OwnPort:           s.OwnPort,
 		Name:              s.Name,
 		Trackers:          s.Trackers,
 		URLList:           s.URLList,
+		HTTPSeeds:         s.HTTPSeeds,
 		FixedPeers:        s.FixedPeers,
+		FileStats:         s.FileStats,
+		Bans:              s.Bans,
+		Dest:              s.Dest,
+		Label:             s.Label,
+		ChokingAlgorithm:  s.ChokingAlgorithm,
+		FirstLastPieces:   s.FirstLastPieces,
+		SelectedFiles:     s.SelectedFiles,
+		ExactLength:       s.ExactLength,
+		MetadataSources:   s.MetadataSources,
 		AddedAt:           s.AddedAt,
 		BytesDownloaded:   s.BytesDownloaded,
 		BytesUploaded:     s.BytesUploaded,
@@ -69,10 +121,13 @@ func (s Spec) MarshalJSON() ([]byte, error) {
 		CompleteCmdRun:    s.CompleteCmdRun,
 		Version:           s.Version,
 
-		InfoHash:  base64.StdEncoding.EncodeToString(s.InfoHash),
-		Info:      base64.StdEncoding.EncodeToString(s.Info),
-		Bitfield:  base64.StdEncoding.EncodeToString(s.Bitfield),
-		SeededFor: int64(s.SeededFor),
+		InfoHash:    base64.StdEncoding.EncodeToString(s.InfoHash),
+		Info:        base64.StdEncoding.EncodeToString(s.Info),
+		PieceLayers: base64.StdEncoding.EncodeToString(s.PieceLayers),
+		MerkleTree:  base64.StdEncoding.EncodeToString(s.MerkleTree),
+		Signatures:  base64.StdEncoding.EncodeToString(s.Signatures),
+		Bitfield:    base64.StdEncoding.EncodeToString(s.Bitfield),
+		SeededFor:   int64(s.SeededFor),
 	}
 	return json.Marshal(j)
 }
@@ -92,16 +147,39 @@ func (s *Spec) UnmarshalJSON(b []byte) error {
 	if err != nil {
 		return err
 	}
+	s.PieceLayers, err = base64.StdEncoding.DecodeString(j.PieceLayers)
+	if err != nil {
+		return err
+	}
+	s.MerkleTree, err = base64.StdEncoding.DecodeString(j.MerkleTree)
+	if err != nil {
+		return err
+	}
+	s.Signatures, err = base64.StdEncoding.DecodeString(j.Signatures)
+	if err != nil {
+		return err
+	}
 	s.Bitfield, err = base64.StdEncoding.DecodeString(j.Bitfield)
 	if err != nil {
 		return err
 	}
 	s.SeededFor = time.Duration(j.SeededFor)
 	s.Port = j.Port
+	s.OwnPort = j.OwnPort
 	s.Name = j.Name
 	s.Trackers = j.Trackers
 	s.URLList = j.URLList
+	s.HTTPSeeds = j.HTTPSeeds
 	s.FixedPeers = j.FixedPeers
+	s.FileStats = j.FileStats
+	s.Bans = j.Bans
+	s.Dest = j.Dest
+	s.Label = j.Label
+	s.ChokingAlgorithm = j.ChokingAlgorithm
+	s.FirstLastPieces = j.FirstLastPieces
+	s.SelectedFiles = j.SelectedFiles
+	s.ExactLength = j.ExactLength
+	s.MetadataSources = j.MetadataSources
 	s.AddedAt = j.AddedAt
 	s.BytesDownloaded = j.BytesDownloaded
 	s.BytesUploaded = j.BytesUploaded
@@ -7,6 +7,7 @@ type Torrent struct {
 	InfoHash string
 	Port     int
 	AddedAt  Time
+	Label    string
 }
 
 // Peer of a Torrent.
@@ -36,6 +37,16 @@ type Webseed struct {
 	DownloadSpeed int
 }
 
+// Ban is an IP address or range that is not allowed to connect.
+type Ban struct {
+	// IP address or range in CIDR notation.
+	IP string
+	// Session is true if the IP is banned in all torrents.
+	Session bool
+	// Zero if the ban lasts until the session is closed.
+	ExpiresAt Time
+}
+
 // Tracker of a Torrent.
 type Tracker struct {
 	URL           string
@@ -50,15 +61,39 @@ type Tracker struct {
 	NextAnnounce  Time
 }
 
+// BlocklistSource contains the status of a blocklist URL.
+type BlocklistSource struct {
+	URL   string
+	Rules int
+	Error string
+}
+
 // SessionStats contains statistics about a Session.
 type SessionStats struct {
 	Uptime         int
 	Torrents       int
 	Peers          int
 	PortsAvailable int
+	ExternalIP     string
+
+	Connections           int
+	ConnectionsWaiting    int
+	ConnectionsContention int64
+	HalfOpen              int
+	HalfOpenWaiting       int
+	HalfOpenContention    int64
+	UploadSlots           int
+	UploadSlotsWaiting    int
+	UploadSlotsContention int64
+
+	DHTNodes           int
+	DHTBuckets         int
+	DHTQueriesSent     int64
+	DHTQueriesReceived int64
 
 	BlockListRules   int
 	BlockListRecency int
+	BlocklistSources []BlocklistSource
 
 	ReadCacheObjects     int
 	ReadCacheSize        int64
@@ -75,6 +110,8 @@ type SessionStats struct {
 	WritesPerSecond int
 	WritesActive    int
 	WritesPending   int
+	WriteLatency    int
+	SyncLatency     int
 
 	SpeedDownload int
 	SpeedUpload   int
@@ -138,6 +175,7 @@ type Stats struct {
 	}
 	Name        string
 	Private     bool
+	Signer      string
 	FileCount   int
 	PieceLength uint32
 	SeededFor   uint
@@ -183,6 +221,10 @@ type AddTorrentOptions struct {
 	Stopped           bool
 	StopAfterDownload bool
 	StopAfterMetadata bool
+	Label             string
+	OwnPort           bool
+	ChokingAlgorithm  string
+	FirstLastPieces   bool
 }
 
 // AddTorrentRequest contains request arguments for Session.AddTorrent method.
@@ -273,6 +315,25 @@ type GetTorrentWebseedsResponse struct {
 	Webseeds []Webseed
 }
 
+// GetTorrentBansRequest contains request arguments for Session.GetTorrentBans method.
+type GetTorrentBansRequest struct {
+	ID string
+}
+
+// GetTorrentBansResponse contains response arguments for Session.GetTorrentBans method.
+type GetTorrentBansResponse struct {
+	Bans []Ban
+}
+
+// GetSessionBansRequest contains request arguments for Session.GetSessionBans method.
+type GetSessionBansRequest struct {
+}
+
+// GetSessionBansResponse contains response arguments for Session.GetSessionBans method.
+type GetSessionBansResponse struct {
+	Bans []Ban
+}
+
 // StartTorrentRequest contains request arguments for Session.StartTorrent method.
 type StartTorrentRequest struct {
 	ID string
@@ -302,13 +363,30 @@ type AnnounceTorrentResponse struct {
 
 // VerifyTorrentRequest contains request arguments for Session.VerifyTorrent method.
 type VerifyTorrentRequest struct {
-	ID string
+	ID     string
+	Files  []string
+	Pieces []PieceRange
+}
+
+// PieceRange is a range of piece indexes. End is not included in the range.
+type PieceRange struct {
+	Begin uint32
+	End   uint32
 }
 
 // VerifyTorrentResponse contains response arguments for Session.VerifyTorrent method.
 type VerifyTorrentResponse struct {
 }
 
+// RecheckTorrentRequest contains request arguments for Session.RecheckTorrent method.
+type RecheckTorrentRequest struct {
+	ID string
+}
+
+// RecheckTorrentResponse contains response arguments for Session.RecheckTorrent method.
+type RecheckTorrentResponse struct {
+}
+
 // MoveTorrentRequest contains request arguments for Session.MoveTorrent method.
 type MoveTorrentRequest struct {
 	ID     string
@@ -329,6 +407,117 @@ type AddPeerRequest struct {
 type AddPeerResponse struct {
 }
 
+// DisconnectPeerRequest contains request arguments for Session.DisconnectPeer method.
+type DisconnectPeerRequest struct {
+	ID   string
+	Addr string
+}
+
+// DisconnectPeerResponse contains response arguments for Session.DisconnectPeer method.
+type DisconnectPeerResponse struct {
+}
+
+// SetPieceDeadlineRequest contains request arguments for Session.SetPieceDeadline method.
+type SetPieceDeadlineRequest struct {
+	ID    string
+	Index uint32
+	// Number of milliseconds from now that the piece is needed. Zero clears the deadline of the piece.
+	Deadline int64
+}
+
+// SetPieceDeadlineResponse contains response arguments for Session.SetPieceDeadline method.
+type SetPieceDeadlineResponse struct {
+}
+
+// SetPiecePriorityRequest contains request arguments for Session.SetPiecePriority method.
+type SetPiecePriorityRequest struct {
+	ID    string
+	Index uint32
+	// 0 for normal, 1 for high priority.
+	Priority int
+}
+
+// SetPiecePriorityResponse contains response arguments for Session.SetPiecePriority method.
+type SetPiecePriorityResponse struct {
+}
+
+// DHTGetRequest contains request arguments for Session.DHTGet method.
+type DHTGetRequest struct {
+	// Hex encoded target of an immutable item. Not used if Key is set.
+	Target string
+	// Hex encoded ed25519 public key of a mutable item.
+	Key string
+	// Salt of a mutable item.
+	Salt string
+}
+
+// DHTGetResponse contains response arguments for Session.DHTGet method.
+type DHTGetResponse struct {
+	// Bencoded value of the item.
+	Value []byte
+	// Sequence number of a mutable item.
+	Seq int64
+}
+
+// DHTPutRequest contains request arguments for Session.DHTPut method.
+type DHTPutRequest struct {
+	// Bencoded value of the item.
+	Value []byte
+	// Hex encoded ed25519 private key or seed for storing a mutable item. An immutable item is stored if empty.
+	PrivateKey string
+	// Salt of a mutable item.
+	Salt string
+	// Sequence number of a mutable item.
+	Seq int64
+}
+
+// DHTPutResponse contains response arguments for Session.DHTPut method.
+type DHTPutResponse struct {
+	// Hex encoded target of the stored item.
+	Target string
+}
+
+// DHTSampleInfoHashesRequest contains request arguments for Session.DHTSampleInfoHashes method.
+type DHTSampleInfoHashesRequest struct {
+	// Address of the DHT node in "host:port" format.
+	Addr string
+}
+
+// DHTSampleInfoHashesResponse contains response arguments for Session.DHTSampleInfoHashes method.
+type DHTSampleInfoHashesResponse struct {
+	// Hex encoded info hashes.
+	InfoHashes []string
+	// Total number of info hashes stored in the node.
+	Num int
+	// Seconds to wait before querying the node again.
+	Interval int
+}
+
+// BanPeerRequest contains request arguments for Session.BanPeer method.
+type BanPeerRequest struct {
+	// Torrent ID. IP is banned in all torrents if empty.
+	ID string
+	// IP address or range in CIDR notation.
+	Addr string
+	// Duration of the ban in seconds. Zero means the ban does not expire.
+	TTL int
+}
+
+// BanPeerResponse contains response arguments for Session.BanPeer method.
+type BanPeerResponse struct {
+}
+
+// UnbanPeerRequest contains request arguments for Session.UnbanPeer method.
+type UnbanPeerRequest struct {
+	// Torrent ID. Empty for the bans in all torrents.
+	ID   string
+	Addr string
+}
+
+// UnbanPeerResponse contains response arguments for Session.UnbanPeer method.
+type UnbanPeerResponse struct {
+}
+
 // AddTrackerRequest contains request arguments for Session.AddTracker method.
 type AddTrackerRequest struct {
 	ID  string
@@ -0,0 +1,77 @@
+// Package smartban finds the peers that send corrupt data.
+//
+// When a piece fails hash check, hashes of its blocks are saved together with the IPs of the peers that sent them.
+// After the same piece is downloaded again and passes hash check,
+// blocks are compared with the saved ones and the peers that have sent different data are found.
+package smartban
+
+import (
+	"crypto/sha1"
+)
+
+// Block of a piece that is received from a peer.
+type Block struct {
+	Begin  uint32
+	Length uint32
+	IP     string
+}
+
+type blockHash struct {
+	Block
+	hash [sha1.Size]byte
+}
+
+// Cache keeps block hashes of pieces that failed hash check.
+type Cache struct {
+	pieces map[uint32][]blockHash
+}
+
+// New returns a new Cache.
+func New() *Cache {
+	return &Cache{
+		pieces: make(map[uint32][]blockHash),
+	}
+}
+
+// Has returns true if the piece at index has failed before and is waiting for a good copy.
+func (c *Cache) Has(index uint32) bool {
+	_, ok := c.pieces[index]
+	return ok
+}
+
+// Add saves the hashes of blocks in a piece that failed hash check.
+// data must contain the whole piece.
+func (c *Cache) Add(index uint32, data []byte, blocks []Block) {
+	hashes := c.pieces[index]
+	for _, b := range blocks {
+		hashes = append(hashes, blockHash{
+			Block: b,
+			hash:  sha1.Sum(data[b.Begin : b.Begin+b.Length]),
+		})
+	}
+	c.pieces[index] = hashes
+}
+
+// Check compares the saved blocks of the piece at index with the data of the correct piece
+// and returns the IPs of peers that have sent a different block.
+// Saved blocks of the piece are removed.
+func (c *Cache) Check(index uint32, data []byte) []string {
+	hashes, ok := c.pieces[index]
+	if !ok {
+		return nil
+	}
+	delete(c.pieces, index)
+	var ret []string
+	seen := make(map[string]struct{})
+	for _, b := range hashes {
+		if sha1.Sum(data[b.Begin:b.Begin+b.Length]) == b.hash {
+			continue
+		}
+		if _, ok := seen[b.IP]; ok {
+			continue
+		}
+		seen[b.IP] = struct{}{}
+		ret = append(ret, b.IP)
+	}
+	return ret
+}
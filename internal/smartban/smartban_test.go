@@ -0,0 +1,24 @@
+package smartban
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	good := []byte("aaaabbbbcccc")
+	bad := []byte("aaaaxxxxcccc")
+	blocks := []Block{
+		{Begin: 0, Length: 4, IP: "1.1.1.1"},
+		{Begin: 4, Length: 4, IP: "2.2.2.2"},
+		{Begin: 8, Length: 4, IP: "1.1.1.1"},
+	}
+	c := New()
+	c.Add(3, bad, blocks)
+	assert.True(t, c.Has(3))
+	assert.False(t, c.Has(4))
+	assert.Equal(t, []string{"2.2.2.2"}, c.Check(3, good))
+	assert.False(t, c.Has(3))
+	assert.Nil(t, c.Check(3, good))
+}
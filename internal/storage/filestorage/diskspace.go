@@ -0,0 +1,69 @@
+package filestorage
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/cenkalti/rain/internal/storage"
+)
+
+var _ storage.Preallocator = (*FileStorage)(nil)
+
+// Preallocate reserves disk space for the whole size of the file.
+// The file must be opened before.
+func (s *FileStorage) Preallocate(name string, size int64) error {
+	name, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	err = fallocate(f, size)
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// RequiredSpace returns the number of bytes that must be allocated on disk to write the file completely.
+func (s *FileStorage) RequiredSpace(name string, size int64) (int64, error) {
+	name, err := s.path(name)
+	if err != nil {
+		return 0, err
+	}
+	fi, err := os.Stat(name)
+	if os.IsNotExist(err) {
+		return size, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	allocated := allocatedSize(fi)
+	if allocated >= size {
+		return 0, nil
+	}
+	return size - allocated, nil
+}
+
+// FreeSpace returns the number of bytes available to write on the file system of the storage.
+func (s *FileStorage) FreeSpace() (int64, error) {
+	// Root directory may not be created yet. Use the nearest existing parent.
+	dir := s.dest
+	for {
+		_, err := os.Stat(dir)
+		if err == nil {
+			break
+		}
+		if !os.IsNotExist(err) {
+			return 0, err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return 0, err
+		}
+		dir = parent
+	}
+	return freeSpace(dir)
+}
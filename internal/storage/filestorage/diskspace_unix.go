@@ -0,0 +1,33 @@
+//go:build !windows
+
+package filestorage
+
+import (
+	"errors"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+func freeSpace(dir string) (int64, error) {
+	var st unix.Statfs_t
+	err := unix.Statfs(dir, &st)
+	if err != nil {
+		return 0, err
+	}
+	return int64(st.Bavail) * int64(st.Bsize), nil
+}
+
+func allocatedSize(fi os.FileInfo) int64 {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fi.Size()
+	}
+	return int64(st.Blocks) * 512
+}
+
+// IsNoSpace returns true if err is caused by a full disk.
+func IsNoSpace(err error) bool {
+	return errors.Is(err, syscall.ENOSPC)
+}
@@ -0,0 +1,30 @@
+package filestorage
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+func freeSpace(dir string) (int64, error) {
+	p, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, err
+	}
+	var avail uint64
+	err = windows.GetDiskFreeSpaceEx(p, &avail, nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	return int64(avail), nil
+}
+
+func allocatedSize(fi os.FileInfo) int64 {
+	return fi.Size()
+}
+
+// IsNoSpace returns true if err is caused by a full disk.
+func IsNoSpace(err error) bool {
+	return errors.Is(err, windows.ERROR_DISK_FULL) || errors.Is(err, windows.ERROR_HANDLE_DISK_FULL)
+}
@@ -13,33 +13,55 @@ import (
 type FileStorage struct {
 	dest string
 	perm fs.FileMode
+	opt  Options
+}
+
+// Options for opening files in FileStorage.
+type Options struct {
+	// New files are created with PartSuffix added to their names.
+	PartSuffix bool
+	// Files are opened with O_SYNC flag. Writes return after the data is written to disk.
+	SyncWrites bool
 }
 
 // New returns a new FileStorage at the destination.
-func New(dest string, perm fs.FileMode) (*FileStorage, error) {
+func New(dest string, perm fs.FileMode, opt Options) (*FileStorage, error) {
 	var err error
 	dest, err = filepath.Abs(dest)
 	if err != nil {
 		return nil, err
 	}
-	return &FileStorage{dest: dest, perm: perm}, nil
+	return &FileStorage{dest: dest, perm: perm, opt: opt}, nil
 }
 
 var _ storage.Storage = (*FileStorage)(nil)
 
-// Open a file.
+var _ storage.ExistingOpener = (*FileStorage)(nil)
+
+// Open a file. The file is created if it does not exist.
 func (s *FileStorage) Open(name string, size int64) (f storage.File, exists bool, err error) {
-	name = filepath.Clean(name)
+	return s.open(name, size, true)
+}
 
-	// All files are saved under dest.
-	name = filepath.Join(s.dest, name)
+// OpenExisting opens a file only if it exists. f is nil if the file does not exist.
+func (s *FileStorage) OpenExisting(name string, size int64) (f storage.File, exists bool, err error) {
+	return s.open(name, size, false)
+}
 
-	// Create containing dir if not exists.
-	err = os.MkdirAll(filepath.Dir(name), os.ModeDir|s.perm)
+func (s *FileStorage) open(name string, size int64, create bool) (f storage.File, exists bool, err error) {
+	name, err = s.path(name)
 	if err != nil {
 		return
 	}
 
+	if create {
+		// Create containing dir if not exists.
+		err = os.MkdirAll(filepath.Dir(name), os.ModeDir|s.perm)
+		if err != nil {
+			return
+		}
+	}
+
 	// Make sure OS file is closed in case of any error.
 	var of *os.File
 	defer func() {
@@ -48,16 +70,23 @@ func (s *FileStorage) Open(name string, size int64) (f storage.File, exists bool
 		}
 		if err != nil && of != nil {
 			_ = of.Close()
-		} else {
+		} else if of != nil {
 			f = of
 		}
 	}()
 
 	// Open OS file.
 	var mode = s.perm &^ 0111
-	openFlags := os.O_RDWR | os.O_SYNC
+	openFlags := os.O_RDWR
+	if s.opt.SyncWrites {
+		openFlags |= os.O_SYNC
+	}
 	openFlags = applyNoAtimeFlag(openFlags)
 	of, err = os.OpenFile(name, openFlags, mode)
+	if os.IsNotExist(err) && !create {
+		err = nil
+		return
+	}
 	if os.IsNotExist(err) {
 		openFlags |= os.O_CREATE
 		of, err = os.OpenFile(name, openFlags, mode)
@@ -81,6 +110,36 @@ func (s *FileStorage) Open(name string, size int64) (f storage.File, exists bool
 	return
 }
 
+// Stat returns the info of the file with given name on disk.
+// The file is looked up by its path, so a file that is deleted or replaced while it is open is detected.
+func (s *FileStorage) Stat(name string) (fs.FileInfo, error) {
+	name, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(name)
+}
+
+// path returns the path of the file with given name on disk.
+func (s *FileStorage) path(name string) (string, error) {
+	name = filepath.Clean(name)
+
+	// All files are saved under dest.
+	name = filepath.Join(s.dest, name)
+
+	// Unfinished files are kept with a suffix until RemovePartSuffix is called.
+	if s.opt.PartSuffix {
+		_, err := os.Stat(name)
+		if os.IsNotExist(err) {
+			return name + PartSuffix, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return name, nil
+}
+
 // RootDir is the root of opened storage file.
 func (s *FileStorage) RootDir() string {
 	return s.dest
@@ -2,7 +2,10 @@
 
 package filestorage
 
-import "os"
+import (
+	"errors"
+	"os"
+)
 
 func disableReadAhead(f *os.File) error {
 	return nil
@@ -11,3 +14,12 @@ func disableReadAhead(f *os.File) error {
 func applyNoAtimeFlag(f int) int {
 	return f
 }
+
+func reflink(dst, src *os.File) error {
+	return errors.New("reflink is not supported on this platform")
+}
+
+// fallocate is not supported on this platform. Files stay sparse.
+func fallocate(f *os.File, size int64) error {
+	return nil
+}
@@ -0,0 +1,98 @@
+package filestorage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// LinkMode is the method used for creating a file from an existing copy of it.
+type LinkMode int
+
+const (
+	// Copy the contents of the source file into a new file.
+	Copy LinkMode = iota
+	// Hardlink creates a new directory entry pointing to the source file.
+	Hardlink
+	// Reflink creates a copy-on-write clone of the source file.
+	// Only works on file systems that support cloning (btrfs, xfs, etc.).
+	Reflink
+)
+
+// Link creates the file at name from the file with the same name under srcDir.
+// Nothing is done if the file already exists in storage or source file does not exist with the given size.
+// Returns true if a new file is created.
+func (s *FileStorage) Link(srcDir, name string, size int64, mode LinkMode) (linked bool, err error) {
+	name = filepath.Clean(name)
+	src := filepath.Join(srcDir, name)
+	if src == filepath.Join(s.dest, name) {
+		return false, nil
+	}
+	// New file is created with the same name that is used when opening it from storage.
+	dst, err := s.path(name)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(dst)
+	if err == nil {
+		return false, nil
+	}
+	if !os.IsNotExist(err) {
+		return false, err
+	}
+	fi, err := os.Stat(src)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !fi.Mode().IsRegular() || fi.Size() != size {
+		return false, nil
+	}
+	err = os.MkdirAll(filepath.Dir(dst), os.ModeDir|s.perm)
+	if err != nil {
+		return false, err
+	}
+	switch mode {
+	case Hardlink:
+		err = os.Link(src, dst)
+	case Reflink:
+		err = s.copyFile(src, dst, reflink)
+	default:
+		err = s.copyFile(src, dst, func(dst, src *os.File) error {
+			_, err := io.Copy(dst, src)
+			return err
+		})
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *FileStorage) copyFile(src, dst string, fn func(dst, src *os.File) error) error {
+	sf, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer sf.Close()
+	// Copy into a temporary file first so a partially copied file is never mistaken as complete.
+	tmp := dst + ".tmp"
+	df, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, s.perm&^0111)
+	if err != nil {
+		return err
+	}
+	err = fn(df, sf)
+	if err == nil {
+		err = df.Sync()
+	}
+	if err2 := df.Close(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
@@ -0,0 +1,49 @@
+package filestorage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinkPartSuffix(t *testing.T) {
+	srcDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(srcDir, "file.bin"), []byte("data"), 0o640)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(t.TempDir(), 0o750, Options{PartSuffix: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	linked, err := s.Link(srcDir, "file.bin", 4, Copy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, linked)
+
+	// Linked file is kept with the suffix until download is completed.
+	_, err = os.Stat(filepath.Join(s.RootDir(), "file.bin"))
+	assert.True(t, os.IsNotExist(err))
+	f, exists, err := s.Open("file.bin", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	assert.True(t, exists)
+	b := make([]byte, 4)
+	_, err = f.ReadAt(b, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "data", string(b))
+
+	// Nothing is done if the incomplete file exists.
+	linked, err = s.Link(srcDir, "file.bin", 4, Copy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, linked)
+}
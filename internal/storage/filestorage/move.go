@@ -0,0 +1,111 @@
+package filestorage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// PartSuffix is added to the names of files that are not completely downloaded yet.
+const PartSuffix = ".part"
+
+var errMoveCancelled = errors.New("move cancelled")
+
+// RemovePartSuffix renames the files that have PartSuffix in storage to their original names.
+func (s *FileStorage) RemovePartSuffix(names []string) error {
+	for _, name := range names {
+		name = filepath.Join(s.dest, filepath.Clean(name))
+		err := os.Rename(name+PartSuffix, name)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Move the file or directory at name under the storage root into dest and return a new FileStorage at dest.
+// The entry appears in dest at once. If dest is on another file system,
+// the entry is copied into a temporary location in dest first and then renamed.
+// Copying can be cancelled by closing stopC.
+func (s *FileStorage) Move(name, dest string, stopC <-chan struct{}) (*FileStorage, error) {
+	sto, err := New(dest, s.perm, s.opt)
+	if err != nil {
+		return nil, err
+	}
+	if sto.dest == s.dest {
+		return sto, nil
+	}
+	name = filepath.Clean(name)
+	src := filepath.Join(s.dest, name)
+	dst := filepath.Join(sto.dest, name)
+	_, err = os.Lstat(dst)
+	if err == nil {
+		return nil, &os.PathError{Op: "move", Path: dst, Err: fs.ErrExist}
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	err = os.MkdirAll(filepath.Dir(dst), os.ModeDir|s.perm)
+	if err != nil {
+		return nil, err
+	}
+	err = os.Rename(src, dst)
+	if !errors.Is(err, syscall.EXDEV) {
+		return sto, err
+	}
+	tmp := filepath.Join(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp")
+	err = os.RemoveAll(tmp)
+	if err != nil {
+		return nil, err
+	}
+	err = s.copyTree(src, tmp, stopC)
+	if err == nil {
+		err = os.Rename(tmp, dst)
+	}
+	if err != nil {
+		_ = os.RemoveAll(tmp)
+		return nil, err
+	}
+	return sto, os.RemoveAll(src)
+}
+
+func (s *FileStorage) copyTree(src, dst string, stopC <-chan struct{}) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, os.ModeDir|s.perm)
+		}
+		return s.copyFile(path, target, func(dst, src *os.File) error {
+			_, err := io.Copy(dst, stopReader{src, stopC})
+			return err
+		})
+	})
+}
+
+// stopReader returns error from Read after stopC is closed.
+type stopReader struct {
+	r     io.Reader
+	stopC <-chan struct{}
+}
+
+func (r stopReader) Read(p []byte) (int, error) {
+	select {
+	case <-r.stopC:
+		return 0, errMoveCancelled
+	default:
+	}
+	return r.r.Read(p)
+}
@@ -0,0 +1,30 @@
+package storage
+
+import "errors"
+
+var errMissingFile = errors.New("file is not created on the storage")
+
+// MissingFile is used in place of a file that is not going to be downloaded and does not exist on the storage.
+type MissingFile struct{}
+
+// NewMissingFile returns a File that reads zeroes and cannot be written.
+func NewMissingFile() File {
+	return MissingFile{}
+}
+
+var _ File = MissingFile{}
+
+func (f MissingFile) ReadAt(p []byte, off int64) (n int, err error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func (f MissingFile) WriteAt(p []byte, off int64) (n int, err error) {
+	return 0, errMissingFile
+}
+
+func (f MissingFile) Close() error {
+	return nil
+}
@@ -15,3 +15,16 @@ type File interface {
 	io.WriterAt
 	io.Closer
 }
+
+// Preallocator is implemented by storages that can reserve disk space for a file before writing into it.
+type Preallocator interface {
+	Preallocate(name string, size int64) error
+	// RequiredSpace returns the number of bytes that are not allocated yet for the file.
+	RequiredSpace(name string, size int64) (int64, error)
+}
+
+// ExistingOpener is implemented by storages that can open a file without creating it.
+// Exists is false and f is nil if the file is not on the storage.
+type ExistingOpener interface {
+	OpenExisting(name string, size int64) (f File, exists bool, err error)
+}
@@ -0,0 +1,67 @@
+package syncer
+
+import (
+	"time"
+
+	"github.com/cenkalti/rain/internal/allocator"
+	"github.com/cenkalti/rain/internal/bitfield"
+	"github.com/rcrowley/go-metrics"
+)
+
+// Syncer flushes the written data of torrent files to disk.
+type Syncer struct {
+	// Pieces that are known to be on disk after files are flushed.
+	Bitfield *bitfield.Bitfield
+	Error    error
+
+	closeC chan struct{}
+	doneC  chan struct{}
+}
+
+// New returns a new Syncer.
+func New() *Syncer {
+	return &Syncer{
+		closeC: make(chan struct{}),
+		doneC:  make(chan struct{}),
+	}
+}
+
+// Close the Syncer.
+func (s *Syncer) Close() {
+	close(s.closeC)
+	<-s.doneC
+}
+
+// Run the Syncer.
+// bf must be a copy of the torrent bitfield taken before the sync is started.
+func (s *Syncer) Run(files []allocator.File, bf *bitfield.Bitfield, latency metrics.Timer, resultC chan *Syncer) {
+	defer close(s.doneC)
+
+	start := time.Now()
+	s.Error = Sync(files)
+	latency.UpdateSince(start)
+	if s.Error == nil {
+		s.Bitfield = bf
+	}
+
+	select {
+	case resultC <- s:
+	case <-s.closeC:
+	}
+}
+
+// Sync flushes files that implement Sync method.
+func Sync(files []allocator.File) error {
+	for _, f := range files {
+		if f.Padding {
+			continue
+		}
+		if sf, ok := f.Storage.(interface{ Sync() error }); ok {
+			err := sf.Sync()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
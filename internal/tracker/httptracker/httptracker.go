@@ -186,9 +186,18 @@ func (t *HTTPTracker) Announce(ctx context.Context, req tracker.AnnounceRequest)
 		Seeders:        response.Complete,
 		Peers:          peers,
 		WarningMessage: response.WarningMessage,
+		ExternalIP:     parseExternalIP(response.ExternalIP),
 	}, nil
 }
 
+// parseExternalIP returns the IP in "external ip" key of the response (BEP 24).
+func parseExternalIP(b []byte) net.IP {
+	if len(b) != net.IPv4len && len(b) != net.IPv6len {
+		return nil
+	}
+	return net.IP(b)
+}
+
 // percentEscape puts `%` before every byte.
 // Some trackers don't like the output of url.QueryEscape function because it may skip encoding safe characters.
 // This function escapes every byte explicitly.
@@ -2,7 +2,9 @@ package httptracker_test
 
 import (
 	"context"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
 	"time"
@@ -99,3 +101,27 @@ func TestHTTPTracker(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestHTTPTrackerExternalIP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("d8:intervali60e5:peers0:11:external ip4:\x01\x02\x03\x04e"))
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trk := httptracker.New(srv.URL, u, timeout, new(http.Transport), "Mozilla/5.0", 2*1024*1024)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	resp, err := trk.Announce(ctx, tracker.AnnounceRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.ExternalIP.Equal(net.IPv4(1, 2, 3, 4)) {
+		t.Fatalf("invalid external ip: %s", resp.ExternalIP)
+	}
+}
@@ -34,6 +34,8 @@ type AnnounceResponse struct {
 	Seeders        int32
 	WarningMessage string
 	Peers          []*net.TCPAddr
+	// IP address of the client as seen by the tracker. Nil if the tracker does not report it.
+	ExternalIP net.IP
 }
 
 // ErrDecode is returned from Tracker.Announce method when there is problem with the encoding of response.
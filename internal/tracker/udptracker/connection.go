@@ -16,6 +16,8 @@ type connection struct {
 	addr        *net.UDPAddr
 	id          int64
 	connectedAt time.Time
+	// Address that the packets are sent from to the tracker.
+	localIP net.IP
 }
 
 var _ udpRequest = (*connection)(nil)
@@ -52,7 +52,6 @@ type announceRequest struct {
 	Key        uint32
 	NumWant    int32
 	Port       uint16
-	Extensions uint16
 }
 
 type transferAnnounceRequest struct {
@@ -0,0 +1,30 @@
+package udptracker
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnounceRequestURLData(t *testing.T) {
+	urlData := "/announce?passkey=" + strings.Repeat("a", 300)
+	req := transferAnnounceRequest{
+		announceRequest: &announceRequest{},
+		urlData:         urlData,
+	}
+	var buf bytes.Buffer
+	_, err := req.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	// Options start right after the 98 bytes announce request (BEP 41).
+	assert.Equal(t, 98+2+255+2+len(urlData)-255, len(b))
+	b = b[98:]
+	assert.Equal(t, []byte{0x2, 255}, b[:2])
+	assert.Equal(t, urlData[:255], string(b[2:257]))
+	assert.Equal(t, []byte{0x2, byte(len(urlData) - 255)}, b[257:259])
+	assert.Equal(t, urlData[255:], string(b[259:]))
+}
@@ -3,6 +3,7 @@ package udptracker
 import (
 	"context"
 	"encoding/binary"
+	"net"
 
 	"github.com/cenkalti/rain/internal/tracker"
 )
@@ -10,6 +11,9 @@ import (
 type transportRequest struct {
 	*requestBase
 	transferAnnounceRequest
+
+	// Set by Transport.Run loop before the request is sent.
+	localIP net.IP
 }
 
 var _ udpRequest = (*transportRequest)(nil)
@@ -24,11 +24,15 @@ const (
 	connectionIDInterval = time.Minute
 )
 
+// ListenFunc returns a connection for sending and receiving UDP packets.
+type ListenFunc func() (net.PacketConn, error)
+
 // Transport for UDP tracker implementation.
 type Transport struct {
 	blocklist  *blocklist.Blocklist
 	log        logger.Logger
 	dnsTimeout time.Duration
+	listen     ListenFunc
 
 	// Transport.Do will send messages to this channel.
 	requestC chan *transportRequest
@@ -44,11 +48,16 @@ type Transport struct {
 }
 
 // NewTransport returns a new UDP tracker transport.
-func NewTransport(bl *blocklist.Blocklist, dnsTimeout time.Duration) *Transport {
+// Packets are sent over the connection returned from listen. If listen is nil, a UDP port is listened on all interfaces.
+func NewTransport(bl *blocklist.Blocklist, dnsTimeout time.Duration, listen ListenFunc) *Transport {
+	if listen == nil {
+		listen = listenUDP
+	}
 	return &Transport{
 		blocklist:  bl,
 		log:        logger.New("udp tracker transport"),
 		dnsTimeout: dnsTimeout,
+		listen:     listen,
 		requestC:   make(chan *transportRequest),
 		readC:      make(chan []byte),
 		closeC:     make(chan struct{}),
@@ -88,8 +97,7 @@ func (t *Transport) Do(req *transportRequest) ([]byte, error) {
 func (t *Transport) Run() {
 	t.log.Debugln("Starting transport run loop")
 	var listening bool
-	var laddr net.UDPAddr
-	udpConn, listenErr := net.ListenUDP("udp4", &laddr)
+	udpConn, listenErr := t.listen()
 	if listenErr != nil {
 		t.log.Error(listenErr)
 	} else {
@@ -137,6 +145,7 @@ func (t *Transport) Run() {
 			} else {
 				if !conn.connectedAt.IsZero() {
 					req.ConnectionID = conn.id
+					req.localIP = conn.localIP
 					trx, err := beginTransaction(req)
 					if err != nil {
 						req.SetResponse(nil, err)
@@ -168,6 +177,7 @@ func (t *Transport) Run() {
 			conn.addr = res.addr
 			conn.id = res.id
 			conn.connectedAt = res.connectedAt
+			conn.localIP = res.localIP
 
 			// Expire the connection after defined period.
 			go func(dest string) {
@@ -185,6 +195,7 @@ func (t *Transport) Run() {
 			// Start announce transaction for all waiting requests.
 			for _, req := range conn.requests {
 				req.ConnectionID = conn.id
+				req.localIP = conn.localIP
 				trx, err := beginTransaction(req)
 				if err != nil {
 					req.SetResponse(nil, err)
@@ -253,13 +264,42 @@ func (t *Transport) Run() {
 	}
 }
 
+func listenUDP() (net.PacketConn, error) {
+	var laddr net.UDPAddr
+	return net.ListenUDP("udp4", &laddr)
+}
+
+// localIP returns the IP address that the packets to addr are sent from if it is a public address.
+// It is the external IP of the client if the host is not behind a NAT.
+// Nil is returned if the packets are not sent directly, e.g. through a proxy.
+func localIP(udpConn net.PacketConn, addr *net.UDPAddr) net.IP {
+	uc, ok := udpConn.(*net.UDPConn)
+	if !ok {
+		return nil
+	}
+	ip := uc.LocalAddr().(*net.UDPAddr).IP
+	if ip.IsUnspecified() {
+		// Connecting a UDP socket does not send any packets, it only selects the route to addr.
+		c, err := net.DialUDP("udp4", nil, addr)
+		if err != nil {
+			return nil
+		}
+		defer c.Close()
+		ip = c.LocalAddr().(*net.UDPAddr).IP
+	}
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return nil
+	}
+	return ip
+}
+
 // readLoop reads datagrams from connection and sends to the run loop.
-func (t *Transport) readLoop(conn net.Conn) {
+func (t *Transport) readLoop(conn net.PacketConn) {
 	// Read buffer must be big enough to hold a UDP packet of maximum expected size.
 	const maxNumWant = 1000
 	bigBuf := make([]byte, 20+6*maxNumWant)
 	for {
-		n, err := conn.Read(bigBuf)
+		n, _, err := conn.ReadFrom(bigBuf)
 		if err != nil {
 			select {
 			case <-t.closeC:
@@ -287,9 +327,10 @@ type connectionResult struct {
 	id          int64
 	err         error
 	connectedAt time.Time
+	localIP     net.IP
 }
 
-func resolveDestinationAndConnect(trx *transaction, dest string, udpConn *net.UDPConn, dnsTimeout time.Duration, blocklist *blocklist.Blocklist, resultC chan *connectionResult, stopC chan struct{}) {
+func resolveDestinationAndConnect(trx *transaction, dest string, udpConn net.PacketConn, dnsTimeout time.Duration, blocklist *blocklist.Blocklist, resultC chan *connectionResult, stopC chan struct{}) {
 	res := &connectionResult{
 		trx:  trx,
 		dest: dest,
@@ -310,6 +351,7 @@ func resolveDestinationAndConnect(trx *transaction, dest string, udpConn *net.UD
 	res.id, res.err = sendAndReceiveConnect(trx, udpConn, res.addr)
 	if res.err == nil {
 		res.connectedAt = time.Now()
+		res.localIP = localIP(udpConn, res.addr)
 	}
 
 	select {
@@ -318,7 +360,7 @@ func resolveDestinationAndConnect(trx *transaction, dest string, udpConn *net.UD
 	}
 }
 
-func sendAndReceiveConnect(trx *transaction, conn *net.UDPConn, addr net.Addr) (connectionID int64, err error) {
+func sendAndReceiveConnect(trx *transaction, conn net.PacketConn, addr net.Addr) (connectionID int64, err error) {
 	// Send request until the transaction is canceled.
 	go retryTransaction(trx, conn, addr)
 
@@ -350,7 +392,7 @@ func sendAndReceiveConnect(trx *transaction, conn *net.UDPConn, addr net.Addr) (
 // Send the request until the transaction is canceled.
 // Transaction canceled by either via outer context or when response is received from the tracker.
 // It backs off with the algorithm described in BEP15 and retries.
-func retryTransaction(trx *transaction, conn *net.UDPConn, addr net.Addr) {
+func retryTransaction(trx *transaction, conn net.PacketConn, addr net.Addr) {
 	var b bytes.Buffer
 	_, _ = trx.request.WriteTo(&b)
 	data := b.Bytes()
@@ -63,6 +63,9 @@ func (t *UDPTracker) Announce(ctx context.Context, req tracker.AnnounceRequest)
 		Leechers: response.Leechers,
 		Seeders:  response.Seeders,
 		Peers:    peers,
+		// UDP trackers do not report the IP of the client.
+		// The local address is the external IP if the host is not behind a NAT.
+		ExternalIP: announce.localIP,
 	}, nil
 }
 
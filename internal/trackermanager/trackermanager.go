@@ -3,6 +3,7 @@ package trackermanager
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -10,6 +11,8 @@ import (
 	"time"
 
 	"github.com/cenkalti/rain/internal/blocklist"
+	"github.com/cenkalti/rain/internal/logger"
+	"github.com/cenkalti/rain/internal/proxy"
 	"github.com/cenkalti/rain/internal/resolver"
 	"github.com/cenkalti/rain/internal/tracker"
 	"github.com/cenkalti/rain/internal/tracker/httptracker"
@@ -24,12 +27,19 @@ type TrackerManager struct {
 }
 
 // New returns a new TrackerManager.
-func New(bl *blocklist.Blocklist, dnsTimeout time.Duration, tlsSkipVerify bool) *TrackerManager {
+// If px is not nil, HTTP trackers are contacted through the proxy.
+// UDP trackers are contacted through the proxy if it supports UDP.
+// If proxyOnly is true, UDP trackers are not contacted when the proxy cannot relay UDP packets.
+// If dialer is not nil, connections are made with it. UDP sockets are bound to its local address and Control function.
+func New(bl *blocklist.Blocklist, dnsTimeout time.Duration, tlsSkipVerify bool, px *proxy.Proxy, proxyOnly bool, dialer *net.Dialer) *TrackerManager {
 	m := &TrackerManager{
 		httpTransport: &http.Transport{
 			TLSClientConfig: &tls.Config{InsecureSkipVerify: tlsSkipVerify}, // nolint: gosec
 		},
-		udpTransport: udptracker.NewTransport(bl, dnsTimeout),
+		udpTransport: udptracker.NewTransport(bl, dnsTimeout, udpListenFunc(px, proxyOnly, dialer)),
+	}
+	if px != nil {
+		m.httpTransport.Proxy = px.HTTPProxy
 	}
 	go m.udpTransport.Run()
 	m.httpTransport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
@@ -38,12 +48,49 @@ func New(bl *blocklist.Blocklist, dnsTimeout time.Duration, tlsSkipVerify bool)
 			return nil, err
 		}
 		var d net.Dialer
+		if dialer != nil {
+			d = *dialer
+		}
 		taddr := &net.TCPAddr{IP: ip, Port: port}
 		return d.DialContext(ctx, network, taddr.String())
 	}
 	return m
 }
 
+func udpListenFunc(px *proxy.Proxy, proxyOnly bool, dialer *net.Dialer) udptracker.ListenFunc {
+	listenDirect := func() (net.PacketConn, error) {
+		var lc net.ListenConfig
+		laddr := &net.UDPAddr{}
+		if dialer != nil {
+			lc.Control = dialer.Control
+			if a, ok := dialer.LocalAddr.(*net.TCPAddr); ok {
+				laddr.IP = a.IP
+			}
+		}
+		return lc.ListenPacket(context.Background(), "udp4", laddr.String())
+	}
+	if px == nil {
+		if dialer == nil {
+			return nil
+		}
+		return listenDirect
+	}
+	return func() (net.PacketConn, error) {
+		if !px.SupportsUDP() {
+			if proxyOnly {
+				return nil, errors.New("UDP trackers are disabled because proxy does not support UDP")
+			}
+			return listenDirect()
+		}
+		conn, err := px.ListenUDP(context.Background())
+		if err != nil && !proxyOnly {
+			logger.New("tracker manager").Warningln("cannot associate UDP port with proxy, UDP trackers will be contacted directly:", err)
+			return listenDirect()
+		}
+		return conn, err
+	}
+}
+
 func (m *TrackerManager) Close() {
 	m.httpTransport.CloseIdleConnections()
 	m.udpTransport.Close()
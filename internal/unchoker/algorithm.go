@@ -0,0 +1,48 @@
+package unchoker
+
+import "fmt"
+
+// Names of the choking algorithms.
+const (
+	AlgorithmTitForTat  = "tit-for-tat"
+	AlgorithmRoundRobin = "round-robin"
+	AlgorithmAntiLeech  = "anti-leech"
+	AlgorithmRateBased  = "rate-based"
+)
+
+// Algorithm selects the peers to unchoke.
+type Algorithm interface {
+	// TickUnchoke must be called at every 10 seconds.
+	TickUnchoke(allPeers []Peer, torrentCompleted bool)
+	// FastUnchoke must be called when remote peer is interested.
+	FastUnchoke(pe Peer)
+	// HandleDisconnect must be called to remove the peer from internal indexes.
+	HandleDisconnect(pe Peer)
+	// SetLimits changes the max number of unchoked peers.
+	SetLimits(numUnchoked, numOptimisticUnchoked int)
+	// NumUnchoked returns the number of unchoked peers, including optimistic unchokes.
+	NumUnchoked() int
+}
+
+var (
+	_ Algorithm = (*Unchoker)(nil)
+	_ Algorithm = (*RoundRobin)(nil)
+	_ Algorithm = (*AntiLeech)(nil)
+	_ Algorithm = (*RateBased)(nil)
+)
+
+// NewAlgorithm returns a new choking algorithm by its name.
+func NewAlgorithm(name string, numUnchoked, numOptimisticUnchoked int) (Algorithm, error) {
+	switch name {
+	case AlgorithmTitForTat:
+		return New(numUnchoked, numOptimisticUnchoked), nil
+	case AlgorithmRoundRobin:
+		return NewRoundRobin(numUnchoked, numOptimisticUnchoked), nil
+	case AlgorithmAntiLeech:
+		return NewAntiLeech(numUnchoked, numOptimisticUnchoked), nil
+	case AlgorithmRateBased:
+		return NewRateBased(numUnchoked, numOptimisticUnchoked), nil
+	default:
+		return nil, fmt.Errorf("unknown choking algorithm: %q", name)
+	}
+}
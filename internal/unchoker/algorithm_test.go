@@ -0,0 +1,110 @@
+package unchoker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testPeerList(testPeers []*TestPeer) []Peer {
+	peers := make([]Peer, len(testPeers))
+	for i := range peers {
+		peers[i] = testPeers[i]
+	}
+	return peers
+}
+
+func unchokedPeers(testPeers []*TestPeer) []int {
+	var ret []int
+	for i, pe := range testPeers {
+		if !pe.choking {
+			ret = append(ret, i)
+		}
+	}
+	return ret
+}
+
+func TestRoundRobin(t *testing.T) {
+	testPeers := []*TestPeer{
+		{interested: true, choking: true, uploadSpeed: 4},
+		{interested: true, choking: true, uploadSpeed: 3},
+		{interested: true, choking: true, uploadSpeed: 2},
+		{choking: true},
+	}
+	u := NewRoundRobin(2, 0)
+
+	// Peers are unchoked in order of arrival.
+	u.TickUnchoke(testPeerList(testPeers), true)
+	assert.Equal(t, []int{0, 1}, unchokedPeers(testPeers))
+
+	// Unchoked peers keep their slots until their turn is over.
+	u.TickUnchoke(testPeerList(testPeers), true)
+	u.TickUnchoke(testPeerList(testPeers), true)
+	assert.Equal(t, []int{0, 1}, unchokedPeers(testPeers))
+
+	// Waiting peer takes a slot from a peer whose turn is over.
+	u.TickUnchoke(testPeerList(testPeers), true)
+	assert.Equal(t, []int{0, 2}, unchokedPeers(testPeers))
+
+	// Peer that has been waiting gets the next slot.
+	u.TickUnchoke(testPeerList(testPeers), true)
+	u.TickUnchoke(testPeerList(testPeers), true)
+	assert.Equal(t, []int{0, 2}, unchokedPeers(testPeers))
+	u.TickUnchoke(testPeerList(testPeers), true)
+	assert.Equal(t, []int{0, 1}, unchokedPeers(testPeers))
+
+	// Tit-for-tat is used while downloading.
+	testPeers[0].downloadSpeed = 10
+	testPeers[1].downloadSpeed = 5
+	u.round = 1
+	u.TickUnchoke(testPeerList(testPeers), false)
+	assert.Equal(t, []int{0, 1}, unchokedPeers(testPeers))
+}
+
+func TestAntiLeech(t *testing.T) {
+	testPeers := []*TestPeer{
+		{interested: true, choking: true, have: 50, total: 100, uploadSpeed: 10},
+		{interested: true, choking: true, have: 99, total: 100},
+		{interested: true, choking: true, have: 5, total: 100, uploadSpeed: 1},
+		{interested: true, choking: true, have: 5, total: 100, uploadSpeed: 2},
+	}
+	u := NewAntiLeech(2, 0)
+	u.round = 1
+	u.TickUnchoke(testPeerList(testPeers), true)
+	assert.Equal(t, []int{1, 3}, unchokedPeers(testPeers))
+}
+
+func TestRateBased(t *testing.T) {
+	testPeers := []*TestPeer{
+		{interested: true, choking: true, uploadSpeed: 5000},
+		{interested: true, choking: true, uploadSpeed: 1500},
+		{interested: true, choking: true, uploadSpeed: 500},
+		{interested: true, choking: true},
+	}
+	u := NewRateBased(10, 0)
+	u.round = 1
+
+	// Slots are added for peers faster than 1 KiB/s and 2 KiB/s, plus one for a new peer.
+	u.TickUnchoke(testPeerList(testPeers), true)
+	assert.Equal(t, []int{0, 1}, unchokedPeers(testPeers))
+
+	// Upload capacity is used by more peers.
+	testPeers[1].uploadSpeed = 3100
+	testPeers[2].uploadSpeed = 3500
+	u.TickUnchoke(testPeerList(testPeers), true)
+	assert.Equal(t, []int{0, 1, 2, 3}, unchokedPeers(testPeers))
+
+	// Number of slots does not exceed the limit.
+	u.SetLimits(2, 0)
+	u.TickUnchoke(testPeerList(testPeers), true)
+	assert.Equal(t, []int{0, 2}, unchokedPeers(testPeers))
+}
+
+func TestNewAlgorithm(t *testing.T) {
+	for _, name := range []string{AlgorithmTitForTat, AlgorithmRoundRobin, AlgorithmAntiLeech, AlgorithmRateBased} {
+		_, err := NewAlgorithm(name, 2, 1)
+		assert.NoError(t, err)
+	}
+	_, err := NewAlgorithm("foo", 2, 1)
+	assert.Error(t, err)
+}
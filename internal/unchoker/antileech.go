@@ -0,0 +1,45 @@
+package unchoker
+
+import "sort"
+
+// AntiLeech is a seed-mode algorithm that prefers peers that have just started downloading or almost finished.
+// Peers in the middle of the download are the least preferred, so that a peer cannot leech the whole torrent from a seed.
+// While downloading, peers are selected by their download speed same as Unchoker.
+type AntiLeech struct {
+	*Unchoker
+}
+
+// NewAntiLeech returns a new AntiLeech.
+func NewAntiLeech(numUnchoked, numOptimisticUnchoked int) *AntiLeech {
+	return &AntiLeech{Unchoker: New(numUnchoked, numOptimisticUnchoked)}
+}
+
+// TickUnchoke must be called at every 10 seconds.
+func (u *AntiLeech) TickUnchoke(allPeers []Peer, torrentCompleted bool) {
+	if !torrentCompleted {
+		u.Unchoker.TickUnchoke(allPeers, false)
+		return
+	}
+	peers := u.candidatesUnchoke(allPeers)
+	sort.SliceStable(peers, func(i, j int) bool {
+		si, sj := antiLeechScore(peers[i]), antiLeechScore(peers[j])
+		if si != sj {
+			return si > sj
+		}
+		return peers[i].UploadSpeed() > peers[j].UploadSpeed()
+	})
+	u.unchokeSorted(peers, u.numUnchoked)
+}
+
+// antiLeechScore is between 0 and 1000. It is highest for peers that have no pieces or all of the pieces.
+func antiLeechScore(pe Peer) int64 {
+	have, total := pe.HavePieces()
+	if total == 0 {
+		return 1000
+	}
+	d := 2*int64(have) - int64(total)
+	if d < 0 {
+		d = -d
+	}
+	return d * 1000 / int64(total)
+}
@@ -0,0 +1,66 @@
+package unchoker
+
+import "sort"
+
+// rateBasedStep is the increase of the upload speed threshold for every slot added by RateBased algorithm.
+const rateBasedStep = 1024
+
+// RateBased is an algorithm that keeps adding upload slots while upload capacity is unused.
+// Peers are sorted by upload speed. A slot is added for each peer that is uploaded faster than a threshold,
+// which starts from 1 KiB/s and increases by 1 KiB/s for each slot.
+// The number of slots is at least one and at most the limit set with SetLimits.
+// Peers to unchoke are selected same as Unchoker.
+type RateBased struct {
+	*Unchoker
+
+	// Number of slots calculated at last call to TickUnchoke.
+	slotsCurrent int
+}
+
+// NewRateBased returns a new RateBased. numUnchoked is the max number of unchoked peers.
+func NewRateBased(numUnchoked, numOptimisticUnchoked int) *RateBased {
+	return &RateBased{
+		Unchoker:     New(numUnchoked, numOptimisticUnchoked),
+		slotsCurrent: 1,
+	}
+}
+
+// FastUnchoke must be called when remote peer is interested.
+// Remote peer is unchoked immediately if there are less unchoked peers than the slots calculated at last round.
+func (u *RateBased) FastUnchoke(pe Peer) {
+	if pe.Choking() && pe.Interested() && len(u.peersUnchoked) < min(u.slotsCurrent, u.numUnchoked) {
+		u.unchokePeer(pe)
+	}
+	if pe.Choking() && pe.Interested() && len(u.peersUnchokedOptimistic) < u.numOptimisticUnchoked {
+		u.optimisticUnchokePeer(pe)
+	}
+}
+
+// TickUnchoke must be called at every 10 seconds.
+func (u *RateBased) TickUnchoke(allPeers []Peer, torrentCompleted bool) {
+	peers := u.candidatesUnchoke(allPeers)
+	u.slotsCurrent = u.slots(peers)
+	u.sortPeers(peers, torrentCompleted)
+	u.unchokeSorted(peers, u.slotsCurrent)
+}
+
+func (u *RateBased) slots(peers []Peer) int {
+	speeds := make([]int, len(peers))
+	for i, pe := range peers {
+		speeds[i] = pe.UploadSpeed()
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(speeds)))
+	slots := 1
+	threshold := rateBasedStep
+	for _, speed := range speeds {
+		if speed < threshold {
+			break
+		}
+		slots++
+		threshold += rateBasedStep
+	}
+	if slots > u.numUnchoked {
+		slots = u.numUnchoked
+	}
+	return slots
+}
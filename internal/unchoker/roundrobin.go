@@ -0,0 +1,95 @@
+package unchoker
+
+import "sort"
+
+// roundRobinRounds is the number of unchoke rounds that a peer keeps its slot while seeding.
+const roundRobinRounds = 3
+
+// RoundRobin is a seed-mode algorithm that gives every interested peer a turn.
+// While seeding, an unchoked peer keeps its slot for 3 rounds, then the peers that have been waiting the longest are unchoked.
+// While downloading, peers are selected by their download speed same as Unchoker.
+type RoundRobin struct {
+	*Unchoker
+
+	// Incremented at every call to TickUnchoke.
+	tick uint64
+	// The tick when the turn of an unchoked peer has started.
+	unchokedAt map[Peer]uint64
+	// The tick when a choked peer has started waiting for a turn.
+	waitingAt map[Peer]uint64
+}
+
+// NewRoundRobin returns a new RoundRobin.
+func NewRoundRobin(numUnchoked, numOptimisticUnchoked int) *RoundRobin {
+	return &RoundRobin{
+		Unchoker:   New(numUnchoked, numOptimisticUnchoked),
+		unchokedAt: make(map[Peer]uint64),
+		waitingAt:  make(map[Peer]uint64),
+	}
+}
+
+// HandleDisconnect must be called to remove the peer from internal indexes.
+func (u *RoundRobin) HandleDisconnect(pe Peer) {
+	u.Unchoker.HandleDisconnect(pe)
+	delete(u.unchokedAt, pe)
+	delete(u.waitingAt, pe)
+}
+
+// TickUnchoke must be called at every 10 seconds.
+func (u *RoundRobin) TickUnchoke(allPeers []Peer, torrentCompleted bool) {
+	u.tick++
+	for _, pe := range allPeers {
+		if !pe.Interested() {
+			delete(u.unchokedAt, pe)
+			delete(u.waitingAt, pe)
+		}
+	}
+	if !torrentCompleted {
+		u.Unchoker.TickUnchoke(allPeers, false)
+		return
+	}
+	peers := u.candidatesUnchoke(allPeers)
+	type order struct {
+		waiting bool
+		since   uint64
+	}
+	orders := make(map[Peer]order, len(peers))
+	for _, pe := range peers {
+		if !pe.Choking() {
+			at, ok := u.unchokedAt[pe]
+			if !ok {
+				// Unchoked by FastUnchoke. Turn starts now.
+				at = u.tick
+				u.unchokedAt[pe] = at
+			}
+			if u.tick-at < roundRobinRounds {
+				orders[pe] = order{since: at}
+				continue
+			}
+			// Turn is over. Go to the end of the queue.
+			delete(u.unchokedAt, pe)
+			u.waitingAt[pe] = u.tick + 1
+		} else if _, ok := u.waitingAt[pe]; !ok {
+			u.waitingAt[pe] = u.tick
+		}
+		orders[pe] = order{waiting: true, since: u.waitingAt[pe]}
+	}
+	sort.SliceStable(peers, func(i, j int) bool {
+		oi, oj := orders[peers[i]], orders[peers[j]]
+		if oi.waiting != oj.waiting {
+			return !oi.waiting
+		}
+		return oi.since < oj.since
+	})
+	u.unchokeSorted(peers, u.numUnchoked)
+	for _, pe := range peers {
+		if pe.Choking() {
+			delete(u.unchokedAt, pe)
+			continue
+		}
+		if _, ok := u.unchokedAt[pe]; !ok {
+			u.unchokedAt[pe] = u.tick
+		}
+		delete(u.waitingAt, pe)
+	}
+}
@@ -5,7 +5,8 @@ import (
 	"sort"
 )
 
-// Unchoker implements an algorithm to select peers to unchoke based on their download speed.
+// Unchoker implements the classic tit-for-tat algorithm that selects peers to unchoke based on their download speed.
+// While seeding, peers are selected based on their upload speed.
 type Unchoker struct {
 	numUnchoked           int
 	numOptimisticUnchoked int
@@ -36,6 +37,9 @@ type Peer interface {
 
 	DownloadSpeed() int
 	UploadSpeed() int
+
+	// HavePieces returns the number of pieces that remote peer has and the total number of pieces in the torrent.
+	HavePieces() (have, total uint32)
 }
 
 // New returns a new Unchoker.
@@ -48,6 +52,17 @@ func New(numUnchoked, numOptimisticUnchoked int) *Unchoker {
 	}
 }
 
+// SetLimits changes the number of unchoked peers. Peers over the limit are choked at next call to TickUnchoke.
+func (u *Unchoker) SetLimits(numUnchoked, numOptimisticUnchoked int) {
+	u.numUnchoked = numUnchoked
+	u.numOptimisticUnchoked = numOptimisticUnchoked
+}
+
+// NumUnchoked returns the number of unchoked peers, including optimistic unchokes.
+func (u *Unchoker) NumUnchoked() int {
+	return len(u.peersUnchoked) + len(u.peersUnchokedOptimistic)
+}
+
 // HandleDisconnect must be called to remove the peer from internal indexes.
 func (u *Unchoker) HandleDisconnect(pe Peer) {
 	delete(u.peersUnchoked, pe)
@@ -76,11 +91,17 @@ func (u *Unchoker) sortPeers(peers []Peer, completed bool) {
 
 // TickUnchoke must be called at every 10 seconds.
 func (u *Unchoker) TickUnchoke(allPeers []Peer, torrentCompleted bool) {
-	optimistic := u.round == 0
 	peers := u.candidatesUnchoke(allPeers)
 	u.sortPeers(peers, torrentCompleted)
+	u.unchokeSorted(peers, u.numUnchoked)
+}
+
+// unchokeSorted unchokes first numUnchoked peers in the list and optimistically unchokes random peers from the rest.
+// Other peers are choked.
+func (u *Unchoker) unchokeSorted(peers []Peer, numUnchoked int) {
+	optimistic := u.round == 0
 	var i, unchoked int
-	for ; i < len(peers) && unchoked < u.numUnchoked; i++ {
+	for ; i < len(peers) && unchoked < numUnchoked; i++ {
 		if !optimistic && peers[i].Optimistic() {
 			continue
 		}
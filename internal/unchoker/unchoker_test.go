@@ -149,13 +149,15 @@ type TestPeer struct {
 	optimistic    bool
 	downloadSpeed int
 	uploadSpeed   int
+	have, total   uint32
 }
 
-func (p *TestPeer) Choke()                   { p.choking = true }
-func (p *TestPeer) Unchoke()                 { p.choking = false }
-func (p *TestPeer) Choking() bool            { return p.choking }
-func (p *TestPeer) Interested() bool         { return p.interested }
-func (p *TestPeer) Optimistic() bool         { return p.optimistic }
-func (p *TestPeer) SetOptimistic(value bool) { p.optimistic = value }
-func (p *TestPeer) DownloadSpeed() int       { return p.downloadSpeed }
-func (p *TestPeer) UploadSpeed() int         { return p.uploadSpeed }
+func (p *TestPeer) Choke()                       { p.choking = true }
+func (p *TestPeer) Unchoke()                     { p.choking = false }
+func (p *TestPeer) Choking() bool                { return p.choking }
+func (p *TestPeer) Interested() bool             { return p.interested }
+func (p *TestPeer) Optimistic() bool             { return p.optimistic }
+func (p *TestPeer) SetOptimistic(value bool)     { p.optimistic = value }
+func (p *TestPeer) DownloadSpeed() int           { return p.downloadSpeed }
+func (p *TestPeer) UploadSpeed() int             { return p.uploadSpeed }
+func (p *TestPeer) HavePieces() (uint32, uint32) { return p.have, p.total }
@@ -0,0 +1,106 @@
+package urldownloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/rain/internal/bufferpool"
+	"github.com/cenkalti/rain/internal/piece"
+)
+
+// Max size of the response body that contains the number of seconds to wait when the HTTP seed is busy.
+const maxRetryAfterBodySize = 64
+
+// runHTTPSeed downloads pieces one by one from a BEP 17 HTTP seed.
+// Each request contains the info hash of the torrent and the index of the piece.
+func (d *URLDownloader) runHTTPSeed(ctx context.Context, cancel context.CancelFunc, client *http.Client, pieces []piece.Piece, resultC chan *PieceResult, pool *bufferpool.Pool, readTimeout time.Duration) {
+	for {
+		index := d.ReadCurrent()
+		buf := pool.Get(int(pieces[index].Length))
+		res := d.downloadHTTPSeedPiece(ctx, cancel, client, index, buf, readTimeout)
+		if res == nil {
+			buf.Release()
+			return
+		}
+		if res.Error != nil {
+			buf.Release()
+			d.sendResult(resultC, res)
+			return
+		}
+		res.Done = index >= d.readEnd()-1
+		d.sendResult(resultC, res)
+		if res.Done {
+			return
+		}
+		d.incrCurrent()
+	}
+}
+
+// downloadHTTPSeedPiece reads the piece into buf. Returns nil if the downloader is closed.
+func (d *URLDownloader) downloadHTTPSeedPiece(ctx context.Context, cancel context.CancelFunc, client *http.Client, index uint32, buf bufferpool.Buffer, readTimeout time.Duration) *PieceResult {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.getHTTPSeedURL(index, len(buf.Data)), nil)
+	if err != nil {
+		return &PieceResult{Downloader: d, Error: err}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return &PieceResult{Downloader: d, Error: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusServiceUnavailable {
+		retryAfter, err := readRetryAfter(resp.Body)
+		if err != nil {
+			return &PieceResult{Downloader: d, Error: err}
+		}
+		return &PieceResult{Downloader: d, Error: fmt.Errorf("http seed is busy, retry after %s", retryAfter), RetryAfter: retryAfter}
+	}
+	err = checkStatus(resp)
+	if err != nil {
+		return &PieceResult{Downloader: d, Error: err}
+	}
+	timer := time.AfterFunc(readTimeout, cancel)
+	defer timer.Stop()
+	if d.bucket != nil {
+		waitDuration := d.bucket.Take(int64(len(buf.Data)))
+		select {
+		case <-time.After(waitDuration):
+		case <-d.closeC:
+			return nil
+		}
+	}
+	_, err = readFull(resp.Body, buf.Data, timer, readTimeout)
+	if err != nil {
+		return &PieceResult{Downloader: d, Error: err}
+	}
+	return &PieceResult{Downloader: d, Buffer: buf, Index: index}
+}
+
+// getHTTPSeedURL returns the URL for requesting the piece with the whole range of the piece data.
+func (d *URLDownloader) getHTTPSeedURL(index uint32, length int) string {
+	sep := "?"
+	if strings.Contains(d.URL, "?") {
+		sep = "&"
+	}
+	return d.URL + sep + "info_hash=" + url.QueryEscape(string(d.infoHash)) +
+		"&piece=" + strconv.FormatUint(uint64(index), 10) +
+		"&ranges=0-" + strconv.Itoa(length-1)
+}
+
+// readRetryAfter parses the body of a 503 response that contains the number of seconds to wait.
+func readRetryAfter(r io.Reader) (time.Duration, error) {
+	b, err := io.ReadAll(io.LimitReader(r, maxRetryAfterBodySize))
+	if err != nil {
+		return 0, err
+	}
+	seconds, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil || seconds < 0 {
+		return 0, fmt.Errorf("invalid retry value in http seed response: %q", b)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
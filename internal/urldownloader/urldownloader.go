@@ -20,6 +20,9 @@ type URLDownloader struct {
 	Begin, End, current uint32 // piece index
 	bucket              *ratelimit.Bucket
 	closeC, doneC       chan struct{}
+
+	// Pieces are requested with info_hash and piece parameters from a BEP 17 HTTP seed if set.
+	infoHash []byte
 }
 
 // PieceResult wraps the downloaded piece data.
@@ -29,6 +32,8 @@ type PieceResult struct {
 	Index      uint32
 	Error      error
 	Done       bool // URL downloader finished downloading all requested pieces
+	// Server is busy and the source must not be contacted again until this duration has passed.
+	RetryAfter time.Duration
 }
 
 // New returns a new URLDownloader for the given source and piece range.
@@ -44,6 +49,13 @@ func New(source string, begin, end uint32, b *ratelimit.Bucket) *URLDownloader {
 	}
 }
 
+// NewHTTPSeed returns a new URLDownloader that downloads pieces from a BEP 17 HTTP seed.
+func NewHTTPSeed(source string, infoHash []byte, begin, end uint32, b *ratelimit.Bucket) *URLDownloader {
+	d := New(source, begin, end, b)
+	d.infoHash = infoHash
+	return d
+}
+
 // Close the URLDownloader.
 func (d *URLDownloader) Close() {
 	close(d.closeC)
@@ -85,6 +97,11 @@ func (d *URLDownloader) Run(client *http.Client, pieces []piece.Piece, multifile
 		cancel()
 	}()
 
+	if d.infoHash != nil {
+		d.runHTTPSeed(ctx, cancel, client, pieces, resultC, pool, readTimeout)
+		return
+	}
+
 	jobs := createJobs(pieces, d.Begin, d.readEnd())
 
 	var n int // position in piece
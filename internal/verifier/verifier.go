@@ -2,18 +2,26 @@ package verifier
 
 import (
 	"crypto/sha1"
+	"sync"
+	"time"
 
 	"github.com/cenkalti/rain/internal/bitfield"
 	"github.com/cenkalti/rain/internal/piece"
+	"github.com/juju/ratelimit"
 )
 
 // Verifier verifies the pieces on disk.
 type Verifier struct {
 	Bitfield *bitfield.Bitfield
 	Error    error
+	// SHA-1 hashes of the pieces that have no known hash.
+	// Pieces of merkle torrents (BEP 30) are verified against the root hash after all of them are hashed.
+	Hashes map[uint32][]byte
 
-	closeC chan struct{}
-	doneC  chan struct{}
+	workers int
+	bucket  *ratelimit.Bucket
+	closeC  chan struct{}
+	doneC   chan struct{}
 }
 
 // Progress information about the verification.
@@ -22,10 +30,17 @@ type Progress struct {
 }
 
 // New returns a new Verifier.
-func New() *Verifier {
+// Pieces are hashed in parallel by given number of workers.
+// If bucket is not nil, reads from disk are limited by it.
+func New(workers int, bucket *ratelimit.Bucket) *Verifier {
+	if workers < 1 {
+		workers = 1
+	}
 	return &Verifier{
-		closeC: make(chan struct{}),
-		doneC:  make(chan struct{}),
+		workers: workers,
+		bucket:  bucket,
+		closeC:  make(chan struct{}),
+		doneC:   make(chan struct{}),
 	}
 }
 
@@ -35,8 +50,17 @@ func (v *Verifier) Close() {
 	<-v.doneC
 }
 
-// Run and verify all pieces of the torrent.
-func (v *Verifier) Run(pieces []piece.Piece, progressC chan Progress, resultC chan *Verifier) {
+type result struct {
+	index uint32
+	ok    bool
+	hash  []byte
+	err   error
+}
+
+// Run and verify the pieces of the torrent.
+// If check is nil, all pieces are verified.
+// Otherwise only the pieces set in check are verified and the state of other pieces is copied from bf.
+func (v *Verifier) Run(pieces []piece.Piece, bf, check *bitfield.Bitfield, progressC chan Progress, resultC chan *Verifier) {
 	defer close(v.doneC)
 
 	defer func() {
@@ -47,25 +71,129 @@ func (v *Verifier) Run(pieces []piece.Piece, progressC chan Progress, resultC ch
 	}()
 
 	v.Bitfield = bitfield.New(uint32(len(pieces)))
-	buf := make([]byte, pieces[0].Length)
-	hash := sha1.New()
-	var numOK uint32
+	var checked, total uint32
 	for _, p := range pieces {
-		buf = buf[:p.Length]
-		_, v.Error = p.Data.ReadAt(buf, 0)
-		if v.Error != nil {
+		if check == nil || check.Test(p.Index) {
+			total++
+		} else if bf != nil && bf.Test(p.Index) {
+			v.Bitfield.Set(p.Index)
+		}
+	}
+	checked = uint32(len(pieces)) - total
+
+	// Workers are stopped when stopC is closed, either by Close or an error.
+	// Files must not be accessed after Run returns.
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	stopC := make(chan struct{})
+	defer close(stopC)
+
+	indexC := make(chan uint32)
+	wg.Add(1 + v.workers)
+	go func() {
+		defer wg.Done()
+		for _, p := range pieces {
+			if check != nil && !check.Test(p.Index) {
+				continue
+			}
+			select {
+			case indexC <- p.Index:
+			case <-stopC:
+				return
+			}
+		}
+		close(indexC)
+	}()
+
+	results := make(chan result)
+	for i := 0; i < v.workers; i++ {
+		go func() {
+			defer wg.Done()
+			v.worker(pieces, indexC, results, stopC)
+		}()
+	}
+
+	for ; total > 0; total-- {
+		var res result
+		select {
+		case res = <-results:
+		case <-v.closeC:
 			return
 		}
-		ok := p.VerifyHash(buf, hash)
-		if ok {
-			v.Bitfield.Set(p.Index)
-			numOK++
+		if res.err != nil {
+			v.Error = res.err
+			return
+		}
+		if res.ok {
+			v.Bitfield.Set(res.index)
 		}
+		if res.hash != nil {
+			if v.Hashes == nil {
+				v.Hashes = make(map[uint32][]byte)
+			}
+			v.Hashes[res.index] = res.hash
+		}
+		checked++
 		select {
-		case progressC <- Progress{Checked: p.Index + 1}:
+		case progressC <- Progress{Checked: checked}:
 		case <-v.closeC:
 			return
 		}
-		hash.Reset()
+	}
+}
+
+func (v *Verifier) worker(pieces []piece.Piece, indexC chan uint32, results chan result, stopC chan struct{}) {
+	buf := make([]byte, pieces[0].Length)
+	hash := sha1.New()
+	for {
+		var i uint32
+		var ok bool
+		select {
+		case i, ok = <-indexC:
+			if !ok {
+				return
+			}
+		case <-stopC:
+			return
+		}
+		p := &pieces[i]
+		res := result{index: i}
+		if !v.wait(int64(p.Length), stopC) {
+			return
+		}
+		buf = buf[:p.Length]
+		_, res.err = p.Data.ReadAt(buf, 0)
+		if res.err == nil && p.Hash == nil {
+			_, _ = hash.Write(buf)
+			res.hash = hash.Sum(nil)
+			hash.Reset()
+		} else if res.err == nil {
+			res.ok = p.VerifyHash(buf, hash)
+			hash.Reset()
+		}
+		select {
+		case results <- res:
+		case <-stopC:
+			return
+		}
+	}
+}
+
+// wait until n bytes can be read from disk. Returns false if stopC is closed before.
+func (v *Verifier) wait(n int64, stopC chan struct{}) bool {
+	if v.bucket == nil {
+		return true
+	}
+	d := v.bucket.Take(n)
+	if d <= 0 {
+		return true
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-stopC:
+		return false
 	}
 }
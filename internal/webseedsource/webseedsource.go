@@ -10,6 +10,7 @@ import (
 // WebseedSource is a URL for downloading torrent data from web sources.
 type WebseedSource struct {
 	URL           string
+	HTTPSeed      bool // BEP 17 source that is requested with info hash and piece index instead of file paths
 	Disabled      bool
 	Downloader    *urldownloader.URLDownloader
 	LastError     error
@@ -29,6 +30,15 @@ func NewList(sources []string) []*WebseedSource {
 	return l
 }
 
+// NewHTTPSeedList returns a new WebseedSource list from BEP 17 HTTP seed URLs.
+func NewHTTPSeedList(sources []string) []*WebseedSource {
+	l := NewList(sources)
+	for _, src := range l {
+		src.HTTPSeed = true
+	}
+	return l
+}
+
 // Downloading returns true if data is being downloaded from this source.
 func (s *WebseedSource) Downloading() bool {
 	return s.Downloader != nil
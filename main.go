@@ -1,8 +1,10 @@
 package main
 
 import (
+	"crypto/ed25519"
 	"crypto/sha1"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -24,6 +26,7 @@ import (
 	"github.com/cenkalti/rain/internal/logger"
 	"github.com/cenkalti/rain/internal/magnet"
 	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/cenkalti/rain/internal/rpctypes"
 	"github.com/cenkalti/rain/rainrpc"
 	"github.com/cenkalti/rain/torrent"
 	"github.com/hokaccha/go-prettyjson"
@@ -198,6 +201,22 @@ func main() {
 							Name:  "id",
 							Usage: "if id is not given, a unique id is automatically generated",
 						},
+						cli.StringFlag{
+							Name:  "label",
+							Usage: "label of the torrent for selecting download directories",
+						},
+						cli.BoolFlag{
+							Name:  "own-port",
+							Usage: "listen on a separate port even if shared port is enabled",
+						},
+						cli.StringFlag{
+							Name:  "choking-algorithm",
+							Usage: "algorithm for selecting peers to unchoke: tit-for-tat, round-robin, anti-leech or rate-based",
+						},
+						cli.BoolFlag{
+							Name:  "first-last-pieces",
+							Usage: "download first and last pieces of each file first",
+						},
 					},
 				},
 				{
@@ -246,6 +265,64 @@ func main() {
 						},
 					},
 				},
+				{
+					Name:     "dht-get",
+					Usage:    "get bencoded value of an item from DHT",
+					Category: "Getters",
+					Action:   handleDHTGet,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "target",
+							Usage: "hex encoded `TARGET` of immutable item",
+						},
+						cli.StringFlag{
+							Name:  "key",
+							Usage: "hex encoded public `KEY` of mutable item",
+						},
+						cli.StringFlag{
+							Name:  "salt",
+							Usage: "`SALT` of mutable item",
+						},
+					},
+				},
+				{
+					Name:     "dht-put",
+					Usage:    "store bencoded value in DHT",
+					Category: "Actions",
+					Action:   handleDHTPut,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:     "value",
+							Usage:    "bencoded `VALUE` to store",
+							Required: true,
+						},
+						cli.StringFlag{
+							Name:  "private-key",
+							Usage: "hex encoded private `KEY` for storing mutable item, immutable item is stored if not given",
+						},
+						cli.StringFlag{
+							Name:  "salt",
+							Usage: "`SALT` of mutable item",
+						},
+						cli.Int64Flag{
+							Name:  "seq",
+							Usage: "sequence number of mutable item",
+						},
+					},
+				},
+				{
+					Name:     "dht-sample",
+					Usage:    "get sample of info hashes from a DHT node",
+					Category: "Getters",
+					Action:   handleDHTSample,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:     "addr",
+							Usage:    "address of DHT node in `HOST:PORT` format",
+							Required: true,
+						},
+					},
+				},
 				{
 					Name:     "trackers",
 					Usage:    "get trackers of torrent",
@@ -270,6 +347,18 @@ func main() {
 						},
 					},
 				},
+				{
+					Name:     "bans",
+					Usage:    "get banned IPs of torrent or session",
+					Category: "Getters",
+					Action:   handleBans,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "id",
+							Usage: "torrent id, session-wide bans are listed if not given",
+						},
+					},
+				},
 				{
 					Name:     "peers",
 					Usage:    "get peers of torrent",
@@ -299,6 +388,61 @@ func main() {
 						},
 					},
 				},
+				{
+					Name:     "disconnect-peer",
+					Usage:    "disconnect peer of torrent",
+					Category: "Actions",
+					Action:   handleDisconnectPeer,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:     "id",
+							Required: true,
+						},
+						cli.StringFlag{
+							Name:     "addr",
+							Usage:    "peer address in ip:port format",
+							Required: true,
+						},
+					},
+				},
+				{
+					Name:     "ban",
+					Usage:    "ban IP address or range",
+					Category: "Actions",
+					Action:   handleBan,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "id",
+							Usage: "torrent id, IP is banned in all torrents if not given",
+						},
+						cli.StringFlag{
+							Name:     "addr",
+							Usage:    "IP address or range in CIDR notation",
+							Required: true,
+						},
+						cli.DurationFlag{
+							Name:  "ttl",
+							Usage: "duration of the ban, ban does not expire if not given",
+						},
+					},
+				},
+				{
+					Name:     "unban",
+					Usage:    "remove ban of IP address or range",
+					Category: "Actions",
+					Action:   handleUnban,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "id",
+							Usage: "torrent id, session-wide ban is removed if not given",
+						},
+						cli.StringFlag{
+							Name:     "addr",
+							Usage:    "IP address or range in CIDR notation",
+							Required: true,
+						},
+					},
+				},
 				{
 					Name:     "add-tracker",
 					Usage:    "add tracker to torrent",
@@ -333,6 +477,69 @@ func main() {
 					Usage:    "verify files",
 					Category: "Actions",
 					Action:   handleVerify,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:     "id",
+							Required: true,
+						},
+						cli.StringSliceFlag{
+							Name:  "file",
+							Usage: "verify only the pieces of file at `PATH`",
+						},
+						cli.StringSliceFlag{
+							Name:  "pieces",
+							Usage: "verify only the pieces in `RANGE` given as BEGIN-END, END is not included",
+						},
+					},
+				},
+				{
+					Name:     "piece-deadline",
+					Usage:    "set the time that a piece is needed",
+					Category: "Actions",
+					Action:   handlePieceDeadline,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:     "id",
+							Required: true,
+						},
+						cli.UintFlag{
+							Name:     "index",
+							Usage:    "piece index",
+							Required: true,
+						},
+						cli.DurationFlag{
+							Name:  "deadline",
+							Usage: "duration from now that the piece is needed, deadline is cleared if not given",
+						},
+					},
+				},
+				{
+					Name:     "piece-priority",
+					Usage:    "set download priority of a piece",
+					Category: "Actions",
+					Action:   handlePiecePriority,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:     "id",
+							Required: true,
+						},
+						cli.UintFlag{
+							Name:     "index",
+							Usage:    "piece index",
+							Required: true,
+						},
+						cli.StringFlag{
+							Name:  "priority",
+							Usage: "`PRIORITY` of the piece: normal or high",
+							Value: "normal",
+						},
+					},
+				},
+				{
+					Name:     "recheck",
+					Usage:    "verify missing pieces and start torrent",
+					Category: "Actions",
+					Action:   handleRecheck,
 					Flags: []cli.Flag{
 						cli.StringFlag{
 							Name:     "id",
@@ -513,6 +720,15 @@ func main() {
 							Name:  "comment,c",
 							Usage: "add `COMMENT` to torrent",
 						},
+						cli.StringFlag{
+							Name:  "meta-version",
+							Usage: "BitTorrent protocol version of the torrent: v1, v2 or hybrid",
+							Value: "v1",
+						},
+						cli.BoolFlag{
+							Name:  "merkle",
+							Usage: "create a merkle torrent (BEP 30) that contains only the root hash of pieces. only for v1 torrents.",
+						},
 						cli.StringSliceFlag{
 							Name:  "tracker,t",
 							Usage: "add tracker `URL`",
@@ -521,6 +737,29 @@ func main() {
 							Name:  "webseed,w",
 							Usage: "add webseed `URL`",
 						},
+						cli.StringFlag{
+							Name:  "sign-key",
+							Usage: "sign info dictionary with the ed25519 private key in PEM `FILE` (BEP 35). file may contain the X.509 certificate of the key.",
+						},
+						cli.StringFlag{
+							Name:  "signer",
+							Usage: "`NAME` of the signer. by default, common name in the certificate is used.",
+						},
+					},
+				},
+				{
+					Name:   "verify-signature",
+					Usage:  "verify signatures in torrent file",
+					Action: handleTorrentVerifySignature,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:     "file,f",
+							Required: true,
+						},
+						cli.StringSliceFlag{
+							Name:  "key,k",
+							Usage: "hex encoded ed25519 public `KEY` of a trusted signer. if not given, keys in the certificates are used.",
+						},
 					},
 				},
 			},
@@ -684,6 +923,8 @@ func handleDownload(c *cli.Context) error {
 	}
 	cfg.DataDir = "."
 	cfg.DataDirIncludesTorrentID = false
+	cfg.IncompleteDir = ""
+	cfg.CompleteDir = ""
 	var ih torrent.InfoHash
 	if strings.HasPrefix(arg, "magnet:") {
 		magnet, err := magnet.New(arg)
@@ -899,6 +1140,10 @@ func handleAdd(c *cli.Context) error {
 		StopAfterDownload: c.Bool("stop-after-download"),
 		StopAfterMetadata: c.Bool("stop-after-metadata"),
 		ID:                c.String("id"),
+		Label:             c.String("label"),
+		OwnPort:           c.Bool("own-port"),
+		ChokingAlgorithm:  c.String("choking-algorithm"),
+		FirstLastPieces:   c.Bool("first-last-pieces"),
 	}
 	if isURI(arg) {
 		resp, err := clt.AddURI(arg, addOpt)
@@ -998,6 +1243,26 @@ func handleWebseeds(c *cli.Context) error {
 	return nil
 }
 
+func handleBans(c *cli.Context) error {
+	var resp []rpctypes.Ban
+	var err error
+	if id := c.String("id"); id != "" {
+		resp, err = clt.GetTorrentBans(id)
+	} else {
+		resp, err = clt.GetSessionBans()
+	}
+	if err != nil {
+		return err
+	}
+	b, err := prettyjson.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	_, _ = os.Stdout.Write(b)
+	_, _ = os.Stdout.WriteString("\n")
+	return nil
+}
+
 func handlePeers(c *cli.Context) error {
 	resp, err := clt.GetTorrentPeers(c.String("id"))
 	if err != nil {
@@ -1016,6 +1281,18 @@ func handleAddPeer(c *cli.Context) error {
 	return clt.AddPeer(c.String("id"), c.String("addr"))
 }
 
+func handleDisconnectPeer(c *cli.Context) error {
+	return clt.DisconnectPeer(c.String("id"), c.String("addr"))
+}
+
+func handleBan(c *cli.Context) error {
+	return clt.BanPeer(c.String("id"), c.String("addr"), c.Duration("ttl"))
+}
+
+func handleUnban(c *cli.Context) error {
+	return clt.UnbanPeer(c.String("id"), c.String("addr"))
+}
+
 func handleAddTracker(c *cli.Context) error {
 	return clt.AddTracker(c.String("id"), c.String("tracker"))
 }
@@ -1025,7 +1302,58 @@ func handleAnnounce(c *cli.Context) error {
 }
 
 func handleVerify(c *cli.Context) error {
-	return clt.VerifyTorrent(c.String("id"))
+	var opts *rainrpc.VerifyTorrentOptions
+	files := c.StringSlice("file")
+	ranges := c.StringSlice("pieces")
+	if len(files) > 0 || len(ranges) > 0 {
+		opts = &rainrpc.VerifyTorrentOptions{Files: files}
+		for _, s := range ranges {
+			r, err := parsePieceRange(s)
+			if err != nil {
+				return err
+			}
+			opts.Pieces = append(opts.Pieces, r)
+		}
+	}
+	return clt.VerifyTorrent(c.String("id"), opts)
+}
+
+func parsePieceRange(s string) (r rpctypes.PieceRange, err error) {
+	begin, end, ok := strings.Cut(s, "-")
+	if !ok {
+		return r, fmt.Errorf("invalid piece range: %s", s)
+	}
+	b, err := strconv.ParseUint(begin, 10, 32)
+	if err != nil {
+		return r, fmt.Errorf("invalid piece range: %s", s)
+	}
+	e, err := strconv.ParseUint(end, 10, 32)
+	if err != nil {
+		return r, fmt.Errorf("invalid piece range: %s", s)
+	}
+	r.Begin, r.End = uint32(b), uint32(e)
+	return r, nil
+}
+
+func handlePieceDeadline(c *cli.Context) error {
+	return clt.SetPieceDeadline(c.String("id"), uint32(c.Uint("index")), c.Duration("deadline"))
+}
+
+func handlePiecePriority(c *cli.Context) error {
+	var priority int
+	switch c.String("priority") {
+	case "normal":
+		priority = 0
+	case "high":
+		priority = 1
+	default:
+		return fmt.Errorf("invalid piece priority: %s", c.String("priority"))
+	}
+	return clt.SetPiecePriority(c.String("id"), uint32(c.Uint("index")), priority)
+}
+
+func handleRecheck(c *cli.Context) error {
+	return clt.RecheckTorrent(c.String("id"))
 }
 
 func handleStart(c *cli.Context) error {
@@ -1110,6 +1438,10 @@ func handleTorrentCreate(c *cli.Context) error {
 	comment := c.String("comment")
 	trackers := c.StringSlice("tracker")
 	webseeds := c.StringSlice("webseed")
+	metaVersion := c.String("meta-version")
+	merkle := c.Bool("merkle")
+	signKey := c.String("sign-key")
+	signerName := c.String("signer")
 
 	var err error
 	out, err = homedir.Expand(out)
@@ -1128,11 +1460,43 @@ func handleTorrentCreate(c *cli.Context) error {
 		tiers[i] = []string{tr}
 	}
 
-	info, err := metainfo.NewInfoBytes(root, paths, private, uint32(pieceLength<<10), name, log)
+	var info, pieceLayers []byte
+	switch metaVersion {
+	case "v1":
+		if merkle {
+			info, err = metainfo.NewInfoBytesMerkle(root, paths, private, uint32(pieceLength<<10), name, log)
+		} else {
+			info, err = metainfo.NewInfoBytes(root, paths, private, uint32(pieceLength<<10), name, log)
+		}
+	case "v2", "hybrid":
+		if merkle {
+			return errors.New("merkle torrents can only be created with v1 meta version")
+		}
+		info, pieceLayers, err = metainfo.NewInfoBytesV2(root, paths, private, uint32(pieceLength<<10), name, metaVersion == "hybrid", log)
+	default:
+		return fmt.Errorf("invalid meta version: %s", metaVersion)
+	}
 	if err != nil {
 		return err
 	}
-	mi, err := metainfo.NewBytes(info, tiers, webseeds, comment)
+	var signers []metainfo.Signer
+	if signKey != "" {
+		signKey, err = homedir.Expand(signKey)
+		if err != nil {
+			return err
+		}
+		b, err := os.ReadFile(signKey)
+		if err != nil {
+			return err
+		}
+		signer, err := metainfo.NewKeySigner(signerName, b)
+		if err != nil {
+			return err
+		}
+		log.Infof("Signing as %q with public key: %x", signer.Name, signer.Key.Public())
+		signers = append(signers, signer)
+	}
+	mi, err := metainfo.NewBytes(info, pieceLayers, tiers, webseeds, comment, signers...)
 	if err != nil {
 		return err
 	}
@@ -1148,6 +1512,51 @@ func handleTorrentCreate(c *cli.Context) error {
 	return f.Close()
 }
 
+func handleTorrentVerifySignature(c *cli.Context) error {
+	f, err := os.Open(c.String("file"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	mi, err := metainfo.New(f)
+	if err != nil {
+		return err
+	}
+	if len(mi.Signatures) == 0 {
+		return errors.New("torrent is not signed")
+	}
+	var keys []ed25519.PublicKey
+	for _, s := range c.StringSlice("key") {
+		b, err := hex.DecodeString(s)
+		if err != nil || len(b) != ed25519.PublicKeySize {
+			return fmt.Errorf("invalid key: %q", s)
+		}
+		keys = append(keys, b)
+	}
+	var valid int
+	for _, sig := range mi.Signatures {
+		sigKeys := keys
+		if len(sigKeys) == 0 {
+			key, err := sig.PublicKey()
+			if err != nil {
+				fmt.Printf("%q: cannot verify: %s\n", sig.Signer, err)
+				continue
+			}
+			sigKeys = []ed25519.PublicKey{key}
+		}
+		if signer := metainfo.TrustedSigner(mi.Info.Bytes, []metainfo.Signature{sig}, sigKeys); signer != "" {
+			valid++
+			fmt.Printf("%q: valid\n", sig.Signer)
+		} else {
+			fmt.Printf("%q: invalid\n", sig.Signer)
+		}
+	}
+	if valid == 0 {
+		return errors.New("no valid signature")
+	}
+	return nil
+}
+
 func handleSaveTorrent(c *cli.Context) error {
 	torrent, err := clt.GetTorrent(c.String("id"))
 	if err != nil {
@@ -1164,6 +1573,53 @@ func handleSaveTorrent(c *cli.Context) error {
 	return f.Close()
 }
 
+func handleDHTGet(c *cli.Context) error {
+	if c.String("key") != "" {
+		value, seq, err := clt.DHTGetMutable(c.String("key"), c.String("salt"))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("seq: %d\n%s\n", seq, value)
+		return nil
+	}
+	value, err := clt.DHTGet(c.String("target"))
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(value))
+	return nil
+}
+
+func handleDHTPut(c *cli.Context) error {
+	value := []byte(c.String("value"))
+	var target string
+	var err error
+	if c.String("private-key") != "" {
+		target, err = clt.DHTPutMutable(c.String("private-key"), c.String("salt"), value, c.Int64("seq"))
+	} else {
+		target, err = clt.DHTPut(value)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println(target)
+	return nil
+}
+
+func handleDHTSample(c *cli.Context) error {
+	resp, err := clt.DHTSampleInfoHashes(c.String("addr"))
+	if err != nil {
+		return err
+	}
+	b, err := prettyjson.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	_, _ = os.Stdout.Write(b)
+	_, _ = os.Stdout.WriteString("\n")
+	return nil
+}
+
 func handleGetMagnet(c *cli.Context) error {
 	magnet, err := clt.GetMagnet(c.String("id"))
 	if err != nil {
@@ -63,6 +63,10 @@ type AddTorrentOptions struct {
 	Stopped           bool
 	StopAfterDownload bool
 	StopAfterMetadata bool
+	Label             string
+	OwnPort           bool
+	ChokingAlgorithm  string
+	FirstLastPieces   bool
 }
 
 // AddTorrent adds a new torrent by reading .torrent file.
@@ -77,6 +81,10 @@ func (c *Client) AddTorrent(f io.Reader, options *AddTorrentOptions) (*rpctypes.
 		args.AddTorrentOptions.Stopped = options.Stopped
 		args.AddTorrentOptions.StopAfterDownload = options.StopAfterDownload
 		args.AddTorrentOptions.StopAfterMetadata = options.StopAfterMetadata
+		args.AddTorrentOptions.Label = options.Label
+		args.AddTorrentOptions.OwnPort = options.OwnPort
+		args.AddTorrentOptions.ChokingAlgorithm = options.ChokingAlgorithm
+		args.AddTorrentOptions.FirstLastPieces = options.FirstLastPieces
 	}
 	var reply rpctypes.AddTorrentResponse
 	return &reply.Torrent, c.client.Call("Session.AddTorrent", args, &reply)
@@ -90,6 +98,10 @@ func (c *Client) AddURI(uri string, options *AddTorrentOptions) (*rpctypes.Torre
 		args.AddTorrentOptions.Stopped = options.Stopped
 		args.AddTorrentOptions.StopAfterDownload = options.StopAfterDownload
 		args.AddTorrentOptions.StopAfterMetadata = options.StopAfterMetadata
+		args.AddTorrentOptions.Label = options.Label
+		args.AddTorrentOptions.OwnPort = options.OwnPort
+		args.AddTorrentOptions.ChokingAlgorithm = options.ChokingAlgorithm
+		args.AddTorrentOptions.FirstLastPieces = options.FirstLastPieces
 	}
 	var reply rpctypes.AddURIResponse
 	return &reply.Torrent, c.client.Call("Session.AddURI", args, &reply)
@@ -163,6 +175,20 @@ func (c *Client) GetTorrentWebseeds(id string) ([]rpctypes.Webseed, error) {
 	return reply.Webseeds, c.client.Call("Session.GetTorrentWebseeds", args, &reply)
 }
 
+// GetTorrentBans returns the IP addresses that are banned in a torrent, including the ones banned in all torrents.
+func (c *Client) GetTorrentBans(id string) ([]rpctypes.Ban, error) {
+	args := rpctypes.GetTorrentBansRequest{ID: id}
+	var reply rpctypes.GetTorrentBansResponse
+	return reply.Bans, c.client.Call("Session.GetTorrentBans", args, &reply)
+}
+
+// GetSessionBans returns the IP addresses that are banned in all torrents.
+func (c *Client) GetSessionBans() ([]rpctypes.Ban, error) {
+	args := rpctypes.GetSessionBansRequest{}
+	var reply rpctypes.GetSessionBansResponse
+	return reply.Bans, c.client.Call("Session.GetSessionBans", args, &reply)
+}
+
 // StartTorrent starts the torrent.
 func (c *Client) StartTorrent(id string) error {
 	args := rpctypes.StartTorrentRequest{ID: id}
@@ -184,14 +210,89 @@ func (c *Client) AnnounceTorrent(id string) error {
 	return c.client.Call("Session.AnnounceTorrent", args, &reply)
 }
 
-// VerifyTorrent stops the torrent and verifies all of the pieces on disk.
+// VerifyTorrentOptions contains optional parameters for verifying only some of the pieces of a Torrent.
+type VerifyTorrentOptions struct {
+	// Verify the pieces of these files.
+	Files []string
+	// Verify the pieces in these ranges.
+	Pieces []rpctypes.PieceRange
+}
+
+// VerifyTorrent stops the torrent and verifies the pieces on disk.
+// All pieces are verified if options is nil.
 // After verification is done, the torrent stays in stopped state.
-func (c *Client) VerifyTorrent(id string) error {
+func (c *Client) VerifyTorrent(id string, options *VerifyTorrentOptions) error {
 	args := rpctypes.VerifyTorrentRequest{ID: id}
+	if options != nil {
+		args.Files = options.Files
+		args.Pieces = options.Pieces
+	}
 	var reply rpctypes.VerifyTorrentResponse
 	return c.client.Call("Session.VerifyTorrent", args, &reply)
 }
 
+// RecheckTorrent verifies the missing pieces of the torrent on disk and starts the torrent.
+// Pieces that are still missing after verification are downloaded again.
+func (c *Client) RecheckTorrent(id string) error {
+	args := rpctypes.RecheckTorrentRequest{ID: id}
+	var reply rpctypes.RecheckTorrentResponse
+	return c.client.Call("Session.RecheckTorrent", args, &reply)
+}
+
+// SetPieceDeadline sets the time that the piece at index is needed, relative to now.
+// Pieces with deadlines are downloaded before other pieces, earliest deadline first.
+// Zero deadline clears the deadline of the piece.
+func (c *Client) SetPieceDeadline(id string, index uint32, deadline time.Duration) error {
+	args := rpctypes.SetPieceDeadlineRequest{ID: id, Index: index, Deadline: deadline.Milliseconds()}
+	var reply rpctypes.SetPieceDeadlineResponse
+	return c.client.Call("Session.SetPieceDeadline", args, &reply)
+}
+
+// SetPiecePriority sets the download priority of the piece at index. Priority is 0 for normal, 1 for high.
+func (c *Client) SetPiecePriority(id string, index uint32, priority int) error {
+	args := rpctypes.SetPiecePriorityRequest{ID: id, Index: index, Priority: priority}
+	var reply rpctypes.SetPiecePriorityResponse
+	return c.client.Call("Session.SetPiecePriority", args, &reply)
+}
+
+// DHTGet looks up the bencoded value of the immutable item with the hex encoded target in DHT (BEP 44).
+func (c *Client) DHTGet(target string) ([]byte, error) {
+	args := rpctypes.DHTGetRequest{Target: target}
+	var reply rpctypes.DHTGetResponse
+	return reply.Value, c.client.Call("Session.DHTGet", args, &reply)
+}
+
+// DHTGetMutable looks up the mutable item with the hex encoded public key and salt in DHT (BEP 44).
+// It returns the bencoded value and the sequence number of the item.
+func (c *Client) DHTGetMutable(key, salt string) (value []byte, seq int64, err error) {
+	args := rpctypes.DHTGetRequest{Key: key, Salt: salt}
+	var reply rpctypes.DHTGetResponse
+	err = c.client.Call("Session.DHTGet", args, &reply)
+	return reply.Value, reply.Seq, err
+}
+
+// DHTPut stores the bencoded value in DHT as an immutable item and returns its hex encoded target (BEP 44).
+func (c *Client) DHTPut(value []byte) (string, error) {
+	args := rpctypes.DHTPutRequest{Value: value}
+	var reply rpctypes.DHTPutResponse
+	return reply.Target, c.client.Call("Session.DHTPut", args, &reply)
+}
+
+// DHTPutMutable signs the bencoded value with the hex encoded ed25519 private key and stores it in DHT as a mutable item (BEP 44).
+// It returns the hex encoded target of the item.
+func (c *Client) DHTPutMutable(privateKey, salt string, value []byte, seq int64) (string, error) {
+	args := rpctypes.DHTPutRequest{Value: value, PrivateKey: privateKey, Salt: salt, Seq: seq}
+	var reply rpctypes.DHTPutResponse
+	return reply.Target, c.client.Call("Session.DHTPut", args, &reply)
+}
+
+// DHTSampleInfoHashes queries the DHT node at addr in "host:port" format for a sample of the info hashes that it stores (BEP 51).
+func (c *Client) DHTSampleInfoHashes(addr string) (*rpctypes.DHTSampleInfoHashesResponse, error) {
+	args := rpctypes.DHTSampleInfoHashesRequest{Addr: addr}
+	var reply rpctypes.DHTSampleInfoHashesResponse
+	return &reply, c.client.Call("Session.DHTSampleInfoHashes", args, &reply)
+}
+
 // MoveTorrent moves the torrent to another Session.
 func (c *Client) MoveTorrent(id, target string) error {
 	args := rpctypes.MoveTorrentRequest{ID: id, Target: target}
@@ -220,6 +321,29 @@ func (c *Client) AddPeer(id string, addr string) error {
 	return c.client.Call("Session.AddPeer", args, &reply)
 }
 
+// DisconnectPeer closes the connection to the peer with the address in a torrent.
+func (c *Client) DisconnectPeer(id string, addr string) error {
+	args := rpctypes.DisconnectPeerRequest{ID: id, Addr: addr}
+	var reply rpctypes.DisconnectPeerResponse
+	return c.client.Call("Session.DisconnectPeer", args, &reply)
+}
+
+// BanPeer bans the IP address or range in CIDR notation for ttl.
+// If id is empty, the IP is banned in all torrents. Zero ttl means the ban does not expire.
+func (c *Client) BanPeer(id string, addr string, ttl time.Duration) error {
+	args := rpctypes.BanPeerRequest{ID: id, Addr: addr, TTL: int(ttl / time.Second)}
+	var reply rpctypes.BanPeerResponse
+	return c.client.Call("Session.BanPeer", args, &reply)
+}
+
+// UnbanPeer removes the ban of the IP address or range in CIDR notation.
+// If id is empty, the ban is removed from the session.
+func (c *Client) UnbanPeer(id string, addr string) error {
+	args := rpctypes.UnbanPeerRequest{ID: id, Addr: addr}
+	var reply rpctypes.UnbanPeerResponse
+	return c.client.Call("Session.UnbanPeer", args, &reply)
+}
+
 // AddTracker adds a new tracker to a torrent.
 func (c *Client) AddTracker(id string, uri string) error {
 	args := rpctypes.AddTrackerRequest{ID: id, URL: uri}
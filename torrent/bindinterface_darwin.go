@@ -0,0 +1,31 @@
+package torrent
+
+import (
+	"net"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const bindInterfaceSupported = true
+
+// bindInterface makes the socket send its traffic only through the network interface with name.
+func bindInterface(network string, c syscall.RawConn, name string) error {
+	// Index is looked up on every call because it changes when a tunnel interface is created again.
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return err
+	}
+	cerr := c.Control(func(fd uintptr) {
+		if strings.HasSuffix(network, "6") {
+			err = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_BOUND_IF, iface.Index)
+		} else {
+			err = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_BOUND_IF, iface.Index)
+		}
+	})
+	if cerr != nil {
+		return cerr
+	}
+	return err
+}
@@ -0,0 +1,21 @@
+package torrent
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const bindInterfaceSupported = true
+
+// bindInterface makes the socket send its traffic only through the network interface with name.
+func bindInterface(network string, c syscall.RawConn, name string) error {
+	var err error
+	cerr := c.Control(func(fd uintptr) {
+		err = unix.BindToDevice(int(fd), name)
+	})
+	if cerr != nil {
+		return cerr
+	}
+	return err
+}
@@ -0,0 +1,16 @@
+//go:build !linux && !darwin
+
+package torrent
+
+import (
+	"errors"
+	"syscall"
+)
+
+// Binding only the source address does not force the traffic through the interface.
+// OutgoingInterface is rejected on these platforms so traffic cannot bypass it.
+const bindInterfaceSupported = false
+
+func bindInterface(network string, c syscall.RawConn, name string) error {
+	return errors.New("binding to interface is not supported")
+}
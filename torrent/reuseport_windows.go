@@ -0,0 +1,13 @@
+//go:build windows
+
+package torrent
+
+import "syscall"
+
+// SO_REUSEADDR on Windows allows other processes to steal the port.
+// Holepunch connections are dialed from a random port instead.
+const reusePortSupported = false
+
+func reusePort(network, address string, c syscall.RawConn) error {
+	return nil
+}
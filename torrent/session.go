@@ -3,30 +3,39 @@ package torrent
 
 import (
 	"context"
+	"crypto/ed25519"
 	"crypto/tls"
 	"errors"
 	"net"
 	"net/http"
 	"os"
 	"path/filepath"
-	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/cenkalti/rain/internal/acceptor"
+	"github.com/cenkalti/rain/internal/allocator"
 	"github.com/cenkalti/rain/internal/bitfield"
 	"github.com/cenkalti/rain/internal/blocklist"
+	"github.com/cenkalti/rain/internal/btconn"
+	"github.com/cenkalti/rain/internal/budget"
+	"github.com/cenkalti/rain/internal/dht"
+	"github.com/cenkalti/rain/internal/externalip"
 	"github.com/cenkalti/rain/internal/logger"
 	"github.com/cenkalti/rain/internal/peer"
 	"github.com/cenkalti/rain/internal/piececache"
+	"github.com/cenkalti/rain/internal/proxy"
 	"github.com/cenkalti/rain/internal/resolver"
 	"github.com/cenkalti/rain/internal/resourcemanager"
 	"github.com/cenkalti/rain/internal/resumer/boltdbresumer"
 	"github.com/cenkalti/rain/internal/semaphore"
 	"github.com/cenkalti/rain/internal/tracker"
 	"github.com/cenkalti/rain/internal/trackermanager"
+	"github.com/cenkalti/rain/internal/unchoker"
 	"github.com/juju/ratelimit"
 	"github.com/mitchellh/go-homedir"
-	"github.com/nictuku/dht"
 	"go.etcd.io/bbolt"
 )
 
@@ -34,8 +43,12 @@ var (
 	sessionBucket         = []byte("session")
 	torrentsBucket        = []byte("torrents")
 	blocklistKey          = []byte("blocklist")
+	blocklistsBucket      = []byte("blocklists")
 	blocklistTimestampKey = []byte("blocklist-timestamp")
 	blocklistURLHashKey   = []byte("blocklist-url-hash")
+	bansKey               = []byte("bans")
+	dhtIDKey              = []byte("dht-id")
+	dhtNodesKey           = []byte("dht-nodes")
 )
 
 // Session contains torrents, DHT node, caches and other data structures shared by multiple torrents.
@@ -49,14 +62,35 @@ type Session struct {
 	rpc            *rpcServer
 	trackerManager *trackermanager.TrackerManager
 	ram            *resourcemanager.ResourceManager[*peer.Peer]
+	connSlots      *budget.Budget[*torrent]
+	dialSlots      *budget.Budget[*torrent]
+	uploadSlots    *budget.Budget[*torrent]
 	pieceCache     *piececache.Cache
 	webseedClient  http.Client
+	// Used for HTTP requests other than trackers and webseeds. Nil if there is no proxy.
+	httpTransport http.RoundTripper
+	// Used for connecting to peers.
+	peerDialer btconn.Dialer
+	// Source address of outgoing connections. Nil if not configured.
+	outgoingIP net.IP
+	// Binds sockets to Config.OutgoingInterface. Nil if not configured.
+	outgoingControl func(network, address string, c syscall.RawConn) error
+	// Last known address of Config.OutgoingInterface. Only used by the kill-switch loop.
+	outgoingInterfaceAddr net.IP
+	// Set by the kill-switch when the outgoing address is not available.
+	outgoingDown   atomic.Bool
 	createdAt      time.Time
 	semWrite       *semaphore.Semaphore
 	metrics        *sessionMetrics
 	bucketDownload *ratelimit.Bucket
 	bucketUpload   *ratelimit.Bucket
+	bucketVerify   *ratelimit.Bucket
 	closeC         chan struct{}
+	allocationMode allocator.Mode
+	// Public keys in Config.TrustedSigningKeys.
+	trustedSigningKeys []ed25519.PublicKey
+	// External IP of the client reported by trackers and peers.
+	externalIP *externalip.Voter
 
 	mPeerRequests   sync.Mutex
 	dhtPeerRequests map[*torrent]struct{}
@@ -64,14 +98,19 @@ type Session struct {
 	mTorrents          sync.RWMutex
 	torrents           map[string]*Torrent
 	torrentsByInfoHash map[dht.InfoHash][]*Torrent
-	invalidTorrentIDs  []string
+
+	// Hybrid torrents by the info hash of their other swarm.
+	torrentsByHybridHash map[dht.InfoHash][]*Torrent
+	invalidTorrentIDs    []string
 
 	mPorts         sync.RWMutex
 	availablePorts map[int]struct{}
+	sharedAcceptor *acceptor.Acceptor
 
 	mBlocklist         sync.RWMutex
 	blocklist          *blocklist.Blocklist
 	blocklistTimestamp time.Time
+	blocklistSources   []BlocklistSource
 }
 
 // NewSession creates a new Session for downloading and seeding torrents.
@@ -80,6 +119,50 @@ func NewSession(cfg Config) (*Session, error) {
 	if cfg.PortBegin >= cfg.PortEnd {
 		return nil, errors.New("invalid port range")
 	}
+	if _, _, err := parseDuplicateTorrentData(cfg.DuplicateTorrentData); err != nil {
+		return nil, err
+	}
+	allocationMode, err := parseFileAllocation(cfg.FileAllocation)
+	if err != nil {
+		return nil, err
+	}
+	if err = validateDiskSpaceCheck(cfg.DiskSpaceCheck); err != nil {
+		return nil, err
+	}
+	if err = validateWriteDurability(cfg.WriteDurability); err != nil {
+		return nil, err
+	}
+	if err = validatePeerBanScope(cfg.PeerBanScope); err != nil {
+		return nil, err
+	}
+	if err = validateSharedPort(&cfg); err != nil {
+		return nil, err
+	}
+	if _, err = unchoker.NewAlgorithm(cfg.ChokingAlgorithm, 0, 0); err != nil {
+		return nil, err
+	}
+	trustedSigningKeys, err := parseTrustedSigningKeys(cfg.TrustedSigningKeys)
+	if err != nil {
+		return nil, err
+	}
+	outIP, err := outgoingIP(&cfg)
+	if err != nil {
+		return nil, err
+	}
+	outControl := outgoingControl(&cfg)
+	var px *proxy.Proxy
+	if cfg.ProxyURL != "" {
+		px, err = proxy.New(cfg.ProxyURL, outgoingDialer(outIP, outControl, cfg.ProxyDialTimeout))
+		if err != nil {
+			return nil, err
+		}
+	} else if cfg.ProxyOnly {
+		return nil, errors.New("proxy-only mode requires a proxy URL")
+	}
+	if cfg.ProxyOnly {
+		// DHT node sends UDP packets directly.
+		cfg.DHTEnabled = false
+	}
 	if cfg.MaxOpenFiles > 0 {
 		err := setNoFile(cfg.MaxOpenFiles)
 		if err != nil {
@@ -94,7 +177,6 @@ func NewSession(cfg Config) (*Session, error) {
 		logger.SetDebug()
 	}
 
-	var err error
 	cfg.Database, err = homedir.Expand(cfg.Database)
 	if err != nil {
 		return nil, err
@@ -103,6 +185,27 @@ func NewSession(cfg Config) (*Session, error) {
 	if err != nil {
 		return nil, err
 	}
+	cfg.IncompleteDir, err = homedir.Expand(cfg.IncompleteDir)
+	if err != nil {
+		return nil, err
+	}
+	cfg.CompleteDir, err = homedir.Expand(cfg.CompleteDir)
+	if err != nil {
+		return nil, err
+	}
+	labelDirs := make(map[string]LabelDirs, len(cfg.LabelDirs))
+	for label, ld := range cfg.LabelDirs {
+		ld.IncompleteDir, err = homedir.Expand(ld.IncompleteDir)
+		if err != nil {
+			return nil, err
+		}
+		ld.CompleteDir, err = homedir.Expand(ld.CompleteDir)
+		if err != nil {
+			return nil, err
+		}
+		labelDirs[label] = ld
+	}
+	cfg.LabelDirs = labelDirs
 	err = os.MkdirAll(filepath.Dir(cfg.Database), os.ModeDir|cfg.FilePermissions)
 	if err != nil {
 		return nil, err
@@ -141,19 +244,27 @@ func NewSession(cfg Config) (*Session, error) {
 	if err != nil {
 		return nil, err
 	}
+	var trackerDialer *net.Dialer
+	if outIP != nil || outControl != nil {
+		trackerDialer = outgoingDialer(outIP, outControl, 0)
+	}
 	var dhtNode *dht.DHT
 	if cfg.DHTEnabled {
-		dhtConfig := dht.NewConfig()
-		dhtConfig.Address = cfg.DHTHost
-		dhtConfig.Port = int(cfg.DHTPort)
-		dhtConfig.DHTRouters = strings.Join(cfg.DHTBootstrapNodes, ",")
-		dhtConfig.SaveRoutingTable = false
-		dhtConfig.NumTargetPeers = 0
-		dhtNode, err = dht.New(dhtConfig)
+		dhtConfig := dht.Config{
+			Host:             cfg.DHTHost,
+			Port:             int(cfg.DHTPort),
+			BootstrapNodes:   cfg.DHTBootstrapNodes,
+			Control:          outControl,
+			EnforceSecureIDs: cfg.DHTEnforceSecureIDs,
+		}
+		if outIP != nil && (cfg.DHTHost == "" || net.ParseIP(cfg.DHTHost).IsUnspecified()) {
+			dhtConfig.Host = outIP.String()
+		}
+		dhtConfig.ID, dhtConfig.Nodes, err = loadDHTState(db)
 		if err != nil {
 			return nil, err
 		}
-		err = dhtNode.Start()
+		dhtNode, err = dht.New(dhtConfig)
 		if err != nil {
 			return nil, err
 		}
@@ -168,21 +279,31 @@ func NewSession(cfg Config) (*Session, error) {
 		blTracker = bl
 	}
 	c := &Session{
-		config:             cfg,
-		db:                 db,
-		resumer:            res,
-		blocklist:          bl,
-		trackerManager:     trackermanager.New(blTracker, cfg.DNSResolveTimeout, !cfg.TrackerHTTPVerifyTLS),
-		log:                l,
-		torrents:           make(map[string]*Torrent),
-		torrentsByInfoHash: make(map[dht.InfoHash][]*Torrent),
-		availablePorts:     ports,
-		dht:                dhtNode,
-		pieceCache:         piececache.New(cfg.ReadCacheSize, cfg.ReadCacheTTL, cfg.ParallelReads),
-		ram:                resourcemanager.New[*peer.Peer](cfg.WriteCacheSize),
-		createdAt:          time.Now(),
-		semWrite:           semaphore.New(int(cfg.ParallelWrites)),
-		closeC:             make(chan struct{}),
+		config:               cfg,
+		db:                   db,
+		resumer:              res,
+		blocklist:            bl,
+		trackerManager:       trackermanager.New(blTracker, cfg.DNSResolveTimeout, !cfg.TrackerHTTPVerifyTLS, px, cfg.ProxyOnly, trackerDialer),
+		log:                  l,
+		torrents:             make(map[string]*Torrent),
+		torrentsByInfoHash:   make(map[dht.InfoHash][]*Torrent),
+		torrentsByHybridHash: make(map[dht.InfoHash][]*Torrent),
+		availablePorts:       ports,
+		dht:                  dhtNode,
+		pieceCache:           piececache.New(cfg.ReadCacheSize, cfg.ReadCacheTTL, cfg.ParallelReads),
+		ram:                  resourcemanager.New[*peer.Peer](cfg.WriteCacheSize),
+		connSlots:            budget.New[*torrent](cfg.MaxConnections),
+		dialSlots:            budget.New[*torrent](cfg.MaxHalfOpenConnections),
+		uploadSlots:          budget.New[*torrent](cfg.MaxUploadSlots),
+		createdAt:            time.Now(),
+		semWrite:             semaphore.New(int(cfg.ParallelWrites)),
+		closeC:               make(chan struct{}),
+		allocationMode:       allocationMode,
+		trustedSigningKeys:   trustedSigningKeys,
+		externalIP:           externalip.New(),
+		outgoingIP:           outIP,
+		outgoingControl:      outControl,
+		peerDialer:           outgoingDialer(outIP, outControl, 0),
 		webseedClient: http.Client{
 			Transport: &http.Transport{
 				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
@@ -190,7 +311,7 @@ func NewSession(cfg Config) (*Session, error) {
 					if err != nil {
 						return nil, err
 					}
-					var d net.Dialer
+					d := outgoingDialer(outIP, outControl, 0)
 					taddr := &net.TCPAddr{IP: ip, Port: port}
 					dctx, cancel := context.WithTimeout(ctx, cfg.WebseedDialTimeout)
 					defer cancel()
@@ -202,6 +323,16 @@ func NewSession(cfg Config) (*Session, error) {
 			},
 		},
 	}
+	if px != nil || outIP != nil || outControl != nil {
+		tr := http.DefaultTransport.(*http.Transport).Clone()
+		tr.DialContext = outgoingDialer(outIP, outControl, 30*time.Second).DialContext
+		if px != nil {
+			tr.Proxy = px.HTTPProxy
+			c.webseedClient.Transport.(*http.Transport).Proxy = px.HTTPProxy
+			c.peerDialer = px
+		}
+		c.httpTransport = tr
+	}
 	dlSpeed := cfg.SpeedLimitDownload * 1024
 	if cfg.SpeedLimitDownload > 0 {
 		c.bucketDownload = ratelimit.NewBucketWithRate(float64(dlSpeed), dlSpeed)
@@ -210,6 +341,18 @@ func NewSession(cfg Config) (*Session, error) {
 	if cfg.SpeedLimitUpload > 0 {
 		c.bucketUpload = ratelimit.NewBucketWithRate(float64(ulSpeed), ulSpeed)
 	}
+	verifySpeed := cfg.SpeedLimitVerify * 1024
+	if cfg.SpeedLimitVerify > 0 {
+		c.bucketVerify = ratelimit.NewBucketWithRate(float64(verifySpeed), verifySpeed)
+	}
+	err = c.loadBans()
+	if err != nil {
+		return nil, err
+	}
+	err = c.loadBlocklistAllowlist()
+	if err != nil {
+		return nil, err
+	}
 	err = c.startBlocklistReloader()
 	if err != nil {
 		return nil, err
@@ -220,11 +363,16 @@ func NewSession(cfg Config) (*Session, error) {
 	}
 	ext.Set(61) // Fast Extension (BEP 6)
 	ext.Set(43) // Extension Protocol (BEP 10)
+	ext.Set(59) // BitTorrent v2 (BEP 52)
 	if cfg.DHTEnabled {
 		ext.Set(63) // DHT Protocol (BEP 5)
 		c.dhtPeerRequests = make(map[*torrent]struct{})
 	}
 	c.initMetrics()
+	err = c.startSharedPort()
+	if err != nil {
+		return nil, err
+	}
 	c.loadExistingTorrents(ids)
 	if c.config.RPCEnabled {
 		c.rpc = newRPCServer(c)
@@ -237,6 +385,10 @@ func NewSession(cfg Config) (*Session, error) {
 		go c.processDHTResults()
 	}
 	go c.updateStatsLoop()
+	go c.checkDiskSpaceLoop()
+	if cfg.OutgoingKillSwitch {
+		go c.checkOutgoingAddressLoop()
+	}
 	return c, nil
 }
 
@@ -271,23 +423,30 @@ func (s *Session) Close() error {
 	close(s.closeC)
 
 	if s.config.DHTEnabled {
-		s.dht.Stop()
+		s.dht.Close()
+		s.saveDHTState()
+	}
+	if s.sharedAcceptor != nil {
+		s.sharedAcceptor.Close()
 	}
 
 	s.updateStats()
 
-	var wg sync.WaitGroup
+	// Lock must not be held while closing torrents because torrent loops may need to read the list of torrents.
 	s.mTorrents.Lock()
-	wg.Add(len(s.torrents))
-	for _, t := range s.torrents {
+	torrents := s.torrents
+	s.torrents = nil
+	s.mTorrents.Unlock()
+
+	var wg sync.WaitGroup
+	wg.Add(len(torrents))
+	for _, t := range torrents {
 		go func(t *Torrent) {
 			t.torrent.Close()
 			wg.Done()
 		}(t)
 	}
 	wg.Wait()
-	s.torrents = nil
-	s.mTorrents.Unlock()
 
 	if s.rpc != nil {
 		err := s.rpc.Stop(s.config.RPCShutdownTimeout)
@@ -326,6 +485,10 @@ func (s *Session) getPort() (int, error) {
 }
 
 func (s *Session) releasePort(port int) {
+	if port < int(s.config.PortBegin) || port >= int(s.config.PortEnd) {
+		// Shared port is not in the range.
+		return
+	}
 	s.mPorts.Lock()
 	defer s.mPorts.Unlock()
 	s.availablePorts[port] = struct{}{}
@@ -368,15 +531,19 @@ func (s *Session) removeTorrentFromClient(id string) (*Torrent, error) {
 		}
 	}
 
-	// DHT.RemoveInfoHash below sends a message to DHT loop, hence it is blocking.
-	// We need to make sure that we are not holding any lock that cause a block in DHT loop.
-	// DHT.PeersRequestResults tries to hold the same lock (mTorrents) when a message is received from
-	// DHT.PeersRequestResults. That's why we are releasing the lock before calling DHT.RemoveInfoHash.
+	if h := t.torrent.hybrid.Load(); h != nil {
+		hh := dht.InfoHash(h.InfoHash[:])
+		a = s.torrentsByHybridHash[hh]
+		for i, it := range a {
+			if it == t {
+				a[i] = a[len(a)-1]
+				s.torrentsByHybridHash[hh] = a[:len(a)-1]
+				break
+			}
+		}
+	}
 	s.mTorrents.Unlock()
 
-	if s.config.DHTEnabled && len(s.torrentsByInfoHash[ih]) == 0 {
-		s.dht.RemoveInfoHash(string(ih))
-	}
 	return t, s.db.Update(func(tx *bbolt.Tx) error {
 		return tx.Bucket(torrentsBucket).DeleteBucket([]byte(id))
 	})
@@ -388,9 +555,13 @@ func (s *Session) stopAndRemoveData(t *Torrent) error {
 	var err error
 	var dest string
 	if s.config.DataDirIncludesTorrentID {
-		dest = filepath.Join(s.config.DataDir, t.torrent.id)
+		dest = t.torrent.RootDirectory()
 	} else if t.torrent.info != nil {
-		dest = filepath.Join(s.config.DataDir, t.torrent.info.Name)
+		dest = filepath.Join(t.torrent.RootDirectory(), t.torrent.info.Name)
+	}
+	if dest != "" && s.dataShared(t.torrent) {
+		s.log.Infof("not removing torrent data because it is shared with another torrent. dest: %s", dest)
+		dest = ""
 	}
 	if dest != "" {
 		err = os.RemoveAll(dest)
@@ -444,8 +615,48 @@ func (s *Session) StopAll() error {
 }
 
 func (s *Session) getDataDir(torrentID string) string {
+	return s.dataDirIn(s.config.DataDir, torrentID)
+}
+
+func (s *Session) dataDirIn(dir, torrentID string) string {
 	if s.config.DataDirIncludesTorrentID {
-		return filepath.Join(s.config.DataDir, torrentID)
+		return filepath.Join(dir, torrentID)
+	}
+	return dir
+}
+
+// labelDirs returns the incomplete and complete directories for the torrents with label.
+func (s *Session) labelDirs(label string) (incomplete, complete string) {
+	incomplete, complete = s.config.IncompleteDir, s.config.CompleteDir
+	if ld, ok := s.config.LabelDirs[label]; ok && label != "" {
+		if ld.IncompleteDir != "" {
+			incomplete = ld.IncompleteDir
+		}
+		if ld.CompleteDir != "" {
+			complete = ld.CompleteDir
+		}
+	}
+	return
+}
+
+// getIncompleteDataDir returns the directory that a new torrent is downloaded into.
+func (s *Session) getIncompleteDataDir(torrentID, label string) string {
+	incomplete, _ := s.labelDirs(label)
+	if incomplete == "" {
+		return s.getDataDir(torrentID)
+	}
+	return s.dataDirIn(incomplete, torrentID)
+}
+
+// getCompleteDataDir returns the directory that the torrent is moved into after completion.
+// Returns empty string if completed torrents with the label are not moved.
+func (s *Session) getCompleteDataDir(torrentID, label string) string {
+	incomplete, complete := s.labelDirs(label)
+	if incomplete == "" && complete == "" {
+		return ""
+	}
+	if complete == "" {
+		complete = s.config.DataDir
 	}
-	return s.config.DataDir
+	return s.dataDirIn(complete, torrentID)
 }
@@ -7,9 +7,11 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/cenkalti/rain/internal/dht"
 	"github.com/cenkalti/rain/internal/magnet"
 	"github.com/cenkalti/rain/internal/metainfo"
 	"github.com/cenkalti/rain/internal/resumer"
@@ -17,7 +19,6 @@ import (
 	"github.com/cenkalti/rain/internal/storage/filestorage"
 	"github.com/cenkalti/rain/internal/webseedsource"
 	"github.com/gofrs/uuid"
-	"github.com/nictuku/dht"
 )
 
 // AddTorrentOptions contains options for adding a new torrent.
@@ -31,6 +32,15 @@ type AddTorrentOptions struct {
 	StopAfterDownload bool
 	// Stop torrent after metadata is downloaded from magnet links.
 	StopAfterMetadata bool
+	// Label of the torrent. Used for selecting download directories from Config.LabelDirs.
+	Label string
+	// Listen on a port from Config.PortBegin-PortEnd range even if Config.SharedPort is set.
+	OwnPort bool
+	// Algorithm for selecting peers to unchoke. If empty, Config.ChokingAlgorithm is used.
+	ChokingAlgorithm string
+	// Download the first and last pieces of each file before other pieces.
+	// Useful for previewing media files while the torrent is downloading.
+	FirstLastPieces bool
 }
 
 // AddTorrent adds a new torrent to the session by reading .torrent metainfo from reader.
@@ -39,6 +49,9 @@ func (s *Session) AddTorrent(r io.Reader, opt *AddTorrentOptions) (*Torrent, err
 	if opt == nil {
 		opt = &AddTorrentOptions{}
 	}
+	if err := validateChokingAlgorithm(opt.ChokingAlgorithm); err != nil {
+		return nil, newInputError(err)
+	}
 	t, err := s.addTorrentStopped(r, opt)
 	if err != nil {
 		return nil, err
@@ -66,7 +79,16 @@ func (s *Session) addTorrentStopped(r io.Reader, opt *AddTorrentOptions) (*Torre
 	if err != nil {
 		return nil, newInputError(err)
 	}
-	id, port, sto, err := s.add(opt)
+	return s.addMetaInfoStopped(mi, opt)
+}
+
+// addMetaInfoStopped adds a torrent from parsed metainfo.
+func (s *Session) addMetaInfoStopped(mi *metainfo.MetaInfo, opt *AddTorrentOptions) (*Torrent, error) {
+	signer := s.trustedSigner(mi.Info.Bytes, mi.Signatures)
+	if signer == "" && s.config.RequireTrustedSignature {
+		return nil, newInputError(errUntrustedTorrent)
+	}
+	id, port, dataDir, sto, err := s.add(opt, mi.Info.Hash[:])
 	if err != nil {
 		return nil, err
 	}
@@ -75,6 +97,10 @@ func (s *Session) addTorrentStopped(r io.Reader, opt *AddTorrentOptions) (*Torre
 			s.releasePort(port)
 		}
 	}()
+	if s.config.DiskSpaceCheck == diskSpaceCheckRefuse && !s.hasDiskSpace(sto, &mi.Info) {
+		err = errNotEnoughDiskSpace
+		return nil, err
+	}
 	t, err := newTorrent2(
 		s,
 		id,
@@ -88,14 +114,22 @@ func (s *Session) addTorrentStopped(r io.Reader, opt *AddTorrentOptions) (*Torre
 		&mi.Info,
 		nil, // bitfield
 		resumer.Stats{},
-		webseedsource.NewList(mi.URLList),
+		append(webseedsource.NewList(mi.URLList), webseedsource.NewHTTPSeedList(mi.HTTPSeeds)...),
 		opt.StopAfterDownload,
 		opt.StopAfterMetadata,
 		false, // completeCmdRun
+		opt.ChokingAlgorithm,
 	)
 	if err != nil {
 		return nil, err
 	}
+	t.dataDir = dataDir
+	t.label = opt.Label
+	t.ownPort = opt.OwnPort
+	t.firstLastPieces = opt.FirstLastPieces
+	t.sharedPort = s.usesSharedPort(opt.OwnPort)
+	t.signatures = mi.Signatures
+	t.signer = signer
 	go s.checkTorrent(t)
 	defer func() {
 		if err != nil {
@@ -108,10 +142,18 @@ func (s *Session) addTorrentStopped(r io.Reader, opt *AddTorrentOptions) (*Torre
 		Name:              mi.Info.Name,
 		Trackers:          mi.AnnounceList,
 		URLList:           mi.URLList,
+		HTTPSeeds:         mi.HTTPSeeds,
 		Info:              mi.Info.Bytes,
+		PieceLayers:       mi.Info.PieceLayersBytes(),
+		Signatures:        marshalSignatures(mi.Signatures),
+		Dest:              dataDir,
+		Label:             opt.Label,
 		AddedAt:           t.addedAt,
 		StopAfterDownload: opt.StopAfterDownload,
 		StopAfterMetadata: opt.StopAfterMetadata,
+		OwnPort:           opt.OwnPort,
+		ChokingAlgorithm:  opt.ChokingAlgorithm,
+		FirstLastPieces:   opt.FirstLastPieces,
 	}
 	err = s.resumer.Write(id, rspec)
 	if err != nil {
@@ -130,6 +172,9 @@ func (s *Session) AddURI(uri string, opt *AddTorrentOptions) (*Torrent, error) {
 	if opt == nil {
 		opt = &AddTorrentOptions{}
 	}
+	if err := validateChokingAlgorithm(opt.ChokingAlgorithm); err != nil {
+		return nil, newInputError(err)
+	}
 	u, err := url.Parse(uri)
 	if err != nil {
 		return nil, newInputError(err)
@@ -158,20 +203,37 @@ func filterOutControlChars(s string) string {
 }
 
 func (s *Session) addURL(u string, opt *AddTorrentOptions) (*Torrent, error) {
+	mi, err := s.downloadMetaInfo(u)
+	if err != nil {
+		return nil, newInputError(err)
+	}
+	t, err := s.addMetaInfoStopped(mi, opt)
+	if err != nil {
+		return nil, err
+	}
+	if !opt.Stopped {
+		err = t.Start()
+	}
+	return t, err
+}
+
+// downloadMetaInfo downloads the torrent file at HTTP URL u.
+func (s *Session) downloadMetaInfo(u string) (*metainfo.MetaInfo, error) {
 	client := http.Client{
-		Timeout: s.config.TorrentAddHTTPTimeout,
+		Timeout:   s.config.TorrentAddHTTPTimeout,
+		Transport: s.httpTransport,
 	}
 	resp, err := client.Get(u) // nolint: noctx
 	if err != nil {
-		return nil, newInputError(err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.ContentLength > int64(s.config.MaxTorrentSize) {
-		return nil, newInputError(fmt.Errorf("torrent too large: %d", resp.ContentLength))
+		return nil, fmt.Errorf("torrent too large: %d", resp.ContentLength)
 	}
 	r := io.LimitReader(resp.Body, int64(s.config.MaxTorrentSize))
-	return s.AddTorrent(r, opt)
+	return s.parseMetaInfo(r)
 }
 
 func (s *Session) addMagnet(link string, opt *AddTorrentOptions) (*Torrent, error) {
@@ -179,7 +241,12 @@ func (s *Session) addMagnet(link string, opt *AddTorrentOptions) (*Torrent, erro
 	if err != nil {
 		return nil, newInputError(err)
 	}
-	id, port, sto, err := s.add(opt)
+	sources := metadataSources(ma)
+	// Signatures are not sent with the metadata, so the torrent file must be downloaded from a source in the magnet link.
+	if s.config.RequireTrustedSignature && len(sources) == 0 {
+		return nil, newInputError(errUntrustedTorrent)
+	}
+	id, port, dataDir, sto, err := s.add(opt, ma.InfoHash[:])
 	if err != nil {
 		return nil, err
 	}
@@ -201,14 +268,23 @@ func (s *Session) addMagnet(link string, opt *AddTorrentOptions) (*Torrent, erro
 		nil, // info
 		nil, // bitfield
 		resumer.Stats{},
-		nil, // webseedSources
+		webseedsource.NewList(ma.Webseeds),
 		opt.StopAfterDownload,
 		opt.StopAfterMetadata,
 		false, // completeCmdRun
+		opt.ChokingAlgorithm,
 	)
 	if err != nil {
 		return nil, err
 	}
+	t.dataDir = dataDir
+	t.label = opt.Label
+	t.ownPort = opt.OwnPort
+	t.firstLastPieces = opt.FirstLastPieces
+	t.sharedPort = s.usesSharedPort(opt.OwnPort)
+	t.selectedFiles = ma.SelectOnly
+	t.exactLength = ma.Length
+	t.metadataSources = sources
 	go s.checkTorrent(t)
 	defer func() {
 		if err != nil {
@@ -220,10 +296,19 @@ func (s *Session) addMagnet(link string, opt *AddTorrentOptions) (*Torrent, erro
 		Port:              port,
 		Name:              ma.Name,
 		Trackers:          ma.Trackers,
+		URLList:           ma.Webseeds,
 		FixedPeers:        ma.Peers,
+		Dest:              dataDir,
+		Label:             opt.Label,
 		AddedAt:           t.addedAt,
 		StopAfterDownload: opt.StopAfterDownload,
 		StopAfterMetadata: opt.StopAfterMetadata,
+		OwnPort:           opt.OwnPort,
+		ChokingAlgorithm:  opt.ChokingAlgorithm,
+		FirstLastPieces:   opt.FirstLastPieces,
+		SelectedFiles:     ma.SelectOnly,
+		ExactLength:       ma.Length,
+		MetadataSources:   sources,
 	}
 	err = s.resumer.Write(id, rspec)
 	if err != nil {
@@ -236,10 +321,16 @@ func (s *Session) addMagnet(link string, opt *AddTorrentOptions) (*Torrent, erro
 	return t2, err
 }
 
-func (s *Session) add(opt *AddTorrentOptions) (id string, port int, sto *filestorage.FileStorage, err error) {
-	port, err = s.getPort()
-	if err != nil {
-		return
+// add reserves a port and prepares the storage for a new torrent.
+// dataDir is set only if the torrent uses a data directory other than the default one.
+func (s *Session) add(opt *AddTorrentOptions, infoHash []byte) (id string, port int, dataDir string, sto *filestorage.FileStorage, err error) {
+	if s.usesSharedPort(opt.OwnPort) {
+		port = int(s.config.SharedPort)
+	} else {
+		port, err = s.getPort()
+		if err != nil {
+			return
+		}
 	}
 	defer func() {
 		if err != nil {
@@ -252,8 +343,9 @@ func (s *Session) add(opt *AddTorrentOptions) (id string, port int, sto *filesto
 	}
 	if givenID != "" {
 		s.mTorrents.RLock()
-		defer s.mTorrents.RUnlock()
-		if _, ok := s.torrents[givenID]; ok {
+		_, ok := s.torrents[givenID]
+		s.mTorrents.RUnlock()
+		if ok {
 			err = errors.New("duplicate torrent id")
 			return
 		}
@@ -266,10 +358,21 @@ func (s *Session) add(opt *AddTorrentOptions) (id string, port int, sto *filesto
 		}
 		id = base64.RawURLEncoding.EncodeToString(u1[:])
 	}
-	sto, err = filestorage.New(s.getDataDir(id), s.config.FilePermissions)
+	dir := s.getIncompleteDataDir(id, opt.Label)
+	if shared := s.sharedDataDir(infoHash); shared != "" {
+		dir = shared
+	}
+	sto, err = s.newStorage(dir)
+	if err != nil {
+		return
+	}
+	defaultDir, err := filepath.Abs(s.getDataDir(id))
 	if err != nil {
 		return
 	}
+	if sto.RootDir() != defaultDir {
+		dataDir = sto.RootDir()
+	}
 	return
 }
 
@@ -283,5 +386,33 @@ func (s *Session) insertTorrent(t *torrent) *Torrent {
 	s.torrents[t.id] = t2
 	ih := dht.InfoHash(t.InfoHash())
 	s.torrentsByInfoHash[ih] = append(s.torrentsByInfoHash[ih], t2)
+	if h := t.hybrid.Load(); h != nil {
+		hh := dht.InfoHash(h.InfoHash[:])
+		s.torrentsByHybridHash[hh] = append(s.torrentsByHybridHash[hh], t2)
+	}
 	return t2
 }
+
+// addHybridHash sets the other info hash of the hybrid torrent after its info is known, so peers of the other swarm are accepted.
+func (s *Session) addHybridHash(t *torrent, h *hybridHashes) {
+	s.mTorrents.Lock()
+	defer s.mTorrents.Unlock()
+	t.hybrid.Store(h)
+	if t2, ok := s.torrents[t.id]; ok {
+		hh := dht.InfoHash(h.InfoHash[:])
+		s.torrentsByHybridHash[hh] = append(s.torrentsByHybridHash[hh], t2)
+	}
+}
+
+// metadataSources returns the exact and acceptable sources in the magnet link that the torrent file can be downloaded from.
+func metadataSources(ma *magnet.Magnet) []string {
+	var sources []string
+	for _, src := range append(ma.ExactSources, ma.AcceptableSources...) {
+		u, err := url.Parse(src)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		sources = append(sources, src)
+	}
+	return sources
+}
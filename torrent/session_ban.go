@@ -0,0 +1,144 @@
+package torrent
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/cenkalti/rain/internal/blocklist"
+	"github.com/cenkalti/rain/internal/resumer/boltdbresumer"
+	"go.etcd.io/bbolt"
+)
+
+// Values for Config.PeerBanScope.
+const (
+	banScopeTorrent = "torrent"
+	banScopeSession = "session"
+)
+
+func validatePeerBanScope(value string) error {
+	switch value {
+	case "", banScopeTorrent, banScopeSession:
+		return nil
+	default:
+		return fmt.Errorf("invalid value for peer ban scope: %q", value)
+	}
+}
+
+// Ban is an IP address or range that is not allowed to connect.
+// IPs are banned manually or automatically after sending corrupt data.
+type Ban struct {
+	// IP address or range in CIDR notation.
+	IP string
+	// Session is true if the IP is banned in all torrents in the session.
+	Session bool
+	// Time that the ban is lifted. Zero if the ban does not expire.
+	ExpiresAt time.Time
+}
+
+// banExpiry returns the time that a ban expires after ttl. Zero ttl means the ban does not expire.
+func banExpiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+func bansFromRules(rules []blocklist.Rule, session bool) []Ban {
+	ret := make([]Ban, len(rules))
+	for i, r := range rules {
+		ret[i] = Ban{IP: banString(r.Net), Session: session, ExpiresAt: r.ExpiresAt}
+	}
+	return ret
+}
+
+// banString returns the range as an IP if it contains a single address.
+func banString(n *net.IPNet) string {
+	if ones, bits := n.Mask.Size(); ones == bits {
+		return n.IP.String()
+	}
+	return n.String()
+}
+
+func marshalBans(rules []blocklist.Rule) []boltdbresumer.Ban {
+	ret := make([]boltdbresumer.Ban, len(rules))
+	for i, r := range rules {
+		ret[i] = boltdbresumer.Ban{Net: r.Net.String(), ExpiresAt: r.ExpiresAt}
+	}
+	return ret
+}
+
+// unmarshalBans adds the bans read from resume db to bl. Invalid entries are skipped.
+func unmarshalBans(bans []boltdbresumer.Ban, bl *blocklist.Blocklist) {
+	for _, b := range bans {
+		_, n, err := net.ParseCIDR(b.Net)
+		if err != nil {
+			continue
+		}
+		bl.Add(blocklist.Rule{Net: n, ExpiresAt: b.ExpiresAt})
+	}
+}
+
+// Bans returns the IP addresses and ranges that are banned in all torrents in the session.
+func (s *Session) Bans() []Ban {
+	return bansFromRules(s.blocklist.Rules(), true)
+}
+
+// Ban the IP address or range in CIDR notation in all torrents for ttl.
+// Zero ttl means the ban does not expire. Connected peers in the range are disconnected.
+// Banned ranges are checked only where the blocklist is enabled with BlocklistEnabledFor* settings in Config.
+func (s *Session) Ban(addr string, ttl time.Duration) error {
+	n, err := blocklist.ParseNet(addr)
+	if err != nil {
+		return err
+	}
+	return s.banNet(n, banExpiry(ttl))
+}
+
+func (s *Session) banNet(n *net.IPNet, expiresAt time.Time) error {
+	s.blocklist.Add(blocklist.Rule{Net: n, ExpiresAt: expiresAt})
+	err := s.writeBans()
+	for _, t := range s.ListTorrents() {
+		t.torrent.notifyBansChanged()
+	}
+	return err
+}
+
+// Unban removes the ban of the IP address or range in CIDR notation from all torrents.
+func (s *Session) Unban(addr string) error {
+	n, err := blocklist.ParseNet(addr)
+	if err != nil {
+		return err
+	}
+	if !s.blocklist.Remove(n) {
+		return fmt.Errorf("not banned: %s", addr)
+	}
+	return s.writeBans()
+}
+
+func (s *Session) writeBans() error {
+	val, err := json.Marshal(marshalBans(s.blocklist.Rules()))
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(sessionBucket).Put(bansKey, val)
+	})
+}
+
+func (s *Session) loadBans() error {
+	var bans []boltdbresumer.Ban
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		val := tx.Bucket(sessionBucket).Get(bansKey)
+		if val == nil {
+			return nil
+		}
+		return json.Unmarshal(val, &bans)
+	})
+	if err != nil {
+		return err
+	}
+	unmarshalBans(bans, s.blocklist)
+	return nil
+}
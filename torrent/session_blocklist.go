@@ -1,6 +1,7 @@
 package torrent
 
 import (
+	"archive/zip"
 	"bytes"
 	"compress/gzip"
 	"context"
@@ -8,14 +9,56 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/cenkalti/backoff/v3"
+	"github.com/cenkalti/rain/internal/blocklist"
 	"go.etcd.io/bbolt"
 )
 
+// BlocklistSource contains the status of a blocklist URL.
+type BlocklistSource struct {
+	URL string
+	// Number of rules loaded from the source.
+	Rules int
+	// Error from the last load. Rules from the last successful load are used if there is an error.
+	Error error
+}
+
+// blocklistURLs returns the URLs in Config.BlocklistURL and Config.BlocklistURLs.
+func (s *Session) blocklistURLs() []string {
+	var urls []string
+	if s.config.BlocklistURL != "" {
+		urls = append(urls, s.config.BlocklistURL)
+	}
+	for _, u := range s.config.BlocklistURLs {
+		if u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+func (s *Session) loadBlocklistAllowlist() error {
+	if len(s.config.BlocklistAllowlist) == 0 {
+		return nil
+	}
+	l, err := blocklist.Parse(strings.NewReader(strings.Join(s.config.BlocklistAllowlist, "\n")), nil)
+	if err != nil {
+		return err
+	}
+	if l.Len() != len(s.config.BlocklistAllowlist) {
+		return errors.New("invalid blocklist allowlist")
+	}
+	s.blocklist.SetAllowlist(l)
+	return nil
+}
+
 func (s *Session) startBlocklistReloader() error {
-	if s.config.BlocklistURL == "" {
+	if len(s.blocklistURLs()) == 0 {
 		return nil
 	}
 	blocklistTimestamp, err := s.getBlocklistTimestamp()
@@ -55,8 +98,13 @@ func (s *Session) startBlocklistReloader() error {
 	return nil
 }
 
+// blocklistURLsHash is saved in session db to detect the changes in blocklist URLs.
+func (s *Session) blocklistURLsHash() [sha1.Size]byte {
+	return sha1.Sum([]byte(strings.Join(s.blocklistURLs(), "\n")))
+}
+
 func (s *Session) getBlocklistTimestamp() (time.Time, error) {
-	sum := sha1.Sum([]byte(s.config.BlocklistURL))
+	sum := s.blocklistURLsHash()
 	var t time.Time
 	err := s.db.View(func(tx *bbolt.Tx) error {
 		b := tx.Bucket(sessionBucket)
@@ -100,10 +148,97 @@ func (s *Session) retryReloadBlocklist() {
 	}
 }
 
+// reloadBlocklist downloads all blocklist sources and merges them into the blocklist.
+// If a source cannot be loaded, its rules from the last successful load are used.
+// Returns error if none of the sources can be loaded.
 func (s *Session) reloadBlocklist() error {
-	req, err := http.NewRequest(http.MethodGet, s.config.BlocklistURL, nil)
+	urls := s.blocklistURLs()
+	sources := make([]BlocklistSource, len(urls))
+	lists := make([]*blocklist.List, 0, len(urls))
+	var loaded int
+	for i, u := range urls {
+		sources[i].URL = u
+		l, err := s.reloadBlocklistSource(u)
+		if err != nil {
+			s.log.Errorf("cannot load blocklist from %s: %s", u, err)
+			sources[i].Error = err
+			l, _ = s.loadBlocklistSourceFromDB(u)
+		} else {
+			loaded++
+		}
+		if l != nil {
+			sources[i].Rules = l.Len()
+			lists = append(lists, l)
+		}
+	}
+	s.mBlocklist.Lock()
+	s.blocklistSources = sources
+	s.mBlocklist.Unlock()
+	if loaded == 0 {
+		return errors.New("no blocklist source could be loaded")
+	}
+
+	n := s.blocklist.Load(lists...)
+	s.log.Infof("Loaded %d rules from blocklist.", n)
+
+	now := time.Now()
+
+	s.mBlocklist.Lock()
+	s.blocklistTimestamp = now
+	s.mBlocklist.Unlock()
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(sessionBucket)
+		// Blocklist data was saved under a single key in previous versions.
+		err2 := b.Delete(blocklistKey)
+		if err2 != nil {
+			return err2
+		}
+		sum := s.blocklistURLsHash()
+		err2 = b.Put(blocklistURLHashKey, sum[:])
+		if err2 != nil {
+			return err2
+		}
+		return b.Put(blocklistTimestampKey, []byte(now.Format(time.RFC3339)))
+	})
+}
+
+func (s *Session) reloadBlocklistSource(u string) (*blocklist.List, error) {
+	buf, err := s.fetchBlocklist(u)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	buf, err = decompressBlocklist(buf)
+	if err != nil {
+		return nil, err
+	}
+	l, err := blocklist.Parse(bytes.NewReader(buf), s.log.Errorf)
+	if err != nil {
+		return nil, err
+	}
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		b, err2 := tx.Bucket(sessionBucket).CreateBucketIfNotExists(blocklistsBucket)
+		if err2 != nil {
+			return err2
+		}
+		return b.Put([]byte(u), buf)
+	})
+	return l, err
+}
+
+// fetchBlocklist reads the contents of a blocklist from an HTTP or file URL.
+func (s *Session) fetchBlocklist(u string) ([]byte, error) {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+	if pu.Scheme == "file" {
+		return s.readBlocklistFile(pu.Path)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -118,12 +253,13 @@ func (s *Session) reloadBlocklist() error {
 	req = req.WithContext(ctx)
 
 	client := http.Client{
-		Timeout: s.config.BlocklistUpdateTimeout,
+		Timeout:   s.config.BlocklistUpdateTimeout,
+		Transport: s.httpTransport,
 	}
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
@@ -132,78 +268,197 @@ func (s *Session) reloadBlocklist() error {
 	s.log.Infoln("Blocklist response content type:", resp.Header.Get("content-type"))
 
 	if resp.StatusCode != 200 {
-		return errors.New("invalid blocklist status code")
-	}
-	if resp.ContentLength == -1 {
-		return errors.New("unknown content length")
+		return nil, errors.New("invalid blocklist status code")
 	}
 	if resp.ContentLength > s.config.BlocklistMaxResponseSize {
-		return errors.New("response too big")
+		return nil, errors.New("response too big")
 	}
+	return readLimited(resp.Body, s.config.BlocklistMaxResponseSize)
+}
 
-	buf := make([]byte, resp.ContentLength)
-	_, err = io.ReadFull(resp.Body, buf)
+func (s *Session) readBlocklistFile(name string) ([]byte, error) {
+	f, err := os.Open(name)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	resp.Body.Close()
+	defer f.Close()
+	return readLimited(f, s.config.BlocklistMaxResponseSize)
+}
 
-	if resp.Header.Get("content-type") == "application/x-gzip" {
-		gr, gerr := gzip.NewReader(bytes.NewReader(buf))
-		if gerr != nil {
-			return gerr
+// readLimited reads r until EOF. Returns error if there are more than max bytes.
+func readLimited(r io.Reader, max int64) ([]byte, error) {
+	buf, err := io.ReadAll(io.LimitReader(r, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(buf)) > max {
+		return nil, errors.New("response too big")
+	}
+	return buf, nil
+}
+
+// decompressBlocklist detects gzip and zip files by their magic numbers and returns the decompressed data.
+// Contents of all files in a zip archive are concatenated.
+func decompressBlocklist(buf []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(buf, []byte{0x1f, 0x8b}):
+		gr, err := gzip.NewReader(bytes.NewReader(buf))
+		if err != nil {
+			return nil, err
 		}
 		defer gr.Close()
-		buf, err = io.ReadAll(gr)
+		return io.ReadAll(gr)
+	case bytes.HasPrefix(buf, []byte("PK\x03\x04")):
+		zr, err := zip.NewReader(bytes.NewReader(buf), int64(len(buf)))
 		if err != nil {
-			return err
+			return nil, err
 		}
+		var out bytes.Buffer
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			_, err = io.Copy(&out, rc) // nolint: gosec
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+			out.WriteByte('\n')
+		}
+		return out.Bytes(), nil
+	default:
+		return buf, nil
 	}
+}
 
-	err = s.loadBlocklistReader(bytes.NewReader(buf))
+// loadBlocklistFromDB loads the blocklist sources saved in session db.
+// Sources that are not found in db are downloaded in background.
+// Returns error if none of the sources can be loaded.
+func (s *Session) loadBlocklistFromDB() error {
+	err := s.migrateBlocklistKey()
 	if err != nil {
 		return err
 	}
+	missing, err := s.loadBlocklistSourcesFromDB()
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		go s.reloadMissingBlocklistSources(missing)
+	}
+	return nil
+}
 
-	now := time.Now()
+// migrateBlocklistKey moves the blocklist data that was saved under a single key for Config.BlocklistURL in previous versions.
+func (s *Session) migrateBlocklistKey() error {
+	if s.config.BlocklistURL == "" {
+		return nil
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		sb := tx.Bucket(sessionBucket)
+		val := sb.Get(blocklistKey)
+		if len(val) == 0 {
+			return nil
+		}
+		b, err := sb.CreateBucketIfNotExists(blocklistsBucket)
+		if err != nil {
+			return err
+		}
+		if b.Get([]byte(s.config.BlocklistURL)) == nil {
+			err = b.Put([]byte(s.config.BlocklistURL), append([]byte(nil), val...))
+			if err != nil {
+				return err
+			}
+		}
+		return sb.Delete(blocklistKey)
+	})
+}
 
+// loadBlocklistSourcesFromDB merges the sources saved in session db into the blocklist.
+// Returns the URLs of the sources that are not found in db.
+func (s *Session) loadBlocklistSourcesFromDB() ([]string, error) {
+	urls := s.blocklistURLs()
+	sources := make([]BlocklistSource, len(urls))
+	lists := make([]*blocklist.List, 0, len(urls))
+	var missing []string
+	for i, u := range urls {
+		sources[i].URL = u
+		l, err := s.loadBlocklistSourceFromDB(u)
+		if err != nil {
+			sources[i].Error = err
+			missing = append(missing, u)
+			continue
+		}
+		sources[i].Rules = l.Len()
+		lists = append(lists, l)
+	}
+	if len(lists) == 0 {
+		return nil, errors.New("no blocklist data in db")
+	}
+	n := s.blocklist.Load(lists...)
+	s.log.Infof("Loaded %d rules from blocklist.", n)
 	s.mBlocklist.Lock()
-	s.blocklistTimestamp = now
+	s.blocklistSources = sources
 	s.mBlocklist.Unlock()
+	return missing, nil
+}
 
-	return s.db.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket(sessionBucket)
-		err2 := b.Put(blocklistKey, buf)
-		if err2 != nil {
-			return err2
+// reloadMissingBlocklistSources downloads the sources that are not found in session db and merges them into the blocklist.
+// Failed sources are retried until the next periodic reload.
+func (s *Session) reloadMissingBlocklistSources(urls []string) {
+	bo := backoff.NewExponentialBackOff()
+	bo.MaxElapsedTime = s.config.BlocklistUpdateInterval
+
+	ticker := backoff.NewTicker(bo)
+	defer ticker.Stop()
+
+	for len(urls) > 0 {
+		select {
+		case _, ok := <-ticker.C:
+			if !ok {
+				return
+			}
+		case <-s.closeC:
+			return
 		}
-		sum := sha1.Sum([]byte(s.config.BlocklistURL))
-		err2 = b.Put(blocklistURLHashKey, sum[:])
-		if err2 != nil {
-			return err2
+		var failed []string
+		for _, u := range urls {
+			_, err := s.reloadBlocklistSource(u)
+			if err != nil {
+				s.log.Errorf("cannot load blocklist from %s: %s", u, err)
+				failed = append(failed, u)
+			}
 		}
-		return b.Put(blocklistTimestampKey, []byte(now.Format(time.RFC3339)))
-	})
+		if len(failed) < len(urls) {
+			_, err := s.loadBlocklistSourcesFromDB()
+			if err != nil {
+				s.log.Errorln("cannot load blocklist:", err)
+			}
+		}
+		urls = failed
+	}
 }
 
-func (s *Session) loadBlocklistFromDB() error {
-	return s.db.View(func(tx *bbolt.Tx) error {
-		b := tx.Bucket(sessionBucket)
-		val := b.Get(blocklistKey)
-		if len(val) == 0 {
+func (s *Session) loadBlocklistSourceFromDB(u string) (*blocklist.List, error) {
+	var l *blocklist.List
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(sessionBucket).Bucket(blocklistsBucket)
+		if b == nil {
 			return errors.New("no blocklist data in db")
 		}
-		return s.loadBlocklistReader(bytes.NewReader(val))
+		val := b.Get([]byte(u))
+		if len(val) == 0 {
+			return errors.New("no blocklist data in db for " + u)
+		}
+		var err2 error
+		l, err2 = blocklist.Parse(bytes.NewReader(val), nil)
+		return err2
 	})
-}
-
-func (s *Session) loadBlocklistReader(r io.Reader) error {
-	n, err := s.blocklist.Reload(r)
-	if err != nil {
-		return err
-	}
-	s.log.Infof("Loaded %d rules from blocklist.", n)
-	return nil
+	return l, err
 }
 
 func (s *Session) blocklistReloader(d time.Duration) {
@@ -0,0 +1,129 @@
+package torrent
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.etcd.io/bbolt"
+)
+
+func TestBlocklistSources(t *testing.T) {
+	dir := t.TempDir()
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	_, _ = gw.Write([]byte("# p2p\nSome org:1.2.3.0-1.2.3.255\nOther org:5.6.7.8-5.6.7.8\n"))
+	_ = gw.Close()
+	p2pPath := filepath.Join(dir, "p2p.gz")
+	if err := os.WriteFile(p2pPath, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	datPath := filepath.Join(dir, "list.dat")
+	if err := os.WriteFile(datPath, []byte("010.000.000.000 - 010.255.255.255 , 000 , Blocked\n011.000.000.000 - 011.255.255.255 , 200 , Allowed\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s, closeSession := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.BlocklistURLs = []string{
+			"file://" + p2pPath,
+			"file://" + datPath,
+			"file://" + filepath.Join(dir, "missing.txt"),
+		}
+		cfg.BlocklistAllowlist = []string{"1.2.3.4"}
+	})
+	defer closeSession()
+
+	sources := s.Stats().BlocklistSources
+	if len(sources) != 3 {
+		t.Fatalf("got %d sources", len(sources))
+	}
+	if sources[0].Rules != 2 || sources[0].Error != nil {
+		t.Errorf("unexpected p2p source: %+v", sources[0])
+	}
+	if sources[1].Rules != 1 || sources[1].Error != nil {
+		t.Errorf("unexpected dat source: %+v", sources[1])
+	}
+	if sources[2].Rules != 0 || sources[2].Error == nil {
+		t.Errorf("unexpected missing source: %+v", sources[2])
+	}
+
+	for ip, blocked := range map[string]bool{
+		"1.2.3.5":  true,
+		"1.2.3.4":  false,
+		"5.6.7.8":  true,
+		"10.1.2.3": true,
+		"11.1.2.3": false,
+		"8.8.8.8":  false,
+	} {
+		if s.blocklist.Blocked(net.ParseIP(ip)) != blocked {
+			t.Errorf("unexpected result for %s, blocked must be %v", ip, blocked)
+		}
+	}
+}
+
+func TestBlocklistLoadFromDB(t *testing.T) {
+	dir := t.TempDir()
+	db := filepath.Join(dir, "session.db")
+	p2pPath := filepath.Join(dir, "p2p.txt")
+	if err := os.WriteFile(p2pPath, []byte("Some org:1.2.3.0-1.2.3.255\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	missingPath := filepath.Join(dir, "missing.txt")
+	newSession := func() (*Session, func()) {
+		return newTestSessionWithConfig(t, func(cfg *Config) {
+			cfg.Database = db
+			cfg.BlocklistURL = "file://" + p2pPath
+			cfg.BlocklistURLs = []string{"file://" + missingPath}
+		})
+	}
+
+	s, closeSession := newSession()
+	assert.NotNil(t, s.Stats().BlocklistSources[1].Error)
+	closeSession()
+
+	// Simulate a db from previous versions that saved a single blocklist.
+	bdb, err := bbolt.Open(db, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = bdb.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(sessionBucket)
+		err2 := b.Put(blocklistKey, b.Bucket(blocklistsBucket).Get([]byte("file://"+p2pPath)))
+		if err2 != nil {
+			return err2
+		}
+		return b.DeleteBucket(blocklistsBucket)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = bdb.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Saved source is loaded from db and the missing source is downloaded in background.
+	if err = os.Remove(p2pPath); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(missingPath, []byte("Other org:5.6.7.8-5.6.7.8\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s, closeSession = newSession()
+	defer closeSession()
+	assert.True(t, s.blocklist.Blocked(net.ParseIP("1.2.3.4")))
+	for deadline := time.Now().Add(timeout); !s.blocklist.Blocked(net.ParseIP("5.6.7.8")); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("missing source is not loaded")
+		}
+	}
+	sources := s.Stats().BlocklistSources
+	assert.Equal(t, 1, sources[0].Rules)
+	assert.Equal(t, 1, sources[1].Rules)
+	assert.NoError(t, sources[1].Error)
+}
@@ -0,0 +1,57 @@
+package torrent
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionConnectionLimit(t *testing.T) {
+	_, addr1, close1 := loopbackSeeder(t, "127.0.0.2")
+	defer close1()
+	_, addr2, close2 := loopbackSeeder(t, "127.0.0.3")
+	defer close2()
+
+	s, closeSession := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.MaxConnections = 1
+	})
+	defer closeSession()
+
+	tor, err := s.AddURI(torrentMagnetLink+"&x.pe="+addr1+"&x.pe="+addr2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(timeout); tor.Stats().Status != Seeding; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("download did not finish")
+		}
+		assert.LessOrEqual(t, s.Stats().Connections, 1)
+	}
+	assert.Greater(t, s.Stats().ConnectionsContention, int64(0))
+}
+
+func TestSessionUploadSlots(t *testing.T) {
+	seeder, closeSeeder := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.Host = "127.0.0.2"
+		cfg.FileCheckInterval = 0
+		cfg.MaxUploadSlots = 1
+	})
+	defer closeSeeder()
+	seederTorrent := addSeedingTorrent(t, seeder)
+	addr := "127.0.0.2:" + strconv.Itoa(seederTorrent.Port())
+
+	s, closeSession := newTestSessionWithConfig(t, nil)
+	defer closeSession()
+	tor, err := s.AddURI(torrentMagnetLink+"&x.pe="+addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, tor, Seeding)
+	assert.Equal(t, 1, seeder.Stats().UploadSlots)
+
+	seederTorrent.Stop()
+	waitStatus(t, seederTorrent, Stopped)
+	assert.Equal(t, 0, seeder.Stats().UploadSlots)
+}
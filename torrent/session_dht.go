@@ -1,8 +1,13 @@
 package torrent
 
 import (
+	"crypto/ed25519"
+	"errors"
 	"net"
 	"time"
+
+	"github.com/cenkalti/rain/internal/dht"
+	"go.etcd.io/bbolt"
 )
 
 func (s *Session) processDHTResults() {
@@ -16,6 +21,9 @@ func (s *Session) processDHTResults() {
 			for ih, peers := range res {
 				s.mTorrents.RLock()
 				torrents, ok := s.torrentsByInfoHash[ih]
+				if !ok {
+					torrents, ok = s.torrentsByHybridHash[ih]
+				}
 				s.mTorrents.RUnlock()
 				if !ok {
 					continue
@@ -40,6 +48,9 @@ func (s *Session) handleDHTtick() {
 	defer s.mPeerRequests.Unlock()
 	for t := range s.dhtPeerRequests {
 		s.dht.PeersRequestPort(string(t.infoHash[:]), true, t.port)
+		if hh := t.hybrid.Load(); hh != nil {
+			s.dht.PeersRequestPort(string(hh.InfoHash[:]), true, t.port)
+		}
 		delete(s.dhtPeerRequests, t)
 		return
 	}
@@ -60,3 +71,91 @@ func parseDHTPeers(peers []string) []*net.TCPAddr {
 	}
 	return addrs
 }
+
+var errDHTDisabled = errors.New("DHT is disabled")
+
+// DHTGetImmutable looks up the bencoded value of the immutable item with the target in DHT (BEP 44).
+func (s *Session) DHTGetImmutable(target [20]byte) ([]byte, error) {
+	if s.dht == nil {
+		return nil, errDHTDisabled
+	}
+	return s.dht.GetImmutable(target)
+}
+
+// DHTPutImmutable stores the bencoded value in DHT as an immutable item and returns its target (BEP 44).
+func (s *Session) DHTPutImmutable(v []byte) ([20]byte, error) {
+	if s.dht == nil {
+		return [20]byte{}, errDHTDisabled
+	}
+	return s.dht.PutImmutable(v)
+}
+
+// DHTGetMutable looks up the mutable item with the public key and salt in DHT (BEP 44).
+// It returns the bencoded value and the sequence number of the item.
+func (s *Session) DHTGetMutable(key ed25519.PublicKey, salt []byte) (v []byte, seq int64, err error) {
+	if s.dht == nil {
+		return nil, 0, errDHTDisabled
+	}
+	return s.dht.GetMutable(key, salt)
+}
+
+// DHTPutMutable signs the bencoded value with the private key and stores it in DHT as a mutable item (BEP 44).
+func (s *Session) DHTPutMutable(key ed25519.PrivateKey, salt, v []byte, seq int64) error {
+	if s.dht == nil {
+		return errDHTDisabled
+	}
+	return s.dht.PutMutable(key, salt, v, seq)
+}
+
+// DHTSampleInfoHashes queries the DHT node at addr in "host:port" format for a sample of the info hashes that it stores (BEP 51).
+// It returns the sample, the total number of info hashes in the node and the interval that should be waited before querying the node again.
+func (s *Session) DHTSampleInfoHashes(addr string) (samples [][20]byte, num int, interval time.Duration, err error) {
+	if s.dht == nil {
+		return nil, 0, 0, errDHTDisabled
+	}
+	ihs, num, interval, err := s.dht.SampleInfoHashes(addr)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	samples = make([][20]byte, len(ihs))
+	for i, ih := range ihs {
+		copy(samples[i][:], ih)
+	}
+	return samples, num, interval, nil
+}
+
+func (s *Session) dhtStats() dht.Stats {
+	if s.dht == nil {
+		return dht.Stats{}
+	}
+	return s.dht.Stats()
+}
+
+// loadDHTState reads the node ID and routing table of DHT that are saved in the previous run.
+func loadDHTState(db *bbolt.DB) (id [20]byte, nodes []byte, err error) {
+	err = db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(sessionBucket)
+		copy(id[:], b.Get(dhtIDKey))
+		nodes = append(nodes, b.Get(dhtNodesKey)...)
+		return nil
+	})
+	return
+}
+
+// saveDHTState writes the node ID and routing table of DHT to the session database,
+// so the node does not need to bootstrap again on next start.
+func (s *Session) saveDHTState() {
+	id := s.dht.ID()
+	nodes := s.dht.Nodes()
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(sessionBucket)
+		err := b.Put(dhtIDKey, id[:])
+		if err != nil {
+			return err
+		}
+		return b.Put(dhtNodesKey, nodes)
+	})
+	if err != nil {
+		s.log.Errorln("cannot save dht state:", err.Error())
+	}
+}
@@ -0,0 +1,114 @@
+package torrent
+
+import (
+	"crypto/ed25519"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newDHTTestSession(t *testing.T, dir string, bootstrap ...string) *Session {
+	cfg := DefaultConfig
+	cfg.Database = filepath.Join(dir, "session.db")
+	cfg.DataDir = dir
+	cfg.PEXEnabled = false
+	cfg.RPCEnabled = false
+	cfg.Host = "127.0.0.1"
+	cfg.DHTHost = "127.0.0.1"
+	cfg.DHTPort = 0
+	cfg.DHTBootstrapNodes = bootstrap
+	s, err := NewSession(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func waitDHTNodes(t *testing.T, s *Session) {
+	for deadline := time.Now().Add(timeout); s.Stats().DHTNodes == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("dht node is not added to routing table")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestDHTRoutingTableSaved(t *testing.T) {
+	dir1, closeDir1 := tempdir(t)
+	defer closeDir1()
+	s1 := newDHTTestSession(t, dir1)
+	defer s1.Close()
+
+	dir2, closeDir2 := tempdir(t)
+	defer closeDir2()
+	s2 := newDHTTestSession(t, dir2, s1.dht.Addr().String())
+	waitDHTNodes(t, s2)
+	stats := s2.Stats()
+	if stats.DHTBuckets == 0 || stats.DHTQueriesSent == 0 {
+		t.Fatalf("unexpected dht stats: %+v", stats)
+	}
+	id := s2.dht.ID()
+	err := s2.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Routing table and node ID must be loaded from the database without bootstrapping.
+	s2 = newDHTTestSession(t, dir2)
+	defer s2.Close()
+	if s2.Stats().DHTNodes == 0 {
+		t.Fatal("routing table is not loaded")
+	}
+	if s2.dht.ID() != id {
+		t.Fatal("node id is not loaded")
+	}
+}
+
+func TestDHTItems(t *testing.T) {
+	dir1, closeDir1 := tempdir(t)
+	defer closeDir1()
+	s1 := newDHTTestSession(t, dir1)
+	defer s1.Close()
+
+	dir2, closeDir2 := tempdir(t)
+	defer closeDir2()
+	s2 := newDHTTestSession(t, dir2, s1.dht.Addr().String())
+	defer s2.Close()
+	waitDHTNodes(t, s2)
+
+	target, err := s2.DHTPutImmutable([]byte("5:hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := s2.DHTGetImmutable(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "5:hello", string(v))
+
+	pub, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s2.DHTPutMutable(key, []byte("salt"), []byte("i42e"), 1); err != nil {
+		t.Fatal(err)
+	}
+	v, seq, err := s2.DHTGetMutable(pub, []byte("salt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "i42e", string(v))
+	assert.Equal(t, int64(1), seq)
+
+	_, _, _, err = s2.DHTSampleInfoHashes(s1.dht.Addr().String())
+	assert.NoError(t, err)
+
+	s3, closeSession := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.DHTEnabled = false
+	})
+	defer closeSession()
+	_, err = s3.DHTGetImmutable(target)
+	assert.ErrorIs(t, err, errDHTDisabled)
+}
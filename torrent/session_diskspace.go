@@ -0,0 +1,89 @@
+package torrent
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cenkalti/rain/internal/allocator"
+	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/cenkalti/rain/internal/storage"
+	"github.com/cenkalti/rain/internal/storage/filestorage"
+)
+
+// Values for Config.FileAllocation.
+const (
+	fileAllocationSparse = "sparse"
+	fileAllocationFull   = "full"
+)
+
+// Values for Config.DiskSpaceCheck.
+const (
+	diskSpaceCheckRefuse = "refuse"
+	diskSpaceCheckQueue  = "queue"
+)
+
+var errNotEnoughDiskSpace = errors.New("not enough disk space")
+
+func parseFileAllocation(value string) (allocator.Mode, error) {
+	switch value {
+	case "", fileAllocationSparse:
+		return allocator.Sparse, nil
+	case fileAllocationFull:
+		return allocator.Full, nil
+	default:
+		return 0, fmt.Errorf("invalid value for file allocation: %q", value)
+	}
+}
+
+func validateDiskSpaceCheck(value string) error {
+	switch value {
+	case "", diskSpaceCheckRefuse, diskSpaceCheckQueue:
+		return nil
+	default:
+		return fmt.Errorf("invalid value for disk space check: %q", value)
+	}
+}
+
+// hasDiskSpace returns false if the remaining data of the torrent does not fit into the free space of the storage.
+// Returns true if the space cannot be calculated.
+func (s *Session) hasDiskSpace(sto storage.Storage, info *metainfo.Info) bool {
+	fs, ok := sto.(*filestorage.FileStorage)
+	if !ok || info == nil {
+		return true
+	}
+	free, err := fs.FreeSpace()
+	if err != nil {
+		s.log.Debugf("cannot get free disk space: %s", err)
+		return true
+	}
+	var required int64
+	for _, f := range info.Files {
+		if f.Padding {
+			continue
+		}
+		n, err := fs.RequiredSpace(f.Path, f.Length)
+		if err != nil {
+			s.log.Debugf("cannot get required disk space: %s", err)
+			return true
+		}
+		required += n
+	}
+	return required <= free
+}
+
+// checkDiskSpaceLoop notifies torrents that are paused for disk space periodically.
+func (s *Session) checkDiskSpaceLoop() {
+	ticker := time.NewTicker(s.config.DiskSpaceCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, t := range s.ListTorrents() {
+				t.torrent.notifyDiskSpace()
+			}
+		case <-s.closeC:
+			return
+		}
+	}
+}
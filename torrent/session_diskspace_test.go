@@ -0,0 +1,104 @@
+package torrent
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/bufferpool"
+	"github.com/cenkalti/rain/internal/piece"
+	"github.com/cenkalti/rain/internal/piecewriter"
+	"github.com/cenkalti/rain/internal/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileAllocationFull(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("full allocation is supported on linux only")
+	}
+	s, closeSession := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.FileAllocation = "full"
+	})
+	defer closeSession()
+
+	f, err := os.Open(torrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tor, err := s.AddTorrent(f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(timeout); tor.Stats().Status != Downloading; {
+		if time.Now().After(deadline) {
+			t.Fatal("files are not allocated")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	files, err := tor.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sto := tor.torrent.storage.(*filestorage.FileStorage)
+	for _, file := range files {
+		n, err := sto.RequiredSpace(file.Path(), file.Stats().BytesTotal)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Zero(t, n, file.Path())
+	}
+}
+
+func TestPauseOnDiskFull(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("ENOSPC is not returned on windows")
+	}
+	s, closeSession := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.DiskSpaceCheckInterval = 50 * time.Millisecond
+	})
+	defer closeSession()
+
+	f, err := os.Open(torrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tor, err := s.AddTorrent(f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, tor, Downloading)
+
+	// Torrent is paused without an error when a write fails because the disk is full.
+	pw := piecewriter.New(&piece.Piece{}, nil, bufferpool.New(1).Get(1), nil)
+	pw.HashOK = true
+	pw.Error = &os.PathError{Op: "write", Path: "file", Err: syscall.ENOSPC}
+	stopC := tor.NotifyStop()
+	tor.torrent.pieceWriterResultC <- pw
+	select {
+	case err = <-stopC:
+		assert.NoError(t, err)
+	case <-time.After(timeout):
+		t.Fatal("torrent is not paused")
+	}
+
+	// It is resumed after the space is available.
+	waitStatus(t, tor, Downloading)
+}
+
+func TestInvalidFileAllocation(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.FileAllocation = "foo"
+	_, err := NewSession(cfg)
+	assert.Error(t, err)
+}
+
+func TestInvalidDiskSpaceCheck(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.DiskSpaceCheck = "foo"
+	_, err := NewSession(cfg)
+	assert.Error(t, err)
+}
@@ -0,0 +1,99 @@
+package torrent
+
+import (
+	"fmt"
+
+	"github.com/cenkalti/rain/internal/bitfield"
+	"github.com/cenkalti/rain/internal/dht"
+	"github.com/cenkalti/rain/internal/storage/filestorage"
+)
+
+// Values for Config.DuplicateTorrentData.
+const (
+	duplicateDataCopy     = "copy"
+	duplicateDataHardlink = "hardlink"
+	duplicateDataReflink  = "reflink"
+	duplicateDataShared   = "shared"
+)
+
+func parseDuplicateTorrentData(value string) (linkMode filestorage.LinkMode, link bool, err error) {
+	switch value {
+	case "", duplicateDataShared:
+		return 0, false, nil
+	case duplicateDataCopy:
+		return filestorage.Copy, true, nil
+	case duplicateDataHardlink:
+		return filestorage.Hardlink, true, nil
+	case duplicateDataReflink:
+		return filestorage.Reflink, true, nil
+	default:
+		return 0, false, fmt.Errorf("invalid value for duplicate torrent data: %q", value)
+	}
+}
+
+func (s *Session) sharesDuplicateData() bool {
+	return s.config.DuplicateTorrentData == duplicateDataShared
+}
+
+// duplicatesOf returns other torrents in the session that have the same info hash with t.
+func (s *Session) duplicatesOf(t *torrent) []*torrent {
+	s.mTorrents.RLock()
+	defer s.mTorrents.RUnlock()
+	a := s.torrentsByInfoHash[dht.InfoHash(t.infoHash[:])]
+	ret := make([]*torrent, 0, len(a))
+	for _, t2 := range a {
+		if t2.torrent != t {
+			ret = append(ret, t2.torrent)
+		}
+	}
+	return ret
+}
+
+// sharedDataDir returns the data directory of an existing torrent with the same info hash.
+// Returns empty string if there is no such torrent or data sharing is disabled.
+func (s *Session) sharedDataDir(infoHash []byte) string {
+	if !s.sharesDuplicateData() {
+		return ""
+	}
+	s.mTorrents.RLock()
+	defer s.mTorrents.RUnlock()
+	for _, t := range s.torrentsByInfoHash[dht.InfoHash(infoHash)] {
+		return t.torrent.RootDirectory()
+	}
+	return ""
+}
+
+// completedDuplicate returns a duplicate of t that has all pieces, together with a copy of its bitfield.
+func (s *Session) completedDuplicate(t *torrent) (*torrent, *bitfield.Bitfield) {
+	for _, t2 := range s.duplicatesOf(t) {
+		t2.mBitfield.RLock()
+		var bf *bitfield.Bitfield
+		if t2.bitfield != nil && t2.bitfield.All() {
+			bf = t2.bitfield.Copy()
+		}
+		t2.mBitfield.RUnlock()
+		if bf != nil {
+			return t2, bf
+		}
+	}
+	return nil, nil
+}
+
+// dataShared returns true if another torrent in the session keeps its data in the same place with t.
+func (s *Session) dataShared(t *torrent) bool {
+	s.mTorrents.RLock()
+	defer s.mTorrents.RUnlock()
+	for _, t2 := range s.torrents {
+		if t2.torrent == t {
+			continue
+		}
+		if s.config.DataDirIncludesTorrentID {
+			if t2.torrent.RootDirectory() == t.RootDirectory() {
+				return true
+			}
+		} else if t2.torrent.infoHash == t.infoHash {
+			return true
+		}
+	}
+	return false
+}
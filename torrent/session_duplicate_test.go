@@ -0,0 +1,113 @@
+package torrent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	cp "github.com/otiai10/copy"
+	"github.com/stretchr/testify/assert"
+)
+
+func addCompletedTorrent(t *testing.T, s *Session) *Torrent {
+	f, err := os.Open(torrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tor, err := s.AddTorrent(f, &AddTorrentOptions{Stopped: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cp.Copy(filepath.Join(torrentDataDir, torrentName), filepath.Join(tor.RootDirectory(), torrentName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tor.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-tor.NotifyComplete():
+	case err = <-tor.NotifyStop():
+		t.Fatal(err)
+	case <-time.After(timeout):
+		t.Fatal("torrent is not completed")
+	}
+	return tor
+}
+
+func TestDuplicateTorrentHardlink(t *testing.T) {
+	s, closeSession := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.DuplicateTorrentData = "hardlink"
+	})
+	defer closeSession()
+
+	tor1 := addCompletedTorrent(t, s)
+
+	f, err := os.Open(torrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tor2, err := s.AddTorrent(f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertCompleted(t, tor2)
+	assert.NotEqual(t, tor1.RootDirectory(), tor2.RootDirectory())
+
+	name := filepath.Join(torrentName, "data", "file1.bin")
+	fi1, err := os.Stat(filepath.Join(tor1.RootDirectory(), name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi2, err := os.Stat(filepath.Join(tor2.RootDirectory(), name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, os.SameFile(fi1, fi2))
+}
+
+func TestDuplicateTorrentShared(t *testing.T) {
+	s, closeSession := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.DuplicateTorrentData = "shared"
+	})
+	defer closeSession()
+
+	tor1 := addCompletedTorrent(t, s)
+
+	f, err := os.Open(torrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tor2, err := s.AddTorrent(f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-tor2.NotifyComplete():
+	case err = <-tor2.NotifyStop():
+		t.Fatal(err)
+	case <-time.After(timeout):
+		t.Fatal("torrent is not completed")
+	}
+	assert.Equal(t, tor1.RootDirectory(), tor2.RootDirectory())
+
+	// Data must be kept until the last torrent using it is removed.
+	err = s.RemoveTorrent(tor2.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = os.Stat(tor1.RootDirectory())
+	assert.NoError(t, err)
+}
+
+func TestInvalidDuplicateTorrentData(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.DuplicateTorrentData = "foo"
+	_, err := NewSession(cfg)
+	assert.Error(t, err)
+}
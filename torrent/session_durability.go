@@ -0,0 +1,55 @@
+package torrent
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cenkalti/rain/internal/storage/filestorage"
+)
+
+// Values for Config.WriteDurability.
+const (
+	durabilitySync     = "sync"
+	durabilityPiece    = "piece"
+	durabilityPeriodic = "periodic"
+	durabilityBitfield = "bitfield"
+)
+
+func validateWriteDurability(value string) error {
+	switch value {
+	case "", durabilitySync, durabilityPiece, durabilityPeriodic, durabilityBitfield:
+		return nil
+	default:
+		return fmt.Errorf("invalid value for write durability: %q", value)
+	}
+}
+
+// syncsOnWrite returns true if the data of a piece is on disk when its write is finished.
+func (s *Session) syncsOnWrite() bool {
+	switch s.config.WriteDurability {
+	case "", durabilitySync, durabilityPiece:
+		return true
+	default:
+		return false
+	}
+}
+
+// syncInterval returns the interval for flushing files of torrents in background.
+// Returns zero if files are not flushed periodically.
+func (s *Session) syncInterval() time.Duration {
+	switch s.config.WriteDurability {
+	case durabilityPeriodic:
+		return s.config.WriteSyncInterval
+	case durabilityBitfield:
+		return s.config.ResumeWriteInterval
+	default:
+		return 0
+	}
+}
+
+func (s *Session) newStorage(dir string) (*filestorage.FileStorage, error) {
+	return filestorage.New(dir, s.config.FilePermissions, filestorage.Options{
+		PartSuffix: s.config.IncompleteFileSuffix,
+		SyncWrites: s.config.WriteDurability == "" || s.config.WriteDurability == durabilitySync,
+	})
+}
@@ -0,0 +1,99 @@
+package torrent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/bitfield"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteDurabilityBitfield(t *testing.T) {
+	addr, cl := seeder(t, true)
+	defer cl()
+
+	s, closeSession := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.WriteDurability = "bitfield"
+	})
+	defer closeSession()
+
+	tor, err := s.AddURI(torrentMagnetLink+"&x.pe="+addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-tor.NotifyComplete():
+	case err = <-tor.NotifyStop():
+		t.Fatal(err)
+	case <-time.After(timeout):
+		t.Fatal("download did not finish")
+	}
+
+	// Bitfield is saved after files are flushed on completion.
+	for deadline := time.Now().Add(timeout); ; {
+		spec, err := s.resumer.Read(tor.ID())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(spec.Bitfield) > 0 {
+			bf, err := bitfield.NewBytes(spec.Bitfield, uint32(tor.Stats().Pieces.Total))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bf.All() {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("bitfield is not saved")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.NotZero(t, s.metrics.SyncLatency.Count())
+}
+
+func TestWriteDurabilityStop(t *testing.T) {
+	addr, cl := seeder(t, true)
+	defer cl()
+
+	s, closeSession := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.WriteDurability = "periodic"
+		cfg.WriteSyncInterval = time.Hour
+	})
+	defer closeSession()
+
+	tor, err := s.AddURI(torrentMagnetLink+"&x.pe="+addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-tor.NotifyComplete():
+	case err = <-tor.NotifyStop():
+		t.Fatal(err)
+	case <-time.After(timeout):
+		t.Fatal("download did not finish")
+	}
+	err = tor.Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, tor, Stopped)
+
+	// Files are flushed in background and the bitfield is saved before the torrent is stopped.
+	spec, err := s.resumer.Read(tor.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bf, err := bitfield.NewBytes(spec.Bitfield, uint32(tor.Stats().Pieces.Total))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, bf.All())
+}
+
+func TestInvalidWriteDurability(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.WriteDurability = "foo"
+	_, err := NewSession(cfg)
+	assert.Error(t, err)
+}
@@ -7,7 +7,6 @@ import (
 	"github.com/cenkalti/rain/internal/metainfo"
 	"github.com/cenkalti/rain/internal/resumer"
 	"github.com/cenkalti/rain/internal/resumer/boltdbresumer"
-	"github.com/cenkalti/rain/internal/storage/filestorage"
 	"github.com/cenkalti/rain/internal/webseedsource"
 	"go.etcd.io/bbolt"
 )
@@ -75,6 +74,18 @@ func (s *Session) loadExistingTorrent(id string) (tt *Torrent, hasStarted bool,
 		}
 		info = info2
 		private = info.Private
+		if len(spec.PieceLayers) > 0 {
+			err2 = info.SetPieceLayers(spec.PieceLayers)
+			if err2 != nil {
+				return nil, spec.Started, err2
+			}
+		}
+		if len(spec.MerkleTree) > 0 {
+			err2 = info.SetMerkleTree(spec.MerkleTree)
+			if err2 != nil {
+				return nil, spec.Started, err2
+			}
+		}
 		if len(spec.Bitfield) > 0 {
 			bf3, err3 := bitfield.NewBytes(spec.Bitfield, info.NumPieces)
 			if err3 != nil {
@@ -83,10 +94,23 @@ func (s *Session) loadExistingTorrent(id string) (tt *Torrent, hasStarted bool,
 			bf = bf3
 		}
 	}
-	sto, err := filestorage.New(s.getDataDir(id), s.config.FilePermissions)
+	dir := s.getDataDir(id)
+	if spec.Dest != "" {
+		dir = spec.Dest
+	}
+	sto, err := s.newStorage(dir)
+	if err != nil {
+		return
+	}
+	port, err := s.loadPort(spec.Port, spec.OwnPort)
 	if err != nil {
 		return
 	}
+	defer func() {
+		if err != nil {
+			s.releasePort(port)
+		}
+	}()
 	t, err := newTorrent2(
 		s,
 		id,
@@ -94,7 +118,7 @@ func (s *Session) loadExistingTorrent(id string) (tt *Torrent, hasStarted bool,
 		spec.InfoHash,
 		sto,
 		spec.Name,
-		spec.Port,
+		port,
 		s.parseTrackers(spec.Trackers, private),
 		spec.FixedPeers,
 		info,
@@ -105,18 +129,33 @@ func (s *Session) loadExistingTorrent(id string) (tt *Torrent, hasStarted bool,
 			BytesWasted:     spec.BytesWasted,
 			SeededFor:       int64(spec.SeededFor),
 		},
-		webseedsource.NewList(spec.URLList),
+		append(webseedsource.NewList(spec.URLList), webseedsource.NewHTTPSeedList(spec.HTTPSeeds)...),
 		spec.StopAfterDownload,
 		spec.StopAfterMetadata,
 		spec.CompleteCmdRun,
+		spec.ChokingAlgorithm,
 	)
 	if err != nil {
 		return
 	}
 	t.rawTrackers = spec.Trackers
 	t.rawWebseedSources = spec.URLList
+	t.rawHTTPSeeds = spec.HTTPSeeds
+	t.signatures = unmarshalSignatures(spec.Signatures)
+	if info != nil {
+		t.signer = s.trustedSigner(info.Bytes, t.signatures)
+	}
+	t.dataDir = spec.Dest
+	t.label = spec.Label
+	t.fileStats = spec.FileStats
+	t.ownPort = spec.OwnPort
+	t.firstLastPieces = spec.FirstLastPieces
+	t.selectedFiles = spec.SelectedFiles
+	t.exactLength = spec.ExactLength
+	t.metadataSources = spec.MetadataSources
+	t.sharedPort = s.usesSharedPort(spec.OwnPort)
+	unmarshalBans(spec.Bans, t.bans)
 	go s.checkTorrent(t)
-	delete(s.availablePorts, spec.Port)
 
 	tt = s.insertTorrent(t)
 	return
@@ -162,14 +201,34 @@ func (s *Session) CompactDatabase(output string) error {
 		return err
 	}
 	for _, t := range s.torrents {
+		t.torrent.mStorage.RLock()
+		dataDir := t.torrent.dataDir
+		t.torrent.mStorage.RUnlock()
+		var pieceLayers, merkleTree []byte
+		if t.torrent.info != nil {
+			pieceLayers = t.torrent.info.PieceLayersBytes()
+			merkleTree = t.torrent.info.MerkleTree()
+		}
 		spec := &boltdbresumer.Spec{
 			InfoHash:          t.torrent.InfoHash(),
 			Port:              t.torrent.port,
+			OwnPort:           t.torrent.ownPort,
+			ChokingAlgorithm:  t.torrent.chokingAlgorithm,
+			FirstLastPieces:   t.torrent.firstLastPieces,
+			SelectedFiles:     t.torrent.selectedFiles,
+			ExactLength:       t.torrent.exactLength,
+			MetadataSources:   t.torrent.metadataSources,
 			Name:              t.torrent.name,
 			Trackers:          t.torrent.rawTrackers,
 			URLList:           t.torrent.rawWebseedSources,
+			HTTPSeeds:         t.torrent.rawHTTPSeeds,
 			FixedPeers:        t.torrent.fixedPeers,
 			Info:              t.torrent.info.Bytes,
+			PieceLayers:       pieceLayers,
+			MerkleTree:        merkleTree,
+			Signatures:        marshalSignatures(t.torrent.signatures),
+			Dest:              dataDir,
+			Label:             t.torrent.label,
 			AddedAt:           t.torrent.addedAt,
 			StopAfterDownload: t.torrent.stopAfterDownload,
 			StopAfterMetadata: t.torrent.stopAfterMetadata,
@@ -0,0 +1,79 @@
+package torrent
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMagnetExactSource(t *testing.T) {
+	srv := httptest.NewServer(http.FileServer(http.Dir(torrentDataDir)))
+	defer srv.Close()
+	xs := "&xs=" + url.QueryEscape(srv.URL+"/sample_torrent.torrent")
+	ws := "&ws=" + url.QueryEscape("http://127.0.0.1:1/files/")
+
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+
+	// Torrent file is downloaded from the exact source after the torrent is started.
+	tor, err := s.AddURI(torrentMagnetLink+xs+ws+"&xl=10506282", &AddTorrentOptions{StopAfterMetadata: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-tor.NotifyMetadata():
+	case <-time.After(timeout):
+		t.Fatal("metadata is not downloaded")
+	}
+	_, err = tor.Torrent()
+	assert.NoError(t, err)
+	assert.Len(t, tor.Webseeds(), 1)
+	m, err := tor.Magnet()
+	assert.NoError(t, err)
+	assert.Contains(t, m, ws)
+
+	// Torrent with a different length is not accepted, metadata is going to be downloaded from peers.
+	err = s.RemoveTorrent(tor.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tor, err = s.AddURI(torrentMagnetLink+xs+"&xl=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second)
+	assert.Equal(t, DownloadingMetadata, tor.Stats().Status)
+	_, err = tor.Torrent()
+	assert.Error(t, err)
+}
+
+func TestMagnetSelectOnly(t *testing.T) {
+	_, addr, closeSeeder := loopbackSeeder(t, "127.0.0.2")
+	defer closeSeeder()
+
+	s, closeSession := newTestSessionWithConfig(t, nil)
+	defer closeSession()
+
+	// Only the first piece contains data/file1.bin.
+	tor, err := s.AddURI(torrentMagnetLink+"&x.pe="+addr+"&so=0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-tor.NotifyComplete():
+	case <-time.After(timeout):
+		t.Fatal("selected file is not downloaded")
+	}
+	waitStatus(t, tor, Seeding)
+	assert.Equal(t, uint32(1), tor.Stats().Pieces.Have)
+
+	// Files that are not selected are not created.
+	_, err = os.Stat(filepath.Join(s.config.DataDir, tor.ID(), "sample_torrent", "README"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -13,6 +13,19 @@ type sessionMetrics struct {
 	Torrents              metrics.Gauge
 	Peers                 metrics.Counter
 	PortsAvailable        metrics.Gauge
+	Connections           metrics.Gauge
+	ConnectionsWaiting    metrics.Gauge
+	ConnectionsContention metrics.Gauge
+	HalfOpen              metrics.Gauge
+	HalfOpenWaiting       metrics.Gauge
+	HalfOpenContention    metrics.Gauge
+	UploadSlots           metrics.Gauge
+	UploadSlotsWaiting    metrics.Gauge
+	UploadSlotsContention metrics.Gauge
+	DHTNodes              metrics.Gauge
+	DHTBuckets            metrics.Gauge
+	DHTQueriesSent        metrics.Gauge
+	DHTQueriesReceived    metrics.Gauge
 	Uptime                metrics.Gauge
 	BlockListRules        metrics.Gauge
 	BlockListRecency      metrics.Gauge
@@ -28,6 +41,8 @@ type sessionMetrics struct {
 	WritesPerSecond       metrics.Meter
 	WritesActive          metrics.Gauge
 	WritesPending         metrics.Gauge
+	WriteLatency          metrics.Timer
+	SyncLatency           metrics.Timer
 	SpeedDownload         metrics.Meter
 	SpeedUpload           metrics.Meter
 	SpeedRead             metrics.Meter
@@ -53,6 +68,21 @@ func (s *Session) initMetrics() {
 			return int64(len(s.availablePorts))
 		}),
 
+		Connections:           metrics.NewRegisteredFunctionalGauge("connections", r, func() int64 { return int64(s.connSlots.Stats().Used) }),
+		ConnectionsWaiting:    metrics.NewRegisteredFunctionalGauge("connections_waiting", r, func() int64 { return int64(s.connSlots.Stats().Waiting) }),
+		ConnectionsContention: metrics.NewRegisteredFunctionalGauge("connections_contention", r, func() int64 { return s.connSlots.Stats().Contention }),
+		HalfOpen:              metrics.NewRegisteredFunctionalGauge("half_open", r, func() int64 { return int64(s.dialSlots.Stats().Used) }),
+		HalfOpenWaiting:       metrics.NewRegisteredFunctionalGauge("half_open_waiting", r, func() int64 { return int64(s.dialSlots.Stats().Waiting) }),
+		HalfOpenContention:    metrics.NewRegisteredFunctionalGauge("half_open_contention", r, func() int64 { return s.dialSlots.Stats().Contention }),
+		UploadSlots:           metrics.NewRegisteredFunctionalGauge("upload_slots", r, func() int64 { return int64(s.uploadSlots.Stats().Used) }),
+		UploadSlotsWaiting:    metrics.NewRegisteredFunctionalGauge("upload_slots_waiting", r, func() int64 { return int64(s.uploadSlots.Stats().Waiting) }),
+		UploadSlotsContention: metrics.NewRegisteredFunctionalGauge("upload_slots_contention", r, func() int64 { return s.uploadSlots.Stats().Contention }),
+
+		DHTNodes:           metrics.NewRegisteredFunctionalGauge("dht_nodes", r, func() int64 { return int64(s.dhtStats().Nodes) }),
+		DHTBuckets:         metrics.NewRegisteredFunctionalGauge("dht_buckets", r, func() int64 { return int64(s.dhtStats().Buckets) }),
+		DHTQueriesSent:     metrics.NewRegisteredFunctionalGauge("dht_queries_sent", r, func() int64 { return s.dhtStats().QueriesSent }),
+		DHTQueriesReceived: metrics.NewRegisteredFunctionalGauge("dht_queries_received", r, func() int64 { return s.dhtStats().QueriesReceived }),
+
 		BlockListRules: metrics.NewRegisteredFunctionalGauge("blocklist_rules", r, func() int64 { return int64(s.blocklist.Len()) }),
 		BlockListRecency: metrics.NewRegisteredFunctionalGauge("blocklist_recency", r, func() int64 {
 			s.mBlocklist.RLock()
@@ -78,6 +108,8 @@ func (s *Session) initMetrics() {
 		WritesPerSecond: metrics.NewRegisteredMeter("writes_per_second", r),
 		WritesActive:    metrics.NewRegisteredFunctionalGauge("writes_active", r, func() int64 { return int64(s.semWrite.Len()) }),
 		WritesPending:   metrics.NewRegisteredFunctionalGauge("writes_pending", r, func() int64 { return int64(s.semWrite.Waiting()) }),
+		WriteLatency:    metrics.NewRegisteredTimer("write_latency", r),
+		SyncLatency:     metrics.NewRegisteredTimer("sync_latency", r),
 
 		SpeedDownload: metrics.NewRegisteredMeter("speed_download", r),
 		SpeedUpload:   metrics.NewRegisteredMeter("speed_upload", r),
@@ -90,6 +122,8 @@ func (s *Session) initMetrics() {
 
 func (m *sessionMetrics) Close() {
 	m.WritesPerSecond.Stop()
+	m.WriteLatency.Stop()
+	m.SyncLatency.Stop()
 	m.SpeedDownload.Stop()
 	m.SpeedUpload.Stop()
 	m.SpeedWrite.Stop()
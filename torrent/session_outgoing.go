@@ -0,0 +1,160 @@
+package torrent
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+)
+
+// outgoingIP returns the IP address that outgoing connections are made from.
+// Returns nil if the address is not configured or traffic is bound to OutgoingInterface instead.
+func outgoingIP(cfg *Config) (net.IP, error) {
+	if cfg.OutgoingAddress != "" && cfg.OutgoingInterface != "" {
+		return nil, errors.New("outgoing address and interface cannot be set together")
+	}
+	if cfg.OutgoingAddress != "" {
+		ip := net.ParseIP(cfg.OutgoingAddress)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid outgoing address: %q", cfg.OutgoingAddress)
+		}
+		return ip, nil
+	}
+	if cfg.OutgoingInterface != "" {
+		if !bindInterfaceSupported {
+			return nil, errors.New("outgoing interface is not supported on this platform")
+		}
+		_, err := net.InterfaceByName(cfg.OutgoingInterface)
+		return nil, err
+	}
+	if cfg.OutgoingKillSwitch {
+		return nil, errors.New("kill-switch requires an outgoing address or interface")
+	}
+	return nil, nil
+}
+
+// outgoingControl returns a function that binds sockets to OutgoingInterface before they are connected.
+// Returns nil if the interface is not configured.
+func outgoingControl(cfg *Config) func(network, address string, c syscall.RawConn) error {
+	name := cfg.OutgoingInterface
+	if name == "" {
+		return nil
+	}
+	return func(network, address string, c syscall.RawConn) error {
+		return bindInterface(network, c, name)
+	}
+}
+
+func interfaceIPs(iface *net.Interface) ([]net.IP, error) {
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		if in, ok := addr.(*net.IPNet); ok {
+			ips = append(ips, in.IP)
+		}
+	}
+	return ips, nil
+}
+
+// outgoingDialer returns a dialer that binds to ip if it is not nil.
+// control is set as the Control function of the dialer for binding to an interface.
+func outgoingDialer(ip net.IP, control func(network, address string, c syscall.RawConn) error, timeout time.Duration) *net.Dialer {
+	d := &net.Dialer{Timeout: timeout, Control: control}
+	if ip != nil {
+		d.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+	return d
+}
+
+// outgoingAddressAvailable returns true if the outgoing address is assigned to an interface that is up.
+// If OutgoingInterface is set, the interface must be up and have an IPv4 address.
+// The address of the interface is resolved again on every call because it may change when the tunnel is reconnected.
+func (s *Session) outgoingAddressAvailable() bool {
+	if s.config.OutgoingInterface != "" {
+		ip := s.outgoingInterfaceIP()
+		if ip != nil && !ip.Equal(s.outgoingInterfaceAddr) {
+			s.log.Infof("outgoing interface %s has address %s", s.config.OutgoingInterface, ip)
+		}
+		s.outgoingInterfaceAddr = ip
+		return ip != nil
+	}
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		s.log.Errorln("cannot get network interfaces:", err)
+		return false
+	}
+	for i := range ifaces {
+		if ifaces[i].Flags&net.FlagUp == 0 {
+			continue
+		}
+		ips, err := interfaceIPs(&ifaces[i])
+		if err != nil {
+			continue
+		}
+		for _, ip := range ips {
+			if ip.Equal(s.outgoingIP) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// outgoingInterfaceIP returns the first IPv4 address of OutgoingInterface.
+// Returns nil if the interface is not found, down or has no IPv4 address.
+func (s *Session) outgoingInterfaceIP() net.IP {
+	iface, err := net.InterfaceByName(s.config.OutgoingInterface)
+	if err != nil || iface.Flags&net.FlagUp == 0 {
+		return nil
+	}
+	ips, err := interfaceIPs(iface)
+	if err != nil {
+		return nil
+	}
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			return ip
+		}
+	}
+	return nil
+}
+
+// outgoingName returns the configured outgoing address or interface for logging.
+func (s *Session) outgoingName() string {
+	if s.config.OutgoingInterface != "" {
+		return "interface " + s.config.OutgoingInterface
+	}
+	return "address " + s.outgoingIP.String()
+}
+
+// checkOutgoingAddressLoop pauses the torrents when the outgoing address is not available and starts them again after it is back.
+func (s *Session) checkOutgoingAddressLoop() {
+	ticker := time.NewTicker(s.config.OutgoingKillSwitchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.setOutgoingAddressDown(!s.outgoingAddressAvailable())
+		case <-s.closeC:
+			return
+		}
+	}
+}
+
+func (s *Session) setOutgoingAddressDown(down bool) {
+	if s.outgoingDown.Swap(down) == down {
+		return
+	}
+	if down {
+		s.log.Warningf("outgoing %s is not available, pausing torrents", s.outgoingName())
+	} else {
+		s.log.Infof("outgoing %s is available again", s.outgoingName())
+	}
+	for _, t := range s.ListTorrents() {
+		t.torrent.notifyOutgoingAddress()
+	}
+}
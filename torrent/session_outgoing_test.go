@@ -0,0 +1,114 @@
+package torrent
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutgoingAddress(t *testing.T) {
+	seederTorrent, seederAddr, closeSeeder := loopbackSeeder(t, "127.0.0.2")
+	defer closeSeeder()
+
+	s, closeSession := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.OutgoingAddress = "127.0.0.5"
+		cfg.SpeedLimitDownload = 1
+	})
+	defer closeSession()
+
+	_, err := s.AddURI(torrentMagnetLink+"&x.pe="+seederAddr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(timeout); ; time.Sleep(10 * time.Millisecond) {
+		peers := seederTorrent.Peers()
+		if len(peers) > 0 {
+			assert.Equal(t, "127.0.0.5", peers[0].Addr.(*net.TCPAddr).IP.String())
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("leecher did not connect")
+		}
+	}
+}
+
+func TestOutgoingInterface(t *testing.T) {
+	seederTorrent, seederAddr, closeSeeder := loopbackSeeder(t, "127.0.0.2")
+	defer closeSeeder()
+
+	s, closeSession := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.OutgoingInterface = "lo"
+		cfg.SpeedLimitDownload = 1
+	})
+	defer closeSession()
+
+	_, err := s.AddURI(torrentMagnetLink+"&x.pe="+seederAddr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(timeout); len(seederTorrent.Peers()) == 0; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("leecher did not connect")
+		}
+	}
+}
+
+func TestOutgoingConfig(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.OutgoingKillSwitch = true
+	_, err := outgoingIP(&cfg)
+	assert.Error(t, err)
+
+	cfg.OutgoingInterface = "lo"
+	ip, err := outgoingIP(&cfg)
+	if assert.NoError(t, err) {
+		assert.Nil(t, ip)
+	}
+	assert.NotNil(t, outgoingControl(&cfg))
+
+	cfg.OutgoingAddress = "127.0.0.1"
+	_, err = outgoingIP(&cfg)
+	assert.Error(t, err)
+
+	cfg.OutgoingInterface = ""
+	cfg.OutgoingAddress = "invalid"
+	_, err = outgoingIP(&cfg)
+	assert.Error(t, err)
+}
+
+func TestOutgoingInterfaceKillSwitch(t *testing.T) {
+	s, closeSession := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.OutgoingInterface = "lo"
+		cfg.OutgoingKillSwitch = true
+		cfg.OutgoingKillSwitchInterval = time.Hour
+	})
+	defer closeSession()
+	assert.True(t, s.outgoingAddressAvailable())
+	assert.True(t, s.outgoingInterfaceAddr.IsLoopback())
+
+	// Address is resolved again on every check.
+	s.outgoingInterfaceAddr = net.ParseIP("10.0.0.1")
+	assert.True(t, s.outgoingAddressAvailable())
+	assert.True(t, s.outgoingInterfaceAddr.IsLoopback())
+}
+
+func TestOutgoingKillSwitch(t *testing.T) {
+	s, closeSession := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.OutgoingAddress = "127.0.0.1"
+		cfg.OutgoingKillSwitch = true
+		cfg.OutgoingKillSwitchInterval = time.Hour
+	})
+	defer closeSession()
+	assert.True(t, s.outgoingAddressAvailable())
+
+	tor := addSeedingTorrent(t, s)
+
+	s.setOutgoingAddressDown(true)
+	waitStatus(t, tor, Stopped)
+	assert.NoError(t, tor.Stats().Error)
+
+	s.setOutgoingAddressDown(false)
+	waitStatus(t, tor, Seeding)
+}
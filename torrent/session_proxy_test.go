@@ -0,0 +1,91 @@
+package torrent
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// connectProxy is an HTTP proxy that only supports CONNECT method. It records the addresses of tunnels.
+type connectProxy struct {
+	m     sync.Mutex
+	addrs []string
+}
+
+func (p *connectProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodConnect {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	p.m.Lock()
+	p.addrs = append(p.addrs, r.Host)
+	p.m.Unlock()
+	target, err := net.Dial("tcp", r.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer target.Close()
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	_, _ = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+	go func() { _, _ = io.Copy(target, conn) }()
+	_, _ = io.Copy(conn, target)
+}
+
+func (p *connectProxy) Addrs() []string {
+	p.m.Lock()
+	defer p.m.Unlock()
+	return append([]string(nil), p.addrs...)
+}
+
+func TestProxyOnly(t *testing.T) {
+	_, seederAddr, closeSeeder := loopbackSeeder(t, "127.0.0.2")
+	defer closeSeeder()
+
+	px := new(connectProxy)
+	srv := httptest.NewServer(px)
+	defer srv.Close()
+
+	s, closeSession := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.ProxyURL = srv.URL
+		cfg.ProxyOnly = true
+		cfg.DHTEnabled = true
+	})
+	defer closeSession()
+	assert.False(t, s.config.DHTEnabled)
+
+	tor, err := s.AddURI(torrentMagnetLink+"&x.pe="+seederAddr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-tor.NotifyComplete():
+	case err = <-tor.NotifyStop():
+		t.Fatal(err)
+	case <-time.After(timeout):
+		t.Fatal("download did not finish")
+	}
+	assert.Contains(t, px.Addrs(), seederAddr)
+
+	// Incoming connections are not accepted.
+	_, err = net.DialTimeout("tcp", "127.0.0.1:"+strconv.Itoa(tor.Port()), time.Second)
+	assert.Error(t, err)
+}
+
+func TestProxyOnlyRequiresURL(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.ProxyOnly = true
+	_, err := NewSession(cfg)
+	assert.Error(t, err)
+}
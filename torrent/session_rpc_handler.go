@@ -2,6 +2,7 @@ package torrent
 
 import (
 	"archive/tar"
+	"crypto/ed25519"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
@@ -14,6 +15,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/cenkalti/rain/internal/dht"
 	"github.com/cenkalti/rain/internal/resumer/boltdbresumer"
 	"github.com/cenkalti/rain/internal/rpctypes"
 	"github.com/powerman/rpc-codec/jsonrpc2"
@@ -46,6 +48,10 @@ func (h *rpcHandler) AddTorrent(args *rpctypes.AddTorrentRequest, reply *rpctype
 		ID:                args.AddTorrentOptions.ID,
 		StopAfterDownload: args.StopAfterDownload,
 		StopAfterMetadata: args.StopAfterMetadata,
+		Label:             args.Label,
+		OwnPort:           args.OwnPort,
+		ChokingAlgorithm:  args.ChokingAlgorithm,
+		FirstLastPieces:   args.FirstLastPieces,
 	}
 	t, err := h.session.AddTorrent(r, opt)
 	var e *InputError
@@ -65,6 +71,10 @@ func (h *rpcHandler) AddURI(args *rpctypes.AddURIRequest, reply *rpctypes.AddURI
 		ID:                args.AddTorrentOptions.ID,
 		StopAfterDownload: args.StopAfterDownload,
 		StopAfterMetadata: args.StopAfterMetadata,
+		Label:             args.Label,
+		OwnPort:           args.OwnPort,
+		ChokingAlgorithm:  args.ChokingAlgorithm,
+		FirstLastPieces:   args.FirstLastPieces,
 	}
 	t, err := h.session.AddURI(args.URI, opt)
 	var e *InputError
@@ -85,6 +95,7 @@ func newTorrent(t *Torrent) rpctypes.Torrent {
 		InfoHash: t.InfoHash().String(),
 		Port:     t.Port(),
 		AddedAt:  rpctypes.Time{Time: t.AddedAt()},
+		Label:    t.Label(),
 	}
 }
 
@@ -126,9 +137,26 @@ func (h *rpcHandler) GetSessionStats(args *rpctypes.GetSessionStatsRequest, repl
 		Torrents:       s.Torrents,
 		Peers:          s.Peers,
 		PortsAvailable: s.PortsAvailable,
+		ExternalIP:     s.ExternalIP,
+
+		Connections:           s.Connections,
+		ConnectionsWaiting:    s.ConnectionsWaiting,
+		ConnectionsContention: s.ConnectionsContention,
+		HalfOpen:              s.HalfOpen,
+		HalfOpenWaiting:       s.HalfOpenWaiting,
+		HalfOpenContention:    s.HalfOpenContention,
+		UploadSlots:           s.UploadSlots,
+		UploadSlotsWaiting:    s.UploadSlotsWaiting,
+		UploadSlotsContention: s.UploadSlotsContention,
+
+		DHTNodes:           s.DHTNodes,
+		DHTBuckets:         s.DHTBuckets,
+		DHTQueriesSent:     s.DHTQueriesSent,
+		DHTQueriesReceived: s.DHTQueriesReceived,
 
 		BlockListRules:   s.BlockListRules,
 		BlockListRecency: int(s.BlockListRecency / time.Second),
+		BlocklistSources: make([]rpctypes.BlocklistSource, len(s.BlocklistSources)),
 
 		ReadCacheObjects:     s.ReadCacheObjects,
 		ReadCacheSize:        s.ReadCacheSize,
@@ -145,6 +173,8 @@ func (h *rpcHandler) GetSessionStats(args *rpctypes.GetSessionStatsRequest, repl
 		WritesPerSecond: s.WritesPerSecond,
 		WritesActive:    s.WritesActive,
 		WritesPending:   s.WritesPending,
+		WriteLatency:    int(s.WriteLatency / time.Millisecond),
+		SyncLatency:     int(s.SyncLatency / time.Millisecond),
 
 		SpeedDownload: s.SpeedDownload,
 		SpeedUpload:   s.SpeedUpload,
@@ -156,6 +186,12 @@ func (h *rpcHandler) GetSessionStats(args *rpctypes.GetSessionStatsRequest, repl
 		BytesRead:       s.BytesRead,
 		BytesWritten:    s.BytesWritten,
 	}
+	for i, src := range s.BlocklistSources {
+		reply.Stats.BlocklistSources[i] = rpctypes.BlocklistSource{URL: src.URL, Rules: src.Rules}
+		if src.Error != nil {
+			reply.Stats.BlocklistSources[i].Error = src.Error.Error()
+		}
+	}
 	return nil
 }
 
@@ -250,6 +286,7 @@ func (h *rpcHandler) GetTorrentStats(args *rpctypes.GetTorrentStatsRequest, repl
 		},
 		Name:        s.Name,
 		Private:     s.Private,
+		Signer:      s.Signer,
 		FileCount:   s.FileCount,
 		PieceLength: s.PieceLength,
 		SeededFor:   uint(s.SeededFor / time.Second),
@@ -322,6 +359,8 @@ func (h *rpcHandler) GetTorrentPeers(args *rpctypes.GetTorrentPeersRequest, repl
 			source = "INCOMING"
 		case SourceManual:
 			source = "MANUAL"
+		case SourceHolepunch:
+			source = "HOLEPUNCH"
 		default:
 			panic("unhandled peer source")
 		}
@@ -366,6 +405,89 @@ func (h *rpcHandler) GetTorrentWebseeds(args *rpctypes.GetTorrentWebseedsRequest
 	return nil
 }
 
+func (h *rpcHandler) GetTorrentBans(args *rpctypes.GetTorrentBansRequest, reply *rpctypes.GetTorrentBansResponse) error {
+	t := h.session.GetTorrent(args.ID)
+	if t == nil {
+		return errTorrentNotFound
+	}
+	reply.Bans = newBans(t.Bans())
+	return nil
+}
+
+func (h *rpcHandler) DHTGet(args *rpctypes.DHTGetRequest, reply *rpctypes.DHTGetResponse) error {
+	if args.Key != "" {
+		key, err := hex.DecodeString(args.Key)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return errors.New("invalid public key")
+		}
+		reply.Value, reply.Seq, err = h.session.DHTGetMutable(key, []byte(args.Salt))
+		return err
+	}
+	b, err := hex.DecodeString(args.Target)
+	if err != nil || len(b) != 20 {
+		return errors.New("invalid target")
+	}
+	var target [20]byte
+	copy(target[:], b)
+	reply.Value, err = h.session.DHTGetImmutable(target)
+	return err
+}
+
+func (h *rpcHandler) DHTPut(args *rpctypes.DHTPutRequest, reply *rpctypes.DHTPutResponse) error {
+	if args.PrivateKey == "" {
+		target, err := h.session.DHTPutImmutable(args.Value)
+		reply.Target = hex.EncodeToString(target[:])
+		return err
+	}
+	b, err := hex.DecodeString(args.PrivateKey)
+	if err != nil {
+		return errors.New("invalid private key")
+	}
+	var key ed25519.PrivateKey
+	switch len(b) {
+	case ed25519.SeedSize:
+		key = ed25519.NewKeyFromSeed(b)
+	case ed25519.PrivateKeySize:
+		key = b
+	default:
+		return errors.New("invalid private key")
+	}
+	target := dht.MutableTarget(key.Public().(ed25519.PublicKey), []byte(args.Salt))
+	reply.Target = hex.EncodeToString(target[:])
+	return h.session.DHTPutMutable(key, []byte(args.Salt), args.Value, args.Seq)
+}
+
+func (h *rpcHandler) DHTSampleInfoHashes(args *rpctypes.DHTSampleInfoHashesRequest, reply *rpctypes.DHTSampleInfoHashesResponse) error {
+	samples, num, interval, err := h.session.DHTSampleInfoHashes(args.Addr)
+	if err != nil {
+		return err
+	}
+	reply.InfoHashes = make([]string, len(samples))
+	for i, ih := range samples {
+		reply.InfoHashes[i] = hex.EncodeToString(ih[:])
+	}
+	reply.Num = num
+	reply.Interval = int(interval / time.Second)
+	return nil
+}
+
+func (h *rpcHandler) GetSessionBans(args *rpctypes.GetSessionBansRequest, reply *rpctypes.GetSessionBansResponse) error {
+	reply.Bans = newBans(h.session.Bans())
+	return nil
+}
+
+func newBans(bans []Ban) []rpctypes.Ban {
+	ret := make([]rpctypes.Ban, len(bans))
+	for i, b := range bans {
+		ret[i] = rpctypes.Ban{
+			IP:        b.IP,
+			Session:   b.Session,
+			ExpiresAt: rpctypes.Time{Time: b.ExpiresAt},
+		}
+	}
+	return ret
+}
+
 func (h *rpcHandler) StartTorrent(args *rpctypes.StartTorrentRequest, reply *rpctypes.StartTorrentResponse) error {
 	t := h.session.GetTorrent(args.ID)
 	if t == nil {
@@ -396,7 +518,23 @@ func (h *rpcHandler) VerifyTorrent(args *rpctypes.VerifyTorrentRequest, reply *r
 	if t == nil {
 		return errTorrentNotFound
 	}
-	return t.Verify()
+	var opts *VerifyOptions
+	if len(args.Files) > 0 || len(args.Pieces) > 0 {
+		opts = &VerifyOptions{Files: args.Files}
+		for _, r := range args.Pieces {
+			opts.Pieces = append(opts.Pieces, PieceRange{Begin: r.Begin, End: r.End})
+		}
+	}
+	return t.Verify(opts)
+}
+
+func (h *rpcHandler) RecheckTorrent(args *rpctypes.RecheckTorrentRequest, reply *rpctypes.RecheckTorrentResponse) error {
+	t := h.session.GetTorrent(args.ID)
+	if t == nil {
+		return errTorrentNotFound
+	}
+	t.Recheck()
+	return nil
 }
 
 func (h *rpcHandler) StartAllTorrents(args *rpctypes.StartAllTorrentsRequest, reply *rpctypes.StartAllTorrentsResponse) error {
@@ -415,6 +553,57 @@ func (h *rpcHandler) AddPeer(args *rpctypes.AddPeerRequest, reply *rpctypes.AddP
 	return t.AddPeer(args.Addr)
 }
 
+func (h *rpcHandler) DisconnectPeer(args *rpctypes.DisconnectPeerRequest, reply *rpctypes.DisconnectPeerResponse) error {
+	t := h.session.GetTorrent(args.ID)
+	if t == nil {
+		return errTorrentNotFound
+	}
+	return t.DisconnectPeer(args.Addr)
+}
+
+func (h *rpcHandler) SetPieceDeadline(args *rpctypes.SetPieceDeadlineRequest, reply *rpctypes.SetPieceDeadlineResponse) error {
+	t := h.session.GetTorrent(args.ID)
+	if t == nil {
+		return errTorrentNotFound
+	}
+	var deadline time.Time
+	if args.Deadline != 0 {
+		deadline = time.Now().Add(time.Duration(args.Deadline) * time.Millisecond)
+	}
+	return t.SetPieceDeadline(args.Index, deadline)
+}
+
+func (h *rpcHandler) SetPiecePriority(args *rpctypes.SetPiecePriorityRequest, reply *rpctypes.SetPiecePriorityResponse) error {
+	t := h.session.GetTorrent(args.ID)
+	if t == nil {
+		return errTorrentNotFound
+	}
+	return t.SetPiecePriority(args.Index, args.Priority)
+}
+
+func (h *rpcHandler) BanPeer(args *rpctypes.BanPeerRequest, reply *rpctypes.BanPeerResponse) error {
+	ttl := time.Duration(args.TTL) * time.Second
+	if args.ID == "" {
+		return h.session.Ban(args.Addr, ttl)
+	}
+	t := h.session.GetTorrent(args.ID)
+	if t == nil {
+		return errTorrentNotFound
+	}
+	return t.Ban(args.Addr, ttl)
+}
+
+func (h *rpcHandler) UnbanPeer(args *rpctypes.UnbanPeerRequest, reply *rpctypes.UnbanPeerResponse) error {
+	if args.ID == "" {
+		return h.session.Unban(args.Addr)
+	}
+	t := h.session.GetTorrent(args.ID)
+	if t == nil {
+		return errTorrentNotFound
+	}
+	return t.Unban(args.Addr)
+}
+
 func (h *rpcHandler) AddTracker(args *rpctypes.AddTrackerRequest, reply *rpctypes.AddTrackerResponse) error {
 	t := h.session.GetTorrent(args.ID)
 	if t == nil {
@@ -508,6 +697,8 @@ func (h *rpcHandler) handleMoveTorrent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	s.Port = port
+	// Data is moved into the default data directory of the torrent on this session.
+	s.Dest = ""
 	spec := &s
 	// case "data":
 	p, err = mr.NextPart()
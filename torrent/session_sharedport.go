@@ -0,0 +1,146 @@
+package torrent
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/cenkalti/rain/internal/acceptor"
+	"github.com/cenkalti/rain/internal/btconn"
+	"github.com/cenkalti/rain/internal/handshaker/incominghandshaker"
+)
+
+func validateSharedPort(cfg *Config) error {
+	if cfg.SharedPort != 0 && cfg.SharedPort >= cfg.PortBegin && cfg.SharedPort < cfg.PortEnd {
+		return fmt.Errorf("shared port %d must not be in the port range", cfg.SharedPort)
+	}
+	return nil
+}
+
+// usesSharedPort returns true if a torrent listens on Config.SharedPort instead of its own port.
+func (s *Session) usesSharedPort(ownPort bool) bool {
+	return s.config.SharedPort != 0 && !ownPort
+}
+
+// startSharedPort starts accepting connections for all torrents that do not have their own port.
+func (s *Session) startSharedPort() error {
+	if s.config.SharedPort == 0 || s.config.ProxyOnly {
+		return nil
+	}
+	ip := net.ParseIP(s.config.Host)
+	listener, err := listenPeers(&s.config, ip, int(s.config.SharedPort))
+	if err != nil {
+		return err
+	}
+	s.log.Info("Listening peers on tcp://" + listener.Addr().String())
+	connC := make(chan net.Conn)
+	s.sharedAcceptor = acceptor.New(listener, connC, s.log)
+	go s.sharedAcceptor.Run()
+	go s.acceptSharedConnections(connC)
+	return nil
+}
+
+func (s *Session) acceptSharedConnections(connC chan net.Conn) {
+	// Limit the number of concurrent handshakes because they are not counted by torrents until they are complete.
+	handshakes := make(chan struct{}, s.config.MaxPeerAccept)
+	for {
+		select {
+		case conn := <-connC:
+			ip := conn.RemoteAddr().(*net.TCPAddr).IP
+			if s.config.BlocklistEnabledForIncomingConnections && s.blocklist.Blocked(ip) {
+				s.log.Debugln("peer is blocked:", conn.RemoteAddr().String())
+				conn.Close()
+				continue
+			}
+			select {
+			case handshakes <- struct{}{}:
+			default:
+				s.log.Debugln("too many incoming handshakes, rejecting peer", conn.RemoteAddr().String())
+				conn.Close()
+				continue
+			}
+			go func() {
+				s.handshakeShared(conn)
+				<-handshakes
+			}()
+		case <-s.closeC:
+			return
+		}
+	}
+}
+
+// handshakeShared does the handshake on a connection accepted from the shared port and sends it to the torrent with the info hash.
+func (s *Session) handshakeShared(conn net.Conn) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-s.closeC:
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	var t *torrent
+	getPeerID := func(infoHash [20]byte) ([20]byte, bool) {
+		t = s.sharedTorrent(func(t *torrent) bool { return t.checkInfoHash(infoHash) })
+		if t == nil {
+			return [20]byte{}, false
+		}
+		return t.peerID, true
+	}
+	newConn, cipher, peerExtensions, peerID, _, err := btconn.AcceptMulti(
+		conn, s.config.PeerHandshakeTimeout, s.getSharedSKey, s.config.ForceIncomingEncryption, getPeerID, s.extensions)
+	if err != nil {
+		s.log.Debugf("cannot complete incoming handshake from %s: %s", conn.RemoteAddr(), err)
+		conn.Close()
+		return
+	}
+	h := &incominghandshaker.IncomingHandshaker{
+		Conn:       newConn,
+		PeerID:     peerID,
+		Extensions: peerExtensions,
+		Cipher:     cipher,
+	}
+	select {
+	case t.sharedHandshakeC <- h:
+	case <-t.closeC:
+		newConn.Close()
+	}
+}
+
+// getSharedSKey returns the info hash of the torrent that matches the SKEY hash sent in MSE handshake.
+func (s *Session) getSharedSKey(sKeyHash [20]byte) []byte {
+	t := s.sharedTorrent(func(t *torrent) bool { return t.getSKey(sKeyHash) != nil })
+	if t == nil {
+		return nil
+	}
+	return t.getSKey(sKeyHash)
+}
+
+// sharedTorrent returns the first torrent on the shared port that matches f.
+func (s *Session) sharedTorrent(f func(t *torrent) bool) *torrent {
+	s.mTorrents.RLock()
+	defer s.mTorrents.RUnlock()
+	for _, t := range s.torrents {
+		if t.torrent.sharedPort && f(t.torrent) {
+			return t.torrent
+		}
+	}
+	return nil
+}
+
+// loadPort returns the port for a torrent loaded from the resume db.
+// The saved port is reserved again. A new port is selected if the saved one is not in the port range,
+// which happens when the torrent was using the shared port before.
+func (s *Session) loadPort(port int, ownPort bool) (int, error) {
+	if s.usesSharedPort(ownPort) {
+		return int(s.config.SharedPort), nil
+	}
+	if port < int(s.config.PortBegin) || port >= int(s.config.PortEnd) {
+		return s.getPort()
+	}
+	s.mPorts.Lock()
+	delete(s.availablePorts, port)
+	s.mPorts.Unlock()
+	return port, nil
+}
@@ -0,0 +1,53 @@
+package torrent
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSharedPort = 45000
+
+func TestSharedPort(t *testing.T) {
+	seeder, closeSeeder := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.Host = "127.0.0.2"
+		cfg.SharedPort = testSharedPort
+		cfg.FileCheckInterval = 0
+	})
+	defer closeSeeder()
+
+	// Another torrent on the shared port, so that connections must be routed by info hash.
+	other, err := seeder.AddURI("magnet:?xt=urn:btih:0000000000000000000000000000000000000001", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, testSharedPort, other.Port())
+	own, err := seeder.AddURI("magnet:?xt=urn:btih:0000000000000000000000000000000000000002", &AddTorrentOptions{OwnPort: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotEqual(t, testSharedPort, own.Port())
+
+	tor := addSeedingTorrent(t, seeder)
+	assert.Equal(t, testSharedPort, tor.Port())
+	addr := "127.0.0.2:" + strconv.Itoa(testSharedPort)
+
+	for _, forceEncryption := range []bool{false, true} {
+		s, closeSession := newTestSessionWithConfig(t, func(cfg *Config) {
+			cfg.ForceOutgoingEncryption = forceEncryption
+		})
+		leecher, err := s.AddURI(torrentMagnetLink+"&x.pe="+addr, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		waitStatus(t, leecher, Seeding)
+		closeSession()
+	}
+}
+
+func TestSharedPortInRange(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.SharedPort = cfg.PortBegin
+	assert.Error(t, validateSharedPort(&cfg))
+}
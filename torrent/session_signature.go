@@ -0,0 +1,45 @@
+package torrent
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/cenkalti/rain/internal/metainfo"
+)
+
+var errUntrustedTorrent = errors.New("torrent is not signed by a trusted key")
+
+func parseTrustedSigningKeys(keys []string) ([]ed25519.PublicKey, error) {
+	ret := make([]ed25519.PublicKey, 0, len(keys))
+	for _, s := range keys {
+		b, err := hex.DecodeString(s)
+		if err != nil || len(b) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid trusted signing key: %q", s)
+		}
+		ret = append(ret, ed25519.PublicKey(b))
+	}
+	return ret, nil
+}
+
+// trustedSigner returns the name of the signer if the info is signed by one of the trusted keys in config (BEP 35).
+func (s *Session) trustedSigner(info []byte, sigs []metainfo.Signature) string {
+	return metainfo.TrustedSigner(info, sigs, s.trustedSigningKeys)
+}
+
+func unmarshalSignatures(b []byte) []metainfo.Signature {
+	if len(b) == 0 {
+		return nil
+	}
+	sigs, _ := metainfo.UnmarshalSignatures(b)
+	return sigs
+}
+
+func marshalSignatures(sigs []metainfo.Signature) []byte {
+	if len(sigs) == 0 {
+		return nil
+	}
+	b, _ := metainfo.MarshalSignatures(sigs)
+	return b
+}
@@ -0,0 +1,59 @@
+package torrent
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"testing"
+
+	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireTrustedSignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, closeSession := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.TrustedSigningKeys = []string{hex.EncodeToString(pub)}
+		cfg.RequireTrustedSignature = true
+	})
+	defer closeSession()
+
+	unsigned, err := os.ReadFile(torrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.AddTorrent(bytes.NewReader(unsigned), &AddTorrentOptions{Stopped: true})
+	assert.ErrorIs(t, err, errUntrustedTorrent)
+	_, err = s.AddURI("magnet:?xt=urn:btih:"+hex.EncodeToString(make([]byte, 20)), &AddTorrentOptions{Stopped: true})
+	assert.ErrorIs(t, err, errUntrustedTorrent)
+
+	mi, err := metainfo.New(bytes.NewReader(unsigned))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := metainfo.NewBytes(mi.Info.Bytes, nil, nil, nil, "", &metainfo.KeySigner{Name: "publisher", Key: priv})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tor, err := s.AddTorrent(bytes.NewReader(signed), &AddTorrentOptions{Stopped: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "publisher", tor.Stats().Signer)
+
+	// Signatures are kept when the torrent file is exported.
+	b, err := tor.Torrent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mi2, err := metainfo.New(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "publisher", metainfo.TrustedSigner(mi2.Info.Bytes, mi2.Signatures, []ed25519.PublicKey{pub}))
+}
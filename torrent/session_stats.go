@@ -1,6 +1,7 @@
 package torrent
 
 import (
+	"net"
 	"strconv"
 	"time"
 
@@ -18,11 +19,43 @@ type SessionStats struct {
 	Peers int
 	// Number of available ports for new torrents.
 	PortsAvailable int
+	// External IP of the client decided by the votes of trackers and peers. Empty if not known yet.
+	ExternalIP string
+
+	// Number of peer connections and handshakes in all torrents, limited by Config.MaxConnections.
+	Connections int
+	// Number of torrents that could not make a connection because of the limit.
+	ConnectionsWaiting int
+	// Number of times a connection could not be made because of the limit.
+	ConnectionsContention int64
+	// Number of outgoing connections that are dialing or handshaking, limited by Config.MaxHalfOpenConnections.
+	HalfOpen int
+	// Number of torrents that could not dial a peer because of the limit.
+	HalfOpenWaiting int
+	// Number of times a peer could not be dialed because of the limit.
+	HalfOpenContention int64
+	// Number of upload slots reserved by torrents, limited by Config.MaxUploadSlots.
+	UploadSlots int
+	// Number of torrents that got less upload slots than they want because of the limit.
+	UploadSlotsWaiting int
+	// Number of times a torrent got less upload slots than it wants because of the limit.
+	UploadSlotsContention int64
+
+	// Number of nodes in DHT routing table.
+	DHTNodes int
+	// Number of DHT routing table buckets that have at least one node.
+	DHTBuckets int
+	// Number of queries sent to other DHT nodes.
+	DHTQueriesSent int64
+	// Number of queries received from other DHT nodes.
+	DHTQueriesReceived int64
 
 	// Number of rules in blocklist.
 	BlockListRules int
 	// Time elapsed after the last successful update of blocklist.
 	BlockListRecency time.Duration
+	// Number of rules and load errors of each blocklist URL.
+	BlocklistSources []BlocklistSource
 
 	// Number of objects in piece read cache.
 	// Each object is a block whose size is defined in Config.ReadCacheBlockSize.
@@ -55,6 +88,10 @@ type SessionStats struct {
 	WritesActive int
 	// Number of pending write requests to disk.
 	WritesPending int
+	// Mean duration of writing a piece to disk, including the flush if writes are synced.
+	WriteLatency time.Duration
+	// Mean duration of flushing torrent files to disk.
+	SyncLatency time.Duration
 
 	// Download speed from peers in bytes/s.
 	SpeedDownload int
@@ -77,14 +114,34 @@ type SessionStats struct {
 
 // Stats returns current statistics about the Session.
 func (s *Session) Stats() SessionStats {
+	s.mBlocklist.RLock()
+	blocklistSources := append([]BlocklistSource(nil), s.blocklistSources...)
+	s.mBlocklist.RUnlock()
 	return SessionStats{
 		Uptime:         time.Duration(s.metrics.Uptime.Value()) * time.Second,
 		Torrents:       int(s.metrics.Torrents.Value()),
 		Peers:          int(s.metrics.Peers.Count()),
 		PortsAvailable: int(s.metrics.PortsAvailable.Value()),
+		ExternalIP:     externalIPString(s.externalIP.IP()),
+
+		Connections:           int(s.metrics.Connections.Value()),
+		ConnectionsWaiting:    int(s.metrics.ConnectionsWaiting.Value()),
+		ConnectionsContention: s.metrics.ConnectionsContention.Value(),
+		HalfOpen:              int(s.metrics.HalfOpen.Value()),
+		HalfOpenWaiting:       int(s.metrics.HalfOpenWaiting.Value()),
+		HalfOpenContention:    s.metrics.HalfOpenContention.Value(),
+		UploadSlots:           int(s.metrics.UploadSlots.Value()),
+		UploadSlotsWaiting:    int(s.metrics.UploadSlotsWaiting.Value()),
+		UploadSlotsContention: s.metrics.UploadSlotsContention.Value(),
+
+		DHTNodes:           int(s.metrics.DHTNodes.Value()),
+		DHTBuckets:         int(s.metrics.DHTBuckets.Value()),
+		DHTQueriesSent:     s.metrics.DHTQueriesSent.Value(),
+		DHTQueriesReceived: s.metrics.DHTQueriesReceived.Value(),
 
 		BlockListRules:   int(s.metrics.BlockListRules.Value()),
 		BlockListRecency: time.Duration(s.metrics.BlockListRecency.Value()) * time.Second,
+		BlocklistSources: blocklistSources,
 
 		ReadCacheObjects:     int(s.metrics.ReadCacheObjects.Value()),
 		ReadCacheSize:        s.metrics.ReadCacheSize.Value(),
@@ -101,6 +158,8 @@ func (s *Session) Stats() SessionStats {
 		WritesPerSecond: int(s.metrics.WritesPerSecond.Rate1()),
 		WritesActive:    int(s.metrics.WritesActive.Value()),
 		WritesPending:   int(s.metrics.WritesPending.Value()),
+		WriteLatency:    time.Duration(s.metrics.WriteLatency.Mean()),
+		SyncLatency:     time.Duration(s.metrics.SyncLatency.Mean()),
 
 		SpeedDownload: int(s.metrics.SpeedDownload.Rate1()),
 		SpeedUpload:   int(s.metrics.SpeedUpload.Rate1()),
@@ -121,6 +180,9 @@ func (s *Session) updateStatsLoop() {
 		select {
 		case <-ticker.C:
 			s.updateStats()
+			if s.config.DHTEnabled {
+				s.saveDHTState()
+			}
 		case <-s.closeC:
 			return
 		}
@@ -140,8 +202,8 @@ func (s *Session) updateStats() {
 			_ = b.Put(boltdbresumer.Keys.SeededFor, []byte(time.Duration(t.torrent.seededFor.Count()).String()))
 
 			t.torrent.mBitfield.RLock()
-			if t.torrent.bitfield != nil {
-				_ = b.Put(boltdbresumer.Keys.Bitfield, t.torrent.bitfield.Bytes())
+			if bf := t.torrent.durableBitfieldBytes(); bf != nil {
+				_ = b.Put(boltdbresumer.Keys.Bitfield, bf)
 			}
 		}
 		return nil
@@ -153,3 +215,10 @@ func (s *Session) updateStats() {
 		s.log.Errorln("cannot update stats:", err.Error())
 	}
 }
+
+func externalIPString(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
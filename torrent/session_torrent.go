@@ -42,6 +42,11 @@ func (t *Torrent) Name() string {
 	return t.torrent.Name()
 }
 
+// Label of the torrent given in AddTorrentOptions.
+func (t *Torrent) Label() string {
+	return t.torrent.label
+}
+
 // RootDirectory of the torrent.
 // The directory that contains the files in the torrent.
 func (t *Torrent) RootDirectory() string {
@@ -105,6 +110,43 @@ func (t *Torrent) Webseeds() []Webseed {
 	return t.torrent.Webseeds()
 }
 
+// Bans returns the IP addresses and ranges that are not allowed to connect to the torrent,
+// including the ones that are banned in the session.
+func (t *Torrent) Bans() []Ban {
+	return t.torrent.Bans()
+}
+
+// Ban the IP address or range in CIDR notation in the torrent for ttl.
+// Zero ttl means the ban does not expire. Connected peers in the range are disconnected.
+func (t *Torrent) Ban(addr string, ttl time.Duration) error {
+	return t.torrent.Ban(addr, ttl)
+}
+
+// Unban removes the ban of the IP address or range in CIDR notation from the torrent.
+func (t *Torrent) Unban(addr string) error {
+	return t.torrent.Unban(addr)
+}
+
+// DisconnectPeer closes the connection to the peer with the address in "ip:port" format.
+// The peer may connect again unless its IP is banned.
+func (t *Torrent) DisconnectPeer(addr string) error {
+	return t.torrent.DisconnectPeer(addr)
+}
+
+// SetPieceDeadline sets the time that the piece at index is needed.
+// Pieces with deadlines are downloaded before other pieces, earliest deadline first.
+// Zero deadline clears the deadline of the piece.
+func (t *Torrent) SetPieceDeadline(index uint32, deadline time.Time) error {
+	return t.torrent.SetPieceDeadline(index, deadline)
+}
+
+// SetPiecePriority sets the download priority of the piece at index.
+// Priority must be PiecePriorityNormal or PiecePriorityHigh.
+// Pieces with high priority are downloaded before normal priority pieces.
+func (t *Torrent) SetPiecePriority(index uint32, priority int) error {
+	return t.torrent.SetPiecePriority(index, priority)
+}
+
 // Port returns the TCP port number that the torrent is listening peers.
 func (t *Torrent) Port() int {
 	return t.torrent.port
@@ -145,29 +187,7 @@ func (t *Torrent) AddPeer(addr string) error {
 
 // AddTracker adds a new tracker to the torrent.
 func (t *Torrent) AddTracker(uri string) error {
-	var private bool
-	if t.torrent.info != nil {
-		private = t.torrent.info.Private
-	}
-	tr, err := t.torrent.session.trackerManager.Get(uri, t.torrent.session.config.TrackerHTTPTimeout, t.torrent.session.getTrackerUserAgent(private), int64(t.torrent.session.config.TrackerHTTPMaxResponseSize))
-	if err != nil {
-		return err
-	}
-	err = t.torrent.session.db.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket(torrentsBucket).Bucket([]byte(t.torrent.id))
-		value := b.Get(boltdbresumer.Keys.Trackers)
-		var trackers [][]string
-		err = json.Unmarshal(value, &trackers)
-		if err != nil {
-			return err
-		}
-		trackers = append(trackers, []string{uri})
-		value, err = json.Marshal(trackers)
-		if err != nil {
-			return err
-		}
-		return b.Put(boltdbresumer.Keys.Trackers, value)
-	})
+	tr, err := t.torrent.addTracker(uri)
 	if err != nil {
 		return err
 	}
@@ -181,8 +201,11 @@ func (t *Torrent) Start() error {
 	if err != nil {
 		return err
 	}
-	t.torrent.Start()
-	return nil
+	err = t.torrent.Start()
+	if err == errNotEnoughDiskSpace {
+		_ = t.torrent.session.resumer.WriteStarted(t.torrent.id, false)
+	}
+	return err
 }
 
 // Stop the torrent. Does not block. After Stop is called, the torrent switches into Stopping state.
@@ -202,19 +225,47 @@ func (t *Torrent) Announce() {
 	t.torrent.Announce()
 }
 
-// Verify pieces of torrent by reading all of the torrents files from disk.
+// VerifyOptions contains options for verifying only some of the pieces of a Torrent.
+// Pieces selected by Files and Pieces are verified together.
+type VerifyOptions struct {
+	// Verify the pieces containing any part of these files.
+	// Paths must be in the same form that is returned from File.Path.
+	Files []string
+	// Verify the pieces in these ranges.
+	Pieces []PieceRange
+}
+
+// PieceRange is a range of piece indexes. End is not included in the range.
+type PieceRange struct {
+	Begin, End uint32
+}
+
+func (o *VerifyOptions) all() bool {
+	return o == nil || len(o.Files) == 0 && len(o.Pieces) == 0
+}
+
+// Verify pieces of torrent by reading the torrents files from disk.
+// If opts is nil, all pieces are verified, otherwise only the selected pieces are verified.
 // After Verify called, the torrent is stopped, then verification starts and the torrent switches into Verifying state.
 // The torrent stays stopped after verification finishes.
-func (t *Torrent) Verify() error {
-	err := t.torrent.session.db.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket(torrentsBucket).Bucket([]byte(t.torrent.id))
-		return b.Delete([]byte("bitfield"))
-	})
-	if err != nil {
-		return err
+func (t *Torrent) Verify(opts *VerifyOptions) error {
+	if opts.all() {
+		err := t.torrent.session.db.Update(func(tx *bbolt.Tx) error {
+			b := tx.Bucket(torrentsBucket).Bucket([]byte(t.torrent.id))
+			return b.Delete([]byte("bitfield"))
+		})
+		if err != nil {
+			return err
+		}
 	}
-	t.torrent.Verify()
-	return nil
+	return t.torrent.Verify(opts)
+}
+
+// Recheck verifies the pieces that are missing on disk and starts the torrent.
+// It is used for recovering a torrent that is stopped because its files are deleted or changed.
+// Pieces that are still missing after verification are downloaded again.
+func (t *Torrent) Recheck() {
+	t.torrent.Recheck()
 }
 
 // Move torrent to another Session.
@@ -294,7 +345,7 @@ func (t *Torrent) generateTar(pw *io.PipeWriter) {
 	defer func() { _ = pw.CloseWithError(err) }()
 
 	tw := tar.NewWriter(pw)
-	root := t.torrent.session.getDataDir(t.torrent.id)
+	root := t.torrent.RootDirectory()
 	walkFunc := func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -6,6 +6,7 @@ import (
 	"net"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cenkalti/rain/internal/acceptor"
@@ -15,12 +16,12 @@ import (
 	"github.com/cenkalti/rain/internal/bitfield"
 	"github.com/cenkalti/rain/internal/blocklist"
 	"github.com/cenkalti/rain/internal/bufferpool"
-	"github.com/cenkalti/rain/internal/externalip"
 	"github.com/cenkalti/rain/internal/handshaker/incominghandshaker"
 	"github.com/cenkalti/rain/internal/handshaker/outgoinghandshaker"
 	"github.com/cenkalti/rain/internal/infodownloader"
 	"github.com/cenkalti/rain/internal/logger"
 	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/cenkalti/rain/internal/mover"
 	"github.com/cenkalti/rain/internal/mse"
 	"github.com/cenkalti/rain/internal/peer"
 	"github.com/cenkalti/rain/internal/pexlist"
@@ -29,8 +30,11 @@ import (
 	"github.com/cenkalti/rain/internal/piecepicker"
 	"github.com/cenkalti/rain/internal/piecewriter"
 	"github.com/cenkalti/rain/internal/resumer"
+	"github.com/cenkalti/rain/internal/resumer/boltdbresumer"
+	"github.com/cenkalti/rain/internal/smartban"
 	"github.com/cenkalti/rain/internal/storage"
 	"github.com/cenkalti/rain/internal/suspendchan"
+	"github.com/cenkalti/rain/internal/syncer"
 	"github.com/cenkalti/rain/internal/tracker"
 	"github.com/cenkalti/rain/internal/unchoker"
 	"github.com/cenkalti/rain/internal/urldownloader"
@@ -61,6 +65,15 @@ type torrent struct {
 	// Storage implementation to save the files in torrent.
 	storage storage.Storage
 
+	// Data directory of the torrent if it is different from the default one in Config.
+	dataDir string
+
+	// Protects storage and dataDir changing in torrent loop after files are moved and reading from other goroutines.
+	mStorage sync.RWMutex
+
+	// Label of the torrent. Used for selecting download directories.
+	label string
+
 	// TCP Port to listen for peer connections.
 	port int
 
@@ -71,6 +84,10 @@ type torrent struct {
 	// Bits are set only after data is written to file.
 	bitfield *bitfield.Bitfield
 
+	// Copy of bitfield taken before the last flush of files to disk.
+	// Only this bitfield is saved to resume db if writes are not synced immediately.
+	durableBitfield *bitfield.Bitfield
+
 	// Protects bitfield writing from torrent loop and reading from announcer loop.
 	mBitfield sync.RWMutex
 
@@ -79,6 +96,9 @@ type torrent struct {
 
 	files  []allocator.File
 	pieces []piece.Piece
+	// Protects pieces from being replaced while peers read the piece data for uploading.
+	// Replaced files are closed after the reads in progress are done.
+	mFiles sync.RWMutex
 
 	piecePicker *piecepicker.PiecePicker
 
@@ -101,8 +121,12 @@ type torrent struct {
 	// Keep recently seen peers to fill underpopulated PEX lists.
 	recentlySeen pexlist.RecentlySeen
 
-	// Unchoker implements an algorithm to select peers to unchoke based on their download speed.
-	unchoker *unchoker.Unchoker
+	// Unchoker implements an algorithm to select peers to unchoke.
+	unchoker unchoker.Algorithm
+	// Name of the choking algorithm given in AddTorrentOptions. Empty means Config.ChokingAlgorithm.
+	chokingAlgorithm string
+	// Number of upload slots reserved from the session.
+	uploadSlots int
 
 	// Active piece downloads are kept in this map.
 	pieceDownloaders        map[*peer.Peer]*piecedownloader.PieceDownloader
@@ -127,6 +151,37 @@ type torrent struct {
 	// True after all pieces are download, verified and written to disk.
 	completed bool
 
+	// Moves files to the complete directory after all pieces are downloaded.
+	mover        *mover.Mover
+	moverResultC chan *mover.Mover
+
+	// Peers notify the torrent loop when a piece cannot be read from disk.
+	readErrorC chan *piece.Piece
+
+	// Reads stats of files periodically for detecting files deleted or changed by someone else.
+	fileCheck        *fileCheck
+	fileCheckResultC chan *fileCheck
+
+	// Flushes written data to disk periodically if writes are not synced immediately.
+	syncer        *syncer.Syncer
+	syncerResultC chan *syncer.Syncer
+	// True if the bitfield is going to be saved after the running syncer is done.
+	bitfieldWritePending bool
+	// Flushes files in background after the torrent is stopped. Files are closed after it is done.
+	finalSyncer      *syncer.Syncer
+	finalSyncerFiles []allocator.File
+
+	// True after files of a completed torrent are moved to their final location.
+	filesMoved bool
+
+	// True if the torrent is stopped because of insufficient disk space and it will be started when space is available.
+	waitingForDiskSpace bool
+	diskSpaceCommandC   chan struct{}
+
+	// True if the torrent is stopped by the kill-switch and it will be started when the outgoing address is available.
+	waitingForOutgoingAddress bool
+	outgoingAddressCommandC   chan struct{}
+
 	// If any unrecoverable error occurs, it will be sent to this channel and download will be stopped.
 	errC chan error
 
@@ -147,10 +202,14 @@ type torrent struct {
 	trackersCommandC     chan trackersRequest     // Trackers()
 	peersCommandC        chan peersRequest        // Peers()
 	webseedsCommandC     chan webseedsRequest     // Webseeds()
-	startCommandC        chan struct{}            // Start()
+	disconnectCommandC   chan disconnectRequest   // DisconnectPeer()
+	deadlineCommandC     chan deadlineRequest     // SetPieceDeadline()
+	priorityCommandC     chan priorityRequest     // SetPiecePriority()
+	startCommandC        chan chan error          // Start()
 	stopCommandC         chan struct{}            // Stop()
 	announceCommandC     chan struct{}            // Announce()
-	verifyCommandC       chan struct{}            // Verify()
+	verifyCommandC       chan verifyRequest       // Verify()
+	recheckCommandC      chan struct{}            // Recheck()
 	notifyErrorCommandC  chan notifyErrorCommand  // NotifyError()
 	notifyListenCommandC chan notifyListenCommand // NotifyListen()
 	addPeersCommandC     chan []*net.TCPAddr      // AddPeers()
@@ -165,6 +224,37 @@ type torrent struct {
 	// New raw connections created by OutgoingHandshaker are sent to here.
 	incomingConnC chan net.Conn
 
+	// True if the torrent accepts connections from the shared port of the session instead of its own port.
+	sharedPort bool
+	// True if the torrent is added with AddTorrentOptions.OwnPort.
+	ownPort bool
+
+	// True if the torrent is added with AddTorrentOptions.FirstLastPieces.
+	firstLastPieces bool
+	// Deadlines set with SetPieceDeadline. Kept here because the piece picker is created again when the torrent is restarted.
+	pieceDeadlines map[uint32]time.Time
+	// Priorities set with SetPiecePriority. Kept for the same reason as pieceDeadlines.
+	piecePriorities map[uint32]int
+	// Indexes of the files selected with "so" param of the magnet link (BEP 53). All files are downloaded if nil.
+	selectedFiles []int
+	// Pieces that contain data of the selected files. Calculated from selectedFiles after the info is known.
+	wanted *bitfield.Bitfield
+	// Length of the torrent given with "xl" param of the magnet link. Metadata with a different length is rejected.
+	exactLength int64
+	// Torrent file is downloaded from these URLs given with "xs" and "as" params of the magnet link while the metadata is downloaded from peers.
+	metadataSources []string
+	// True while the torrent file is being downloaded from metadataSources.
+	metadataSourceDownloading bool
+	// Torrent file downloaded from metadataSources is sent to this channel. Nil is sent if none of the sources work.
+	metadataSourceResultC chan *metainfo.MetaInfo
+
+	// Piece layers of v2 torrent that are being received from peers, keyed by pieces root.
+	pendingLayers map[string]*pendingLayer
+	// True while the torrent is accepting connections from the shared port.
+	acceptingShared bool
+	// Connections from the shared port are sent to this channel after the handshake is completed by the session.
+	sharedHandshakeC chan *incominghandshaker.IncomingHandshaker
+
 	// Keep a set of peer IDs to block duplicate connections.
 	peerIDs map[[20]byte]struct{}
 
@@ -174,9 +264,16 @@ type torrent struct {
 	// Special hash of info hash for encypted connection handshake.
 	sKeyHash [20]byte
 
+	// Info hashes of the other swarm if the torrent is a hybrid torrent. Nil otherwise.
+	// Set when the info is known and read by handshakers and the session without the torrent loop.
+	hybrid atomic.Pointer[hybridHashes]
+
 	// Announces the status of torrent to trackers to get peer addresses periodically.
 	announcers []*announcer.PeriodicalAnnouncer
 
+	// Announces the other info hash of a hybrid torrent to trackers.
+	hybridAnnouncers []*announcer.PeriodicalAnnouncer
+
 	// This announcer announces Stopped event to the trackers after
 	// all periodical trackers are closed.
 	stoppedEventAnnouncer *announcer.StopAnnouncer
@@ -205,6 +302,10 @@ type torrent struct {
 	allocatorResultC   chan *allocator.Allocator
 	bytesAllocated     int64
 
+	// Opens the files again from the new storage after they are moved. Torrent status is not changed while it runs.
+	reopener        *allocator.Allocator
+	reopenerResultC chan *allocator.Allocator
+
 	// A worker that does hash check of files on the disk.
 	verifier          *verifier.Verifier
 	verifierProgressC chan verifier.Progress
@@ -225,8 +326,24 @@ type torrent struct {
 	// Holds connected peer IPs so we don't dial/accept multiple connections to/from same IP.
 	connectedPeerIPs map[string]struct{}
 
-	// Peers that are sending corrupt data are banned.
-	bannedPeerIPs map[string]struct{}
+	// Peers that sent us addresses with PEX, keyed by address. They are asked to relay holepunch messages.
+	holepunchRelays map[string]*peer.Peer
+
+	// Tracker URLs received with tracker exchange extension that are being checked before adding to the torrent.
+	texPending map[string]struct{}
+	// Number of trackers added from tracker exchange extension.
+	texAdded int
+	// Results of the tracker checks are sent to this channel.
+	texResultC chan *texResult
+
+	// IP ranges that are banned in this torrent, manually or after sending corrupt data.
+	bans *blocklist.Blocklist
+
+	// A signal sent to run() loop when a new ban is added to close the banned peers.
+	bansChangedC chan struct{}
+
+	// Keeps blocks of pieces that failed hash check to find the peers sending corrupt data.
+	smartBan *smartban.Cache
 
 	// A signal sent to run() loop when announcers are stopped.
 	announcersStoppedC chan struct{}
@@ -234,23 +351,44 @@ type torrent struct {
 	// Piece buffers that are being downloaded are pooled to reduce load on GC.
 	piecePool *bufferpool.Pool
 
-	// Used to calculate canonical peer priority (BEP 40).
-	// Initialized with value found in network interfaces.
-	// Then, updated from "yourip" field in BEP 10 extension handshake message.
-	externalIP net.IP
-
 	ramNotifyC chan *peer.Peer
 
+	// Session notifies the torrent from this channel when connection slots are released after the torrent has hit the session limits.
+	budgetC chan struct{}
+	// Number of connections last reported to the session budgets.
+	connectionsReported int
+	halfOpenReported    int
+
+	// Signatures of the info dictionary in torrent file (BEP 35).
+	signatures []metainfo.Signature
+	// Name of the signer if the torrent is signed by a trusted key.
+	signer string
+
 	webseedClient          *http.Client
 	webseedSources         []*webseedsource.WebseedSource
 	rawWebseedSources      []string
+	rawHTTPSeeds           []string
 	webseedPieceResultC    *suspendchan.Chan[*urldownloader.PieceResult]
 	webseedRetryC          chan *webseedsource.WebseedSource
 	webseedActiveDownloads int
 
+	// Bitfield of a completed torrent with the same info hash.
+	// It is used instead of verification if the files are created from the copy of that torrent.
+	duplicateBitfield *bitfield.Bitfield
+
+	// Pieces written by other torrents sharing the same data directory are sent to this channel.
+	duplicatePieceDoneC chan uint32
+
 	// Set to true when manual verification is requested
 	doVerify bool
 
+	// Pieces to check in next verification. All pieces are checked if nil.
+	verifyPieces *bitfield.Bitfield
+
+	// Sizes and modification times of files when the bitfield is saved last time.
+	// Used for detecting changed files when the torrent is started.
+	fileStats []boltdbresumer.FileStat
+
 	// If true, the torrent is stopped automatically when all torrent pieces are downloaded.
 	stopAfterDownload bool
 
@@ -282,6 +420,7 @@ func newTorrent2(
 	stopAfterDownload bool,
 	stopAfterMetadata bool,
 	completeCmdRun bool,
+	chokingAlgorithm string,
 ) (*torrent, error) {
 	if len(infoHash) != 20 {
 		return nil, errors.New("invalid infoHash (must be 20 bytes)")
@@ -318,21 +457,31 @@ func newTorrent2(
 		completeC:                 make(chan struct{}),
 		completeMetadataC:         make(chan struct{}),
 		closeC:                    make(chan struct{}),
-		startCommandC:             make(chan struct{}),
+		startCommandC:             make(chan chan error),
 		stopCommandC:              make(chan struct{}),
 		announceCommandC:          make(chan struct{}),
-		verifyCommandC:            make(chan struct{}),
+		verifyCommandC:            make(chan verifyRequest),
+		recheckCommandC:           make(chan struct{}),
+		readErrorC:                make(chan *piece.Piece, 1),
 		statsCommandC:             make(chan statsRequest),
 		trackersCommandC:          make(chan trackersRequest),
 		peersCommandC:             make(chan peersRequest),
 		webseedsCommandC:          make(chan webseedsRequest),
+		disconnectCommandC:        make(chan disconnectRequest),
+		deadlineCommandC:          make(chan deadlineRequest),
+		priorityCommandC:          make(chan priorityRequest),
+		bansChangedC:              make(chan struct{}, 1),
 		notifyErrorCommandC:       make(chan notifyErrorCommand),
 		notifyListenCommandC:      make(chan notifyListenCommand),
 		addPeersCommandC:          make(chan []*net.TCPAddr),
 		addTrackersCommandC:       make(chan []tracker.Tracker),
 		addrsFromTrackers:         make(chan []*net.TCPAddr),
 		peerIDs:                   make(map[[20]byte]struct{}),
+		pieceDeadlines:            make(map[uint32]time.Time),
+		piecePriorities:           make(map[uint32]int),
+		pendingLayers:             make(map[string]*pendingLayer),
 		incomingConnC:             make(chan net.Conn),
+		sharedHandshakeC:          make(chan *incominghandshaker.IncomingHandshaker),
 		sKeyHash:                  mse.HashSKey(ih[:]),
 		infoDownloaderResultC:     make(chan *infodownloader.InfoDownloader),
 		incomingHandshakers:       make(map[*incominghandshaker.IncomingHandshaker]struct{}),
@@ -341,13 +490,23 @@ func newTorrent2(
 		outgoingHandshakerResultC: make(chan *outgoinghandshaker.OutgoingHandshaker),
 		allocatorProgressC:        make(chan allocator.Progress),
 		allocatorResultC:          make(chan *allocator.Allocator),
+		reopenerResultC:           make(chan *allocator.Allocator),
 		verifierProgressC:         make(chan verifier.Progress),
 		verifierResultC:           make(chan *verifier.Verifier),
+		moverResultC:              make(chan *mover.Mover),
+		fileCheckResultC:          make(chan *fileCheck),
+		syncerResultC:             make(chan *syncer.Syncer),
+		diskSpaceCommandC:         make(chan struct{}),
+		outgoingAddressCommandC:   make(chan struct{}),
 		connectedPeerIPs:          make(map[string]struct{}),
-		bannedPeerIPs:             make(map[string]struct{}),
+		holepunchRelays:           make(map[string]*peer.Peer),
+		texPending:                make(map[string]struct{}),
+		texResultC:                make(chan *texResult),
+		metadataSourceResultC:     make(chan *metainfo.MetaInfo),
+		bans:                      blocklist.New(),
+		smartBan:                  smartban.New(),
 		announcersStoppedC:        make(chan struct{}),
 		dhtPeersC:                 make(chan []*net.TCPAddr, 1),
-		externalIP:                externalip.FirstExternalIP(),
 		downloadSpeed:             metrics.NilMeter{},
 		uploadSpeed:               metrics.NilMeter{},
 		bytesDownloaded:           metrics.NewCounter(),
@@ -355,14 +514,17 @@ func newTorrent2(
 		bytesWasted:               metrics.NewCounter(),
 		seededFor:                 metrics.NewCounter(),
 		ramNotifyC:                make(chan *peer.Peer),
+		budgetC:                   make(chan struct{}, 1),
 		webseedClient:             &s.webseedClient,
 		webseedSources:            ws,
 		webseedPieceResultC:       suspendchan.New[*urldownloader.PieceResult](0),
 		webseedRetryC:             make(chan *webseedsource.WebseedSource),
+		duplicatePieceDoneC:       make(chan uint32),
 		doneC:                     make(chan struct{}),
 		stopAfterDownload:         stopAfterDownload,
 		stopAfterMetadata:         stopAfterMetadata,
 		completeCmdRun:            completeCmdRun,
+		chokingAlgorithm:          chokingAlgorithm,
 	}
 	if len(t.webseedSources) > s.config.WebseedMaxSources {
 		t.webseedSources = t.webseedSources[:10]
@@ -375,16 +537,22 @@ func newTorrent2(
 	if cfg.BlocklistEnabledForOutgoingConnections {
 		blocklistForOutgoingConns = s.blocklist
 	}
-	t.addrList = addrlist.New(cfg.MaxPeerAddresses, blocklistForOutgoingConns, port, &t.externalIP)
+	t.addrList = addrlist.New(cfg.MaxPeerAddresses, blocklistForOutgoingConns, port, s.externalIP)
 	if t.info != nil {
 		t.piecePool = bufferpool.New(int(t.info.PieceLength))
 	}
+	if hh, ok := hybridInfoHash(info, ih); ok {
+		t.hybrid.Store(newHybridHashes(hh))
+	}
 	n := t.copyPeerIDPrefix()
 	_, err := rand.Read(t.peerID[n:])
 	if err != nil {
 		return nil, err
 	}
-	t.unchoker = unchoker.New(cfg.UnchokedPeers, cfg.OptimisticUnchokedPeers)
+	t.unchoker, err = t.newUnchoker()
+	if err != nil {
+		return nil, err
+	}
 	go t.run()
 	return t, nil
 }
@@ -415,6 +583,8 @@ func (t *torrent) InfoHash() []byte {
 }
 
 func (t *torrent) RootDirectory() string {
+	t.mStorage.RLock()
+	defer t.mStorage.RUnlock()
 	return t.storage.RootDir()
 }
 
@@ -7,6 +7,7 @@ import (
 	"github.com/cenkalti/rain/internal/bitfield"
 	"github.com/cenkalti/rain/internal/piece"
 	"github.com/cenkalti/rain/internal/piecepicker"
+	"github.com/cenkalti/rain/internal/storage/filestorage"
 )
 
 func (t *torrent) handleAllocationDone(al *allocator.Allocator) {
@@ -15,14 +16,16 @@ func (t *torrent) handleAllocationDone(al *allocator.Allocator) {
 	}
 	t.allocator = nil
 
+	if filestorage.IsNoSpace(al.Error) {
+		t.log.Warning("disk is full, pausing torrent until space is available")
+		t.pauseForDiskSpace()
+		return
+	}
 	if al.Error != nil {
 		t.stop(fmt.Errorf("file allocation error: %s", al.Error))
 		return
 	}
 
-	if t.files != nil {
-		panic("files exist")
-	}
 	t.files = al.Files
 
 	if t.pieces != nil {
@@ -33,7 +36,9 @@ func (t *torrent) handleAllocationDone(al *allocator.Allocator) {
 		t.stop(fmt.Errorf("torrent has zero pieces"))
 		return
 	}
+	t.mFiles.Lock()
 	t.pieces = pieces
+	t.mFiles.Unlock()
 
 	for pe := range t.peers {
 		pe.GenerateAndSendAllowedFastMessages(t.session.config.AllowedFastSet, t.info.NumPieces, t.infoHash, t.pieces)
@@ -43,17 +48,42 @@ func (t *torrent) handleAllocationDone(al *allocator.Allocator) {
 		panic("piece picker exists")
 	}
 	t.piecePicker = piecepicker.New(t.pieces, t.session.config.EndgameMaxDuplicateDownloads, t.webseedSources)
+	t.setPiecePriorities()
 
 	for pe := range t.peers {
 		pe.Bitfield = bitfield.New(t.info.NumPieces)
 	}
 
+	// Files are created from a completed torrent with the same info hash, no need to verify them again.
+	if t.duplicateBitfield != nil && (al.Linked || t.session.sharesDuplicateData() && !al.HasMissing) {
+		t.mBitfield.Lock()
+		t.bitfield = t.duplicateBitfield
+		t.mBitfield.Unlock()
+		err := t.writeBitfield()
+		if err != nil {
+			t.stop(err)
+			return
+		}
+	}
+	t.duplicateBitfield = nil
+
 	// If we already have bitfield from resume db, skip verification and start downloading.
-	if t.bitfield != nil && !al.HasMissing {
+	if t.bitfield != nil && !al.HasMissing && !t.doVerify {
+		if t.verifyPieces != nil {
+			t.startVerifier()
+			return
+		}
+		// Files may be changed while the torrent is not running.
+		if check, changed := t.changedPieces(); changed {
+			t.log.Info("files are changed since the last run, verifying changed files")
+			t.verifyPieces = check
+			t.startVerifier()
+			return
+		}
 		for i := uint32(0); i < t.bitfield.Len(); i++ {
 			t.pieces[i].Done = t.bitfield.Test(i)
 		}
-		if t.checkCompletion() && t.stopAfterDownload {
+		if t.checkCompletion() && t.stopAfterDownload && t.mover == nil {
 			t.stopAndSetStoppedOnComplete()
 			return
 		}
@@ -1,11 +1,51 @@
 package torrent
 
 import (
+	"encoding/json"
 	"math"
 
+	"github.com/cenkalti/rain/internal/resumer/boltdbresumer"
 	"github.com/cenkalti/rain/internal/tracker"
+	"go.etcd.io/bbolt"
 )
 
+// addTracker saves the tracker URL to the resume db and returns a tracker for announcing.
+// The returned tracker must be passed to the event loop to start announcing.
+func (t *torrent) addTracker(uri string) (tracker.Tracker, error) {
+	tr, err := t.newTracker(uri)
+	if err != nil {
+		return nil, err
+	}
+	err = t.session.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(torrentsBucket).Bucket([]byte(t.id))
+		value := b.Get(boltdbresumer.Keys.Trackers)
+		var trackers [][]string
+		err = json.Unmarshal(value, &trackers)
+		if err != nil {
+			return err
+		}
+		trackers = append(trackers, []string{uri})
+		value, err = json.Marshal(trackers)
+		if err != nil {
+			return err
+		}
+		return b.Put(boltdbresumer.Keys.Trackers, value)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tr, nil
+}
+
+// newTracker returns a tracker for announcing without saving it to the resume db.
+func (t *torrent) newTracker(uri string) (tracker.Tracker, error) {
+	var private bool
+	if t.info != nil {
+		private = t.info.Private
+	}
+	return t.session.trackerManager.Get(uri, t.session.config.TrackerHTTPTimeout, t.session.getTrackerUserAgent(private), int64(t.session.config.TrackerHTTPMaxResponseSize))
+}
+
 func (t *torrent) handleNewTrackers(trackers []tracker.Tracker) {
 	t.trackers = append(t.trackers, trackers...)
 	status := t.status()
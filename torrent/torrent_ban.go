@@ -0,0 +1,163 @@
+package torrent
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/cenkalti/rain/internal/blocklist"
+	"github.com/cenkalti/rain/internal/peer"
+	"github.com/cenkalti/rain/internal/piecewriter"
+	"github.com/cenkalti/rain/internal/smartban"
+)
+
+var errPeerNotFound = errors.New("peer not found")
+
+// blocked returns true if the IP is banned in the torrent.
+// If useBlocklist is true, the IP is also checked against the blocklist of the session, which contains the session-wide bans.
+func (t *torrent) blocked(ip net.IP, useBlocklist bool) bool {
+	if t.bans.Blocked(ip) {
+		return true
+	}
+	return useBlocklist && t.session.blocklist != nil && t.session.blocklist.Blocked(ip)
+}
+
+// Ban the IP address or range in CIDR notation in the torrent for ttl.
+// Zero ttl means the ban does not expire. Connected peers in the range are disconnected.
+func (t *torrent) Ban(addr string, ttl time.Duration) error {
+	n, err := blocklist.ParseNet(addr)
+	if err != nil {
+		return err
+	}
+	return t.banNet(n, banExpiry(ttl))
+}
+
+// banNet may be called from any goroutine.
+func (t *torrent) banNet(n *net.IPNet, expiresAt time.Time) error {
+	t.bans.Add(blocklist.Rule{Net: n, ExpiresAt: expiresAt})
+	t.notifyBansChanged()
+	return t.writeBans()
+}
+
+// Unban removes the ban of the IP address or range in CIDR notation from the torrent.
+func (t *torrent) Unban(addr string) error {
+	n, err := blocklist.ParseNet(addr)
+	if err != nil {
+		return err
+	}
+	if !t.bans.Remove(n) {
+		return errors.New("not banned: " + addr)
+	}
+	return t.writeBans()
+}
+
+// Bans returns the IP addresses and ranges that are banned in the torrent, including the ones banned in the session.
+func (t *torrent) Bans() []Ban {
+	return append(bansFromRules(t.bans.Rules(), false), t.session.Bans()...)
+}
+
+func (t *torrent) writeBans() error {
+	return t.session.resumer.WriteBans(t.id, marshalBans(t.bans.Rules()))
+}
+
+func (t *torrent) notifyBansChanged() {
+	select {
+	case t.bansChangedC <- struct{}{}:
+	default:
+	}
+}
+
+// closeBannedPeers disconnects the peers whose IPs are banned after they are connected.
+func (t *torrent) closeBannedPeers() {
+	for pe := range t.peers {
+		useBlocklist := t.session.config.BlocklistEnabledForOutgoingConnections
+		if _, ok := t.incomingPeers[pe]; ok {
+			useBlocklist = t.session.config.BlocklistEnabledForIncomingConnections
+		}
+		if t.blocked(pe.Conn.Addr().IP, useBlocklist) {
+			t.log.Infof("disconnecting banned peer %s", pe.String())
+			t.closePeer(pe)
+		}
+	}
+}
+
+type disconnectRequest struct {
+	Addr     string
+	Response chan error
+}
+
+// DisconnectPeer closes the connection to the peer with the address.
+// The peer may connect again unless its IP is banned.
+func (t *torrent) DisconnectPeer(addr string) error {
+	req := disconnectRequest{Addr: addr, Response: make(chan error, 1)}
+	select {
+	case t.disconnectCommandC <- req:
+	case <-t.closeC:
+		return errClosed
+	}
+	select {
+	case err := <-req.Response:
+		return err
+	case <-t.closeC:
+		return errClosed
+	}
+}
+
+func (t *torrent) handleDisconnectCommand(addr string) error {
+	for pe := range t.peers {
+		if pe.Conn.Addr().String() == addr {
+			t.closePeer(pe)
+			return nil
+		}
+	}
+	return errPeerNotFound
+}
+
+// banPeerIP bans the IP that has sent corrupt data.
+func (t *torrent) banPeerIP(ip string) {
+	t.log.Infof("banning %s for sending corrupt data", ip)
+	n, err := blocklist.ParseNet(ip)
+	if err != nil {
+		t.log.Errorf("cannot ban %s: %s", ip, err)
+		return
+	}
+	expiresAt := banExpiry(t.session.config.PeerBanDuration)
+	if t.session.config.PeerBanScope == banScopeSession {
+		err = t.session.banNet(n, expiresAt)
+	} else {
+		err = t.banNet(n, expiresAt)
+	}
+	if err != nil {
+		t.log.Errorf("cannot write bans to resume db: %s", err)
+	}
+	// Close connections from the IP now, regardless of the blocklist settings.
+	for pe := range t.peers {
+		if pe.IP() == ip {
+			t.closePeer(pe)
+		}
+	}
+}
+
+// handleCorruptPiece saves the blocks of a piece that failed hash check.
+// Peers that sent the corrupt blocks are banned after the piece is downloaded correctly from other sources.
+func (t *torrent) handleCorruptPiece(pw *piecewriter.PieceWriter, src *peer.Peer) {
+	blocks := make([]smartban.Block, len(pw.Blocks))
+	for i, b := range pw.Blocks {
+		blocks[i] = smartban.Block{Begin: b.Begin, Length: b.Length, IP: b.Peer.(*peer.Peer).IP()}
+	}
+	t.smartBan.Add(pw.Piece.Index, pw.Buffer.Data, blocks)
+	// Try another peer for this piece.
+	if t.piecePicker != nil {
+		t.piecePicker.HandleCorrupt(src, pw.Piece.Index)
+	}
+}
+
+// checkSmartBan compares the correct data of the piece with the blocks that failed before and bans the peers that sent them.
+func (t *torrent) checkSmartBan(pw *piecewriter.PieceWriter) {
+	if !t.smartBan.Has(pw.Piece.Index) {
+		return
+	}
+	for _, ip := range t.smartBan.Check(pw.Piece.Index, pw.Buffer.Data) {
+		t.banPeerIP(ip)
+	}
+}
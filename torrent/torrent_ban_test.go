@@ -0,0 +1,147 @@
+package torrent
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// loopbackSeeder starts seeding the sample torrent at given loopback address.
+// Seeders are put on different addresses so that they are recognized as different peers.
+func loopbackSeeder(t *testing.T, host string) (*Torrent, string, func()) {
+	s, closeSession := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.Host = host
+		cfg.FileCheckInterval = 0
+	})
+	tor := addSeedingTorrent(t, s)
+	return tor, host + ":" + strconv.Itoa(tor.Port()), closeSession
+}
+
+func TestSmartBan(t *testing.T) {
+	badTorrent, badAddr, closeBad := loopbackSeeder(t, "127.0.0.2")
+	defer closeBad()
+	corruptFile(t, badTorrent, "sample_torrent/data/file1.bin")
+
+	_, goodAddr, closeGood := loopbackSeeder(t, "127.0.0.3")
+	defer closeGood()
+
+	s, closeSession := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.PeerBanDuration = time.Hour
+	})
+	defer closeSession()
+
+	tor, err := s.AddURI(torrentMagnetLink+"&x.pe="+badAddr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first piece is corrupt at the bad seeder. Other pieces are downloaded from it.
+	for deadline := time.Now().Add(timeout); ; time.Sleep(10 * time.Millisecond) {
+		stats := tor.Stats()
+		if stats.Pieces.Total > 0 && stats.Pieces.Have == stats.Pieces.Total-1 && stats.Bytes.Wasted > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("pieces are not downloaded from bad seeder")
+		}
+	}
+	assert.Empty(t, tor.Bans())
+
+	err = tor.AddPeer(goodAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-tor.NotifyComplete():
+	case err = <-tor.NotifyStop():
+		t.Fatal(err)
+	case <-time.After(timeout):
+		t.Fatal("download did not finish")
+	}
+
+	bans := tor.Bans()
+	if assert.Len(t, bans, 1) {
+		assert.Equal(t, "127.0.0.2", bans[0].IP)
+		assert.False(t, bans[0].Session)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), bans[0].ExpiresAt, time.Minute)
+	}
+	assert.Empty(t, s.Bans())
+}
+
+func TestBanPeer(t *testing.T) {
+	_, seederAddr, closeSeeder := loopbackSeeder(t, "127.0.0.2")
+	defer closeSeeder()
+
+	// Session is restarted during the test.
+	cfg := DefaultConfig
+	cfg.DataDir = t.TempDir()
+	cfg.Database = filepath.Join(cfg.DataDir, "session.db")
+	cfg.DHTEnabled = false
+	cfg.PEXEnabled = false
+	cfg.RPCEnabled = false
+	cfg.Host = "127.0.0.1"
+	// Keep the peer connected while testing.
+	cfg.SpeedLimitDownload = 1
+	s, err := NewSession(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = s.Close() }()
+
+	tor, err := s.AddURI(torrentMagnetLink+"&x.pe="+seederAddr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitPeers := func(n int) {
+		for deadline := time.Now().Add(timeout); len(tor.Peers()) != n; time.Sleep(10 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("number of peers is not %d", n)
+			}
+		}
+	}
+	waitPeers(1)
+	assert.ErrorIs(t, tor.DisconnectPeer("127.0.0.9:1234"), errPeerNotFound)
+
+	err = s.Ban("10.0.0.0/8", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tor.Ban("127.0.0.2", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitPeers(0)
+	err = tor.AddPeer(seederAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	assert.Empty(t, tor.Peers())
+
+	// Bans are kept after restart.
+	id := tor.ID()
+	err = s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err = NewSession(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tor = s.GetTorrent(id)
+	bans := tor.Bans()
+	if assert.Len(t, bans, 2) {
+		assert.Equal(t, "127.0.0.2", bans[0].IP)
+		assert.False(t, bans[0].Session)
+		assert.Equal(t, "10.0.0.0/8", bans[1].IP)
+		assert.True(t, bans[1].Session)
+		assert.True(t, bans[1].ExpiresAt.IsZero())
+	}
+	assert.NoError(t, tor.Unban("127.0.0.2"))
+	assert.Error(t, tor.Unban("127.0.0.2"))
+	assert.NoError(t, s.Unban("10.0.0.0/8"))
+	assert.Empty(t, tor.Bans())
+}
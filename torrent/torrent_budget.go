@@ -0,0 +1,80 @@
+package torrent
+
+import "github.com/cenkalti/rain/internal/unchoker"
+
+// acquireDialSlot takes a half-open connection slot and a connection slot from the session budgets.
+// If a slot is not available, the torrent is notified via budgetC when slots are released.
+func (t *torrent) acquireDialSlot() bool {
+	if !t.session.dialSlots.Acquire(t, t.budgetC) {
+		return false
+	}
+	t.halfOpenReported++
+	if !t.acquireConnectionSlot() {
+		t.halfOpenReported = len(t.outgoingHandshakers)
+		t.session.dialSlots.Set(t, t.halfOpenReported)
+		return false
+	}
+	return true
+}
+
+func (t *torrent) acquireConnectionSlot() bool {
+	if !t.session.connSlots.Acquire(t, t.budgetC) {
+		return false
+	}
+	t.connectionsReported++
+	return true
+}
+
+// updateBudgets reports the number of connections to the session budgets after they are closed or handshakes are completed.
+// It is called after every event in the torrent loop.
+func (t *torrent) updateBudgets() {
+	if n := len(t.outgoingHandshakers); n != t.halfOpenReported {
+		t.halfOpenReported = n
+		t.session.dialSlots.Set(t, n)
+	}
+	if n := len(t.outgoingHandshakers) + len(t.incomingHandshakers) + len(t.peers); n != t.connectionsReported {
+		t.connectionsReported = n
+		t.session.connSlots.Set(t, n)
+	}
+}
+
+// removeBudgets releases all slots held by the torrent.
+func (t *torrent) removeBudgets() {
+	t.session.dialSlots.Remove(t)
+	t.session.connSlots.Remove(t)
+	t.session.uploadSlots.Remove(t)
+	t.halfOpenReported = 0
+	t.connectionsReported = 0
+	t.uploadSlots = 0
+	t.setUnchokerLimits()
+}
+
+// tickUnchoke reserves upload slots for interested peers from the session and runs the unchoker.
+func (t *torrent) tickUnchoke() {
+	peers := t.getPeersForUnchoker()
+	var interested int
+	for _, pe := range peers {
+		if pe.Interested() {
+			interested++
+		}
+	}
+	t.uploadSlots = t.session.uploadSlots.Reserve(t, min(t.maxUploadSlots(), interested))
+	t.setUnchokerLimits()
+	t.unchoker.TickUnchoke(peers, t.completed)
+}
+
+// fastUnchoke takes another upload slot from the session if all reserved slots are in use.
+func (t *torrent) fastUnchoke(pe unchoker.Peer) {
+	if t.unchoker.NumUnchoked() >= t.uploadSlots && t.uploadSlots < t.maxUploadSlots() && t.session.uploadSlots.Acquire(t, nil) {
+		t.uploadSlots++
+		t.setUnchokerLimits()
+	}
+	t.unchoker.FastUnchoke(pe)
+}
+
+func (t *torrent) setUnchokerLimits() {
+	regular := min(t.uploadSlots, t.session.config.UnchokedPeers)
+	optimistic := min(t.uploadSlots-regular, t.session.config.OptimisticUnchokedPeers)
+	// Rate based algorithm can use more regular slots.
+	t.unchoker.SetLimits(t.uploadSlots-optimistic, optimistic)
+}
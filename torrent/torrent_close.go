@@ -14,6 +14,8 @@ var errClosed = errors.New("torrent is closed")
 func (t *torrent) close() {
 	// Stop if running.
 	t.stop(errClosed)
+	t.removeBudgets()
+	t.stopFinalSyncer()
 
 	// Maybe we are in "Stopping" state. Close "stopped" event announcer.
 	if t.stoppedEventAnnouncer != nil {
@@ -46,6 +48,8 @@ func (t *torrent) closePeer(pe *peer.Peer) {
 	}
 	t.unchoker.HandleDisconnect(pe)
 	t.pexDropPeer(pe.Addr())
+	t.removeHolepunchRelay(pe)
+	t.cancelHashRequests(pe)
 	t.dialAddresses()
 	t.session.metrics.Peers.Dec(1)
 }
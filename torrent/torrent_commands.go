@@ -12,10 +12,19 @@ import (
 
 // Start downloading.
 // After all files are downloaded, seeding continues until the torrent is stopped.
-func (t *torrent) Start() {
+// Returns an error if the torrent is refused to start.
+func (t *torrent) Start() error {
+	respC := make(chan error, 1)
 	select {
-	case t.startCommandC <- struct{}{}:
+	case t.startCommandC <- respC:
 	case <-t.closeC:
+		return errClosed
+	}
+	select {
+	case err := <-respC:
+		return err
+	case <-t.closeC:
+		return errClosed
 	}
 }
 
@@ -36,11 +45,32 @@ func (t *torrent) Announce() {
 	}
 }
 
+// Recheck the missing pieces and continue running.
+func (t *torrent) Recheck() {
+	select {
+	case t.recheckCommandC <- struct{}{}:
+	case <-t.closeC:
+	}
+}
+
+type verifyRequest struct {
+	Options  *VerifyOptions
+	Response chan error
+}
+
 // Verify pieces by checking files.
-func (t *torrent) Verify() {
+func (t *torrent) Verify(opts *VerifyOptions) error {
+	req := verifyRequest{Options: opts, Response: make(chan error, 1)}
+	select {
+	case t.verifyCommandC <- req:
+	case <-t.closeC:
+		return errClosed
+	}
 	select {
-	case t.verifyCommandC <- struct{}{}:
+	case err := <-req.Response:
+		return err
 	case <-t.closeC:
+		return errClosed
 	}
 }
 
@@ -97,11 +127,18 @@ func (t *torrent) Magnet() (string, error) {
 	if t.info != nil && t.info.Private {
 		return "", errors.New("torrent is private")
 	}
+	var webseeds []string
+	for _, src := range t.webseedSources {
+		if !src.HTTPSeed {
+			webseeds = append(webseeds, src.URL)
+		}
+	}
 	m := magnet.Magnet{
 		InfoHash: t.infoHash,
 		Name:     t.Name(),
 		Trackers: t.getTieredTrackers(),
 		Peers:    t.fixedPeers,
+		Webseeds: webseeds,
 	}
 	return m.String(), nil
 }
@@ -114,7 +151,11 @@ func (t *torrent) Torrent() ([]byte, error) {
 	for i, ws := range t.webseedSources {
 		webseeds[i] = ws.URL
 	}
-	return metainfo.NewBytes(t.info.Bytes, t.getTieredTrackers(), webseeds, "")
+	signers := make([]metainfo.Signer, len(t.signatures))
+	for i, sig := range t.signatures {
+		signers[i] = sig
+	}
+	return metainfo.NewBytes(t.info.Bytes, t.info.PieceLayersBytes(), t.getTieredTrackers(), webseeds, "", signers...)
 }
 
 func (t *torrent) getTieredTrackers() [][]string {
@@ -254,6 +295,8 @@ const (
 	SourceIncoming
 	// SourceManual indicates that the peer is added manually via AddPeer method.
 	SourceManual
+	// SourceHolepunch indicates that the peer is connected with the help of a relay peer (BEP 55).
+	SourceHolepunch
 )
 
 type peersRequest struct {
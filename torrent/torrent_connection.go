@@ -4,31 +4,15 @@ import (
 	"net"
 
 	"github.com/cenkalti/rain/internal/handshaker/incominghandshaker"
+	"github.com/cenkalti/rain/internal/peersource"
 )
 
 func (t *torrent) handleNewConnection(conn net.Conn) {
-	if len(t.incomingHandshakers)+len(t.incomingPeers) >= t.session.config.MaxPeerAccept {
-		t.log.Debugln("peer limit reached, rejecting peer", conn.RemoteAddr().String())
-		conn.Close()
-		return
-	}
-	ip := conn.RemoteAddr().(*net.TCPAddr).IP
-	ipstr := ip.String()
-	if t.session.config.BlocklistEnabledForIncomingConnections && t.session.blocklist != nil && t.session.blocklist.Blocked(ip) {
-		t.log.Debugln("peer is blocked:", conn.RemoteAddr().String())
-		conn.Close()
-		return
-	}
-	if _, ok := t.connectedPeerIPs[ipstr]; ok {
-		t.log.Debugln("received duplicate connection from same IP: ", ipstr)
-		conn.Close()
-		return
-	}
-	if _, ok := t.bannedPeerIPs[ipstr]; ok {
-		t.log.Debugln("connection attempt from banned IP: ", ipstr)
+	if !t.acceptIncoming(conn) {
 		conn.Close()
 		return
 	}
+	ipstr := conn.RemoteAddr().(*net.TCPAddr).IP.String()
 	h := incominghandshaker.New(conn)
 	t.incomingHandshakers[h] = struct{}{}
 	t.connectedPeerIPs[ipstr] = struct{}{}
@@ -42,3 +26,35 @@ func (t *torrent) handleNewConnection(conn net.Conn) {
 		t.session.config.ForceIncomingEncryption,
 	)
 }
+
+// acceptIncoming returns false if the connection must be rejected because of the peer limits, blocklist or a duplicate connection.
+func (t *torrent) acceptIncoming(conn net.Conn) bool {
+	if len(t.incomingHandshakers)+len(t.incomingPeers) >= t.session.config.MaxPeerAccept {
+		t.log.Debugln("peer limit reached, rejecting peer", conn.RemoteAddr().String())
+		return false
+	}
+	ip := conn.RemoteAddr().(*net.TCPAddr).IP
+	if t.blocked(ip, t.session.config.BlocklistEnabledForIncomingConnections) {
+		t.log.Debugln("peer is blocked:", conn.RemoteAddr().String())
+		return false
+	}
+	if _, ok := t.connectedPeerIPs[ip.String()]; ok {
+		t.log.Debugln("received duplicate connection from same IP: ", ip.String())
+		return false
+	}
+	if !t.acquireConnectionSlot() {
+		t.log.Debugln("session connection limit reached, rejecting peer", conn.RemoteAddr().String())
+		return false
+	}
+	return true
+}
+
+// handleSharedHandshake starts a peer from a connection that is accepted on the shared port of the session.
+func (t *torrent) handleSharedHandshake(h *incominghandshaker.IncomingHandshaker) {
+	if !t.acceptingShared || !t.acceptIncoming(h.Conn) {
+		h.Conn.Close()
+		return
+	}
+	t.connectedPeerIPs[h.Conn.RemoteAddr().(*net.TCPAddr).IP.String()] = struct{}{}
+	t.startPeer(h.Conn, peersource.Incoming, t.incomingPeers, h.PeerID, h.Extensions, h.Cipher)
+}
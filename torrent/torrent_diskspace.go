@@ -0,0 +1,44 @@
+package torrent
+
+// hasDiskSpace returns false if disk space check is enabled and the remaining data does not fit into the free space.
+func (t *torrent) hasDiskSpace() bool {
+	if t.session.config.DiskSpaceCheck == "" {
+		return true
+	}
+	return t.session.hasDiskSpace(t.storage, t.info)
+}
+
+// stopForDiskSpace stops the torrent because its data does not fit on the disk.
+// In "queue" mode, the torrent is paused until enough space is available.
+func (t *torrent) stopForDiskSpace() {
+	if t.session.config.DiskSpaceCheck == diskSpaceCheckQueue {
+		t.pauseForDiskSpace()
+		return
+	}
+	t.stop(errNotEnoughDiskSpace)
+}
+
+// pauseForDiskSpace stops the torrent without an error and starts it again after enough space is available.
+func (t *torrent) pauseForDiskSpace() {
+	t.stop(nil)
+	t.waitingForDiskSpace = true
+}
+
+func (t *torrent) notifyDiskSpace() {
+	select {
+	case t.diskSpaceCommandC <- struct{}{}:
+	case <-t.closeC:
+	}
+}
+
+func (t *torrent) handleDiskSpaceCheck() {
+	if !t.waitingForDiskSpace || t.status() != Stopped {
+		return
+	}
+	if !t.session.hasDiskSpace(t.storage, t.info) {
+		return
+	}
+	t.log.Info("disk space is available, starting torrent")
+	t.waitingForDiskSpace = false
+	t.start()
+}
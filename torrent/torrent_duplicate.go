@@ -0,0 +1,81 @@
+package torrent
+
+import (
+	"github.com/cenkalti/rain/internal/allocator"
+	"github.com/cenkalti/rain/internal/storage/filestorage"
+)
+
+// duplicateLinker returns a function for creating files from a completed torrent with the same info hash.
+// Returns nil if there is no such torrent or linking is disabled in config.
+func (t *torrent) duplicateLinker() allocator.Linker {
+	t.duplicateBitfield = nil
+	if t.bitfield != nil || t.session.config.DuplicateTorrentData == "" {
+		return nil
+	}
+	src, bf := t.session.completedDuplicate(t)
+	if src == nil {
+		return nil
+	}
+	t.duplicateBitfield = bf
+	mode, link, _ := parseDuplicateTorrentData(t.session.config.DuplicateTorrentData)
+	if !link {
+		return nil
+	}
+	sto, ok := t.storage.(*filestorage.FileStorage)
+	if !ok {
+		return nil
+	}
+	t.log.Infof("creating missing files from torrent %s", src.id)
+	srcDir := src.RootDirectory()
+	return func(name string, size int64) (bool, error) {
+		return sto.Link(srcDir, name, size, mode)
+	}
+}
+
+// notifyDuplicates tells other torrents sharing the same data directory that a piece is written to disk.
+func (t *torrent) notifyDuplicates(index uint32) {
+	if !t.session.sharesDuplicateData() {
+		return
+	}
+	for _, t2 := range t.session.duplicatesOf(t) {
+		if t2.RootDirectory() != t.storage.RootDir() {
+			continue
+		}
+		go t2.notifyDuplicatePieceDone(index)
+	}
+}
+
+func (t *torrent) notifyDuplicatePieceDone(index uint32) {
+	select {
+	case t.duplicatePieceDoneC <- index:
+	case <-t.closeC:
+	}
+}
+
+func (t *torrent) handleDuplicatePieceDone(index uint32) {
+	if t.status() != Downloading || t.pieces == nil || t.bitfield == nil {
+		return
+	}
+	if index >= uint32(len(t.pieces)) {
+		return
+	}
+	pi := &t.pieces[index]
+	if pi.Done || pi.Writing {
+		return
+	}
+	// Webseed downloader will write the piece again when it arrives.
+	if t.piecePicker != nil && t.piecePicker.RequestedWebseedSource(index) != nil {
+		return
+	}
+	t.log.Debugf("piece #%d is written by another torrent", index)
+	t.handlePieceDone(pi, nil)
+}
+
+// readCacheKey returns the key that identifies the data of the torrent in session read cache.
+// Torrents sharing the same data directory share the cached data too.
+func (t *torrent) readCacheKey() [20]byte {
+	if t.session.sharesDuplicateData() {
+		return t.infoHash
+	}
+	return t.peerID
+}
@@ -0,0 +1,140 @@
+package torrent
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/cenkalti/rain/internal/bitfield"
+	"github.com/cenkalti/rain/internal/syncer"
+)
+
+// startSyncer starts flushing the files of the torrent in background.
+func (t *torrent) startSyncer() {
+	if t.syncer != nil || t.files == nil || t.bitfield == nil {
+		return
+	}
+	t.mBitfield.RLock()
+	unchanged := t.durableBitfield != nil && bytes.Equal(t.durableBitfield.Bytes(), t.bitfield.Bytes())
+	t.mBitfield.RUnlock()
+	if unchanged {
+		return
+	}
+	t.syncer = syncer.New()
+	go t.syncer.Run(t.files, t.bitfield.Copy(), t.session.metrics.SyncLatency, t.syncerResultC)
+}
+
+func (t *torrent) stopSyncer() {
+	t.log.Debugln("stopping syncer")
+	if t.syncer != nil {
+		t.syncer.Close()
+		t.syncer = nil
+	}
+}
+
+func (t *torrent) handleSyncerDone(sy *syncer.Syncer) {
+	if sy == t.finalSyncer {
+		t.handleFinalSyncerDone()
+		return
+	}
+	if t.syncer != sy {
+		panic("invalid syncer")
+	}
+	t.syncer = nil
+
+	if sy.Error != nil {
+		t.stop(fmt.Errorf("cannot sync files: %s", sy.Error))
+		return
+	}
+	t.setDurableBitfield(sy.Bitfield)
+
+	// In "bitfield" mode files are flushed only before saving the bitfield.
+	if !t.bitfieldWritePending && t.session.config.WriteDurability != durabilityBitfield {
+		return
+	}
+	t.mBitfield.RLock()
+	bf := t.durableBitfieldBytes()
+	t.mBitfield.RUnlock()
+	if bf == nil {
+		return
+	}
+	err := t.saveBitfield(bf)
+	if err != nil {
+		t.stop(err)
+		return
+	}
+	// Pieces completed after the syncer is started are saved after the next flush.
+	if t.bitfieldWritePending {
+		t.bitfieldWritePending = false
+		t.startSyncer()
+		t.bitfieldWritePending = t.syncer != nil
+	}
+}
+
+// startFinalSyncer flushes the files in background after the torrent is stopped and saves the bitfield when it is done.
+// Files are closed after they are flushed. The torrent stays in Stopping state until then.
+func (t *torrent) startFinalSyncer() {
+	t.bitfieldWritePending = false
+	if t.files == nil || t.bitfield == nil || t.finalSyncer != nil {
+		return
+	}
+	t.finalSyncer = syncer.New()
+	t.finalSyncerFiles = t.files
+	go t.finalSyncer.Run(t.files, t.bitfield.Copy(), t.session.metrics.SyncLatency, t.syncerResultC)
+}
+
+// stopFinalSyncer waits until the files are flushed.
+func (t *torrent) stopFinalSyncer() {
+	if t.finalSyncer != nil {
+		t.finalSyncer.Close()
+		t.handleFinalSyncerDone()
+	}
+}
+
+func (t *torrent) handleFinalSyncerDone() {
+	sy := t.finalSyncer
+	t.finalSyncer = nil
+	if sy.Error != nil {
+		t.log.Errorf("cannot sync files: %s", sy.Error)
+	} else {
+		t.setDurableBitfield(sy.Bitfield)
+		t.mBitfield.RLock()
+		bf := t.durableBitfieldBytes()
+		t.mBitfield.RUnlock()
+		if bf != nil && t.saveBitfield(bf) == nil {
+			t.saveFileStats(t.finalSyncerFiles)
+		}
+	}
+	t.closeFiles(t.finalSyncerFiles)
+	t.finalSyncerFiles = nil
+	// Stopped event may be announced to trackers before the files are flushed.
+	if t.stoppedEventAnnouncer == nil && t.errC != nil {
+		t.handleStopped()
+	}
+}
+
+func (t *torrent) setDurableBitfield(bf *bitfield.Bitfield) {
+	t.mBitfield.Lock()
+	t.durableBitfield = bf
+	t.mBitfield.Unlock()
+}
+
+// durableBitfieldBytes returns the bitfield that is safe to save into resume db.
+// Pieces are included only if they are still in the current bitfield, so pieces removed after a verification are not saved.
+// Returns nil if there is nothing to save. mBitfield must be held by the caller.
+func (t *torrent) durableBitfieldBytes() []byte {
+	if t.bitfield == nil {
+		return nil
+	}
+	if t.session.syncsOnWrite() {
+		return t.bitfield.Bytes()
+	}
+	if t.durableBitfield == nil || t.durableBitfield.Len() != t.bitfield.Len() {
+		return nil
+	}
+	durable := t.durableBitfield.Bytes()
+	b := make([]byte, len(durable))
+	for i, c := range t.bitfield.Bytes() {
+		b[i] = c & durable[i]
+	}
+	return b
+}
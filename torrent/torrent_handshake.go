@@ -12,11 +12,18 @@ func (t *torrent) getSKey(sKeyHash [20]byte) []byte {
 	if sKeyHash == t.sKeyHash {
 		return t.infoHash[:]
 	}
+	if hh := t.hybrid.Load(); hh != nil && sKeyHash == hh.SKeyHash {
+		return hh.InfoHash[:]
+	}
 	return nil
 }
 
 func (t *torrent) checkInfoHash(infoHash [20]byte) bool {
-	return infoHash == t.infoHash
+	if infoHash == t.infoHash {
+		return true
+	}
+	hh := t.hybrid.Load()
+	return hh != nil && infoHash == hh.InfoHash
 }
 
 func (t *torrent) handleIncomingHandshakeDone(ih *incominghandshaker.IncomingHandshaker) {
@@ -30,10 +37,23 @@ func (t *torrent) handleIncomingHandshakeDone(ih *incominghandshaker.IncomingHan
 
 func (t *torrent) handleOutgoingHandshakeDone(oh *outgoinghandshaker.OutgoingHandshaker) {
 	delete(t.outgoingHandshakers, oh)
+	ip := oh.Addr.IP.String()
 	if oh.Error != nil {
-		delete(t.connectedPeerIPs, oh.Addr.IP.String())
+		if oh.Source != peersource.Holepunch {
+			delete(t.connectedPeerIPs, ip)
+		}
+		if oh.Source == peersource.PEX {
+			t.sendRendezvous(oh.Addr)
+		}
 		t.dialAddresses()
 		return
 	}
+	if oh.Source == peersource.Holepunch {
+		if _, ok := t.connectedPeerIPs[ip]; ok {
+			oh.Conn.Close()
+			return
+		}
+		t.connectedPeerIPs[ip] = struct{}{}
+	}
 	t.startPeer(oh.Conn, oh.Source, t.outgoingPeers, oh.PeerID, oh.Extensions, oh.Cipher)
 }
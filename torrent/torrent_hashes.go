@@ -0,0 +1,234 @@
+package torrent
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/cenkalti/rain/internal/merkle"
+	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/cenkalti/rain/internal/peer"
+	"github.com/cenkalti/rain/internal/peerprotocol"
+)
+
+// maxHashesPerRequest is the maximum number of hashes that can be requested in a single hash request message.
+const maxHashesPerRequest = 512
+
+// pendingLayer is a piece layer of a v2 torrent that is being downloaded from peers in chunks.
+type pendingLayer struct {
+	hashes []byte
+	// Chunks that are not received yet, keyed by index of the first hash in chunk.
+	missing map[uint32]struct{}
+	// Outstanding requests of missing chunks, keyed by index of the first hash in chunk.
+	requested map[uint32]hashRequest
+	// Peers that rejected a request for this layer. They are not asked again.
+	rejected map[*peer.Peer]struct{}
+}
+
+// hashRequest is a chunk of a piece layer that is requested from a peer.
+type hashRequest struct {
+	peer   *peer.Peer
+	sentAt time.Time
+}
+
+// pieceLayerLevel returns the level of the piece layer in merkle trees, counted from the leaves.
+func (t *torrent) pieceLayerLevel() uint32 {
+	return uint32(merkle.Log2(int(t.info.PieceLength / merkle.BlockSize)))
+}
+
+// requestPieceLayers sends hash requests for missing piece layers of a v2 torrent to connected peers.
+// Each chunk is requested from a single peer at a time.
+// Pieces cannot be verified, hence the torrent cannot be allocated until all piece layers are received.
+func (t *torrent) requestPieceLayers() {
+	for pe := range t.peers {
+		t.requestPieceLayersFrom(pe)
+	}
+}
+
+func (t *torrent) requestPieceLayersFrom(pe *peer.Peer) {
+	if !pe.V2Enabled {
+		return
+	}
+	for _, l := range t.info.PieceLayers {
+		if l.Hashes != nil {
+			continue
+		}
+		root := string(l.PiecesRoot)
+		pl, ok := t.pendingLayers[root]
+		if !ok {
+			pl = newPendingLayer(l)
+			t.pendingLayers[root] = pl
+		}
+		if _, ok = pl.rejected[pe]; ok {
+			continue
+		}
+		width := merkle.NextPowerOfTwo(int(l.NumPieces))
+		length := min(width, maxHashesPerRequest)
+		for index := range pl.missing {
+			if _, ok = pl.requested[index]; ok {
+				continue
+			}
+			pl.requested[index] = hashRequest{peer: pe, sentAt: time.Now()}
+			msg := peerprotocol.HashRequestMessage{
+				BaseLayer:   t.pieceLayerLevel(),
+				Index:       index,
+				Length:      uint32(length),
+				ProofLayers: uint32(merkle.Log2(width)),
+			}
+			copy(msg.PiecesRoot[:], l.PiecesRoot)
+			pe.SendMessage(msg)
+		}
+	}
+}
+
+func newPendingLayer(l metainfo.PieceLayer) *pendingLayer {
+	width := merkle.NextPowerOfTwo(int(l.NumPieces))
+	length := min(width, maxHashesPerRequest)
+	pl := &pendingLayer{
+		hashes:    make([]byte, width*merkle.HashSize),
+		missing:   make(map[uint32]struct{}),
+		requested: make(map[uint32]hashRequest),
+		rejected:  make(map[*peer.Peer]struct{}),
+	}
+	for index := 0; index < int(l.NumPieces); index += length {
+		pl.missing[uint32(index)] = struct{}{}
+	}
+	return pl
+}
+
+func (t *torrent) handleHashes(pe *peer.Peer, msg peerprotocol.HashesMessage) {
+	if t.info == nil {
+		return
+	}
+	pl, ok := t.pendingLayers[string(msg.PiecesRoot[:])]
+	if !ok {
+		return
+	}
+	if _, ok = pl.missing[msg.Index]; !ok || msg.BaseLayer != t.pieceLayerLevel() {
+		return
+	}
+	n := int(msg.Length) * merkle.HashSize
+	if msg.Length == 0 || msg.Length&(msg.Length-1) != 0 || msg.Index%msg.Length != 0 || n > len(msg.Hashes) || int(msg.Index)*merkle.HashSize+n > len(pl.hashes) {
+		pe.Logger().Errorln("invalid hashes message")
+		t.closePeer(pe)
+		return
+	}
+	root, err := merkle.ProofRoot(msg.Hashes[:n], int(msg.Index), msg.Hashes[n:])
+	if err != nil || !bytes.Equal(root, msg.PiecesRoot[:]) {
+		pe.Logger().Errorln("received hashes do not match with pieces root")
+		t.closePeer(pe)
+		return
+	}
+	copy(pl.hashes[int(msg.Index)*merkle.HashSize:], msg.Hashes[:n])
+	delete(pl.missing, msg.Index)
+	delete(pl.requested, msg.Index)
+	if len(pl.missing) > 0 {
+		return
+	}
+	delete(t.pendingLayers, string(msg.PiecesRoot[:]))
+	l := t.info.PieceLayer(msg.PiecesRoot[:])
+	err = t.info.SetPieceLayer(msg.PiecesRoot[:], pl.hashes[:l.NumPieces*merkle.HashSize])
+	if err != nil {
+		pe.Logger().Error(err)
+		return
+	}
+	if !t.info.HasPieceLayers() {
+		return
+	}
+	t.log.Info("received all piece layers")
+	err = t.session.resumer.WritePieceLayers(t.id, t.info.PieceLayersBytes())
+	if err != nil {
+		t.stop(fmt.Errorf("cannot write resume info: %s", err))
+		return
+	}
+	if !t.hasDiskSpace() {
+		t.stopForDiskSpace()
+	} else {
+		t.startAllocator()
+	}
+}
+
+// handleHashReject requests the rejected chunk from other peers.
+func (t *torrent) handleHashReject(pe *peer.Peer, msg peerprotocol.HashRejectMessage) {
+	pe.Logger().Debugln("hash request rejected:", msg.Index)
+	pl, ok := t.pendingLayers[string(msg.PiecesRoot[:])]
+	if !ok {
+		return
+	}
+	req, ok := pl.requested[msg.Index]
+	if !ok || req.peer != pe {
+		return
+	}
+	delete(pl.requested, msg.Index)
+	pl.rejected[pe] = struct{}{}
+	t.requestPieceLayers()
+}
+
+// cancelHashRequests releases the chunks requested from the disconnected peer.
+// They are requested from other peers when the timeout checker runs or a new peer is connected.
+func (t *torrent) cancelHashRequests(pe *peer.Peer) {
+	for _, pl := range t.pendingLayers {
+		for index, req := range pl.requested {
+			if req.peer == pe {
+				delete(pl.requested, index)
+			}
+		}
+		delete(pl.rejected, pe)
+	}
+}
+
+// checkHashRequests requests the chunks that are not received in time from other peers.
+func (t *torrent) checkHashRequests() {
+	if len(t.pendingLayers) == 0 {
+		return
+	}
+	for _, pl := range t.pendingLayers {
+		for index, req := range pl.requested {
+			if time.Since(req.sentAt) > t.session.config.RequestTimeout {
+				req.peer.Logger().Debugln("hash request timed out:", index)
+				delete(pl.requested, index)
+			}
+		}
+	}
+	t.requestPieceLayers()
+}
+
+func (t *torrent) handleHashRequest(pe *peer.Peer, msg peerprotocol.HashRequestMessage) {
+	hashes, ok := t.getHashes(msg)
+	if !ok {
+		pe.SendMessage(peerprotocol.HashRejectMessage{HashRequestMessage: msg})
+		return
+	}
+	pe.SendMessage(peerprotocol.HashesMessage{HashRequestMessage: msg, Hashes: hashes})
+}
+
+// getHashes returns the requested hashes from the piece layer followed by the uncle hashes for verifying them.
+func (t *torrent) getHashes(msg peerprotocol.HashRequestMessage) ([]byte, bool) {
+	if t.info == nil || msg.BaseLayer != t.pieceLayerLevel() {
+		return nil, false
+	}
+	l := t.info.PieceLayer(msg.PiecesRoot[:])
+	if l == nil || l.Hashes == nil {
+		return nil, false
+	}
+	width := merkle.NextPowerOfTwo(int(l.NumPieces))
+	if msg.Length < 2 || msg.Length > maxHashesPerRequest || msg.Length&(msg.Length-1) != 0 || msg.Index%msg.Length != 0 || int(msg.Index)+int(msg.Length) > width {
+		return nil, false
+	}
+	layers := merkle.Layers(l.Hashes, width, merkle.PadHash(int(t.info.PieceLength/merkle.BlockSize)))
+	base := layers[len(layers)-1]
+	begin := int(msg.Index) * merkle.HashSize
+	hashes := append([]byte(nil), base[begin:begin+int(msg.Length)*merkle.HashSize]...)
+	// Uncles start from the layer of the subtree root and go up until the requested proof layer or the root.
+	level := len(layers) - 1 - merkle.Log2(int(msg.Length))
+	pos := int(msg.Index / msg.Length)
+	// Proof cannot go higher than the root of the tree.
+	proofLayers := min(int(msg.ProofLayers), len(layers)-1)
+	for numUncles := proofLayers - merkle.Log2(int(msg.Length)); numUncles > 0 && level > 0; numUncles-- {
+		sibling := (pos ^ 1) * merkle.HashSize
+		hashes = append(hashes, layers[level][sibling:sibling+merkle.HashSize]...)
+		level--
+		pos /= 2
+	}
+	return hashes, true
+}
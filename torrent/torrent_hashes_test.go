@@ -0,0 +1,128 @@
+package torrent
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/logger"
+	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/cenkalti/rain/internal/peerprotocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloadV2Magnet(t *testing.T) {
+	for _, hybrid := range []bool{false, true} {
+		dir := t.TempDir()
+		data := make([]byte, 100<<10)
+		_, _ = rand.Read(data)
+		if err := os.WriteFile(filepath.Join(dir, "file.bin"), data, 0640); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "small.txt"), []byte("small"), 0640); err != nil {
+			t.Fatal(err)
+		}
+		info, layers, err := metainfo.NewInfoBytesV2("", []string{dir}, false, 16<<10, "v2_torrent", hybrid, logger.New("test"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := metainfo.NewBytes(info, layers, nil, nil, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		mi, err := metainfo.New(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		seeder, closeSeeder := newTestSessionWithConfig(t, func(cfg *Config) {
+			cfg.Host = "127.0.0.2"
+		})
+		seed, err := seeder.AddTorrent(bytes.NewReader(b), &AddTorrentOptions{Stopped: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		root := filepath.Join(seed.RootDirectory(), "v2_torrent")
+		if err = os.MkdirAll(root, 0750); err != nil {
+			t.Fatal(err)
+		}
+		_ = os.WriteFile(filepath.Join(root, "file.bin"), data, 0640)
+		_ = os.WriteFile(filepath.Join(root, "small.txt"), []byte("small"), 0640)
+		if err = seed.Start(); err != nil {
+			t.Fatal(err)
+		}
+		waitStatus(t, seed, Seeding)
+
+		s, closeSession := newTestSessionWithConfig(t, nil)
+		addr := "127.0.0.2:" + strconv.Itoa(seed.Port())
+		tor, err := s.AddURI("magnet:?xt=urn:btmh:1220"+hex.EncodeToString(mi.Info.HashV2[:])+"&x.pe="+addr, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-tor.NotifyComplete():
+		case err = <-tor.NotifyStop():
+			t.Fatal(err)
+		case <-time.After(timeout):
+			t.Fatal("torrent is not completed")
+		}
+		b2, err := os.ReadFile(filepath.Join(tor.RootDirectory(), "v2_torrent", "file.bin"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, data, b2)
+		// Hybrid torrent joins the v1 swarm after its info is downloaded.
+		if hh := tor.torrent.hybrid.Load(); assert.Equal(t, hybrid, hh != nil) && hybrid {
+			assert.Equal(t, mi.Info.Hash, hh.InfoHash)
+		}
+		closeSession()
+		closeSeeder()
+	}
+}
+
+func TestHashRequestBounds(t *testing.T) {
+	dir := t.TempDir()
+	data := make([]byte, 3*16<<10)
+	_, _ = rand.Read(data)
+	if err := os.WriteFile(filepath.Join(dir, "file.bin"), data, 0640); err != nil {
+		t.Fatal(err)
+	}
+	info, layers, err := metainfo.NewInfoBytesV2("", []string{filepath.Join(dir, "file.bin")}, false, 16<<10, "file.bin", false, logger.New("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := metainfo.NewBytes(info, layers, nil, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mi, err := metainfo.New(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tor := &torrent{info: &mi.Info}
+	msg := peerprotocol.HashRequestMessage{BaseLayer: tor.pieceLayerLevel(), Length: 4}
+	copy(msg.PiecesRoot[:], mi.Info.PieceLayers[0].PiecesRoot)
+
+	hashes, ok := tor.getHashes(msg)
+	assert.True(t, ok)
+	assert.Len(t, hashes, 4*32)
+
+	// Proof layers above the root are not sent.
+	msg.ProofLayers = 1 << 31
+	hashes, ok = tor.getHashes(msg)
+	assert.True(t, ok)
+	assert.Len(t, hashes, 4*32)
+
+	// Requests larger than the piece layer are rejected.
+	msg.Length = 512
+	_, ok = tor.getHashes(msg)
+	assert.False(t, ok)
+	msg.Index, msg.Length = 4, 4
+	_, ok = tor.getHashes(msg)
+	assert.False(t, ok)
+}
@@ -0,0 +1,201 @@
+package torrent
+
+import (
+	"context"
+	"net"
+	"syscall"
+
+	"github.com/cenkalti/rain/internal/btconn"
+	"github.com/cenkalti/rain/internal/handshaker/outgoinghandshaker"
+	"github.com/cenkalti/rain/internal/peer"
+	"github.com/cenkalti/rain/internal/peerprotocol"
+	"github.com/cenkalti/rain/internal/peersource"
+)
+
+// listenPeers starts listening for incoming peer connections.
+// The port is shared with outgoing holepunch connections if holepunch extension is enabled.
+func listenPeers(cfg *Config, ip net.IP, port int) (net.Listener, error) {
+	addr := &net.TCPAddr{IP: ip, Port: port}
+	if !cfg.HolepunchEnabled || !reusePortSupported {
+		l, err := net.ListenTCP("tcp4", addr)
+		if err != nil {
+			return nil, err
+		}
+		return l, nil
+	}
+	lc := net.ListenConfig{Control: reusePort}
+	return lc.Listen(context.Background(), "tcp4", addr.String())
+}
+
+func (t *torrent) holepunchEnabled() bool {
+	if !t.session.config.HolepunchEnabled || t.session.config.ProxyURL != "" {
+		return false
+	}
+	return t.info == nil || !t.info.Private
+}
+
+func supportsHolepunch(pe *peer.Peer) bool {
+	return supportsExtension(pe, peerprotocol.ExtensionKeyHolepunch)
+}
+
+func sendHolepunchMessage(pe *peer.Peer, msg peerprotocol.ExtensionHolepunchMessage) {
+	pe.SendMessage(peerprotocol.ExtensionMessage{
+		ExtendedMessageID: pe.ExtensionHandshake.M[peerprotocol.ExtensionKeyHolepunch],
+		Payload:           msg,
+	})
+}
+
+// addHolepunchRelays remembers the peer that sent the addresses with PEX,
+// so we can ask it to introduce us if we cannot connect to them directly.
+func (t *torrent) addHolepunchRelays(pe *peer.Peer, addrs []*net.TCPAddr) {
+	if !t.holepunchEnabled() || !supportsHolepunch(pe) {
+		return
+	}
+	for _, addr := range addrs {
+		t.holepunchRelays[addr.String()] = pe
+	}
+}
+
+func (t *torrent) removeHolepunchRelay(pe *peer.Peer) {
+	for addr, relay := range t.holepunchRelays {
+		if relay == pe {
+			delete(t.holepunchRelays, addr)
+		}
+	}
+}
+
+// sendRendezvous asks the peer that told us about addr to make us connect each other at the same time.
+// It is called after the direct connection to addr has failed.
+func (t *torrent) sendRendezvous(addr *net.TCPAddr) {
+	key := addr.String()
+	relay, ok := t.holepunchRelays[key]
+	if !ok {
+		return
+	}
+	delete(t.holepunchRelays, key)
+	if _, ok = t.peers[relay]; !ok {
+		return
+	}
+	relay.Logger().Debugln("sending holepunch rendezvous for", addr)
+	sendHolepunchMessage(relay, peerprotocol.ExtensionHolepunchMessage{Type: peerprotocol.HolepunchRendezvous, Addr: addr})
+}
+
+func (t *torrent) handleHolepunchMessage(pe *peer.Peer, msg peerprotocol.ExtensionHolepunchMessage) {
+	if !t.holepunchEnabled() {
+		return
+	}
+	switch msg.Type {
+	case peerprotocol.HolepunchRendezvous:
+		t.handleRendezvous(pe, msg.Addr)
+	case peerprotocol.HolepunchConnect:
+		pe.Logger().Debugln("received holepunch connect for", msg.Addr)
+		t.dialHolepunch(msg.Addr)
+	case peerprotocol.HolepunchError:
+		pe.Logger().Debugln("holepunch error for", msg.Addr, "code:", msg.ErrCode)
+	default:
+		pe.Logger().Debugln("unknown holepunch message type:", msg.Type)
+	}
+}
+
+// handleRendezvous relays the rendezvous message from pe to the target peer.
+func (t *torrent) handleRendezvous(pe *peer.Peer, addr *net.TCPAddr) {
+	sendError := func(code uint32) {
+		sendHolepunchMessage(pe, peerprotocol.ExtensionHolepunchMessage{Type: peerprotocol.HolepunchError, Addr: addr, ErrCode: code})
+	}
+	if addrEqual(pe.Addr(), addr) || addrEqual(listenAddr(pe), addr) {
+		sendError(peerprotocol.HolepunchNoSelf)
+		return
+	}
+	var target *peer.Peer
+	for p := range t.peers {
+		if addrEqual(p.Addr(), addr) || addrEqual(listenAddr(p), addr) {
+			target = p
+			break
+		}
+	}
+	switch {
+	case target == nil:
+		sendError(peerprotocol.HolepunchNoSuchPeer)
+	case target.Closed:
+		sendError(peerprotocol.HolepunchNotConnected)
+	case !supportsHolepunch(target):
+		sendError(peerprotocol.HolepunchNoSupport)
+	default:
+		sendHolepunchMessage(pe, peerprotocol.ExtensionHolepunchMessage{Type: peerprotocol.HolepunchConnect, Addr: listenAddr(target)})
+		sendHolepunchMessage(target, peerprotocol.ExtensionHolepunchMessage{Type: peerprotocol.HolepunchConnect, Addr: listenAddr(pe)})
+	}
+}
+
+// dialHolepunch connects to the address at the same time with the remote peer that gets the same connect message from the relay.
+func (t *torrent) dialHolepunch(addr *net.TCPAddr) {
+	if status := t.status(); status == Stopped || status == Stopping {
+		return
+	}
+	if t.session.config.ForceOutgoingEncryption {
+		// Both sides start the handshake in a simultaneous open and encryption handshake cannot complete.
+		return
+	}
+	ip := addr.IP.String()
+	if _, ok := t.connectedPeerIPs[ip]; ok {
+		return
+	}
+	if t.blocked(addr.IP, t.session.config.BlocklistEnabledForOutgoingConnections) {
+		return
+	}
+	if !t.acquireDialSlot() {
+		return
+	}
+	// IP is not reserved in connectedPeerIPs until the handshake is done,
+	// because the remote peer is connecting us at the same time and its connection may be accepted first.
+	h := outgoinghandshaker.New(addr, peersource.Holepunch)
+	t.outgoingHandshakers[h] = struct{}{}
+	go h.Run(
+		t.holepunchDialer(),
+		t.session.config.PeerConnectTimeout,
+		t.session.config.PeerHandshakeTimeout,
+		t.peerID,
+		t.infoHash,
+		t.outgoingHandshakerResultC,
+		t.session.extensions,
+		true,
+		false,
+	)
+}
+
+// holepunchDialer returns a dialer that connects from the port we are listening on,
+// so the NAT mapping created by the outgoing connection matches the address that the remote peer is dialing.
+func (t *torrent) holepunchDialer() btconn.Dialer {
+	bind := t.session.outgoingControl
+	d := outgoingDialer(t.session.outgoingIP, bind, 0)
+	if !reusePortSupported || (t.acceptor == nil && !t.acceptingShared) {
+		return d
+	}
+	ip := t.session.outgoingIP
+	if ip == nil {
+		ip = net.ParseIP(t.session.config.Host)
+	}
+	d.LocalAddr = &net.TCPAddr{IP: ip, Port: t.port}
+	d.Control = func(network, address string, c syscall.RawConn) error {
+		if bind != nil {
+			if err := bind(network, address, c); err != nil {
+				return err
+			}
+		}
+		return reusePort(network, address, c)
+	}
+	return d
+}
+
+// listenAddr returns the address that the peer accepts connections on.
+// Remote port of incoming connections is replaced with the port in extension handshake.
+func listenAddr(pe *peer.Peer) *net.TCPAddr {
+	addr := pe.Addr()
+	if pe.Source != peersource.Incoming || pe.ExtensionHandshake == nil || pe.ExtensionHandshake.Port <= 0 || pe.ExtensionHandshake.Port > 65535 {
+		return addr
+	}
+	return &net.TCPAddr{IP: addr.IP, Port: pe.ExtensionHandshake.Port}
+}
+
+func addrEqual(a, b *net.TCPAddr) bool {
+	return a.IP.Equal(b.IP) && a.Port == b.Port
+}
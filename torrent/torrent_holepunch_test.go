@@ -0,0 +1,104 @@
+package torrent
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/peerprotocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHolepunchMessage(t *testing.T) {
+	msg := peerprotocol.ExtensionHolepunchMessage{
+		Type:    peerprotocol.HolepunchError,
+		Addr:    &net.TCPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 6881},
+		ErrCode: peerprotocol.HolepunchNoSupport,
+	}
+	b, err := msg.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []byte{2, 0, 1, 2, 3, 4, 0x1a, 0xe1, 0, 0, 0, 3}, b)
+	var msg2 peerprotocol.ExtensionHolepunchMessage
+	err = msg2.UnmarshalBinary(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, msg.Type, msg2.Type)
+	assert.Equal(t, msg.Addr.String(), msg2.Addr.String())
+	assert.Equal(t, msg.ErrCode, msg2.ErrCode)
+	assert.Error(t, msg2.UnmarshalBinary(b[:8]))
+}
+
+// holepunchTestTorrent starts a torrent that has no data, so it keeps connecting to peers.
+func holepunchTestTorrent(t *testing.T, host string) (*Torrent, func()) {
+	s, closeSession := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.Host = host
+		cfg.OutgoingAddress = host
+		cfg.PEXEnabled = true
+		cfg.HolepunchEnabled = true
+	})
+	f, err := os.Open(torrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tor, err := s.AddTorrent(f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, tor, Downloading)
+	return tor, closeSession
+}
+
+func TestHolepunch(t *testing.T) {
+	relay, closeRelay := holepunchTestTorrent(t, "127.0.0.2")
+	defer closeRelay()
+	relayAddr := "127.0.0.2:" + strconv.Itoa(relay.Port())
+
+	waitPeers := func(tor *Torrent, n int) {
+		for deadline := time.Now().Add(timeout); len(tor.Peers()) < n; time.Sleep(10 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("number of peers is less than %d", n)
+			}
+		}
+	}
+
+	// Relay sends the remote address of this peer with PEX, which is not connectable.
+	target, closeTarget := holepunchTestTorrent(t, "127.0.0.3")
+	defer closeTarget()
+	err := target.AddPeer(relayAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitPeers(relay, 1)
+
+	tor, closeTor := holepunchTestTorrent(t, "127.0.0.1")
+	defer closeTor()
+	err = tor.AddPeer(relayAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Either side may accept the connection of the other before its own connection is made.
+	holepunched := func() bool {
+		for _, tt := range []*Torrent{tor, target} {
+			for _, p := range tt.Peers() {
+				if p.Source == SourceHolepunch {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	for deadline := time.Now().Add(timeout); !holepunched(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("no peer is connected with holepunch")
+		}
+	}
+	waitPeers(tor, 2)
+	waitPeers(target, 2)
+}
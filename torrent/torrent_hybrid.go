@@ -0,0 +1,54 @@
+package torrent
+
+import (
+	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/cenkalti/rain/internal/mse"
+	"github.com/cenkalti/rain/internal/tracker"
+)
+
+// hybridHashes contains the info hash of the other swarm of a hybrid torrent and its hash for encrypted handshake.
+type hybridHashes struct {
+	InfoHash [20]byte
+	SKeyHash [20]byte
+}
+
+func newHybridHashes(infoHash [20]byte) *hybridHashes {
+	return &hybridHashes{InfoHash: infoHash, SKeyHash: mse.HashSKey(infoHash[:])}
+}
+
+// hybridInfoHash returns the info hash of the other swarm of a hybrid torrent.
+// Hybrid torrents have both v1 and v2 info hashes and they are announced and accepted in both swarms.
+// The other swarm of a torrent added with a magnet link is joined after its info is downloaded.
+func hybridInfoHash(info *metainfo.Info, infoHash [20]byte) (ih [20]byte, ok bool) {
+	if info == nil || !info.V1 || !info.V2 {
+		return
+	}
+	if infoHash == info.Hash {
+		copy(ih[:], info.HashV2[:])
+	} else {
+		ih = info.Hash
+	}
+	return ih, true
+}
+
+// joinHybridSwarm starts announcing the other info hash after the info of a torrent added with a magnet link is downloaded.
+func (t *torrent) joinHybridSwarm() {
+	if t.hybrid.Load() != nil {
+		return
+	}
+	hh, ok := hybridInfoHash(t.info, t.infoHash)
+	if !ok {
+		return
+	}
+	t.session.addHybridHash(t, newHybridHashes(hh))
+	t.log.Infof("joining the other swarm of hybrid torrent: %x", hh)
+	for _, an := range t.announcers {
+		t.hybridAnnouncers = append(t.hybridAnnouncers, t.runAnnouncer(an.Tracker, t.hybridAnnouncerFields))
+	}
+}
+
+func (t *torrent) hybridAnnouncerFields() tracker.Torrent {
+	tr := t.announcerFields()
+	tr.InfoHash = t.hybrid.Load().InfoHash
+	return tr
+}
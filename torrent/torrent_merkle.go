@@ -0,0 +1,75 @@
+package torrent
+
+import (
+	"github.com/cenkalti/rain/internal/peer"
+	"github.com/cenkalti/rain/internal/peerprotocol"
+	"github.com/cenkalti/rain/internal/verifier"
+)
+
+// pieceHashList returns the bencoded hash chain that is sent with the first block of a piece in merkle torrents (BEP 30).
+// It returns nil for other torrents and blocks.
+func (t *torrent) pieceHashList(msg peerprotocol.RequestMessage) []byte {
+	if t.info.RootHash == nil || msg.Begin != 0 {
+		return nil
+	}
+	chain := t.info.HashChain(msg.Index)
+	if chain == nil {
+		t.log.Warningf("hash chain of piece #%d is not known", msg.Index)
+		return nil
+	}
+	b, err := peerprotocol.MarshalHashList(chain)
+	if err != nil {
+		t.log.Errorf("cannot encode hash list: %s", err)
+		return nil
+	}
+	return b
+}
+
+// handlePieceHashes verifies the hash chain received with a piece and sets the hashes of pieces in the chain.
+// It returns false if the chain does not match the root hash.
+func (t *torrent) handlePieceHashes(pe *peer.Peer, index uint32, hashes map[uint32][]byte) bool {
+	if t.info.RootHash == nil || t.pieces[index].Hash != nil {
+		return true
+	}
+	err := t.info.SetHashChain(index, hashes)
+	if err != nil {
+		pe.Logger().Errorf("invalid hash chain for piece #%d: %s", index, err)
+		return false
+	}
+	// Chain contains the hash of the sibling piece too.
+	for _, i := range []uint32{index, index ^ 1} {
+		if i < uint32(len(t.pieces)) && t.pieces[i].Hash == nil {
+			t.pieces[i].Hash = t.info.PieceHash(i)
+		}
+	}
+	return true
+}
+
+// setVerifiedPieceHashes builds the merkle tree from the hashes of the pieces on disk.
+// Pieces whose hashes are not received from peers can only be verified all together against the root hash.
+func (t *torrent) setVerifiedPieceHashes(ve *verifier.Verifier) {
+	if t.info.RootHash == nil || len(ve.Hashes) == 0 {
+		return
+	}
+	err := t.info.SetPieceHashes(ve.Hashes)
+	if err != nil {
+		t.log.Debugf("cannot verify pieces with unknown hashes: %s", err)
+		return
+	}
+	for i := range ve.Hashes {
+		t.pieces[i].Hash = t.info.PieceHash(i)
+		ve.Bitfield.Set(i)
+	}
+}
+
+// writeMerkleTree saves the known nodes of the merkle tree, so pieces can be uploaded after restart.
+func (t *torrent) writeMerkleTree() error {
+	if t.info == nil || t.info.RootHash == nil {
+		return nil
+	}
+	err := t.session.resumer.WriteMerkleTree(t.id, t.info.MerkleTree())
+	if err != nil {
+		t.log.Errorf("cannot write merkle tree to resume db: %s", err)
+	}
+	return err
+}
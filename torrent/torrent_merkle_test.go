@@ -0,0 +1,78 @@
+package torrent
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/logger"
+	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloadMerkle(t *testing.T) {
+	dir := t.TempDir()
+	data := make([]byte, 100<<10)
+	_, _ = rand.Read(data)
+	if err := os.WriteFile(filepath.Join(dir, "file.bin"), data, 0640); err != nil {
+		t.Fatal(err)
+	}
+	info, err := metainfo.NewInfoBytesMerkle("", []string{filepath.Join(dir, "file.bin")}, false, 16<<10, "", logger.New("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := metainfo.NewBytes(info, nil, nil, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mi, err := metainfo.New(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NotNil(t, mi.Info.RootHash)
+
+	seeder, closeSeeder := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.Host = "127.0.0.2"
+	})
+	defer closeSeeder()
+	seed, err := seeder.AddTorrent(bytes.NewReader(b), &AddTorrentOptions{Stopped: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(seed.RootDirectory(), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(seed.RootDirectory(), "file.bin"), data, 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err = seed.Start(); err != nil {
+		t.Fatal(err)
+	}
+	// Pieces are verified against the root hash.
+	waitStatus(t, seed, Seeding)
+
+	s, closeSession := newTestSessionWithConfig(t, nil)
+	defer closeSession()
+	addr := "127.0.0.2:" + strconv.Itoa(seed.Port())
+	tor, err := s.AddURI("magnet:?xt=urn:btih:"+hex.EncodeToString(mi.Info.Hash[:])+"&x.pe="+addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-tor.NotifyComplete():
+	case err = <-tor.NotifyStop():
+		t.Fatal(err)
+	case <-time.After(timeout):
+		t.Fatal("torrent is not completed")
+	}
+	b2, err := os.ReadFile(filepath.Join(tor.RootDirectory(), "file.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, data, b2)
+}
@@ -5,7 +5,6 @@ import (
 	"net"
 
 	"github.com/cenkalti/rain/internal/bitfield"
-	"github.com/cenkalti/rain/internal/cachedpiece"
 	"github.com/cenkalti/rain/internal/peer"
 	"github.com/cenkalti/rain/internal/peerconn/peerwriter"
 	"github.com/cenkalti/rain/internal/peerprotocol"
@@ -38,6 +37,12 @@ func (t *torrent) handlePieceMessage(pm peer.PieceMessage) {
 		msg.Buffer.Release()
 		return
 	}
+	if msg.Hashes != nil && !t.handlePieceHashes(pe, msg.Index, msg.Hashes) {
+		t.bytesWasted.Inc(l)
+		t.closePeer(pe)
+		msg.Buffer.Release()
+		return
+	}
 	t.downloadSpeed.Mark(l)
 	t.bytesDownloaded.Inc(l)
 	t.session.metrics.SpeedDownload.Mark(l)
@@ -101,6 +106,15 @@ func (t *torrent) handlePieceMessage(pm peer.PieceMessage) {
 	t.closePieceDownloader(pd)
 	pe.StopSnubTimer()
 
+	if piece.Hash == nil {
+		// Peer did not send the hash chain with the first block of the piece in merkle torrent.
+		pe.Logger().Errorf("hash of piece #%d is not received", msg.Index)
+		t.bytesWasted.Inc(int64(len(pd.Buffer.Data)))
+		pd.Buffer.Release()
+		t.closePeer(pe)
+		return
+	}
+
 	if piece.Writing {
 		panic("piece is already writing")
 	}
@@ -113,8 +127,8 @@ func (t *torrent) handlePieceMessage(pm peer.PieceMessage) {
 	t.pieceMessagesC.Suspend()
 	t.webseedPieceResultC.Suspend()
 
-	pw := piecewriter.New(piece, pe, pd.Buffer)
-	go pw.Run(t.pieceWriterResultC, t.doneC, t.session.metrics.WritesPerSecond, t.session.metrics.SpeedWrite, t.session.semWrite)
+	pw := piecewriter.New(piece, pe, pd.Buffer, pd.Blocks())
+	go pw.Run(t.pieceWriterResultC, t.doneC, t.session.metrics.WritesPerSecond, t.session.metrics.SpeedWrite, t.session.metrics.WriteLatency, t.session.semWrite, t.session.config.WriteDurability == durabilityPiece)
 }
 
 func (t *torrent) handlePeerMessage(pm peer.Message) {
@@ -225,7 +239,7 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 		t.startPieceDownloaders()
 	case peerprotocol.InterestedMessage:
 		pe.PeerInterested = true
-		t.unchoker.FastUnchoke(pe)
+		t.fastUnchoke(pe)
 	case peerprotocol.NotInterestedMessage:
 		pe.PeerInterested = false
 	case peerprotocol.RequestMessage:
@@ -253,14 +267,14 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 		if pe.ClientChoking {
 			if pe.FastEnabled {
 				if pe.SentAllowedFast.Has(pi) {
-					pe.SendPiece(msg, cachedpiece.New(pi, t.session.pieceCache, t.session.config.ReadCacheBlockSize, t.peerID))
+					pe.SendPiece(msg, t.pieceReader(pi), t.pieceHashList(msg))
 				} else {
 					m := peerprotocol.RejectMessage{RequestMessage: msg}
 					pe.SendMessage(m)
 				}
 			}
 		} else {
-			pe.SendPiece(msg, cachedpiece.New(pi, t.session.pieceCache, t.session.config.ReadCacheBlockSize, t.peerID))
+			pe.SendPiece(msg, t.pieceReader(pi), t.pieceHashList(msg))
 		}
 	case peerprotocol.RejectMessage:
 		if t.pieces == nil || t.bitfield == nil {
@@ -322,9 +336,10 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 			break
 		}
 		pe.ExtensionHandshake = &msg
+		pe.UploadOnly = msg.UploadOnly != 0
 
-		if len(msg.YourIP) == 4 {
-			t.externalIP = net.IP(msg.YourIP)
+		if len(msg.YourIP) == net.IPv4len || len(msg.YourIP) == net.IPv6len {
+			t.session.externalIP.VotePeer(net.IP(msg.YourIP), pe.Addr().IP)
 		}
 		if _, ok := msg.M[peerprotocol.ExtensionKeyMetadata]; ok {
 			t.startInfoDownloaders()
@@ -336,8 +351,25 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 				}
 			}
 		}
+		if t.texEnabled() {
+			t.sendTEX(pe, t.workingTrackers())
+		}
 	case peerprotocol.ExtensionMetadataMessage:
 		t.handleMetadataMessage(pe, msg)
+	case peerprotocol.HashRequestMessage:
+		t.handleHashRequest(pe, msg)
+	case peerprotocol.HashesMessage:
+		t.handleHashes(pe, msg)
+	case peerprotocol.ExtensionHolepunchMessage:
+		t.handleHolepunchMessage(pe, msg)
+	case peerprotocol.ExtensionTEXMessage:
+		t.handleTEXMessage(pe, msg)
+	case peerprotocol.ExtensionUploadOnlyMessage:
+		pe.UploadOnly = msg.UploadOnly
+	case peerprotocol.ExtensionDontHaveMessage:
+		t.handleDontHave(pe, msg)
+	case peerprotocol.HashRejectMessage:
+		t.handleHashReject(pe, msg)
 	case peerprotocol.ExtensionPEXMessage:
 		if !t.session.config.PEXEnabled {
 			break
@@ -347,6 +379,7 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 			t.log.Error(err)
 			break
 		}
+		t.addHolepunchRelays(pe, addrs)
 		t.handleNewPeers(addrs, peersource.PEX)
 		addrs, err = tracker.DecodePeersCompact([]byte(msg.Dropped))
 		if err != nil {
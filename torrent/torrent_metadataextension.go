@@ -3,10 +3,12 @@ package torrent
 import (
 	"bytes"
 	"crypto/sha1"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 
 	"github.com/cenkalti/rain/internal/bufferpool"
+	"github.com/cenkalti/rain/internal/metainfo"
 	"github.com/cenkalti/rain/internal/peer"
 	"github.com/cenkalti/rain/internal/peerprotocol"
 	"github.com/cenkalti/rain/internal/resumer/boltdbresumer"
@@ -72,9 +74,10 @@ func (t *torrent) handleMetadataMessage(pe *peer.Peer, msg peerprotocol.Extensio
 		}
 		pe.StopSnubTimer()
 
-		hash := sha1.New()
-		_, _ = hash.Write(id.Bytes)
-		if !bytes.Equal(hash.Sum(nil), t.infoHash[:]) {
+		// Info hash of v2 torrents is the truncated SHA-256 hash of info dictionary.
+		hash := sha1.Sum(id.Bytes)
+		hashV2 := sha256.Sum256(id.Bytes)
+		if hash != t.infoHash && !bytes.Equal(hashV2[:20], t.infoHash[:]) {
 			pe.Logger().Errorln("received info does not match with hash")
 			t.closePeer(id.Peer.(*peer.Peer))
 			t.startInfoDownloaders()
@@ -87,27 +90,7 @@ func (t *torrent) handleMetadataMessage(pe *peer.Peer, msg peerprotocol.Extensio
 			t.stop(fmt.Errorf("cannot parse info bytes: %s", err))
 			break
 		}
-		if info.Private {
-			t.stop(errors.New("private torrent from magnet"))
-			break
-		}
-		t.info = info
-		t.piecePool = bufferpool.New(int(info.PieceLength))
-		err = t.session.resumer.WriteInfo(t.id, t.info.Bytes)
-		if err != nil {
-			t.stop(fmt.Errorf("cannot write resume info: %s", err))
-			break
-		}
-		select {
-		case <-t.completeMetadataC:
-		default:
-			close(t.completeMetadataC)
-		}
-		if t.stopAfterMetadata {
-			t.stopAndSetStoppedOnMetadata()
-		} else {
-			t.startAllocator()
-		}
+		t.setInfo(info)
 	case peerprotocol.ExtensionMetadataMessageTypeReject:
 		id, ok := t.infoDownloaders[pe]
 		if ok {
@@ -117,6 +100,40 @@ func (t *torrent) handleMetadataMessage(pe *peer.Peer, msg peerprotocol.Extensio
 	}
 }
 
+// setInfo is called when the info of a torrent added with a magnet link is received.
+func (t *torrent) setInfo(info *metainfo.Info) {
+	if info.Private {
+		t.stop(errors.New("private torrent from magnet"))
+		return
+	}
+	if t.exactLength > 0 && info.Length != t.exactLength {
+		t.stop(fmt.Errorf("torrent length does not match the magnet link: %d != %d", info.Length, t.exactLength))
+		return
+	}
+	t.info = info
+	t.piecePool = bufferpool.New(int(info.PieceLength))
+	t.joinHybridSwarm()
+	err := t.session.resumer.WriteInfo(t.id, t.info.Bytes)
+	if err != nil {
+		t.stop(fmt.Errorf("cannot write resume info: %s", err))
+		return
+	}
+	select {
+	case <-t.completeMetadataC:
+	default:
+		close(t.completeMetadataC)
+	}
+	if t.stopAfterMetadata {
+		t.stopAndSetStoppedOnMetadata()
+	} else if !t.hasDiskSpace() {
+		t.stopForDiskSpace()
+	} else if !t.info.HasPieceLayers() {
+		t.requestPieceLayers()
+	} else {
+		t.startAllocator()
+	}
+}
+
 func (t *torrent) sendMetadataReject(pe *peer.Peer, i uint32, msgID uint8) {
 	dataMsg := peerprotocol.ExtensionMetadataMessage{
 		Type:  peerprotocol.ExtensionMetadataMessageTypeReject,
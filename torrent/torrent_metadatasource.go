@@ -0,0 +1,101 @@
+package torrent
+
+import (
+	"bytes"
+
+	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/cenkalti/rain/internal/tracker"
+)
+
+// startMetadataSourceDownload starts downloading the torrent file from the exact and acceptable sources in the magnet link (BEP 9).
+// Metadata is downloaded from peers at the same time. Whichever arrives first is used.
+func (t *torrent) startMetadataSourceDownload() {
+	if t.info != nil || len(t.metadataSources) == 0 || t.metadataSourceDownloading {
+		return
+	}
+	t.metadataSourceDownloading = true
+	go t.downloadMetadataSources(t.metadataSources, t.infoHash, t.exactLength)
+}
+
+// downloadMetadataSources tries the sources in order and sends the first torrent file that matches the magnet link.
+func (t *torrent) downloadMetadataSources(sources []string, infoHash [20]byte, length int64) {
+	var found *metainfo.MetaInfo
+	for _, src := range sources {
+		mi, err := t.session.downloadMetaInfo(src)
+		if err != nil {
+			t.log.Debugf("cannot download torrent from %s: %s", src, err)
+			continue
+		}
+		// Info hash of v2 torrents is the truncated SHA-256 hash of info dictionary.
+		if mi.Info.Hash != infoHash && !bytes.Equal(mi.Info.HashV2[:20], infoHash[:]) {
+			t.log.Debugf("torrent downloaded from %s does not match the magnet link", src)
+			continue
+		}
+		if length > 0 && mi.Info.Length != length {
+			t.log.Debugf("length of the torrent downloaded from %s does not match the magnet link", src)
+			continue
+		}
+		found = mi
+		break
+	}
+	select {
+	case t.metadataSourceResultC <- found:
+	case <-t.closeC:
+	}
+}
+
+func (t *torrent) handleMetadataSourceResult(mi *metainfo.MetaInfo) {
+	t.metadataSourceDownloading = false
+	if t.info != nil || t.errC == nil {
+		// Metadata is received from peers or the torrent is stopped. Sources are tried again when the torrent is started.
+		return
+	}
+	if mi == nil {
+		if t.session.config.RequireTrustedSignature {
+			t.stop(errUntrustedTorrent)
+		}
+		return
+	}
+	signer := t.session.trustedSigner(mi.Info.Bytes, mi.Signatures)
+	if signer == "" && t.session.config.RequireTrustedSignature {
+		t.stop(errUntrustedTorrent)
+		return
+	}
+	t.stopInfoDownloaders()
+	t.signatures = mi.Signatures
+	t.signer = signer
+	err := t.session.resumer.WriteSignatures(t.id, marshalSignatures(mi.Signatures))
+	if err != nil {
+		t.stop(err)
+		return
+	}
+	if layers := mi.Info.PieceLayersBytes(); layers != nil {
+		err = t.session.resumer.WritePieceLayers(t.id, layers)
+		if err != nil {
+			t.stop(err)
+			return
+		}
+	}
+	t.setInfo(&mi.Info)
+	if t.info == nil {
+		return
+	}
+	// Trackers in the torrent file are added to the trackers in the magnet link.
+	existing := t.trackerURLs()
+	var trackers []tracker.Tracker
+	for _, tier := range mi.AnnounceList {
+		for _, u := range tier {
+			if _, ok := existing[u]; ok {
+				continue
+			}
+			existing[u] = struct{}{}
+			tr, err := t.addTracker(u)
+			if err != nil {
+				t.log.Debugf("cannot add tracker %s: %s", u, err)
+				continue
+			}
+			trackers = append(trackers, tr)
+		}
+	}
+	t.handleNewTrackers(trackers)
+}
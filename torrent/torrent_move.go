@@ -0,0 +1,180 @@
+package torrent
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/cenkalti/rain/internal/allocator"
+	"github.com/cenkalti/rain/internal/mover"
+	"github.com/cenkalti/rain/internal/piece"
+	"github.com/cenkalti/rain/internal/storage/filestorage"
+)
+
+// startMover starts moving the files of a completed torrent to the complete directory.
+// Returns false if the files are already in their final location.
+func (t *torrent) startMover() bool {
+	if t.filesMoved {
+		return false
+	}
+	if t.mover != nil {
+		return true
+	}
+	sto, ok := t.storage.(*filestorage.FileStorage)
+	if !ok || t.info == nil || len(t.info.Files) == 0 {
+		t.filesMoved = true
+		return false
+	}
+	dest := t.session.getCompleteDataDir(t.id, t.label)
+	if dest != "" {
+		var err error
+		dest, err = filepath.Abs(dest)
+		if err != nil {
+			t.log.Errorf("invalid complete directory: %s", err)
+			dest = ""
+		}
+	}
+	if dest == sto.RootDir() {
+		dest = ""
+	}
+	if dest != "" && t.session.sharesDuplicateData() && t.session.dataShared(t) {
+		t.log.Info("not moving files because they are shared with another torrent")
+		dest = ""
+	}
+	if dest == "" && !t.session.config.IncompleteFileSuffix {
+		t.filesMoved = true
+		return false
+	}
+	files := make([]string, 0, len(t.info.Files))
+	for _, f := range t.info.Files {
+		if !f.Padding {
+			files = append(files, f.Path)
+		}
+	}
+	// All files of the torrent are under the same top level entry in storage.
+	name := t.info.Files[0].Path
+	if i := strings.IndexRune(name, filepath.Separator); i >= 0 {
+		name = name[:i]
+	}
+	if dest != "" {
+		t.log.Infof("moving files to %s", dest)
+	}
+	t.mover = mover.New()
+	go t.mover.Run(sto, files, name, dest, t.session.config.DataDirIncludesTorrentID, t.moverResultC)
+	return true
+}
+
+func (t *torrent) stopMover() {
+	t.log.Debugln("stopping mover")
+	if t.mover != nil {
+		t.mover.Close()
+		// Files may have been moved before the mover is closed.
+		if t.mover.Error == nil && t.mover.Storage != nil {
+			_ = t.setMovedStorage(t.mover.Storage)
+		}
+		t.mover = nil
+	}
+}
+
+func (t *torrent) handleMoverDone(mo *mover.Mover) {
+	if t.mover != mo {
+		panic("invalid mover")
+	}
+	t.mover = nil
+
+	if mo.Error != nil {
+		t.stop(fmt.Errorf("cannot move files: %s", mo.Error))
+		return
+	}
+	if mo.Storage != nil {
+		err := t.setMovedStorage(mo.Storage)
+		if err != nil {
+			t.stop(err)
+			return
+		}
+		t.log.Infof("moved files to %s", mo.Storage.RootDir())
+	}
+	t.filesMoved = true
+	// Modification times may be changed if files are copied to another file system.
+	t.writeFileStats()
+	t.runCompleteCmd()
+	if t.stopAfterDownload {
+		t.stopAndSetStoppedOnComplete()
+		return
+	}
+	if mo.Storage != nil {
+		t.reopenFiles()
+	}
+}
+
+// reopenFiles opens the files from the new storage in background after they are moved.
+// Files that are copied to another file system are deleted from the old location,
+// so the open files refer to deleted copies until they are closed.
+// The torrent keeps uploading from the old files until the new files are opened.
+func (t *torrent) reopenFiles() {
+	if t.reopener != nil {
+		panic("reopener exists")
+	}
+	t.reopener = allocator.New(nil, t.session.allocationMode, t.fileSkipper())
+	go t.reopener.Run(t.info, t.storage, nil, t.reopenerResultC)
+}
+
+func (t *torrent) handleReopenDone(al *allocator.Allocator) {
+	if t.reopener != al {
+		panic("invalid reopener")
+	}
+	t.reopener = nil
+
+	if al.Error != nil {
+		t.stop(fmt.Errorf("cannot open moved files: %s", al.Error))
+		return
+	}
+	t.replaceFiles(al.Files)
+}
+
+// replaceFiles replaces the files of the pieces with the files opened by reopenFiles and closes the old files.
+func (t *torrent) replaceFiles(files []allocator.File) {
+	// Syncer uses open files.
+	t.stopSyncer()
+	pieces := piece.NewPieces(t.info, files)
+	for i := range pieces {
+		pieces[i].Done = t.pieces[i].Done
+		pieces[i].Writing = t.pieces[i].Writing
+	}
+	old := t.files
+	t.files = files
+	t.fileCheck = nil
+	// Announcers read the pieces while holding the lock.
+	// Waits for the reads from the old files to finish, so they can be closed safely.
+	t.mFiles.Lock()
+	t.mBitfield.Lock()
+	t.pieces = pieces
+	t.mBitfield.Unlock()
+	t.mFiles.Unlock()
+	if t.piecePicker != nil {
+		t.rebuildPiecePicker()
+	}
+	t.closeFiles(old)
+}
+
+// setMovedStorage replaces the storage of the torrent after files are moved to a new location.
+// Open files must be opened again from the new storage with reopenFiles.
+func (t *torrent) setMovedStorage(sto *filestorage.FileStorage) error {
+	var dataDir string
+	defaultDir, err := filepath.Abs(t.session.getDataDir(t.id))
+	if err != nil {
+		return err
+	}
+	if sto.RootDir() != defaultDir {
+		dataDir = sto.RootDir()
+	}
+	t.mStorage.Lock()
+	t.storage = sto
+	t.dataDir = dataDir
+	t.mStorage.Unlock()
+	err = t.session.resumer.WriteDest(t.id, dataDir)
+	if err != nil {
+		t.log.Errorf("cannot write data directory to resume db: %s", err)
+	}
+	return err
+}
@@ -0,0 +1,95 @@
+package torrent
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMoveCompletedTorrent(t *testing.T) {
+	addr, cl := seeder(t, true)
+	defer cl()
+
+	var incompleteDir, completeDir string
+	s, closeSession := newTestSessionWithConfig(t, func(cfg *Config) {
+		incompleteDir = filepath.Join(cfg.DataDir, "incomplete")
+		completeDir = filepath.Join(cfg.DataDir, "complete")
+		cfg.IncompleteDir = incompleteDir
+		cfg.LabelDirs = map[string]LabelDirs{"foo": {CompleteDir: completeDir}}
+		cfg.IncompleteFileSuffix = true
+	})
+	defer closeSession()
+
+	tor, err := s.AddURI(torrentMagnetLink+"&x.pe="+addr, &AddTorrentOptions{Label: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "foo", tor.Label())
+	assert.Equal(t, filepath.Join(incompleteDir, tor.ID()), tor.RootDirectory())
+
+	select {
+	case <-tor.NotifyComplete():
+	case err = <-tor.NotifyStop():
+		t.Fatal(err)
+	case <-time.After(timeout):
+		t.Fatal("download did not finish")
+	}
+	// Pieces are uploaded while the files are moved and opened again.
+	readErrC := make(chan error, 1)
+	stopReading := make(chan struct{})
+	go func() {
+		r := pieceDataReader{t: tor.torrent, index: 0}
+		buf := make([]byte, 16*1024)
+		for {
+			select {
+			case <-stopReading:
+				readErrC <- nil
+				return
+			default:
+			}
+			if _, err := r.ReadAt(buf, 0); err != nil {
+				readErrC <- err
+				return
+			}
+		}
+	}()
+	dest := filepath.Join(completeDir, tor.ID())
+	for deadline := time.Now().Add(timeout); tor.RootDirectory() != dest; {
+		if time.Now().After(deadline) {
+			t.Fatal("files are not moved")
+		}
+		assert.NotEqual(t, Allocating, tor.Stats().Status)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Files must be in place without the part suffix.
+	cmd := exec.Command("diff", "-rq", filepath.Join(torrentDataDir, torrentName), filepath.Join(dest, torrentName))
+	err = cmd.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = os.Stat(filepath.Join(incompleteDir, tor.ID()))
+	assert.True(t, os.IsNotExist(err))
+
+	// Files are opened again from the new location without switching to Allocating status.
+	for deadline := time.Now().Add(timeout); ; time.Sleep(10 * time.Millisecond) {
+		tor.torrent.mFiles.RLock()
+		f, ok := tor.torrent.pieces[0].Data[0].File.(*os.File)
+		reopened := ok && strings.HasPrefix(f.Name(), dest)
+		tor.torrent.mFiles.RUnlock()
+		if reopened {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("files are not opened again")
+		}
+	}
+	assert.Equal(t, Seeding, tor.Stats().Status)
+	close(stopReading)
+	assert.NoError(t, <-readErrC)
+}
@@ -0,0 +1,27 @@
+package torrent
+
+func (t *torrent) notifyOutgoingAddress() {
+	select {
+	case t.outgoingAddressCommandC <- struct{}{}:
+	case <-t.closeC:
+	}
+}
+
+// handleOutgoingAddressCheck pauses the torrent when the outgoing address of the session goes down
+// and starts it again after the address is back.
+func (t *torrent) handleOutgoingAddressCheck() {
+	if t.session.outgoingDown.Load() {
+		if t.status() == Stopping || t.status() == Stopped {
+			return
+		}
+		t.stop(nil)
+		t.waitingForOutgoingAddress = true
+		return
+	}
+	if !t.waitingForOutgoingAddress || t.status() != Stopped {
+		return
+	}
+	t.log.Info("outgoing address is available, starting torrent")
+	t.waitingForOutgoingAddress = false
+	t.start()
+}
@@ -18,6 +18,9 @@ func (t *torrent) setNeedMorePeers(val bool) {
 	for _, an := range t.announcers {
 		an.NeedMorePeers(val)
 	}
+	for _, an := range t.hybridAnnouncers {
+		an.NeedMorePeers(val)
+	}
 	if t.dhtAnnouncer != nil {
 		t.dhtAnnouncer.NeedMorePeers(val)
 	}
@@ -80,7 +83,7 @@ func (t *torrent) handleNewPeers(addrs []*net.TCPAddr, source peersource.Source)
 func (t *torrent) filterBannedIPs(a []*net.TCPAddr) []*net.TCPAddr {
 	b := a[:0]
 	for _, x := range a {
-		if _, ok := t.bannedPeerIPs[x.IP.String()]; !ok {
+		if !t.bans.Blocked(x.IP) {
 			b = append(b, x)
 		}
 	}
@@ -104,10 +107,19 @@ func (t *torrent) dialAddresses() {
 		if _, ok := t.connectedPeerIPs[ip]; ok {
 			continue
 		}
+		if t.blocked(addr.IP, t.session.config.BlocklistEnabledForOutgoingConnections) {
+			continue
+		}
+		if !t.acquireDialSlot() {
+			// Session limit is reached. Dial again when notified from budgetC.
+			t.addrList.Push([]*net.TCPAddr{addr}, src)
+			return
+		}
 		h := outgoinghandshaker.New(addr, src)
 		t.outgoingHandshakers[h] = struct{}{}
 		t.connectedPeerIPs[ip] = struct{}{}
 		go h.Run(
+			t.session.peerDialer,
 			t.session.config.PeerConnectTimeout,
 			t.session.config.PeerHandshakeTimeout,
 			t.peerID,
@@ -149,6 +161,9 @@ func (t *torrent) startPeer(
 	go pe.Run(t.messages, t.pieceMessagesC.SendC(), t.peerSnubbedC, t.peerDisconnectedC)
 	t.session.metrics.Peers.Inc(1)
 	t.sendFirstMessage(pe)
+	if t.info != nil && !t.info.HasPieceLayers() {
+		t.requestPieceLayersFrom(pe)
+	}
 	t.recentlySeen.Add(pe.Addr())
 }
 
@@ -173,6 +188,16 @@ func (t *torrent) sendFirstMessage(p *peer.Peer) {
 	}
 	if p.ExtensionsEnabled {
 		extHandshakeMsg := peerprotocol.NewExtensionHandshake(metadataSize, t.getClientVersion(), p.Addr().IP, t.session.config.MaxRequestsIn)
+		if t.acceptor != nil || t.acceptingShared {
+			// Incoming peers need our listen port for relaying holepunch messages.
+			extHandshakeMsg.Port = t.port
+		}
+		if t.completed {
+			extHandshakeMsg.UploadOnly = 1
+		}
+		if t.texEnabled() {
+			extHandshakeMsg.TrackersHash = trackersHash(t.workingTrackers())
+		}
 		msg := peerprotocol.ExtensionMessage{
 			ExtendedMessageID: peerprotocol.ExtensionIDHandshake,
 			Payload:           extHandshakeMsg,
@@ -7,20 +7,56 @@ import (
 )
 
 func (t *torrent) writeBitfield() error {
-	err := t.session.resumer.WriteBitfield(t.id, t.bitfield.Bytes())
+	if t.session.syncsOnWrite() {
+		return t.saveBitfield(t.bitfield.Bytes())
+	}
+	// Pieces must not be saved as completed before their data is on disk.
+	// Pieces that are already flushed are saved now, others are saved after the syncer flushes the files.
+	t.mBitfield.RLock()
+	bf := t.durableBitfieldBytes()
+	t.mBitfield.RUnlock()
+	if bf != nil {
+		err := t.saveBitfield(bf)
+		if err != nil {
+			return err
+		}
+	}
+	t.bitfieldWritePending = true
+	t.startSyncer()
+	return nil
+}
+
+// saveBitfield writes bf into the resume db.
+func (t *torrent) saveBitfield(bf []byte) error {
+	// Hashes of completed pieces must be saved for sending them to peers.
+	err := t.writeMerkleTree()
+	if err != nil {
+		return err
+	}
+	err = t.session.resumer.WriteBitfield(t.id, bf)
 	if err != nil {
 		t.log.Errorf("cannot write bitfield to resume db: %s", err)
+		return err
 	}
-	return err
+	t.writeFileStats()
+	return nil
 }
 
 func (t *torrent) checkCompletion() bool {
-	if t.completed {
-		return true
+	if !t.completed {
+		if !t.wantedDone() {
+			return false
+		}
+		t.setCompleted()
 	}
-	if !t.bitfield.All() {
-		return false
+	// Completion command is run after files are moved to their final location.
+	if !t.startMover() {
+		t.runCompleteCmd()
 	}
+	return true
+}
+
+func (t *torrent) setCompleted() {
 	t.completed = true
 	close(t.completeC)
 	for h := range t.outgoingHandshakers {
@@ -33,6 +69,8 @@ func (t *torrent) checkCompletion() bool {
 	for pe := range t.peers {
 		if !pe.PeerInterested {
 			t.closePeer(pe)
+		} else {
+			t.sendUploadOnly(pe)
 		}
 	}
 	t.addrList.Reset()
@@ -42,13 +80,16 @@ func (t *torrent) checkCompletion() bool {
 	}
 	t.piecePicker = nil
 	t.updateSeedDuration(time.Now())
-	if !t.completeCmdRun && len(t.session.config.OnCompleteCmd) > 0 {
-		go t.session.runOnCompleteCmd(t)
-		t.completeCmdRun = true
-		err := t.session.resumer.WriteCompleteCmdRun(t.id)
-		if err != nil {
-			t.stop(err)
-		}
+}
+
+func (t *torrent) runCompleteCmd() {
+	if t.completeCmdRun || len(t.session.config.OnCompleteCmd) == 0 {
+		return
+	}
+	go t.session.runOnCompleteCmd(t)
+	t.completeCmdRun = true
+	err := t.session.resumer.WriteCompleteCmdRun(t.id)
+	if err != nil {
+		t.stop(err)
 	}
-	return true
 }
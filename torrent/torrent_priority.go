@@ -0,0 +1,260 @@
+package torrent
+
+import (
+	"errors"
+	"time"
+
+	"github.com/cenkalti/rain/internal/allocator"
+	"github.com/cenkalti/rain/internal/bitfield"
+	"github.com/cenkalti/rain/internal/piecepicker"
+)
+
+var errInvalidPieceIndex = errors.New("invalid piece index")
+
+// Piece priorities for Torrent.SetPiecePriority.
+const (
+	// PiecePriorityNormal pieces are downloaded in rarest first order.
+	PiecePriorityNormal = piecepicker.PriorityNormal
+	// PiecePriorityHigh pieces are downloaded before normal priority pieces.
+	PiecePriorityHigh = piecepicker.PriorityHigh
+)
+
+type deadlineRequest struct {
+	Index    uint32
+	Deadline time.Time
+	Response chan error
+}
+
+// SetPieceDeadline sets the time that the piece at index is needed.
+// Pieces with deadlines are downloaded before other pieces, earliest deadline first.
+// Zero deadline clears the deadline of the piece.
+func (t *torrent) SetPieceDeadline(index uint32, deadline time.Time) error {
+	req := deadlineRequest{Index: index, Deadline: deadline, Response: make(chan error, 1)}
+	select {
+	case t.deadlineCommandC <- req:
+	case <-t.closeC:
+		return errClosed
+	}
+	select {
+	case err := <-req.Response:
+		return err
+	case <-t.closeC:
+		return errClosed
+	}
+}
+
+func (t *torrent) handleDeadlineCommand(index uint32, deadline time.Time) error {
+	if t.info == nil {
+		return errors.New("torrent metadata is not downloaded yet")
+	}
+	if index >= t.info.NumPieces {
+		return errInvalidPieceIndex
+	}
+	if t.bitfield != nil && t.bitfield.Test(index) {
+		// Piece is already downloaded.
+		return nil
+	}
+	if deadline.IsZero() {
+		delete(t.pieceDeadlines, index)
+	} else {
+		t.pieceDeadlines[index] = deadline
+	}
+	if t.piecePicker != nil {
+		t.piecePicker.SetDeadline(index, deadline)
+		t.startPieceDownloaders()
+	}
+	return nil
+}
+
+type priorityRequest struct {
+	Index    uint32
+	Priority int
+	Response chan error
+}
+
+// SetPiecePriority sets the download priority of the piece at index.
+// Priority must be PiecePriorityNormal or PiecePriorityHigh.
+func (t *torrent) SetPiecePriority(index uint32, priority int) error {
+	req := priorityRequest{Index: index, Priority: priority, Response: make(chan error, 1)}
+	select {
+	case t.priorityCommandC <- req:
+	case <-t.closeC:
+		return errClosed
+	}
+	select {
+	case err := <-req.Response:
+		return err
+	case <-t.closeC:
+		return errClosed
+	}
+}
+
+func (t *torrent) handlePriorityCommand(index uint32, priority int) error {
+	if t.info == nil {
+		return errors.New("torrent metadata is not downloaded yet")
+	}
+	if index >= t.info.NumPieces {
+		return errInvalidPieceIndex
+	}
+	if priority != PiecePriorityNormal && priority != PiecePriorityHigh {
+		return errors.New("invalid piece priority")
+	}
+	if wanted := t.wantedPieces(); wanted != nil && !wanted.Test(index) {
+		return errors.New("piece is not in selected files")
+	}
+	t.piecePriorities[index] = priority
+	if t.piecePicker != nil {
+		t.piecePicker.SetPriority(index, priority)
+		t.startPieceDownloaders()
+	}
+	return nil
+}
+
+// setPiecePriorities sets priorities and deadlines of pieces after the piece picker is created.
+func (t *torrent) setPiecePriorities() {
+	for i, deadline := range t.pieceDeadlines {
+		t.piecePicker.SetDeadline(i, deadline)
+	}
+	t.skipUnselectedPieces()
+	if t.firstLastPieces {
+		t.prioritizeFirstLastPieces()
+	}
+	// Priorities set with SetPiecePriority override the first and last piece priorities.
+	for i, priority := range t.piecePriorities {
+		t.piecePicker.SetPriority(i, priority)
+	}
+}
+
+// prioritizeFirstLastPieces sets high priority to the first and last pieces of the selected files.
+func (t *torrent) prioritizeFirstLastPieces() {
+	var offset int64
+	for i, f := range t.info.Files {
+		begin := offset
+		offset += f.Length
+		if f.Padding || f.Length == 0 || !t.fileSelected(i) {
+			continue
+		}
+		first := uint32(begin / int64(t.info.PieceLength))
+		last := uint32((offset - 1) / int64(t.info.PieceLength))
+		t.piecePicker.SetPriority(first, piecepicker.PriorityHigh)
+		t.piecePicker.SetPriority(last, piecepicker.PriorityHigh)
+	}
+}
+
+// rebuildPiecePicker creates a new piece picker for the current pieces with the pieces that connected peers have.
+func (t *torrent) rebuildPiecePicker() {
+	t.piecePicker = piecepicker.New(t.pieces, t.session.config.EndgameMaxDuplicateDownloads, t.webseedSources)
+	t.setPiecePriorities()
+	for pe := range t.peers {
+		for i := uint32(0); i < pe.Bitfield.Len(); i++ {
+			if pe.Bitfield.Test(i) {
+				t.piecePicker.HandleHave(pe, i)
+			}
+		}
+	}
+}
+
+// fileSelected returns true if the file at index is going to be downloaded.
+func (t *torrent) fileSelected(index int) bool {
+	if t.selectedFiles == nil {
+		return true
+	}
+	for _, i := range t.selectedFiles {
+		if i == index {
+			return true
+		}
+	}
+	return false
+}
+
+// skipUnselectedPieces disables downloading of the pieces that do not contain any part of the selected files.
+// Pieces at the boundaries of selected files are downloaded even if they contain data of other files.
+func (t *torrent) skipUnselectedPieces() {
+	wanted := t.wantedPieces()
+	if wanted == nil {
+		return
+	}
+	for i := uint32(0); i < t.info.NumPieces; i++ {
+		if !wanted.Test(i) {
+			t.piecePicker.SetPriority(i, piecepicker.PriorityNone)
+		}
+	}
+}
+
+// wantedPieces returns the pieces that contain data of the selected files.
+// Returns nil if all files are selected.
+func (t *torrent) wantedPieces() *bitfield.Bitfield {
+	if t.selectedFiles == nil || t.info == nil {
+		return nil
+	}
+	if t.wanted != nil {
+		return t.wanted
+	}
+	t.wanted = bitfield.New(t.info.NumPieces)
+	var offset int64
+	for i, f := range t.info.Files {
+		begin := offset
+		offset += f.Length
+		if f.Padding || f.Length == 0 || !t.fileSelected(i) {
+			continue
+		}
+		first := uint32(begin / int64(t.info.PieceLength))
+		last := uint32((offset - 1) / int64(t.info.PieceLength))
+		for j := first; j <= last; j++ {
+			t.wanted.Set(j)
+		}
+	}
+	return t.wanted
+}
+
+// fileWanted returns true if any piece that contains data of the file at index is going to be downloaded.
+func (t *torrent) fileWanted(index int) bool {
+	wanted := t.wantedPieces()
+	if wanted == nil || t.fileSelected(index) {
+		return true
+	}
+	var begin int64
+	for _, f := range t.info.Files[:index] {
+		begin += f.Length
+	}
+	length := t.info.Files[index].Length
+	if length == 0 {
+		return false
+	}
+	first := uint32(begin / int64(t.info.PieceLength))
+	last := uint32((begin + length - 1) / int64(t.info.PieceLength))
+	for i := first; i <= last; i++ {
+		if wanted.Test(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileSkipper returns a function for the allocator that skips the files that are not going to be downloaded.
+// Returns nil if all files are selected.
+func (t *torrent) fileSkipper() allocator.Skipper {
+	if t.wantedPieces() == nil {
+		return nil
+	}
+	// Allocator runs in another goroutine, so the result is calculated here.
+	skipped := make([]bool, len(t.info.Files))
+	for i := range skipped {
+		skipped[i] = !t.fileWanted(i)
+	}
+	return func(index int) bool { return skipped[index] }
+}
+
+// wantedDone returns true if all pieces of the selected files are downloaded.
+func (t *torrent) wantedDone() bool {
+	wanted := t.wantedPieces()
+	if wanted == nil {
+		return t.bitfield.All()
+	}
+	for i := uint32(0); i < wanted.Len(); i++ {
+		if wanted.Test(i) && !t.bitfield.Test(i) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+package torrent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPieceDeadline(t *testing.T) {
+	_, addr, closeSeeder := loopbackSeeder(t, "127.0.0.2")
+	defer closeSeeder()
+
+	s, closeSession := newTestSessionWithConfig(t, nil)
+	defer closeSession()
+
+	noInfo, err := s.AddURI("magnet:?xt=urn:btih:0000000000000000000000000000000000000001", &AddTorrentOptions{Stopped: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Error(t, noInfo.SetPieceDeadline(0, time.Now()))
+	assert.Error(t, noInfo.SetPiecePriority(0, PiecePriorityHigh))
+
+	tor, err := s.AddURI(torrentMagnetLink+"&x.pe="+addr, &AddTorrentOptions{FirstLastPieces: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, tor, Seeding)
+	numPieces := tor.Stats().Pieces.Total
+	assert.ErrorIs(t, tor.SetPieceDeadline(numPieces, time.Now()), errInvalidPieceIndex)
+	assert.NoError(t, tor.SetPieceDeadline(numPieces-1, time.Now()))
+	assert.NoError(t, tor.SetPieceDeadline(numPieces-1, time.Time{}))
+	assert.ErrorIs(t, tor.SetPiecePriority(numPieces, PiecePriorityHigh), errInvalidPieceIndex)
+	assert.Error(t, tor.SetPiecePriority(0, -1))
+	assert.NoError(t, tor.SetPiecePriority(0, PiecePriorityHigh))
+}
+
+func TestPieceDeadlineRemovedOnDone(t *testing.T) {
+	_, addr, closeSeeder := loopbackSeeder(t, "127.0.0.2")
+	defer closeSeeder()
+
+	s, closeSession := newTestSessionWithConfig(t, nil)
+	defer closeSession()
+
+	tor, err := s.AddURI(torrentMagnetLink+"&x.pe="+addr, &AddTorrentOptions{StopAfterMetadata: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-tor.NotifyMetadata()
+	waitStatus(t, tor, Stopped)
+	assert.NoError(t, tor.SetPieceDeadline(0, time.Now().Add(time.Minute)))
+	assert.NoError(t, tor.Start())
+	waitStatus(t, tor, Seeding)
+	assert.Empty(t, tor.torrent.pieceDeadlines)
+}
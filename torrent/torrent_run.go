@@ -14,20 +14,57 @@ func (t *torrent) run() {
 	t.unchokeTicker = time.NewTicker(10 * time.Second)
 	defer t.unchokeTicker.Stop()
 
+	texTicker := time.NewTicker(texInterval)
+	defer texTicker.Stop()
+
+	var hashRequestTickerC <-chan time.Time
+	if d := t.session.config.RequestTimeout; d > 0 {
+		hashRequestTicker := time.NewTicker(d)
+		defer hashRequestTicker.Stop()
+		hashRequestTickerC = hashRequestTicker.C
+	}
+
+	var fileCheckTickerC <-chan time.Time
+	if d := t.session.config.FileCheckInterval; d > 0 {
+		fileCheckTicker := time.NewTicker(d)
+		defer fileCheckTicker.Stop()
+		fileCheckTickerC = fileCheckTicker.C
+	}
+
+	// Nil channel blocks forever if files are not flushed periodically.
+	var syncTickerC <-chan time.Time
+	if d := t.session.syncInterval(); d > 0 {
+		syncTicker := time.NewTicker(d)
+		defer syncTicker.Stop()
+		syncTickerC = syncTicker.C
+	}
+
 	for {
 		select {
 		case <-t.closeC:
 			t.close()
 			close(t.doneC)
 			return
-		case <-t.startCommandC:
-			t.start()
+		case respC := <-t.startCommandC:
+			respC <- t.handleStartCommand()
 		case <-t.stopCommandC:
 			t.stop(nil)
 		case <-t.announceCommandC:
 			t.setNeedMorePeers(true)
-		case <-t.verifyCommandC:
-			t.handleVerifyCommand()
+		case req := <-t.verifyCommandC:
+			req.Response <- t.handleVerifyCommand(req.Options)
+		case <-t.recheckCommandC:
+			t.handleRecheckCommand()
+		case pi := <-t.readErrorC:
+			t.handleReadError(pi)
+		case <-fileCheckTickerC:
+			t.startFileCheck()
+		case fc := <-t.fileCheckResultC:
+			t.handleFileCheckDone(fc)
+		case <-t.diskSpaceCommandC:
+			t.handleDiskSpaceCheck()
+		case <-t.outgoingAddressCommandC:
+			t.handleOutgoingAddressCheck()
 		case <-t.announcersStoppedC:
 			t.handleStopped()
 		case cmd := <-t.notifyErrorCommandC:
@@ -42,14 +79,30 @@ func (t *torrent) run() {
 			req.Response <- t.getPeers()
 		case req := <-t.webseedsCommandC:
 			req.Response <- t.getWebseeds()
+		case req := <-t.disconnectCommandC:
+			req.Response <- t.handleDisconnectCommand(req.Addr)
+		case req := <-t.deadlineCommandC:
+			req.Response <- t.handleDeadlineCommand(req.Index, req.Deadline)
+		case req := <-t.priorityCommandC:
+			req.Response <- t.handlePriorityCommand(req.Index, req.Priority)
+		case <-t.bansChangedC:
+			t.closeBannedPeers()
 		case p := <-t.allocatorProgressC:
 			t.bytesAllocated = p.AllocatedSize
 		case al := <-t.allocatorResultC:
 			t.handleAllocationDone(al)
+		case al := <-t.reopenerResultC:
+			t.handleReopenDone(al)
 		case p := <-t.verifierProgressC:
 			t.checkedPieces = p.Checked
 		case ve := <-t.verifierResultC:
 			t.handleVerificationDone(ve)
+		case mo := <-t.moverResultC:
+			t.handleMoverDone(mo)
+		case <-syncTickerC:
+			t.startSyncer()
+		case sy := <-t.syncerResultC:
+			t.handleSyncerDone(sy)
 		case data := <-t.ramNotifyC:
 			t.startSinglePieceDownloader(data)
 		case addrs := <-t.addrsFromTrackers:
@@ -68,12 +121,26 @@ func (t *torrent) run() {
 			t.startPieceDownloaderForWebseed(src)
 		case pw := <-t.pieceWriterResultC:
 			t.handlePieceWriteDone(pw)
+		case i := <-t.duplicatePieceDoneC:
+			t.handleDuplicatePieceDone(i)
 		case now := <-t.seedDurationTicker.C:
 			t.updateSeedDuration(now)
 		case pe := <-t.peerSnubbedC:
 			t.handlePeerSnubbed(pe)
 		case <-t.unchokeTicker.C:
-			t.unchoker.TickUnchoke(t.getPeersForUnchoker(), t.completed)
+			t.tickUnchoke()
+		case <-texTicker.C:
+			t.sendTEXToPeers()
+		case <-hashRequestTickerC:
+			t.checkHashRequests()
+		case mi := <-t.metadataSourceResultC:
+			t.handleMetadataSourceResult(mi)
+		case res := <-t.texResultC:
+			t.handleTEXResult(res)
+		case <-t.budgetC:
+			t.dialAddresses()
+		case ih := <-t.sharedHandshakeC:
+			t.handleSharedHandshake(ih)
 		case ih := <-t.incomingHandshakerResultC:
 			t.handleIncomingHandshakeDone(ih)
 		case oh := <-t.outgoingHandshakerResultC:
@@ -85,5 +152,6 @@ func (t *torrent) run() {
 		case pm := <-t.messages:
 			t.handlePeerMessage(pm)
 		}
+		t.updateBudgets()
 	}
 }
@@ -2,10 +2,12 @@ package torrent
 
 import (
 	"net"
+	"runtime"
 
 	"github.com/cenkalti/rain/internal/acceptor"
 	"github.com/cenkalti/rain/internal/allocator"
 	"github.com/cenkalti/rain/internal/announcer"
+	"github.com/cenkalti/rain/internal/bitfield"
 	"github.com/cenkalti/rain/internal/peer"
 	"github.com/cenkalti/rain/internal/piecedownloader"
 	"github.com/cenkalti/rain/internal/piecepicker"
@@ -16,12 +18,41 @@ import (
 	"github.com/rcrowley/go-metrics"
 )
 
+// handleStartCommand starts the torrent and returns an error if it is refused to start.
+func (t *torrent) handleStartCommand() error {
+	t.start()
+	if t.errC == nil && t.lastError == errNotEnoughDiskSpace && !t.waitingForDiskSpace {
+		return errNotEnoughDiskSpace
+	}
+	return nil
+}
+
 func (t *torrent) start() {
 	// Do not start if already started.
 	if t.errC != nil {
 		return
 	}
 
+	t.waitingForOutgoingAddress = false
+	if t.session.outgoingDown.Load() {
+		t.log.Warning("cannot start torrent: outgoing address is not available")
+		t.lastError = nil
+		t.waitingForOutgoingAddress = true
+		return
+	}
+
+	t.waitingForDiskSpace = false
+	if t.info != nil && !t.hasDiskSpace() {
+		t.log.Warning("cannot start torrent: not enough disk space")
+		if t.session.config.DiskSpaceCheck == diskSpaceCheckQueue {
+			t.lastError = nil
+			t.waitingForDiskSpace = true
+		} else {
+			t.lastError = errNotEnoughDiskSpace
+		}
+		return
+	}
+
 	// Stop announcing Stopped event if in "Stopping" state.
 	if t.stoppedEventAnnouncer != nil {
 		t.stoppedEventAnnouncer.Close()
@@ -36,8 +67,13 @@ func (t *torrent) start() {
 	t.uploadSpeed = metrics.NewMeter()
 
 	if t.info != nil {
-		if t.pieces != nil {
-			if t.bitfield != nil {
+		if !t.info.HasPieceLayers() {
+			// Piece layers of v2 torrent are requested from peers after they are connected.
+			t.addFixedPeers()
+			t.startAcceptor()
+			t.startAnnouncers()
+		} else if t.pieces != nil {
+			if t.bitfield != nil && !t.doVerify && t.verifyPieces == nil {
 				t.addFixedPeers()
 				t.startAcceptor()
 				t.startAnnouncers()
@@ -53,6 +89,7 @@ func (t *torrent) start() {
 		t.startAcceptor()
 		t.startAnnouncers()
 		t.startInfoDownloaders()
+		t.startMetadataSourceDownload()
 	}
 }
 
@@ -63,15 +100,23 @@ func (t *torrent) startVerifier() {
 	if len(t.pieces) == 0 {
 		panic("zero length pieces")
 	}
-	t.verifier = verifier.New()
-	go t.verifier.Run(t.pieces, t.verifierProgressC, t.verifierResultC)
+	var bf *bitfield.Bitfield
+	if t.bitfield != nil {
+		bf = t.bitfield.Copy()
+	}
+	workers := t.session.config.VerifyWorkers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	t.verifier = verifier.New(workers, t.session.bucketVerify)
+	go t.verifier.Run(t.pieces, bf, t.verifyPieces, t.verifierProgressC, t.verifierResultC)
 }
 
 func (t *torrent) startAllocator() {
 	if t.allocator != nil {
 		panic("allocator exists")
 	}
-	t.allocator = allocator.New()
+	t.allocator = allocator.New(t.duplicateLinker(), t.session.allocationMode, t.fileSkipper())
 	go t.allocator.Run(t.info, t.storage, t.allocatorProgressC, t.allocatorResultC)
 }
 
@@ -94,25 +139,42 @@ func (t *torrent) startAnnouncers() {
 }
 
 func (t *torrent) startNewAnnouncer(tr tracker.Tracker) {
+	t.announcers = append(t.announcers, t.runAnnouncer(tr, t.announcerFields))
+	if t.hybrid.Load() != nil {
+		t.hybridAnnouncers = append(t.hybridAnnouncers, t.runAnnouncer(tr, t.hybridAnnouncerFields))
+	}
+}
+
+func (t *torrent) runAnnouncer(tr tracker.Tracker, fields func() tracker.Torrent) *announcer.PeriodicalAnnouncer {
 	an := announcer.NewPeriodicalAnnouncer(
 		tr,
 		t.session.config.TrackerNumWant,
 		t.session.config.TrackerMinAnnounceInterval,
-		t.announcerFields,
+		fields,
 		t.completeC,
 		t.addrsFromTrackers,
+		t.session.externalIP,
 		t.log,
 	)
-	t.announcers = append(t.announcers, an)
 	go an.Run()
+	return an
 }
 
 func (t *torrent) startAcceptor() {
 	if t.acceptor != nil {
 		return
 	}
+	if t.session.config.ProxyOnly {
+		// Incoming connections would not go through the proxy.
+		return
+	}
+	if t.sharedPort {
+		t.acceptingShared = true
+		t.portC <- t.port
+		return
+	}
 	ip := net.ParseIP(t.session.config.Host)
-	listener, err := net.ListenTCP("tcp4", &net.TCPAddr{IP: ip, Port: t.port})
+	listener, err := listenPeers(&t.session.config, ip, t.port)
 	if err != nil {
 		t.log.Warningf("cannot listen port %d: %s", t.port, err)
 	} else {
@@ -128,6 +190,10 @@ func (t *torrent) startInfoDownloaders() {
 	if t.info != nil {
 		return
 	}
+	// Signatures are not sent with the metadata. The torrent file must be downloaded from the sources in the magnet link.
+	if t.session.config.RequireTrustedSignature {
+		return
+	}
 	for len(t.infoDownloaders)-len(t.infoDownloadersSnubbed) < t.session.config.ParallelMetadataDownloads {
 		id := t.nextInfoDownload()
 		if id == nil {
@@ -177,7 +243,12 @@ func (t *torrent) startPieceDownloaderForWebseed(src *webseedsource.WebseedSourc
 
 func (t *torrent) startWebseedDownloader(sp *piecepicker.WebseedDownloadSpec) {
 	t.log.Debugf("downloading pieces %d-%d from webseed %s", sp.Begin, sp.End, sp.Source.URL)
-	ud := urldownloader.New(sp.Source.URL, sp.Begin, sp.End, t.session.bucketDownload)
+	var ud *urldownloader.URLDownloader
+	if sp.Source.HTTPSeed {
+		ud = urldownloader.NewHTTPSeed(sp.Source.URL, t.infoHash[:], sp.Begin, sp.End, t.session.bucketDownload)
+	} else {
+		ud = urldownloader.New(sp.Source.URL, sp.Begin, sp.End, t.session.bucketDownload)
+	}
 	for _, src := range t.webseedSources {
 		if src != sp.Source {
 			continue
@@ -97,6 +97,8 @@ type Stats struct {
 	Name string
 	// Is private torrent?
 	Private bool
+	// Name of the signer if the torrent is signed by one of Config.TrustedSigningKeys (BEP 35).
+	Signer string
 	// Number of files.
 	FileCount int
 	// Length of a single piece.
@@ -148,6 +150,7 @@ func (t *torrent) stats() Stats {
 	s.Pieces.Checked = t.checkedPieces
 	s.Speed.Download = int(t.downloadSpeed.Rate1())
 	s.Speed.Upload = int(t.uploadSpeed.Rate1())
+	s.Signer = t.signer
 
 	if t.info != nil {
 		s.Bytes.Total = t.info.Length
@@ -249,6 +252,8 @@ func (t *torrent) getPeers() []Peer {
 			source = SourceIncoming
 		case peersource.Manual:
 			source = SourceManual
+		case peersource.Holepunch:
+			source = SourceHolepunch
 		default:
 			panic("unhandled peer source")
 		}
@@ -20,6 +20,8 @@ const (
 	Seeding
 	// Stopping the torrent. This is the status after Stop() is called. All peers are disconnected and files are closed. A stop event sent to all trackers. After trackers responded the torrent switches into Stopped state.
 	Stopping
+	// Moving the files of a completed torrent to the complete directory. Pieces are still uploaded to peers.
+	Moving
 )
 
 func (s Status) String() string {
@@ -31,6 +33,7 @@ func (s Status) String() string {
 		Downloading:         "Downloading",
 		Seeding:             "Seeding",
 		Stopping:            "Stopping",
+		Moving:              "Moving",
 	}
 	return m[s]
 }
@@ -39,12 +42,14 @@ func (t *torrent) status() Status {
 	switch {
 	case t.errC == nil:
 		return Stopped
-	case t.stoppedEventAnnouncer != nil:
+	case t.stoppedEventAnnouncer != nil || t.finalSyncer != nil:
 		return Stopping
 	case t.allocator != nil:
 		return Allocating
 	case t.verifier != nil:
 		return Verifying
+	case t.mover != nil:
+		return Moving
 	case t.completed:
 		return Seeding
 	case t.info == nil:
@@ -1,6 +1,7 @@
 package torrent
 
 import (
+	"github.com/cenkalti/rain/internal/allocator"
 	"github.com/cenkalti/rain/internal/announcer"
 	"github.com/cenkalti/rain/internal/handshaker/incominghandshaker"
 	"github.com/cenkalti/rain/internal/handshaker/outgoinghandshaker"
@@ -10,11 +11,15 @@ import (
 
 func (t *torrent) handleStopped() {
 	t.stoppedEventAnnouncer = nil
+	// Called again after files are flushed.
+	if t.finalSyncer != nil {
+		return
+	}
 	t.errC <- t.lastError
 	t.errC = nil
 	t.portC = nil
-	if t.doVerify {
-		t.bitfield = nil
+	if t.doVerify || t.verifyPieces != nil {
+		t.resetBitfieldForVerify()
 		t.start()
 	} else {
 		t.log.Info("torrent has stopped")
@@ -38,6 +43,8 @@ func (t *torrent) stopAndSetStoppedOnMetadata() {
 }
 
 func (t *torrent) stop(err error) {
+	t.waitingForDiskSpace = false
+	t.waitingForOutgoingAddress = false
 	s := t.status()
 	if s == Stopping || s == Stopped {
 		return
@@ -54,9 +61,15 @@ func (t *torrent) stop(err error) {
 	t.stopPiecedownloaders()
 	t.stopInfoDownloaders()
 	t.stopWebseedDownloads()
+	// Syncer uses open files.
+	t.stopSyncer()
 
 	if t.bitfield != nil {
-		_ = t.writeBitfield()
+		if t.session.syncsOnWrite() {
+			_ = t.writeBitfield()
+		} else {
+			t.startFinalSyncer()
+		}
 	}
 
 	// Stop periodical announcers first. We'll create another announcer for announcing Stopped event.
@@ -70,11 +83,18 @@ func (t *torrent) stop(err error) {
 	t.closeData()
 	// Data must be closed before closing Allocator.
 	t.stopAllocator()
+	t.stopReopener()
 	// Data must be closed before closing Verifier.
 	t.stopVerifier()
+	// Pieces selected for verification are kept only if the torrent is going to be started again for verification.
+	if !t.doVerify {
+		t.verifyPieces = nil
+	}
+	t.stopMover()
 
 	t.stopOutgoingHandshakers()
 	t.stopIncomingHandshakers()
+	t.removeBudgets()
 
 	t.resetSpeeds()
 
@@ -105,6 +125,18 @@ func (t *torrent) stopAllocator() {
 	}
 }
 
+func (t *torrent) stopReopener() {
+	t.log.Debugln("stopping reopener")
+	if t.reopener != nil {
+		t.reopener.Close()
+		// Files are closed by the allocator only if there is an error.
+		if t.reopener.Error == nil {
+			t.closeFiles(t.reopener.Files)
+		}
+		t.reopener = nil
+	}
+}
+
 func (t *torrent) stopVerifier() {
 	t.log.Debugln("stopping verifier")
 	if t.verifier != nil {
@@ -143,26 +175,40 @@ func (t *torrent) stopIncomingHandshakers() {
 }
 
 func (t *torrent) closeData() {
-	t.log.Debugln("closing open files")
-	for _, f := range t.files {
-		err := f.Storage.Close()
-		if err != nil {
-			t.log.Error(err)
-		}
+	// Files that are being flushed are closed after the final syncer is done.
+	if t.finalSyncer == nil {
+		t.closeFiles(t.files)
 	}
 	t.files = nil
+	t.fileCheck = nil
+	t.mFiles.Lock()
 	t.pieces = nil
+	t.mFiles.Unlock()
 	t.piecePicker = nil
 	t.bytesAllocated = 0
 	t.checkedPieces = 0
 }
 
+func (t *torrent) closeFiles(files []allocator.File) {
+	t.log.Debugln("closing open files")
+	for _, f := range files {
+		err := f.Storage.Close()
+		if err != nil {
+			t.log.Error(err)
+		}
+	}
+}
+
 func (t *torrent) stopPeriodicalAnnouncers() {
 	t.log.Debugln("stopping announcers")
 	for _, an := range t.announcers {
 		an.Close()
 	}
 	t.announcers = nil
+	for _, an := range t.hybridAnnouncers {
+		an.Close()
+	}
+	t.hybridAnnouncers = nil
 	if t.dhtAnnouncer != nil {
 		t.dhtAnnouncer.Close()
 		t.dhtAnnouncer = nil
@@ -171,6 +217,7 @@ func (t *torrent) stopPeriodicalAnnouncers() {
 
 func (t *torrent) stopAcceptor() {
 	t.log.Debugln("stopping acceptor")
+	t.acceptingShared = false
 	if t.acceptor != nil {
 		t.acceptor.Close()
 	}
@@ -1,16 +1,21 @@
 package torrent
 
 import (
+	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/cenkalti/rain/internal/logger"
+	"github.com/cenkalti/rain/internal/metainfo"
 	"github.com/cenkalti/rain/internal/webseedsource"
 	fhttp "github.com/chihaya/chihaya/frontend/http"
 	"github.com/chihaya/chihaya/middleware"
@@ -35,6 +40,10 @@ func init() {
 }
 
 func newTestSession(t *testing.T) (*Session, func()) {
+	return newTestSessionWithConfig(t, nil)
+}
+
+func newTestSessionWithConfig(t *testing.T, modify func(cfg *Config)) (*Session, func()) {
 	tmp, closeTmp := tempdir(t)
 	cfg := DefaultConfig
 	cfg.Database = filepath.Join(tmp, "session.db")
@@ -43,6 +52,9 @@ func newTestSession(t *testing.T) (*Session, func()) {
 	cfg.PEXEnabled = false
 	cfg.RPCEnabled = false
 	cfg.Host = "127.0.0.1"
+	if modify != nil {
+		modify(&cfg)
+	}
 	s, err := NewSession(cfg)
 	if err != nil {
 		t.Fatal(err)
@@ -279,6 +291,62 @@ func TestDownloadWebseed(t *testing.T) {
 	assertCompleted(t, tor)
 }
 
+func TestDownloadHTTPSeed(t *testing.T) {
+	f, err := os.Open(torrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	mi, err := metainfo.New(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data []byte
+	for _, file := range mi.Info.Files {
+		b, err := os.ReadFile(filepath.Join(torrentDataDir, file.Path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, b...)
+	}
+	var busy int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("info_hash") != string(mi.Info.Hash[:]) {
+			http.Error(w, "invalid info hash", http.StatusNotFound)
+			return
+		}
+		// Client must wait before sending the next request.
+		if atomic.AddInt32(&busy, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("1"))
+			return
+		}
+		index, _ := strconv.ParseInt(q.Get("piece"), 10, 64)
+		var begin, end int64
+		fmt.Sscanf(q.Get("ranges"), "%d-%d", &begin, &end)
+		offset := index * int64(mi.Info.PieceLength)
+		w.Write(data[offset+begin : min(offset+end+1, int64(len(data)))])
+	}))
+	defer srv.Close()
+
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tor, err := s.AddTorrent(f, &AddTorrentOptions{Stopped: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tor.torrent.webseedSources = webseedsource.NewHTTPSeedList([]string{srv.URL + "/seed"})
+	tor.torrent.trackers = nil
+	tor.Start()
+
+	assertCompleted(t, tor)
+}
+
 func assertCompleted(t *testing.T, tor *Torrent) {
 	t2 := tor.torrent
 	select {
@@ -0,0 +1,240 @@
+package torrent
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/rain/internal/announcer"
+	"github.com/cenkalti/rain/internal/peer"
+	"github.com/cenkalti/rain/internal/peerprotocol"
+	"github.com/cenkalti/rain/internal/tracker"
+)
+
+// Tracker exchange messages are sent to and accepted from a peer at most once in this interval.
+const texInterval = time.Minute
+
+// Max number of trackers that are checked from a single tracker exchange message.
+const texMaxTrackersPerMessage = 10
+
+type texResult struct {
+	URL string
+	Err error
+}
+
+// texEnabled returns true if trackers can be exchanged with peers.
+// Trackers of private torrents must not be shared, so the info must be known.
+func (t *torrent) texEnabled() bool {
+	return t.session.config.TEXEnabled && t.info != nil && !t.info.Private
+}
+
+// workingTrackers returns the sorted URLs of trackers that have responded to the last announce.
+func (t *torrent) workingTrackers() []string {
+	var urls []string
+	for _, an := range t.announcers {
+		if an.Stats().Status != announcer.Working {
+			continue
+		}
+		urls = append(urls, an.Tracker.URL())
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// trackersHash is sent in extension handshake so the peer can skip sending the trackers that we already know.
+func trackersHash(urls []string) string {
+	if len(urls) == 0 {
+		return ""
+	}
+	sum := sha1.Sum([]byte(strings.Join(urls, "")))
+	return string(sum[:])
+}
+
+func (t *torrent) sendTEXToPeers() {
+	if !t.texEnabled() || len(t.peers) == 0 {
+		return
+	}
+	urls := t.workingTrackers()
+	for pe := range t.peers {
+		t.sendTEX(pe, urls)
+	}
+}
+
+// sendTEX sends the trackers that are not sent to the peer before.
+func (t *torrent) sendTEX(pe *peer.Peer, urls []string) {
+	if !supportsExtension(pe, peerprotocol.ExtensionKeyTEX) {
+		return
+	}
+	if pe.TEXSent == nil {
+		pe.TEXSent = make(map[string]struct{})
+		// Peer has the same trackers with us.
+		if pe.ExtensionHandshake.TrackersHash != "" && pe.ExtensionHandshake.TrackersHash == trackersHash(urls) {
+			for _, u := range urls {
+				pe.TEXSent[u] = struct{}{}
+			}
+			return
+		}
+	}
+	var added []string
+	for _, u := range urls {
+		if _, ok := pe.TEXSent[u]; ok {
+			continue
+		}
+		pe.TEXSent[u] = struct{}{}
+		added = append(added, u)
+	}
+	if len(added) == 0 {
+		return
+	}
+	pe.SendMessage(peerprotocol.ExtensionMessage{
+		ExtendedMessageID: pe.ExtensionHandshake.M[peerprotocol.ExtensionKeyTEX],
+		Payload:           peerprotocol.ExtensionTEXMessage{Added: added},
+	})
+}
+
+func (t *torrent) handleTEXMessage(pe *peer.Peer, msg peerprotocol.ExtensionTEXMessage) {
+	// Save messages until we know if the torrent is private.
+	if t.info == nil {
+		pe.Messages = append(pe.Messages, msg)
+		return
+	}
+	if !t.texEnabled() {
+		return
+	}
+	now := time.Now()
+	if now.Sub(pe.TEXReceivedAt) < texInterval {
+		pe.Logger().Debugln("tracker exchange message is received too often")
+		return
+	}
+	pe.TEXReceivedAt = now
+	known := t.trackerURLs()
+	for i, u := range msg.Added {
+		if i >= texMaxTrackersPerMessage || t.texAdded+len(t.texPending) >= t.session.config.TEXMaxTrackers {
+			break
+		}
+		if !validTrackerURL(u, t.session.config.TEXAllowLocalTrackers) {
+			continue
+		}
+		if _, ok := known[u]; ok {
+			continue
+		}
+		if _, ok := t.texPending[u]; ok {
+			continue
+		}
+		t.texPending[u] = struct{}{}
+		go t.checkTracker(u)
+	}
+}
+
+// trackerURLs returns the URLs of all trackers of the torrent, including the trackers in tiers.
+func (t *torrent) trackerURLs() map[string]struct{} {
+	m := make(map[string]struct{})
+	for _, tr := range t.trackers {
+		if tier, ok := tr.(*tracker.Tier); ok {
+			for _, tr2 := range tier.Trackers {
+				m[tr2.URL()] = struct{}{}
+			}
+			continue
+		}
+		m[tr.URL()] = struct{}{}
+	}
+	return m
+}
+
+// validTrackerURL returns true if the tracker received from a peer can be checked.
+// Hosts given as local IP addresses are rejected unless allowLocal is true.
+func validTrackerURL(s string, allowLocal bool) bool {
+	u, err := url.Parse(s)
+	if err != nil || u.Hostname() == "" {
+		return false
+	}
+	switch u.Scheme {
+	case "http", "https", "udp":
+	default:
+		return false
+	}
+	if allowLocal {
+		return true
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil {
+		return !localIP(ip)
+	}
+	return true
+}
+
+// localIP returns true if ip is not reachable from the internet.
+func localIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast()
+}
+
+// checkTrackerHost returns an error if the host of the tracker resolves to a local IP address.
+func (t *torrent) checkTrackerHost(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), t.session.config.DNSResolveTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if localIP(addr.IP) {
+			return fmt.Errorf("tracker host resolves to local address: %s", addr.IP)
+		}
+	}
+	return nil
+}
+
+// checkTracker makes an announce to the tracker and sends the result to the torrent loop.
+// Trackers received from peers are not added unless they respond.
+func (t *torrent) checkTracker(uri string) {
+	res := &texResult{URL: uri}
+	var err error
+	// Hosts are not resolved locally when connections go through a proxy or an interface,
+	// otherwise DNS queries for the hosts received from peers would be sent outside of them.
+	cfg := &t.session.config
+	if !cfg.TEXAllowLocalTrackers && cfg.ProxyURL == "" && cfg.OutgoingInterface == "" {
+		err = t.checkTrackerHost(uri)
+	}
+	var tr tracker.Tracker
+	if err == nil {
+		tr, err = t.session.trackerManager.Get(uri, t.session.config.TrackerHTTPTimeout, t.session.getTrackerUserAgent(false), int64(t.session.config.TrackerHTTPMaxResponseSize))
+	}
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), t.session.config.TrackerHTTPTimeout)
+		_, err = tr.Announce(ctx, tracker.AnnounceRequest{Torrent: t.announcerFields(), Event: tracker.EventNone})
+		cancel()
+	}
+	res.Err = err
+	select {
+	case t.texResultC <- res:
+	case <-t.closeC:
+	}
+}
+
+func (t *torrent) handleTEXResult(res *texResult) {
+	delete(t.texPending, res.URL)
+	if res.Err != nil {
+		t.log.Debugf("tracker from tracker exchange is not working: %s: %s", res.URL, res.Err)
+		return
+	}
+	if _, ok := t.trackerURLs()[res.URL]; ok {
+		return
+	}
+	// Trackers from peers are not saved to the resume db, so TEXMaxTrackers limits them for the lifetime of the session.
+	tr, err := t.newTracker(res.URL)
+	if err != nil {
+		t.log.Errorf("cannot add tracker %s: %s", res.URL, err)
+		return
+	}
+	t.log.Infof("tracker added from tracker exchange: %s", res.URL)
+	t.texAdded++
+	t.handleNewTrackers([]tracker.Tracker{tr})
+}
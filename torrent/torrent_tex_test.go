@@ -0,0 +1,68 @@
+package torrent
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTEX(t *testing.T) {
+	defer startHTTPTracker(t)()
+
+	s1, closeSession1 := newTestSession(t)
+	defer closeSession1()
+	seed := addSeedingTorrent(t, s1)
+	trackerURL := "http://127.0.0.1:5000/announce"
+	for deadline := time.Now().Add(timeout); ; time.Sleep(10 * time.Millisecond) {
+		trackers := seed.Trackers()
+		if len(trackers) == 1 && trackers[0].Status == Working {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("tracker is not working")
+		}
+	}
+
+	s2, closeSession2 := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.TEXAllowLocalTrackers = true
+	})
+	defer closeSession2()
+	addr := "127.0.0.1:" + strconv.Itoa(seed.Port())
+	tor, err := s2.AddURI(torrentMagnetLink+"&x.pe="+addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(timeout); ; time.Sleep(10 * time.Millisecond) {
+		trackers := tor.Trackers()
+		if len(trackers) == 1 && trackers[0].URL == trackerURL {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("tracker is not added from tracker exchange")
+		}
+	}
+	// Trackers from peers are not saved to the resume db.
+	spec, err := s2.resumer.Read(tor.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, spec.Trackers)
+}
+
+func TestTEXLocalTracker(t *testing.T) {
+	assert.True(t, validTrackerURL("http://tracker.example.com/announce", false))
+	assert.True(t, validTrackerURL("udp://1.2.3.4:80", false))
+	assert.False(t, validTrackerURL("ftp://tracker.example.com/announce", false))
+	assert.False(t, validTrackerURL("http://127.0.0.1:5000/announce", false))
+	assert.False(t, validTrackerURL("http://192.168.1.1/announce", false))
+	assert.False(t, validTrackerURL("udp://169.254.169.254:80", false))
+	assert.False(t, validTrackerURL("http://[::1]/announce", false))
+	assert.True(t, validTrackerURL("http://127.0.0.1:5000/announce", true))
+
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+	tor := addSeedingTorrent(t, s)
+	assert.Error(t, tor.torrent.checkTrackerHost("http://localhost:5000/announce"))
+}
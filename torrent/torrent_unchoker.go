@@ -0,0 +1,40 @@
+package torrent
+
+import (
+	"math"
+
+	"github.com/cenkalti/rain/internal/unchoker"
+)
+
+// validateChokingAlgorithm returns an error if name is not a known choking algorithm.
+// Empty name is valid for torrents because the algorithm in Config is used.
+func validateChokingAlgorithm(name string) error {
+	if name == "" {
+		return nil
+	}
+	_, err := unchoker.NewAlgorithm(name, 0, 0)
+	return err
+}
+
+// newUnchoker returns the choking algorithm of the torrent.
+// Limits are zero until upload slots are reserved from the session.
+func (t *torrent) newUnchoker() (unchoker.Algorithm, error) {
+	name := t.chokingAlgorithm
+	if name == "" {
+		name = t.session.config.ChokingAlgorithm
+	}
+	return unchoker.NewAlgorithm(name, 0, 0)
+}
+
+func (t *torrent) rateBasedChoking() bool {
+	_, ok := t.unchoker.(*unchoker.RateBased)
+	return ok
+}
+
+func (t *torrent) maxUploadSlots() int {
+	if t.rateBasedChoking() {
+		// Slots are limited by upload capacity and Config.MaxUploadSlots.
+		return math.MaxInt32
+	}
+	return t.session.config.UnchokedPeers + t.session.config.OptimisticUnchokedPeers
+}
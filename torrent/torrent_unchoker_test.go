@@ -0,0 +1,37 @@
+package torrent
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cenkalti/rain/internal/unchoker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChokingAlgorithm(t *testing.T) {
+	s, closeSession := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.ChokingAlgorithm = unchoker.AlgorithmRoundRobin
+	})
+	defer closeSession()
+
+	tor, err := s.AddURI(torrentMagnetLink, &AddTorrentOptions{Stopped: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.IsType(t, &unchoker.RoundRobin{}, tor.torrent.unchoker)
+
+	tor, err = s.AddURI("magnet:?xt=urn:btih:0000000000000000000000000000000000000001", &AddTorrentOptions{Stopped: true, ChokingAlgorithm: unchoker.AlgorithmRateBased})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.IsType(t, &unchoker.RateBased{}, tor.torrent.unchoker)
+
+	_, err = s.AddURI(torrentMagnetLink, &AddTorrentOptions{ChokingAlgorithm: "foo"})
+	var e *InputError
+	assert.True(t, errors.As(err, &e))
+
+	cfg := DefaultConfig
+	cfg.ChokingAlgorithm = "foo"
+	_, err = NewSession(cfg)
+	assert.Error(t, err)
+}
@@ -0,0 +1,120 @@
+package torrent
+
+import (
+	"time"
+
+	"github.com/cenkalti/rain/internal/peer"
+	"github.com/cenkalti/rain/internal/peerprotocol"
+	"github.com/cenkalti/rain/internal/piece"
+)
+
+func supportsExtension(pe *peer.Peer, key string) bool {
+	if pe.ExtensionHandshake == nil {
+		return false
+	}
+	_, ok := pe.ExtensionHandshake.M[key]
+	return ok
+}
+
+// sendUploadOnly tells the peer whether we are interested in downloading more pieces.
+func (t *torrent) sendUploadOnly(pe *peer.Peer) {
+	if !supportsExtension(pe, peerprotocol.ExtensionKeyUploadOnly) {
+		return
+	}
+	pe.SendMessage(peerprotocol.ExtensionMessage{
+		ExtendedMessageID: pe.ExtensionHandshake.M[peerprotocol.ExtensionKeyUploadOnly],
+		Payload:           peerprotocol.ExtensionUploadOnlyMessage{UploadOnly: t.completed},
+	})
+}
+
+// withdrawPieces marks the pieces as missing after their data is lost and tells peers that we don't have them anymore.
+// Peers that do not support lt_donthave extension get a reject message if they request the pieces.
+func (t *torrent) withdrawPieces(indexes []uint32) {
+	var withdrawn []uint32
+	t.mBitfield.Lock()
+	for _, i := range indexes {
+		pi := &t.pieces[i]
+		if !pi.Done {
+			continue
+		}
+		pi.Done = false
+		t.bitfield.Clear(i)
+		withdrawn = append(withdrawn, i)
+	}
+	t.mBitfield.Unlock()
+	if len(withdrawn) == 0 {
+		return
+	}
+	err := t.writeBitfield()
+	if err != nil {
+		t.stop(err)
+		return
+	}
+	if t.completed {
+		t.setIncomplete()
+	}
+	for pe := range t.peers {
+		if supportsExtension(pe, peerprotocol.ExtensionKeyDontHave) {
+			for _, i := range withdrawn {
+				pe.SendMessage(peerprotocol.ExtensionMessage{
+					ExtendedMessageID: pe.ExtensionHandshake.M[peerprotocol.ExtensionKeyDontHave],
+					Payload:           peerprotocol.ExtensionDontHaveMessage{Index: i},
+				})
+			}
+		}
+		t.sendUploadOnly(pe)
+		t.updateInterestedState(pe)
+	}
+	t.startPieceDownloaders()
+}
+
+// setIncomplete switches a completed torrent back to downloading after some of its pieces are lost.
+func (t *torrent) setIncomplete() {
+	t.updateSeedDuration(time.Now())
+	t.completed = false
+	t.completeC = make(chan struct{})
+	t.rebuildPiecePicker()
+}
+
+// handleReadError is called when a piece cannot be read for uploading to a peer.
+// The piece is downloaded again and files are checked in background for finding other lost pieces.
+func (t *torrent) handleReadError(pi *piece.Piece) {
+	// Readers of old pieces fail after the files are closed for opening them again.
+	if len(t.pieces) == 0 || &t.pieces[pi.Index] != pi {
+		return
+	}
+	switch t.status() {
+	case Downloading, Seeding:
+		if t.pieces[pi.Index].Done {
+			t.log.Warningf("cannot read piece #%d, it will be downloaded again", pi.Index)
+		}
+		t.withdrawPieces([]uint32{pi.Index})
+		t.startFileCheck()
+	}
+}
+
+func (t *torrent) handleDontHave(pe *peer.Peer, msg peerprotocol.ExtensionDontHaveMessage) {
+	// Save messages for processing later received while we don't have info yet.
+	if t.pieces == nil || t.bitfield == nil {
+		pe.Messages = append(pe.Messages, msg)
+		return
+	}
+	if msg.Index >= t.info.NumPieces {
+		pe.Logger().Errorln("unexpected dont have index:", msg.Index)
+		t.closePeer(pe)
+		return
+	}
+	if t.piecePicker != nil {
+		t.piecePicker.HandleDontHave(pe, msg.Index)
+	} else {
+		pe.Bitfield.Clear(msg.Index)
+	}
+	// Piece is not going to be sent by the peer.
+	if pd, ok := t.pieceDownloaders[pe]; ok && pd.Piece.Index == msg.Index {
+		t.closePieceDownloader(pd)
+		pd.CancelPending()
+		pe.StopSnubTimer()
+		t.startPieceDownloaderFor(pe)
+	}
+	t.updateInterestedState(pe)
+}
@@ -0,0 +1,48 @@
+package torrent
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cenkalti/rain/internal/peerprotocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDontHaveMessage(t *testing.T) {
+	var buf bytes.Buffer
+	msg := peerprotocol.ExtensionMessage{
+		ExtendedMessageID: peerprotocol.ExtensionIDDontHave,
+		Payload:           peerprotocol.ExtensionDontHaveMessage{Index: 258},
+	}
+	_, err := msg.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []byte{peerprotocol.ExtensionIDDontHave, 0, 0, 1, 2}, buf.Bytes())
+	var msg2 peerprotocol.ExtensionMessage
+	err = msg2.UnmarshalBinary(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, msg.Payload, msg2.Payload)
+	assert.Error(t, msg2.UnmarshalBinary(buf.Bytes()[:3]))
+}
+
+func TestUploadOnlyHandshake(t *testing.T) {
+	var buf bytes.Buffer
+	hs := peerprotocol.NewExtensionHandshake(0, "test", nil, 1)
+	hs.UploadOnly = 1
+	_, err := peerprotocol.ExtensionMessage{ExtendedMessageID: peerprotocol.ExtensionIDHandshake, Payload: hs}.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg peerprotocol.ExtensionMessage
+	err = msg.UnmarshalBinary(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs2 := msg.Payload.(peerprotocol.ExtensionHandshakeMessage)
+	assert.Equal(t, 1, hs2.UploadOnly)
+	assert.Equal(t, uint8(peerprotocol.ExtensionIDUploadOnly), hs2.M[peerprotocol.ExtensionKeyUploadOnly])
+	assert.Equal(t, uint8(peerprotocol.ExtensionIDDontHave), hs2.M[peerprotocol.ExtensionKeyDontHave])
+}
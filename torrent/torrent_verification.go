@@ -1,21 +1,213 @@
 package torrent
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
 
+	"github.com/cenkalti/rain/internal/allocator"
+	"github.com/cenkalti/rain/internal/bitfield"
 	"github.com/cenkalti/rain/internal/peerprotocol"
+	"github.com/cenkalti/rain/internal/resumer/boltdbresumer"
+	"github.com/cenkalti/rain/internal/storage"
 	"github.com/cenkalti/rain/internal/verifier"
 )
 
-func (t *torrent) handleVerifyCommand() {
+func (t *torrent) handleVerifyCommand(opts *VerifyOptions) error {
+	var check *bitfield.Bitfield
+	if !opts.all() {
+		var err error
+		check, err = t.selectPieces(opts)
+		if err != nil {
+			return err
+		}
+	}
 	t.log.Info("verifying")
 	t.doVerify = true
+	t.verifyPieces = check
 	if t.status() == Stopped {
-		t.bitfield = nil
+		t.resetBitfieldForVerify()
 		t.start()
 	} else {
 		t.stop(nil)
 	}
+	return nil
+}
+
+// handleRecheckCommand verifies the pieces that are missing in bitfield, then continues running the torrent.
+// Pieces that are still missing after verification are downloaded again.
+func (t *torrent) handleRecheckCommand() {
+	var check *bitfield.Bitfield
+	if t.info != nil {
+		check = bitfield.New(t.info.NumPieces)
+		for i := uint32(0); i < check.Len(); i++ {
+			if t.bitfield == nil || !t.bitfield.Test(i) {
+				check.Set(i)
+			}
+		}
+		if check.Count() == 0 {
+			check = nil
+		}
+	}
+	t.log.Info("rechecking missing pieces")
+	t.doVerify = false
+	if t.status() != Stopped {
+		if check == nil {
+			return
+		}
+		// Torrent is started again in handleStopped.
+		t.stop(nil)
+		t.verifyPieces = check
+		return
+	}
+	t.verifyPieces = check
+	t.start()
+}
+
+// resetBitfieldForVerify clears the bitfield if all pieces are going to be verified.
+func (t *torrent) resetBitfieldForVerify() {
+	if t.verifyPieces == nil {
+		t.mBitfield.Lock()
+		t.bitfield = nil
+		t.mBitfield.Unlock()
+	}
+}
+
+// selectPieces returns the pieces selected by opts.
+func (t *torrent) selectPieces(opts *VerifyOptions) (*bitfield.Bitfield, error) {
+	if t.info == nil {
+		return nil, errors.New("torrent metadata is not downloaded yet")
+	}
+	check := bitfield.New(t.info.NumPieces)
+	for _, r := range opts.Pieces {
+		if r.Begin >= r.End || r.End > t.info.NumPieces {
+			return nil, fmt.Errorf("invalid piece range: [%d, %d)", r.Begin, r.End)
+		}
+		for i := r.Begin; i < r.End; i++ {
+			check.Set(i)
+		}
+	}
+	for _, name := range opts.Files {
+		var offset int64
+		found := false
+		for _, f := range t.info.Files {
+			if f.Path == name && !f.Padding {
+				found = true
+				if f.Length > 0 {
+					begin := uint32(offset / int64(t.info.PieceLength))
+					end := uint32((offset + f.Length - 1) / int64(t.info.PieceLength))
+					for i := begin; i <= end; i++ {
+						check.Set(i)
+					}
+				}
+				break
+			}
+			offset += f.Length
+		}
+		if !found {
+			return nil, fmt.Errorf("file not found in torrent: %s", name)
+		}
+	}
+	return check, nil
+}
+
+// changedPieces returns the pieces of files that are changed after the bitfield is saved last time.
+// Returns false if there are no changed files or the check is disabled.
+// Returned bitfield is nil if all pieces need to be checked.
+func (t *torrent) changedPieces() (*bitfield.Bitfield, bool) {
+	if !t.session.config.QuickCheck || t.fileStats == nil {
+		return nil, false
+	}
+	stats, err := t.currentFileStats(t.files)
+	if err != nil {
+		t.log.Warningf("cannot get file stats: %s", err)
+		return nil, true
+	}
+	if stats == nil {
+		return nil, false
+	}
+	if len(stats) != len(t.fileStats) {
+		return nil, true
+	}
+	changed := make(map[string]struct{})
+	for i, f := range t.files {
+		if !f.Padding && stats[i] != t.fileStats[i] {
+			changed[f.Name] = struct{}{}
+		}
+	}
+	if len(changed) == 0 {
+		return nil, false
+	}
+	check := bitfield.New(t.info.NumPieces)
+	for _, p := range t.pieces {
+		for _, sec := range p.Data {
+			if _, ok := changed[sec.Name]; ok {
+				check.Set(p.Index)
+				break
+			}
+		}
+	}
+	return check, true
+}
+
+// currentFileStats returns the sizes and modification times of files on disk.
+// Size of a file is -1 if it does not exist.
+// Returns nil if the storage does not support getting file stats.
+func (t *torrent) currentFileStats(files []allocator.File) ([]boltdbresumer.FileStat, error) {
+	return fileStats(t.storage, files)
+}
+
+func fileStats(s storage.Storage, files []allocator.File) ([]boltdbresumer.FileStat, error) {
+	sto, ok := s.(interface {
+		Stat(name string) (fs.FileInfo, error)
+	})
+	if !ok {
+		return nil, nil
+	}
+	stats := make([]boltdbresumer.FileStat, len(files))
+	for i, f := range files {
+		if f.Padding {
+			continue
+		}
+		fi, err := sto.Stat(f.Name)
+		if os.IsNotExist(err) {
+			stats[i].Size = -1
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		stats[i] = boltdbresumer.FileStat{Size: fi.Size(), ModTime: fi.ModTime().UnixNano()}
+	}
+	return stats, nil
+}
+
+// writeFileStats saves the current state of files into resume db.
+// Must be called after the bitfield is saved.
+func (t *torrent) writeFileStats() {
+	// Stats are written after the files are moved.
+	if t.files == nil || t.mover != nil {
+		return
+	}
+	t.saveFileStats(t.files)
+}
+
+func (t *torrent) saveFileStats(files []allocator.File) {
+	stats, err := t.currentFileStats(files)
+	if err != nil {
+		t.log.Errorf("cannot get file stats: %s", err)
+		return
+	}
+	if stats == nil {
+		return
+	}
+	err = t.session.resumer.WriteFileStats(t.id, stats)
+	if err != nil {
+		t.log.Errorf("cannot write file stats to resume db: %s", err)
+		return
+	}
+	t.fileStats = stats
 }
 
 func (t *torrent) handleVerificationDone(ve *verifier.Verifier) {
@@ -23,12 +215,15 @@ func (t *torrent) handleVerificationDone(ve *verifier.Verifier) {
 		panic("invalid verifier")
 	}
 	t.verifier = nil
+	t.verifyPieces = nil
 
 	if ve.Error != nil {
 		t.stop(fmt.Errorf("file verification error: %s", ve.Error))
 		return
 	}
 
+	t.setVerifiedPieceHashes(ve)
+
 	// Now we have a constructed and verified bitfield.
 	t.mBitfield.Lock()
 	t.bitfield = ve.Bitfield
@@ -52,7 +247,7 @@ func (t *torrent) handleVerificationDone(ve *verifier.Verifier) {
 	}
 
 	// We may detect missing pieces after verification. Then, status must be set from Seeding to Downloading.
-	if !t.bitfield.All() {
+	if !t.wantedDone() {
 		t.completed = false
 		if t.completeC == nil {
 			t.completeC = make(chan struct{})
@@ -74,7 +269,7 @@ func (t *torrent) handleVerificationDone(ve *verifier.Verifier) {
 		t.updateInterestedState(pe)
 	}
 
-	if t.checkCompletion() && t.stopAfterDownload {
+	if t.checkCompletion() && t.stopAfterDownload && t.mover == nil {
 		t.stopAndSetStoppedOnComplete()
 		return
 	}
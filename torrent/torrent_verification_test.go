@@ -0,0 +1,118 @@
+package torrent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	cp "github.com/otiai10/copy"
+	"github.com/stretchr/testify/assert"
+)
+
+// addSeedingTorrent adds the sample torrent with its data and waits until it starts seeding.
+func addSeedingTorrent(t *testing.T, s *Session) *Torrent {
+	f, err := os.Open(torrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tor, err := s.AddTorrent(f, &AddTorrentOptions{Stopped: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cp.Copy(filepath.Join(torrentDataDir, torrentName), filepath.Join(s.config.DataDir, tor.ID(), torrentName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tor.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, tor, Seeding)
+	return tor
+}
+
+func waitStatus(t *testing.T, tor *Torrent, status Status) {
+	for deadline := time.Now().Add(timeout); tor.Stats().Status != status; {
+		if time.Now().After(deadline) {
+			t.Fatalf("torrent status is not %s", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func corruptFile(t *testing.T, tor *Torrent, name string) {
+	f, err := os.OpenFile(filepath.Join(tor.RootDirectory(), name), os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	_, err = f.WriteAt([]byte("corrupted"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyFiles(t *testing.T) {
+	s, closeSession := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.QuickCheck = false
+		cfg.VerifyWorkers = 4
+	})
+	defer closeSession()
+
+	tor := addSeedingTorrent(t, s)
+	total := tor.Stats().Pieces.Total
+	corruptFile(t, tor, "sample_torrent/data/file1.bin")
+
+	// Corrupted file is not in the verified pieces.
+	err := tor.Verify(&VerifyOptions{Files: []string{"sample_torrent/folder/file1.txt"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, tor, Stopped)
+	assert.Equal(t, total, tor.Stats().Pieces.Have)
+
+	err = tor.Verify(&VerifyOptions{Files: []string{"sample_torrent/data/file1.bin"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, tor, Stopped)
+	assert.Less(t, tor.Stats().Pieces.Have, total)
+
+	assert.Error(t, tor.Verify(&VerifyOptions{Files: []string{"foo"}}))
+	assert.Error(t, tor.Verify(&VerifyOptions{Pieces: []PieceRange{{Begin: 0, End: total + 1}}}))
+}
+
+func TestQuickCheck(t *testing.T) {
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+
+	tor := addSeedingTorrent(t, s)
+	total := tor.Stats().Pieces.Total
+	err := tor.Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, tor, Stopped)
+
+	// Unchanged files are not verified again.
+	err = tor.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, tor, Seeding)
+	err = tor.Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, tor, Stopped)
+
+	corruptFile(t, tor, "sample_torrent/data/file1.bin")
+	err = tor.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, tor, Downloading)
+	assert.Less(t, tor.Stats().Pieces.Have, total)
+}
@@ -0,0 +1,143 @@
+package torrent
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cenkalti/rain/internal/allocator"
+	"github.com/cenkalti/rain/internal/cachedpiece"
+	"github.com/cenkalti/rain/internal/piece"
+	"github.com/cenkalti/rain/internal/resumer/boltdbresumer"
+	"github.com/cenkalti/rain/internal/storage"
+)
+
+// pieceDataReader reads the data of a piece for uploading to peers and notifies the torrent loop with the piece when a read fails.
+// Data is read from the files that are open at the time of the read,
+// so requests queued before the files are opened again after a move are read from the new files.
+// Reads are done in peer goroutines, so it never blocks.
+type pieceDataReader struct {
+	t     *torrent
+	index uint32
+}
+
+func (r pieceDataReader) ReadAt(p []byte, off int64) (int, error) {
+	t := r.t
+	t.mFiles.RLock()
+	defer t.mFiles.RUnlock()
+	if int(r.index) >= len(t.pieces) {
+		return 0, errClosed
+	}
+	pi := &t.pieces[r.index]
+	m, err := cachedpiece.New(pi, t.session.pieceCache, t.session.config.ReadCacheBlockSize, t.readCacheKey()).ReadAt(p, off)
+	if err != nil {
+		select {
+		case t.readErrorC <- pi:
+		default:
+		}
+	}
+	return m, err
+}
+
+// pieceReader returns a reader for uploading the data of pi to peers.
+func (t *torrent) pieceReader(pi *piece.Piece) io.ReaderAt {
+	return pieceDataReader{t: t, index: pi.Index}
+}
+
+// fileCheck contains the stats of files read in background for detecting files deleted or changed by someone else.
+type fileCheck struct {
+	storage   storage.Storage
+	files     []allocator.File
+	fileStats []boltdbresumer.FileStat // saved stats at the time the check is started
+	stats     []boltdbresumer.FileStat
+	err       error
+}
+
+func (c *fileCheck) run(resultC chan *fileCheck, closeC chan struct{}) {
+	c.stats, c.err = fileStats(c.storage, c.files)
+	select {
+	case resultC <- c:
+	case <-closeC:
+	}
+}
+
+// startFileCheck starts reading stats of files in background.
+// Results are handled in handleFileCheckDone.
+func (t *torrent) startFileCheck() {
+	if t.fileCheck != nil || t.files == nil || t.pieces == nil || t.bitfield == nil || t.allocator != nil || t.verifier != nil || t.mover != nil {
+		return
+	}
+	t.fileCheck = &fileCheck{
+		storage:   t.storage,
+		files:     t.files,
+		fileStats: t.fileStats,
+	}
+	go t.fileCheck.run(t.fileCheckResultC, t.closeC)
+}
+
+// handleFileCheckDone marks pieces of files that are deleted or changed by someone else as missing.
+// Peers are told that we don't have these pieces anymore and they are downloaded again.
+// The torrent is stopped with an error if a file does not exist anymore because open files refer to the deleted copy.
+func (t *torrent) handleFileCheckDone(c *fileCheck) {
+	// Result is discarded if the files are closed or replaced while the check is running.
+	if t.fileCheck != c {
+		return
+	}
+	t.fileCheck = nil
+	if t.pieces == nil || t.bitfield == nil || t.allocator != nil || t.verifier != nil || t.mover != nil {
+		return
+	}
+	if c.err != nil {
+		t.log.Errorf("cannot check files: %s", c.err)
+		return
+	}
+	if c.stats == nil {
+		return
+	}
+	// Saved stats are compared only if they are not saved again while the check is running.
+	compareStats := t.completed && len(t.fileStats) == len(c.stats) && len(c.fileStats) > 0 && &t.fileStats[0] == &c.fileStats[0]
+	sizes := make(map[string]int64)
+	modified := make(map[string]struct{})
+	var missing []string
+	for i, f := range c.files {
+		// Files that are not selected for download may not exist on disk.
+		if f.Padding || !t.fileWanted(i) {
+			continue
+		}
+		sizes[f.Name] = c.stats[i].Size
+		if c.stats[i].Size < 0 {
+			missing = append(missing, f.Name)
+		}
+		// Files are not written after download is completed, so they must not be modified after the bitfield is saved.
+		if compareStats && c.stats[i] != t.fileStats[i] {
+			modified[f.Name] = struct{}{}
+		}
+	}
+	var lost []uint32
+	for i := range t.pieces {
+		pi := &t.pieces[i]
+		if !pi.Done {
+			continue
+		}
+		for _, sec := range pi.Data {
+			size, ok := sizes[sec.Name]
+			if !ok {
+				continue
+			}
+			// Pieces of a truncated file are lost only after the new end of the file.
+			if _, ok = modified[sec.Name]; ok || sec.Offset+sec.Length > size {
+				lost = append(lost, pi.Index)
+				break
+			}
+		}
+	}
+	if len(lost) > 0 {
+		t.log.Errorf("data of %d pieces is missing", len(lost))
+		t.withdrawPieces(lost)
+	}
+	if len(missing) > 0 {
+		// Peers are disconnected when the torrent is stopped.
+		// They receive the new bitfield or HaveNone message when they connect again after a recheck.
+		t.stop(fmt.Errorf("data missing: %s", strings.Join(missing, ", ")))
+	}
+}
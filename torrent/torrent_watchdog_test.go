@@ -0,0 +1,63 @@
+package torrent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	cp "github.com/otiai10/copy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileWatchdog(t *testing.T) {
+	s, closeSession := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.FileCheckInterval = 50 * time.Millisecond
+	})
+	defer closeSession()
+
+	tor := addSeedingTorrent(t, s)
+	total := tor.Stats().Pieces.Total
+	name := filepath.Join("sample_torrent", "data", "file2.bin")
+	err := os.Remove(filepath.Join(tor.RootDirectory(), name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, tor, Stopped)
+	stats := tor.Stats()
+	if assert.Error(t, stats.Error) {
+		assert.True(t, strings.HasPrefix(stats.Error.Error(), "data missing"))
+	}
+	assert.Less(t, stats.Pieces.Have, total)
+
+	// Restore the file and recheck missing pieces.
+	err = cp.Copy(filepath.Join(torrentDataDir, name), filepath.Join(tor.RootDirectory(), name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tor.Recheck()
+	waitStatus(t, tor, Seeding)
+	assert.Equal(t, total, tor.Stats().Pieces.Have)
+}
+
+func TestFileWatchdogTruncate(t *testing.T) {
+	s, closeSession := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.FileCheckInterval = 50 * time.Millisecond
+	})
+	defer closeSession()
+
+	tor := addSeedingTorrent(t, s)
+	total := tor.Stats().Pieces.Total
+	name := filepath.Join("sample_torrent", "data", "file2.bin")
+	err := os.Truncate(filepath.Join(tor.RootDirectory(), name), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Lost pieces are downloaded again without stopping the torrent.
+	waitStatus(t, tor, Downloading)
+	stats := tor.Stats()
+	assert.NoError(t, stats.Error)
+	assert.Less(t, stats.Pieces.Have, total)
+	assert.Greater(t, stats.Pieces.Have, uint32(0))
+}
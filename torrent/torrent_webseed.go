@@ -1,6 +1,7 @@
 package torrent
 
 import (
+	"errors"
 	"time"
 
 	"github.com/cenkalti/rain/internal/piecewriter"
@@ -15,7 +16,7 @@ func (t *torrent) handleWebseedPieceResult(msg *urldownloader.PieceResult) {
 		// * Client.Do error
 		// * Unexpected status code
 		// * Response.Body.Read error
-		t.disableSource(msg.Downloader.URL, msg.Error, true)
+		t.disableSource(msg.Downloader.URL, msg.Error, true, msg.RetryAfter)
 		t.webseedActiveDownloads--
 		t.startPieceDownloaders()
 		return
@@ -24,6 +25,16 @@ func (t *torrent) handleWebseedPieceResult(msg *urldownloader.PieceResult) {
 	piece := &t.pieces[msg.Index]
 	t.log.Debugf("piece #%d downloaded from %s", msg.Index, msg.Downloader.URL)
 
+	if piece.Hash == nil {
+		// Hashes of pieces in merkle torrents are received from peers only.
+		t.bytesWasted.Inc(int64(len(msg.Buffer.Data)))
+		msg.Buffer.Release()
+		t.disableSource(msg.Downloader.URL, errors.New("piece hash is not known"), true, 0)
+		t.webseedActiveDownloads--
+		t.startPieceDownloaders()
+		return
+	}
+
 	t.bytesDownloaded.Inc(int64(len(msg.Buffer.Data)))
 	t.downloadSpeed.Mark(int64(len(msg.Buffer.Data)))
 	for _, src := range t.webseedSources {
@@ -43,8 +54,8 @@ func (t *torrent) handleWebseedPieceResult(msg *urldownloader.PieceResult) {
 	t.pieceMessagesC.Suspend()
 	t.webseedPieceResultC.Suspend()
 
-	pw := piecewriter.New(piece, msg.Downloader, msg.Buffer)
-	go pw.Run(t.pieceWriterResultC, t.doneC, t.session.metrics.WritesPerSecond, t.session.metrics.SpeedWrite, t.session.semWrite)
+	pw := piecewriter.New(piece, msg.Downloader, msg.Buffer, nil)
+	go pw.Run(t.pieceWriterResultC, t.doneC, t.session.metrics.WritesPerSecond, t.session.metrics.SpeedWrite, t.session.metrics.WriteLatency, t.session.semWrite, t.session.config.WriteDurability == durabilityPiece)
 
 	if msg.Done {
 		for _, src := range t.webseedSources {
@@ -59,7 +70,9 @@ func (t *torrent) handleWebseedPieceResult(msg *urldownloader.PieceResult) {
 	}
 }
 
-func (t *torrent) disableSource(srcurl string, err error, retry bool) {
+// disableSource stops downloading from the source.
+// If retry is true, the source is enabled again after retryAfter, or a minute if retryAfter is zero.
+func (t *torrent) disableSource(srcurl string, err error, retry bool, retryAfter time.Duration) {
 	for _, src := range t.webseedSources {
 		if src.URL != srcurl {
 			continue
@@ -69,15 +82,18 @@ func (t *torrent) disableSource(srcurl string, err error, retry bool) {
 		src.LastError = err
 		t.closeWebseedDownloader(src)
 		if retry {
-			go t.notifyWebseedRetry(src)
+			if retryAfter == 0 {
+				retryAfter = time.Minute
+			}
+			go t.notifyWebseedRetry(src, retryAfter)
 		}
 		break
 	}
 }
 
-func (t *torrent) notifyWebseedRetry(src *webseedsource.WebseedSource) {
+func (t *torrent) notifyWebseedRetry(src *webseedsource.WebseedSource, d time.Duration) {
 	select {
-	case <-time.After(time.Minute):
+	case <-time.After(d):
 		select {
 		case t.webseedRetryC <- src:
 		case <-t.closeC:
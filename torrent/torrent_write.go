@@ -3,10 +3,13 @@ package torrent
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/cenkalti/rain/internal/peer"
 	"github.com/cenkalti/rain/internal/peerprotocol"
+	"github.com/cenkalti/rain/internal/piece"
 	"github.com/cenkalti/rain/internal/piecewriter"
+	"github.com/cenkalti/rain/internal/storage/filestorage"
 	"github.com/cenkalti/rain/internal/urldownloader"
 )
 
@@ -16,49 +19,72 @@ func (t *torrent) handlePieceWriteDone(pw *piecewriter.PieceWriter) {
 	t.pieceMessagesC.Resume()
 	t.webseedPieceResultC.Resume()
 
-	pw.Buffer.Release()
-
 	if !pw.HashOK {
 		t.bytesWasted.Inc(int64(len(pw.Buffer.Data)))
 		switch src := pw.Source.(type) {
 		case *peer.Peer:
 			t.log.Debugln("received corrupt piece from peer", src.String())
-			t.closePeer(src)
-			t.bannedPeerIPs[src.IP()] = struct{}{}
+			t.handleCorruptPiece(pw, src)
 		case *urldownloader.URLDownloader:
 			t.log.Debugln("received corrupt piece from webseed", src.URL)
-			t.disableSource(src.URL, errors.New("corrupt piece"), false)
+			t.disableSource(src.URL, errors.New("corrupt piece"), false, 0)
 		default:
 			panic("unhandled piece source")
 		}
+		pw.Buffer.Release()
 		t.startPieceDownloaders()
 		return
 	}
+	if pw.Error == nil {
+		t.checkSmartBan(pw)
+	}
+	pw.Buffer.Release()
+
+	if filestorage.IsNoSpace(pw.Error) {
+		t.log.Warning("disk is full, pausing torrent until space is available")
+		t.pauseForDiskSpace()
+		return
+	}
 	if pw.Error != nil {
 		t.stop(pw.Error)
 		return
 	}
 
-	pw.Piece.Done = true
-	if t.bitfield.Test(pw.Piece.Index) {
-		panic(fmt.Sprintf("already have the piece #%d", pw.Piece.Index))
+	t.handlePieceDone(pw.Piece, pw.Source)
+	t.notifyDuplicates(pw.Piece.Index)
+}
+
+// handlePieceDone marks the piece as done after its data is written to disk.
+// source is the peer or webseed that the piece is downloaded from.
+// It is nil if the piece is written by another torrent that shares the same data directory.
+func (t *torrent) handlePieceDone(pi *piece.Piece, source any) {
+	pi.Done = true
+	if t.bitfield.Test(pi.Index) {
+		panic(fmt.Sprintf("already have the piece #%d", pi.Index))
 	}
 	t.mBitfield.Lock()
-	t.bitfield.Set(pw.Piece.Index)
+	t.bitfield.Set(pi.Index)
 	t.mBitfield.Unlock()
 
+	if _, ok := t.pieceDeadlines[pi.Index]; ok {
+		delete(t.pieceDeadlines, pi.Index)
+		if t.piecePicker != nil {
+			t.piecePicker.SetDeadline(pi.Index, time.Time{})
+		}
+	}
+
 	if t.piecePicker != nil {
-		_, ok := pw.Source.(*urldownloader.URLDownloader)
-		src := t.piecePicker.RequestedWebseedSource(pw.Piece.Index)
+		_, ok := source.(*urldownloader.URLDownloader)
+		src := t.piecePicker.RequestedWebseedSource(pi.Index)
 		if !ok && src != nil {
-			closed := t.piecePicker.WebseedStopAt(src, pw.Piece.Index)
+			closed := t.piecePicker.WebseedStopAt(src, pi.Index)
 			if closed {
 				t.log.Debugf("closed webseed downloader: %s", src.URL)
 				t.startPieceDownloaderForWebseed(src)
 			}
 		}
 
-		for _, pe := range t.piecePicker.RequestedPeers(pw.Piece.Index) {
+		for _, pe := range t.piecePicker.RequestedPeers(pi.Index) {
 			pd2 := t.pieceDownloaders[pe]
 			t.closePieceDownloader(pd2)
 			pd2.CancelPending()
@@ -69,11 +95,11 @@ func (t *torrent) handlePieceWriteDone(pw *piecewriter.PieceWriter) {
 	// Tell everyone that we have this piece
 	for pe := range t.peers {
 		t.updateInterestedState(pe)
-		if pe.Bitfield.Test(pw.Piece.Index) {
+		if pe.Bitfield.Test(pi.Index) {
 			// Skip peers having the piece to save bandwidth
 			continue
 		}
-		msg := peerprotocol.HaveMessage{Index: pw.Piece.Index}
+		msg := peerprotocol.HaveMessage{Index: pi.Index}
 		pe.SendMessage(msg)
 	}
 
@@ -83,7 +109,7 @@ func (t *torrent) handlePieceWriteDone(pw *piecewriter.PieceWriter) {
 		err := t.writeBitfield()
 		if err != nil {
 			t.stop(err)
-		} else if t.stopAfterDownload {
+		} else if t.stopAfterDownload && t.mover == nil {
 			t.stopAndSetStoppedOnComplete()
 		}
 	}